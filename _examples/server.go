@@ -81,15 +81,16 @@ func main() {
 			input = string(v)
 		case EOMKey:
 			fmt.Println("Server Request", function, input)
+			var reply netstring.KV
 			switch function {
 			case lowerFunction:
-				enc.EncodeString(outputKey, strings.ToLower(input))
+				reply = netstring.KV{Key: outputKey, Value: []byte(strings.ToLower(input))}
 			case upperFunction:
-				enc.EncodeString(outputKey, strings.ToUpper(input))
+				reply = netstring.KV{Key: outputKey, Value: []byte(strings.ToUpper(input))}
 			default:
-				enc.EncodeString(errorKey, "Invalid function "+function)
+				reply = netstring.KV{Key: errorKey, Value: []byte("Invalid function " + function)}
 			}
-			enc.EncodeBytes(EOMKey)
+			enc.EncodeMessage(EOMKey, reply)
 			function = ""
 			input = ""
 		default:
@@ -0,0 +1,105 @@
+package netstring
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// AccessLogEntry describes a single message handled by a Server. It is passed to the
+// AccessLogFunc supplied to AccessLog.
+type AccessLogEntry struct {
+	Start       time.Time
+	Duration    time.Duration
+	RemoteAddr  net.Addr
+	TypeKey     Key    // Key of the first netstring in the request, conventionally the message type
+	Type        string // Value of that netstring
+	RequestSize int    // Encoded size of the request, including the end-of-message sentinel
+	ReplySize   int    // Encoded size of the reply
+	Result      string // "ok", "noreply", "error" or "panic"
+}
+
+// AccessLogFunc is the signature of the pluggable formatter supplied to AccessLog.
+type AccessLogFunc func(AccessLogEntry)
+
+// AccessLog is a middleware Handler which calls "fn" after "next" has handled each
+// message, in a similar manner to HTTP access logs. The Result of the entry is "ok" if a
+// reply was assembled, "noreply" if not, "error" if the reply is a standard error-reply
+// created by Encoder.EncodeError and "panic" if "next" panicked. A panic is re-raised
+// after "fn" is called so that the Server still sends its error-reply.
+func AccessLog(next Handler, fn AccessLogFunc) Handler {
+	return HandlerFunc(func(reply *MessageBuilder, req *Request) {
+		e := AccessLogEntry{Start: time.Now(), RemoteAddr: req.RemoteAddr, Result: "panic"}
+		for _, kv := range req.Fields {
+			e.RequestSize += encodedLength(len(kv.Value) + 1)
+		}
+		e.RequestSize += encodedLength(1)
+		if len(req.Fields) > 0 {
+			e.TypeKey = req.Fields[0].Key
+			e.Type = string(req.Fields[0].Value)
+		}
+
+		defer func() {
+			e.Duration = time.Since(e.Start)
+			e.ReplySize = reply.Len()
+			fn(e)
+		}()
+		next.ServeNetstring(reply, req)
+
+		switch {
+		case reply.Len() == 0:
+			e.Result = "noreply"
+		case isErrorReply(reply.Bytes(), req.EOM):
+			e.Result = "error"
+		default:
+			e.Result = "ok"
+		}
+	})
+}
+
+// isErrorReply returns true if "b" contains a standard error-reply message, as
+// determined by remoteErrorOf.
+func isErrorReply(b []byte, eom Key) bool {
+	m, err := NewDecoder(bytes.NewReader(b)).ReadMessage(eom)
+	if err != nil {
+		return false
+	}
+
+	return remoteErrorOf(&m) != nil
+}
+
+// CommonLogFormat returns an AccessLogFunc which writes one line per entry to "w" in a
+// format reminiscent of the HTTP Common Log Format:
+//
+//	127.0.0.1:5001 [02/Jan/2006:15:04:05 -0700] "M login" 45 12 ok 1.2ms
+//
+// The type value is truncated to 32 bytes and quoted. Writes to "w" are serialized.
+func CommonLogFormat(w io.Writer) AccessLogFunc {
+	var mu sync.Mutex
+	return func(e AccessLogEntry) {
+		t := e.Type
+		if len(t) > 32 {
+			t = t[:32]
+		}
+		addr := "-"
+		if e.RemoteAddr != nil {
+			addr = e.RemoteAddr.String()
+		}
+		line := fmt.Sprintf("%s [%s] \"%s %s\" %d %d %s %s\n",
+			addr, e.Start.Format("02/Jan/2006:15:04:05 -0700"),
+			e.TypeKey, quoteTrimmed(t), e.RequestSize, e.ReplySize, e.Result, e.Duration)
+		mu.Lock()
+		defer mu.Unlock()
+		io.WriteString(w, line)
+	}
+}
+
+// quoteTrimmed quotes "s" with %q and removes the surrounding quotes.
+func quoteTrimmed(s string) string {
+	q := fmt.Sprintf("%q", s)
+
+	return q[1 : len(q)-1]
+}
@@ -0,0 +1,96 @@
+package netstring_test
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestAccessLog(t *testing.T) {
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5001}
+	errHandler := netstring.HandlerFunc(func(reply *netstring.MessageBuilder, req *netstring.Request) {
+		reply.EncodeError('z', "", netstring.ErrServerBusy)
+	})
+	silent := netstring.HandlerFunc(func(reply *netstring.MessageBuilder, req *netstring.Request) {})
+	country := netstring.HandlerFunc(func(reply *netstring.MessageBuilder, req *netstring.Request) {
+		reply.EncodeMessage('z', netstring.KV{Key: 'C', Value: []byte("64")},
+			netstring.KV{Key: 'n', Value: []byte("NZ")}) // 'C' is not ErrorCodeKey here
+	})
+
+	testCases := []struct {
+		handler netstring.Handler
+		p       string
+		result  string
+	}{
+		{echoHandler, "pass", "ok"},
+		{errHandler, "pass", "error"},
+		{silent, "pass", "noreply"},
+		{country, "pass", "ok"},
+		{echoHandler, "panic", "panic"},
+	}
+
+	for ix, tc := range testCases {
+		var entry netstring.AccessLogEntry
+		h := netstring.AccessLog(tc.handler, func(e netstring.AccessLogEntry) { entry = e })
+		req := &netstring.Request{RemoteAddr: addr,
+			Message: netstring.Message{EOM: 'z',
+				Fields: []netstring.KV{{Key: 'M', Value: []byte("login")},
+					{Key: 'p', Value: []byte(tc.p)}}}}
+		reply := netstring.NewMessageBuilder()
+		func() {
+			defer func() {
+				r := recover()
+				if (r != nil) != (tc.result == "panic") {
+					t.Error(ix, "Unexpected recover", r)
+				}
+			}()
+			h.ServeNetstring(reply, req)
+		}()
+		if entry.Result != tc.result {
+			t.Error(ix, "Result", entry.Result, "expected", tc.result)
+		}
+		if entry.TypeKey != 'M' || entry.Type != "login" {
+			t.Error(ix, "Type wrong", entry.TypeKey, entry.Type)
+		}
+		if entry.RemoteAddr != addr {
+			t.Error(ix, "RemoteAddr wrong", entry.RemoteAddr)
+		}
+		expect := len("6:Mlogin,") + len("1:z,") + len(tc.p) + 4
+		if entry.RequestSize != expect {
+			t.Error(ix, "RequestSize", entry.RequestSize, "expected", expect)
+		}
+		if entry.ReplySize != reply.Len() {
+			t.Error(ix, "ReplySize", entry.ReplySize, "expected", reply.Len())
+		}
+	}
+}
+
+func TestAccessLogServer(t *testing.T) {
+	var logBuf bytes.Buffer
+	srv := &netstring.Server{Handler: netstring.AccessLog(echoHandler,
+		netstring.CommonLogFormat(&logBuf))}
+	addr := startServer(t, srv)
+
+	conn, err := netstring.DialNetstring("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.EncodeString('M', "hello\tworld")
+	conn.EncodeBytes('z')
+	_, err = conn.ReadMessage('z')
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+
+	line := logBuf.String()
+	for ix, want := range []string{`"M hello\tworld" 20 20 ok `, "127.0.0.1:", "\n"} {
+		if !strings.Contains(line, want) {
+			t.Error(ix, "Log line", line, "does not contain", want)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package netstring
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Deviation identifies a departure from the netstring specification found by
+// AnalyzeStream.
+type Deviation int
+
+// All Deviation values.
+const (
+	DeviationLengthNotDigit Deviation = iota // Netstring does not start with a digit
+	DeviationLeadingZero                     // Length has a leading zero
+	DeviationOversize                        // Length exceeds the maximum length
+	DeviationColonExpected                   // Length is not followed by a colon
+	DeviationCommaExpected                   // Value is not followed by a comma
+	DeviationNotKeyed                        // Netstring is empty or its first byte is not a Key
+	DeviationTruncated                       // Stream ends part way through a netstring
+	deviationCount
+)
+
+func (d Deviation) String() string {
+	switch d {
+	case DeviationLengthNotDigit:
+		return "LengthNotDigit"
+	case DeviationLeadingZero:
+		return "LeadingZero"
+	case DeviationOversize:
+		return "Oversize"
+	case DeviationColonExpected:
+		return "ColonExpected"
+	case DeviationCommaExpected:
+		return "CommaExpected"
+	case DeviationNotKeyed:
+		return "NotKeyed"
+	case DeviationTruncated:
+		return "Truncated"
+	}
+
+	return "Bizarre Deviation"
+}
+
+// Finding is a single Deviation found by AnalyzeStream. Offset is the zero-based stream
+// offset of the start of the netstring for DeviationOversize, DeviationNotKeyed and
+// DeviationTruncated, otherwise it is the offset of the offending byte.
+type Finding struct {
+	Deviation Deviation
+	Offset    int64
+}
+
+// MaximumFindings is the maximum number of Findings retained in a StreamReport. Counts
+// continue to accumulate beyond this limit.
+const MaximumFindings = 100
+
+// StreamReport is the result of AnalyzeStream.
+type StreamReport struct {
+	Bytes      int64               // Total bytes read
+	Netstrings int64               // Netstrings which ended with a comma, deviations or not
+	Keyed      int64               // Netstrings which start with a valid Key
+	Counts     map[Deviation]int64 // Number of each Deviation found
+	Findings   []Finding           // The first MaximumFindings deviations in stream order
+}
+
+// Clean returns true if no deviations were found. A standard stream is never Clean as
+// every netstring in it is reported as DeviationNotKeyed.
+func (sr *StreamReport) Clean() bool {
+	return len(sr.Counts) == 0
+}
+
+// String returns a one-line summary of the report suitable for logging.
+func (sr *StreamReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "bytes=%d netstrings=%d keyed=%d", sr.Bytes, sr.Netstrings, sr.Keyed)
+	for d := Deviation(0); d < deviationCount; d++ {
+		if c := sr.Counts[d]; c > 0 {
+			fmt.Fprintf(&b, " %s=%d", d, c)
+		}
+	}
+
+	return b.String()
+}
+
+/*
+AnalyzeStream reads "rdr" to the end and reports every deviation from the netstring
+specification it finds, with counts and offsets, rather than stopping at the first as
+Decoder does. It is a triage tool for legacy senders: run it over a captured stream
+before pointing a strict Decoder at that sender.
+
+The analysis is lenient where it can be. A leading zero or an oversized length is
+reported and the netstring is otherwise parsed normally. After any other deviation, the
+analysis resynchronizes by skipping past the next comma and continues from there.
+
+DeviationNotKeyed is reported for every netstring which could not be a "keyed"
+netstring, so it is only meaningful when "rdr" is expected to carry "keyed" netstrings.
+
+Of the Options, only WithMaximumLength applies. The returned error is nil unless "rdr"
+returns an error other than io.EOF, in which case the report covers the stream up to
+that error.
+*/
+func AnalyzeStream(rdr io.Reader, opts ...Option) (*StreamReport, error) {
+	o := applyOptions(opts)
+	a := &analyzer{rdr: bufio.NewReader(rdr), maxLength: int64(o.maxLength),
+		report: &StreamReport{Counts: make(map[Deviation]int64)}}
+	for {
+		err := a.netstring()
+		a.report.Bytes = a.offset
+		if err == io.EOF {
+			return a.report, nil
+		}
+		if err != nil {
+			return a.report, err
+		}
+	}
+}
+
+// maxAnalyzeDigits is the most length digits which AnalyzeStream accumulates. Any more
+// and the length cannot be sensible so the netstring is abandoned.
+const maxAnalyzeDigits = 18
+
+type analyzer struct {
+	rdr       *bufio.Reader
+	maxLength int64
+	offset    int64 // Of the next byte to be read
+	report    *StreamReport
+}
+
+func (a *analyzer) readByte() (byte, error) {
+	b, err := a.rdr.ReadByte()
+	if err == nil {
+		a.offset++
+	}
+
+	return b, err
+}
+
+func (a *analyzer) note(d Deviation, offset int64) {
+	a.report.Counts[d]++
+	if len(a.report.Findings) < MaximumFindings {
+		a.report.Findings = append(a.report.Findings, Finding{d, offset})
+	}
+}
+
+// netstring analyzes the next netstring in the stream. It returns io.EOF once the stream
+// is exhausted.
+func (a *analyzer) netstring() error {
+	start := a.offset
+	var length int64
+	var digits int
+	var b byte
+	var err error
+	for {
+		b, err = a.readByte()
+		if err != nil {
+			if digits > 0 {
+				return a.truncated(start, err)
+			}
+			return err
+		}
+		if b < '0' || b > '9' {
+			break
+		}
+		if digits == 1 && length == 0 {
+			a.note(DeviationLeadingZero, a.offset-1)
+		}
+		digits++
+		if digits > maxAnalyzeDigits {
+			a.note(DeviationOversize, start)
+			return a.resync()
+		}
+		length = length*10 + int64(b-'0')
+	}
+	if digits == 0 {
+		a.note(DeviationLengthNotDigit, a.offset-1)
+		return a.resync()
+	}
+	if b != leadingColon {
+		a.note(DeviationColonExpected, a.offset-1)
+		return a.resync()
+	}
+	if length > a.maxLength {
+		a.note(DeviationOversize, start)
+	}
+
+	var keyed bool
+	if length > 0 {
+		b, err = a.readByte()
+		if err != nil {
+			return a.truncated(start, err)
+		}
+		keyed, _ = Key(b).Assess()
+		n, err := io.CopyN(io.Discard, a.rdr, length-1)
+		a.offset += n
+		if err != nil {
+			return a.truncated(start, err)
+		}
+	}
+
+	b, err = a.readByte()
+	if err != nil {
+		return a.truncated(start, err)
+	}
+	if b != trailingComma {
+		a.note(DeviationCommaExpected, a.offset-1)
+		return a.resync()
+	}
+	a.report.Netstrings++
+	if keyed {
+		a.report.Keyed++
+	} else {
+		a.note(DeviationNotKeyed, start)
+	}
+
+	return nil
+}
+
+// truncated reports DeviationTruncated if "err" is io.EOF, which then ends the analysis.
+func (a *analyzer) truncated(start int64, err error) error {
+	if err == io.EOF {
+		a.note(DeviationTruncated, start)
+	}
+
+	return err
+}
+
+// resync skips past the next comma so that the analysis can continue.
+func (a *analyzer) resync() error {
+	for {
+		b, err := a.readByte()
+		if err != nil || b == trailingComma {
+			return err
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package netstring_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestAnalyzeStream(t *testing.T) {
+	//        0         1         2         3         4
+	//        012345678901234567890123456789012345678901234
+	stream := "2:ab,03:abc,x:,2:cd;2:ef,4:1234,1:b;2:gh,3:ab"
+	rep, err := netstring.AnalyzeStream(bytes.NewBufferString(stream),
+		netstring.WithMaximumLength(3))
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if rep.Bytes != int64(len(stream)) {
+		t.Error("Bytes", rep.Bytes, "expected", len(stream))
+	}
+	exp := []netstring.Finding{
+		{netstring.DeviationLeadingZero, 6},
+		{netstring.DeviationLengthNotDigit, 12},
+		{netstring.DeviationCommaExpected, 19},
+		{netstring.DeviationOversize, 25},
+		{netstring.DeviationNotKeyed, 25},
+		{netstring.DeviationCommaExpected, 35},
+		{netstring.DeviationTruncated, 41},
+	}
+	if len(rep.Findings) != len(exp) {
+		t.Fatal("Findings", rep.Findings, "expected", exp)
+	}
+	for ix, f := range exp {
+		if rep.Findings[ix] != f {
+			t.Error(ix, "Finding", rep.Findings[ix], "expected", f)
+		}
+	}
+	if rep.Netstrings != 3 || rep.Keyed != 2 {
+		t.Error("Netstrings", rep.Netstrings, "Keyed", rep.Keyed)
+	}
+	if rep.Counts[netstring.DeviationCommaExpected] != 2 {
+		t.Error("Counts", rep.Counts)
+	}
+	if rep.Clean() {
+		t.Error("Report should not be Clean")
+	}
+	expString := "bytes=45 netstrings=3 keyed=2 LengthNotDigit=1 LeadingZero=1 " +
+		"Oversize=1 CommaExpected=2 NotKeyed=1 Truncated=1"
+	if rep.String() != expString {
+		t.Error("String got", rep.String(), "expected", expString)
+	}
+
+	rep, err = netstring.AnalyzeStream(bytes.NewBufferString("2:ab,1:z,0:,"))
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if rep.Clean() || rep.Counts[netstring.DeviationNotKeyed] != 1 || rep.Keyed != 2 {
+		t.Error("Empty netstring should be NotKeyed", rep)
+	}
+	rep, err = netstring.AnalyzeStream(bytes.NewBufferString("2:ab,1:z,"))
+	if err != nil || !rep.Clean() {
+		t.Error("Expected a Clean report", rep, err)
+	}
+}
+
+func TestAnalyzeStreamLimits(t *testing.T) {
+	var bbuf bytes.Buffer
+	for ix := 0; ix < netstring.MaximumFindings+10; ix++ {
+		bbuf.WriteString("1:a;,")
+	}
+	rep, err := netstring.AnalyzeStream(&bbuf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Findings) != netstring.MaximumFindings {
+		t.Error("Findings not capped", len(rep.Findings))
+	}
+	if rep.Counts[netstring.DeviationCommaExpected] != netstring.MaximumFindings+10 {
+		t.Error("Counts should not be capped", rep.Counts)
+	}
+
+	rep, _ = netstring.AnalyzeStream(bytes.NewBufferString("1234567890123456789:x,1:a,"))
+	if rep.Counts[netstring.DeviationOversize] != 1 || rep.Keyed != 1 {
+		t.Error("Absurd length not handled", rep)
+	}
+
+	ioErr := errors.New("read failed")
+	_, err = netstring.AnalyzeStream(io.MultiReader(bytes.NewBufferString("1:a,"),
+		&errReader{ioErr}))
+	if err != ioErr {
+		t.Error("Expected read error, got", err)
+	}
+}
+
+type errReader struct{ err error }
+
+func (er *errReader) Read([]byte) (int, error) { return 0, er.err }
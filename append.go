@@ -0,0 +1,46 @@
+package netstring
+
+import (
+	"strconv"
+)
+
+// AppendNetstring appends the netstring encoding of the variadic "val" arguments to
+// "dst" and returns the extended buffer. It is the allocation-free counterpart of
+// Encoder.EncodeBytes for callers which manage their own buffering and have no need of an
+// io.Writer. If key == netstring.NoKey a standard netstring is appended otherwise a
+// "keyed" netstring is appended.
+//
+// An error is returned if key.Assess() returns an error or if the length of the
+// netstring value exceeds MaximumLength, in which case "dst" is returned unmodified.
+func AppendNetstring(dst []byte, key Key, val ...[]byte) ([]byte, error) {
+	keyed, err := key.Assess()
+	if err != nil {
+		return dst, err
+	}
+	var l uint64
+	if keyed {
+		l++
+	}
+	for _, subVal := range val {
+		l += uint64(len(subVal))
+	}
+	if l > MaximumLength {
+		return dst, ErrValueToLong
+	}
+
+	return appendNetstring(dst, key, keyed, l, val), nil
+}
+
+// appendNetstring appends a netstring of pre-validated length "l" to "dst".
+func appendNetstring(dst []byte, key Key, keyed bool, l uint64, val [][]byte) []byte {
+	dst = strconv.AppendUint(dst, l, 10)
+	dst = append(dst, leadingDelimiter...)
+	if keyed {
+		dst = append(dst, byte(key))
+	}
+	for _, subVal := range val {
+		dst = append(dst, subVal...)
+	}
+
+	return append(dst, trailingDelimiter...)
+}
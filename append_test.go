@@ -0,0 +1,44 @@
+package netstring_test
+
+import (
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestAppendNetstring(t *testing.T) {
+	quarter := make([]byte, netstring.MaximumLength/4+1) // Untouched so cheap
+	testCases := []struct {
+		key    netstring.Key
+		val    [][]byte
+		expect string
+		err    error
+	}{
+		{netstring.NoKey, nil, "pre0:,", nil},
+		{netstring.NoKey, [][]byte{[]byte("abc"), []byte("xyz")}, "pre6:abcxyz,", nil},
+		{'z', nil, "pre1:z,", nil},
+		{'k', [][]byte{[]byte("hello")}, "pre6:khello,", nil},
+		{'*', [][]byte{[]byte("hello")}, "pre", netstring.ErrInvalidKey},
+		{'k', [][]byte{quarter, quarter, quarter, quarter}, "pre", netstring.ErrValueToLong},
+	}
+
+	for ix, tc := range testCases {
+		dst := make([]byte, 3, 64)
+		copy(dst, "pre")
+		got, err := netstring.AppendNetstring(dst, tc.key, tc.val...)
+		if err != tc.err {
+			t.Error(ix, "Expected", tc.err, "got", err)
+		}
+		if string(got) != tc.expect {
+			t.Error(ix, "Expected", tc.expect, "got", len(got), "bytes")
+		}
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		buf := make([]byte, 0, 64)
+		netstring.AppendNetstring(buf, 'k', []byte("value"))
+	})
+	if allocs > 0 {
+		t.Error("AppendNetstring allocated", allocs)
+	}
+}
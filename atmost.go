@@ -0,0 +1,101 @@
+package netstring
+
+import (
+	"io"
+)
+
+// DecodeAtMost is the same as Decode excepting that it refuses to begin consuming the
+// value of a netstring whose announced length exceeds "maxBytes". In that case it returns
+// a nil netstring, the announced length and ErrValueTooLarge, leaving the Decoder
+// positioned at the start of the value. This gives finer control than a blanket maximum
+// length as the caller can then decide to:
+//
+//   - skip the value with DiscardValue
+//   - stream the value with ValueReader
+//   - accept the value with a subsequent Decode or DecodeAtMost with a larger limit
+//   - abort, typically by closing the connection
+//
+// Otherwise DecodeAtMost returns the netstring and its length. Note that the length of a
+// "keyed" netstring includes the key byte.
+func (dec *Decoder) DecodeAtMost(maxBytes int) (ns []byte, length int, err error) {
+	if maxBytes < 0 {
+		maxBytes = 0
+	}
+	if dec.state == parsePending && dec.length > maxBytes {
+		return nil, dec.length, ErrValueTooLarge
+	}
+
+	dec.atMost = maxBytes
+	ns, err = dec.Decode()
+	dec.atMost = -1
+	if err == ErrValueTooLarge && dec.state == parsePending {
+		return nil, dec.length, err
+	}
+
+	return ns, len(ns), err
+}
+
+// ValueReader returns an io.Reader which streams the value of the netstring refused by
+// DecodeAtMost directly from the underlying io.Reader, without the value ever being held
+// in memory. The io.Reader returns io.EOF once the value and its trailing comma have been
+// consumed, after which the Decoder continues with the next netstring. If the Decoder is
+// used before the value has been fully read, the remainder of the value is skipped.
+//
+// ValueReader returns nil if there is no refused netstring pending.
+func (dec *Decoder) ValueReader() io.Reader {
+	if dec.state != parsePending {
+		return nil
+	}
+	dec.state = parseStream
+	dec.streamRemaining = dec.length
+
+	return &valueReader{dec: dec}
+}
+
+type valueReader struct {
+	dec *Decoder
+}
+
+// Read copies value bytes from the Decoder staging buffer, refilling it from the
+// underlying io.Reader as needed. Once the value is exhausted, the trailing comma is
+// consumed by the regular parser.
+func (vr *valueReader) Read(p []byte) (int, error) {
+	dec := vr.dec
+	if dec.state != parseStream { // Value completed or abandoned
+		return 0, io.EOF
+	}
+	if dec.streamRemaining == 0 {
+		dec.state = parseComma
+		dec.discarding = true // Consume the comma without returning a netstring
+		dec.discardOnly = true
+		dec.parse()
+		dec.discardOnly = false
+		if dec.discarding {
+			return 0, dec.parseError
+		}
+		return 0, io.EOF
+	}
+	if dec.at == dec.end {
+		if dec.parseError == nil {
+			dec.fill()
+			dec.at = 0
+		}
+		if dec.at == dec.end {
+			err := dec.parseError
+			if err == io.EOF { // The value is incomplete
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+	}
+
+	n := dec.end - dec.at
+	if n > dec.streamRemaining {
+		n = dec.streamRemaining
+	}
+	n = copy(p, dec.buf[dec.at:dec.at+n])
+	dec.at += n
+	dec.streamRemaining -= n
+
+	return n, nil
+}
@@ -0,0 +1,92 @@
+package netstring_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestDecodeAtMost(t *testing.T) {
+	big := strings.Repeat("x", 5000) // Exceeds the Decoder staging buffer
+	stream := "3:abc,5000:" + big + ",2:ok,5000:" + big + ",2:hi,5000:" + big + ",1:z,"
+	dec := netstring.NewDecoder(bytes.NewBufferString(stream))
+
+	ns, l, err := dec.DecodeAtMost(10)
+	if err != nil || string(ns) != "abc" || l != 3 {
+		t.Fatal("Small netstring refused", string(ns), l, err)
+	}
+
+	// Skip
+	ns, l, err = dec.DecodeAtMost(10)
+	if err != netstring.ErrValueTooLarge || ns != nil || l != 5000 {
+		t.Fatal("Large netstring not refused", l, err)
+	}
+	_, l, err = dec.DecodeAtMost(10) // Still pending
+	if err != netstring.ErrValueTooLarge || l != 5000 {
+		t.Fatal("Pending netstring not refused again", l, err)
+	}
+	err = dec.DiscardValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns, _, err = dec.DecodeAtMost(10)
+	if err != nil || string(ns) != "ok" {
+		t.Fatal("Netstring after skip wrong", string(ns), err)
+	}
+
+	// Stream
+	_, _, err = dec.DecodeAtMost(10)
+	if err != netstring.ErrValueTooLarge {
+		t.Fatal("Expected ErrValueTooLarge, not", err)
+	}
+	if dec.ValueReader() == nil {
+		t.Fatal("ValueReader returned nil for pending value")
+	}
+	_, _, err = dec.DecodeAtMost(10) // Abandons the ValueReader
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = dec.DecodeAtMost(10)
+	if err != netstring.ErrValueTooLarge {
+		t.Fatal("Expected ErrValueTooLarge, not", err)
+	}
+	b, err := io.ReadAll(dec.ValueReader())
+	if err != nil || string(b) != big {
+		t.Fatal("ValueReader returned", len(b), err)
+	}
+
+	// Accept with a larger limit
+	ns, _, err = dec.DecodeAtMost(netstring.MaximumLength)
+	if err != nil || string(ns) != "z" {
+		t.Fatal("Final netstring wrong", string(ns), err)
+	}
+	if dec.ValueReader() != nil {
+		t.Error("ValueReader should be nil with nothing pending")
+	}
+	_, _, err = dec.DecodeAtMost(10)
+	if err != io.EOF {
+		t.Error("Expected io.EOF, not", err)
+	}
+}
+
+func TestDecodeAtMostAccept(t *testing.T) {
+	dec := netstring.NewDecoder(bytes.NewBufferString("11:hello world,"))
+	_, _, err := dec.DecodeAtMost(5)
+	if err != netstring.ErrValueTooLarge {
+		t.Fatal("Expected ErrValueTooLarge, not", err)
+	}
+	ns, err := dec.Decode()
+	if err != nil || string(ns) != "hello world" {
+		t.Error("Decode did not accept pending value", string(ns), err)
+	}
+
+	dec = netstring.NewDecoder(bytes.NewBufferString("11:hello"))
+	dec.DecodeAtMost(5)
+	_, err = io.ReadAll(dec.ValueReader())
+	if err != io.ErrUnexpectedEOF {
+		t.Error("Expected io.ErrUnexpectedEOF, not", err)
+	}
+}
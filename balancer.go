@@ -0,0 +1,73 @@
+package netstring
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Backend describes a candidate server address presented to a Balancer.
+type Backend struct {
+	Address  string
+	InFlight int // Number of calls in progress or waiting on this Backend
+}
+
+// Balancer selects which Backend a Client.Call is sent to. Pick is given the healthy
+// candidates, or all candidates if none are healthy, and returns the index of the chosen
+// Backend. Pick is called concurrently so implementations must be concurrency-safe.
+type Balancer interface {
+	Pick(candidates []Backend) int
+}
+
+// RoundRobin is a Balancer which cycles through the candidates in order. The zero value
+// is ready to use.
+type RoundRobin struct {
+	next uint32
+}
+
+// Pick implements Balancer.
+func (rr *RoundRobin) Pick(candidates []Backend) int {
+	return int((atomic.AddUint32(&rr.next, 1) - 1) % uint32(len(candidates)))
+}
+
+// LeastInFlight is a Balancer which picks the candidate with the fewest calls in
+// flight. Ties are resolved in favor of the earliest candidate.
+type LeastInFlight struct{}
+
+// Pick implements Balancer.
+func (LeastInFlight) Pick(candidates []Backend) int {
+	best := 0
+	for ix, b := range candidates {
+		if b.InFlight < candidates[best].InFlight {
+			best = ix
+		}
+	}
+
+	return best
+}
+
+// backend is the per-address state of a Client. Calls on a backend are serialized by mu
+// as they share a single connection.
+type backend struct {
+	address   string
+	inFlight  int32     // Accessed atomically
+	downUntil time.Time // Guarded by Client.mu
+
+	mu   sync.Mutex
+	conn Transport
+	dec  *Decoder
+	enc  *Encoder     // Encodes requests into out for the lifetime of conn
+	out  bytes.Buffer // Each request is written with a single Write
+}
+
+// close closes the backend connection, if any. The caller must hold be.mu.
+func (be *backend) close() error {
+	if be.conn == nil {
+		return nil
+	}
+	err := be.conn.Close()
+	be.conn, be.dec, be.enc = nil, nil, nil
+
+	return err
+}
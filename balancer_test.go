@@ -0,0 +1,77 @@
+package netstring_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestRoundRobin(t *testing.T) {
+	var rr netstring.RoundRobin
+	candidates := make([]netstring.Backend, 3)
+	for ix, expect := range []int{0, 1, 2, 0, 1} {
+		if got := rr.Pick(candidates); got != expect {
+			t.Error(ix, "Pick", got, "expected", expect)
+		}
+	}
+}
+
+func TestLeastInFlight(t *testing.T) {
+	testCases := []struct {
+		inFlight []int
+		expect   int
+	}{
+		{[]int{0}, 0},
+		{[]int{3, 1, 2}, 1},
+		{[]int{2, 2, 0, 0}, 2},
+	}
+
+	for ix, tc := range testCases {
+		var candidates []netstring.Backend
+		for _, n := range tc.inFlight {
+			candidates = append(candidates, netstring.Backend{InFlight: n})
+		}
+		if got := (netstring.LeastInFlight{}).Pick(candidates); got != tc.expect {
+			t.Error(ix, "Pick", got, "expected", tc.expect)
+		}
+	}
+}
+
+// countingHandler counts the requests it sees.
+func countingHandler(count *int) netstring.Handler {
+	return netstring.HandlerFunc(func(reply *netstring.MessageBuilder, req *netstring.Request) {
+		*count++ // Calls are serialized by the Client so no locking is needed
+		reply.EncodeBytes('z')
+	})
+}
+
+func TestClientBalancing(t *testing.T) {
+	var c1, c2 int
+	a1 := startServer(t, &netstring.Server{Handler: countingHandler(&c1), MaxInFlight: 1})
+	a2 := startServer(t, &netstring.Server{Handler: countingHandler(&c2), MaxInFlight: 1})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0") // Obtain an address which refuses connections
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead := ln.Addr().String()
+	ln.Close()
+
+	c := &netstring.Client{Network: "tcp", Address: a1, Addresses: []string{dead, a2},
+		MarkDownFor: time.Minute,
+		Retry:       netstring.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}}
+	for ix := 0; ix < 10; ix++ {
+		_, err := c.Call(context.Background(), &netstring.Message{})
+		if err != nil {
+			t.Fatal(ix, err)
+		}
+	}
+	c.Close()
+
+	if c1+c2 != 10 || c1 < 4 || c2 < 4 {
+		t.Error("Calls not balanced across healthy servers", c1, c2)
+	}
+}
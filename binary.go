@@ -0,0 +1,128 @@
+package netstring
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// EncodeBinary encodes "val" as a netstring containing the standard base64 encoding of
+// the output of its MarshalBinary method. This is the sanctioned path for binary types
+// as it keeps the stream text-safe, as recommended in the package documentation. Use
+// DecodeBinary to reverse the encoding. "key" must pass Key.Assess() otherwise an error
+// is returned.
+func (enc *Encoder) EncodeBinary(key Key, val encoding.BinaryMarshaler) (err error) {
+	defer enc.stick(&err)
+	if err = enc.Err(); err != nil {
+		return err
+	}
+	b, err := val.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf(errorPrefix+"%T MarshalBinary failed: %w", val, err)
+	}
+
+	return enc.EncodeBase64Bytes(key, b)
+}
+
+// DecodeBinary is the counterpart of Encoder.EncodeBinary. It base64-decodes the netstring
+// value "val" and passes the result to the UnmarshalBinary method of "dst".
+func DecodeBinary(val []byte, dst encoding.BinaryUnmarshaler) error {
+	b, err := DecodeBase64Bytes(val)
+	if err != nil {
+		return err
+	}
+	err = dst.UnmarshalBinary(b)
+	if err != nil {
+		return fmt.Errorf(errorPrefix+"%T UnmarshalBinary failed: %w", dst, err)
+	}
+
+	return nil
+}
+
+// EncodeBase64Bytes encodes "val" as a netstring containing its standard base64 encoding
+// so that arbitrary binary data is carried in a text-safe form, as recommended in the
+// package documentation. Use DecodeBase64Bytes to reverse the encoding. "key" must pass
+// Key.Assess() otherwise an error is returned.
+func (enc *Encoder) EncodeBase64Bytes(key Key, val []byte) error {
+	b64 := make([]byte, base64.StdEncoding.EncodedLen(len(val)))
+	base64.StdEncoding.Encode(b64, val)
+
+	return enc.EncodeBytes(key, b64)
+}
+
+// DecodeBase64Bytes is the counterpart of Encoder.EncodeBase64Bytes. It returns the
+// base64-decoded form of the netstring value "val".
+func DecodeBase64Bytes(val []byte) ([]byte, error) {
+	b := make([]byte, base64.StdEncoding.DecodedLen(len(val)))
+	n, err := base64.StdEncoding.Decode(b, val)
+	if err != nil {
+		return nil, fmt.Errorf(errorPrefix+"base64 decode failed: %w", err)
+	}
+
+	return b[:n], nil
+}
+
+// EncodeHexBytes encodes "val" as a netstring containing its lower-case hexadecimal
+// encoding. Hex is larger than base64 but is easier for humans to read in dumps and
+// logs. Use DecodeHexBytes to reverse the encoding. "key" must pass Key.Assess()
+// otherwise an error is returned.
+func (enc *Encoder) EncodeHexBytes(key Key, val []byte) error {
+	h := make([]byte, hex.EncodedLen(len(val)))
+	hex.Encode(h, val)
+
+	return enc.EncodeBytes(key, h)
+}
+
+// DecodeHexBytes is the counterpart of Encoder.EncodeHexBytes. It returns the
+// hex-decoded form of the netstring value "val". Upper and lower case hex digits are
+// accepted.
+func DecodeHexBytes(val []byte) ([]byte, error) {
+	b := make([]byte, hex.DecodedLen(len(val)))
+	n, err := hex.Decode(b, val)
+	if err != nil {
+		return nil, fmt.Errorf(errorPrefix+"hex decode failed: %w", err)
+	}
+
+	return b[:n], nil
+}
+
+// EncodeGzipBytes encodes "val" as a netstring containing its gzip compressed form. This
+// suits large textual values such as logs and HTML. The result is binary so the
+// netstring is not text-safe. Use DecodeGzip to reverse the encoding. "key" must pass
+// Key.Assess() otherwise an error is returned.
+func (enc *Encoder) EncodeGzipBytes(key Key, val []byte) error {
+	var bbuf bytes.Buffer
+	zw := gzip.NewWriter(&bbuf)
+	_, err := zw.Write(val)
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		return fmt.Errorf(errorPrefix+"gzip compress failed: %w", err)
+	}
+
+	return enc.EncodeBytes(key, bbuf.Bytes())
+}
+
+// DecodeGzip is the counterpart of Encoder.EncodeGzipBytes. It returns the decompressed
+// form of the netstring value "val". To guard against decompression bombs, an error is
+// returned if the decompressed form exceeds MaximumLength bytes.
+func DecodeGzip(val []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(val))
+	if err != nil {
+		return nil, fmt.Errorf(errorPrefix+"gzip decompress failed: %w", err)
+	}
+	b, err := io.ReadAll(io.LimitReader(zr, MaximumLength+1))
+	if err != nil {
+		return nil, fmt.Errorf(errorPrefix+"gzip decompress failed: %w", err)
+	}
+	if len(b) > MaximumLength {
+		return nil, fmt.Errorf(errorPrefix+"gzip decompressed value exceeds %d bytes", MaximumLength)
+	}
+
+	return b, nil
+}
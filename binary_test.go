@@ -0,0 +1,123 @@
+package netstring_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/markdingo/netstring"
+)
+
+// badBinary fails to marshal and unmarshal.
+type badBinary struct{}
+
+func (badBinary) MarshalBinary() ([]byte, error)  { return nil, errors.New("no marshal") }
+func (*badBinary) UnmarshalBinary(b []byte) error { return errors.New("no unmarshal") }
+
+func TestEncodeBinary(t *testing.T) {
+	tm := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	var bbuf bytes.Buffer
+	enc := netstring.NewEncoder(&bbuf)
+	err := enc.EncodeBinary('b', tm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.IndexFunc(bbuf.Bytes(), func(r rune) bool { return r < ' ' || r > '~' }) >= 0 {
+		t.Error("Encoding is not text-safe", bbuf.String())
+	}
+
+	_, v, err := netstring.NewDecoder(&bbuf).DecodeKeyed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back time.Time
+	err = netstring.DecodeBinary(v, &back)
+	if err != nil || !back.Equal(tm) {
+		t.Error("Round trip failed", back, err)
+	}
+
+	if err := enc.EncodeBinary('b', badBinary{}); err == nil {
+		t.Error("Expected MarshalBinary error")
+	}
+	if err := netstring.DecodeBinary([]byte("!!"), &back); err == nil {
+		t.Error("Expected base64 error")
+	}
+	if err := netstring.DecodeBinary([]byte("AA=="), &badBinary{}); err == nil {
+		t.Error("Expected UnmarshalBinary error")
+	}
+}
+
+func TestBase64HexBytes(t *testing.T) {
+	val := []byte{0, 1, 0xfe, 0xff, '\n'}
+	var bbuf bytes.Buffer
+	enc := netstring.NewEncoder(&bbuf)
+	err := enc.EncodeBase64Bytes('b', val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = enc.EncodeHexBytes('h', val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := "9:bAAH+/wo=,11:h0001feff0a,"
+	if bbuf.String() != exp {
+		t.Fatal("Wire mismatch got", bbuf.String(), "expected", exp)
+	}
+
+	dec := netstring.NewDecoder(&bbuf)
+	_, v, _ := dec.DecodeKeyed()
+	got, err := netstring.DecodeBase64Bytes(v)
+	if err != nil || !bytes.Equal(got, val) {
+		t.Error("DecodeBase64Bytes", got, err)
+	}
+	_, v, _ = dec.DecodeKeyed()
+	got, err = netstring.DecodeHexBytes(v)
+	if err != nil || !bytes.Equal(got, val) {
+		t.Error("DecodeHexBytes", got, err)
+	}
+	got, err = netstring.DecodeHexBytes([]byte("0001FEFF0A"))
+	if err != nil || !bytes.Equal(got, val) {
+		t.Error("DecodeHexBytes upper case", got, err)
+	}
+
+	_, err = netstring.DecodeBase64Bytes([]byte("!!"))
+	if err == nil {
+		t.Error("Expected base64 error")
+	}
+	_, err = netstring.DecodeHexBytes([]byte("0g"))
+	if err == nil {
+		t.Error("Expected hex error")
+	}
+}
+
+func TestGzipBytes(t *testing.T) {
+	val := bytes.Repeat([]byte("<p>Hello, World</p>\n"), 500)
+	var bbuf bytes.Buffer
+	enc := netstring.NewEncoder(&bbuf)
+	err := enc.EncodeGzipBytes('g', val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bbuf.Len() >= len(val)/10 {
+		t.Error("Value not compressed", bbuf.Len())
+	}
+
+	_, v, err := netstring.NewDecoder(&bbuf).DecodeKeyed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := netstring.DecodeGzip(v)
+	if err != nil || !bytes.Equal(got, val) {
+		t.Error("DecodeGzip mismatch", len(got), err)
+	}
+
+	_, err = netstring.DecodeGzip([]byte("not gzip"))
+	if err == nil {
+		t.Error("Expected error from invalid gzip")
+	}
+	_, err = netstring.DecodeGzip(v[:len(v)-4])
+	if err == nil {
+		t.Error("Expected error from truncated gzip")
+	}
+}
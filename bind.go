@@ -0,0 +1,87 @@
+package netstring
+
+import (
+	"io"
+	"reflect"
+	"sync"
+)
+
+/*
+BindChannels bridges "conn" to a pair of typed channels so that application code can
+work purely with channels of "basic-struct" values rather than calling Marshal and
+Unmarshal directly. T is a struct or a pointer to a struct, as accepted by Marshal. Each
+message is terminated by "eom".
+
+Every value received from "send" is written with Marshal. Every message read from "conn"
+is populated into a new T with Unmarshal and delivered to "recv". Either channel can be
+nil if only one direction is required. Two goroutines run until:
+
+  - "send" is closed or a Marshal fails, for the sending direction
+  - "conn" reaches io.EOF or an Unmarshal fails, for the receiving direction, at which
+    point "recv" is closed
+
+The returned channel delivers the error which terminated each direction, excluding
+io.EOF, and is closed once both directions have finished, so ranging over it is a
+convenient way to wait for the binding to end. Closing "conn" terminates the receiving
+direction. Closing "conn" is otherwise left to the caller.
+
+Keys in incoming messages which are not known to T are ignored.
+*/
+func BindChannels[T any](conn *Conn, eom Key, send <-chan T, recv chan<- T) <-chan error {
+	errs := make(chan error, 2) // Never blocks as each direction reports at most once
+	var wg sync.WaitGroup
+
+	if send != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for v := range send {
+				err := conn.Encoder.Marshal(eom, v)
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	if recv != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(recv)
+			for {
+				v, target := newBound[T]()
+				_, err := conn.Decoder.Unmarshal(eom, target)
+				if err != nil {
+					if err != io.EOF {
+						errs <- err
+					}
+					return
+				}
+				recv <- *v
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(errs)
+	}()
+
+	return errs
+}
+
+// newBound returns a pointer to a new T along with the pointer to the struct which
+// Unmarshal populates. If T is a pointer to a struct, the struct is also allocated.
+func newBound[T any]() (v *T, target any) {
+	v = new(T)
+	rt := reflect.TypeOf(*v)
+	if rt != nil && rt.Kind() == reflect.Pointer {
+		sp := reflect.New(rt.Elem())
+		reflect.ValueOf(v).Elem().Set(sp)
+		return v, sp.Interface()
+	}
+
+	return v, v
+}
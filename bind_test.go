@@ -0,0 +1,70 @@
+package netstring_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+type bindMsg struct {
+	Name string `netstring:"n"`
+	Age  int    `netstring:"a"`
+}
+
+func TestBindChannels(t *testing.T) {
+	left, right := net.Pipe()
+	lc := netstring.NewConn(left)
+	rc := netstring.NewConn(right)
+
+	send := make(chan bindMsg)
+	lerrs := netstring.BindChannels[bindMsg](lc, 'z', send, nil)
+	recv := make(chan *bindMsg) // Pointer types are also accepted
+	rerrs := netstring.BindChannels[*bindMsg](rc, 'z', nil, recv)
+
+	msgs := []bindMsg{{"Bob", 22}, {"Alice", 33}, {"", 0}}
+	go func() {
+		for _, m := range msgs {
+			send <- m
+		}
+		close(send)
+	}()
+	for ix, exp := range msgs {
+		got := <-recv
+		if got == nil || *got != exp {
+			t.Error(ix, "Expected", exp, "got", got)
+		}
+	}
+
+	for err := range lerrs { // Sender ends cleanly when "send" is closed
+		t.Error("Unexpected sender error", err)
+	}
+	lc.Close() // Receiver sees EOF
+	for err := range rerrs {
+		t.Error("Unexpected receiver error", err)
+	}
+	_, ok := <-recv
+	if ok {
+		t.Error("Expected recv to be closed")
+	}
+}
+
+func TestBindChannelsError(t *testing.T) {
+	left, right := net.Pipe()
+	lc := netstring.NewConn(left)
+	rc := netstring.NewConn(right)
+	defer lc.Close()
+
+	recv := make(chan bindMsg, 1)
+	errs := netstring.BindChannels[bindMsg](rc, 'z', nil, recv)
+	go lc.EncodeString('a', "not-an-int")
+
+	err, ok := <-errs
+	if !ok || err == nil {
+		t.Error("Expected Unmarshal error to be reported")
+	}
+	_, ok = <-errs
+	if ok {
+		t.Error("Expected error channel to be closed")
+	}
+}
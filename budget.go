@@ -0,0 +1,199 @@
+package netstring
+
+import (
+	"container/list"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// BudgetPolicy determines what a MemoryBudget does when a Decoder needs more memory than
+// remains in the budget.
+type BudgetPolicy int
+
+const (
+	BudgetReject     BudgetPolicy = iota // The Decoder needing memory fails
+	BudgetShedOldest                     // The least recently active Decoders are shed
+)
+
+/*
+MemoryBudget caps the memory held by partially received netstring values across any
+number of Decoders, typically all the Decoders of a gateway's connections, so that
+thousands of slow or malicious connections, each trickling in the start of a large
+value, cannot exhaust memory between them. Decoders share a MemoryBudget by being
+constructed WithMemoryBudget. A MemoryBudget *must* be constructed with
+[NewMemoryBudget].
+
+Each Decoder charges the budget with the full length of a value when it parses the
+length and the charge is released when the value is returned to the application, at
+which point the memory belongs to the application. The fixed staging area of each Decoder
+and any buffering added by WithBuffering are not charged.
+
+When a charge does not fit, the policy decides which Decoder fails:
+
+  - BudgetReject fails the Decoder which needs the memory
+  - BudgetShedOldest fails Decoders holding charges, least recently active first, until
+    the charge fits
+
+A failed Decoder returns ErrMemoryBudget in perpetuity. A shed Decoder may well be
+blocked in a Read, so if its io.Reader implements io.Closer, such as a net.Conn, it is
+closed to return control to the application promptly. A charge which exceeds the whole
+budget always fails.
+
+A MemoryBudget is safe for concurrent use.
+*/
+type MemoryBudget struct {
+	limit  int64
+	policy BudgetPolicy
+
+	mu   sync.Mutex
+	used int64
+	lru  list.List // Holders with a charge, least recently active first
+	shed int64
+}
+
+// budgetHolder is the per-Decoder state of a MemoryBudget.
+type budgetHolder struct {
+	mb      *MemoryBudget
+	closer  io.Closer // Closed when shed, if the io.Reader allows
+	charged int64
+	elem    *list.Element // In mb.lru while charged
+	shed    atomic.Bool
+}
+
+// NewMemoryBudget constructs a MemoryBudget of "limit" bytes which applies "policy" when
+// the limit is reached.
+func NewMemoryBudget(limit int64, policy BudgetPolicy) *MemoryBudget {
+	return &MemoryBudget{limit: limit, policy: policy}
+}
+
+// WithMemoryBudget causes a Decoder to charge "mb" for the memory of each value while it
+// is being received. See MemoryBudget. Encoder ignores this Option.
+func WithMemoryBudget(mb *MemoryBudget) Option {
+	return func(o *options) {
+		o.budget = mb
+	}
+}
+
+// InUse returns the total bytes currently charged to the budget.
+func (mb *MemoryBudget) InUse() int64 {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	return mb.used
+}
+
+// Shed returns the number of Decoders which have been shed by BudgetShedOldest.
+func (mb *MemoryBudget) Shed() int64 {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	return mb.shed
+}
+
+// newHolder returns the per-Decoder state for "rdr", the io.Reader supplied to
+// NewDecoder.
+func (mb *MemoryBudget) newHolder(rdr io.Reader) *budgetHolder {
+	h := &budgetHolder{mb: mb}
+	h.closer, _ = rdr.(io.Closer)
+
+	return h
+}
+
+// reserve charges "n" bytes to the holder, shedding other holders if the policy allows.
+func (h *budgetHolder) reserve(n int) error {
+	mb := h.mb
+	var victims []*budgetHolder
+	defer func() { // Close outside the lock as Close can be slow
+		for _, v := range victims {
+			if v.closer != nil {
+				v.closer.Close()
+			}
+		}
+	}()
+
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	if h.shed.Load() || int64(n) > mb.limit {
+		return ErrMemoryBudget
+	}
+	if n == 0 {
+		return nil
+	}
+	for mb.used+int64(n) > mb.limit {
+		front := mb.lru.Front()
+		if mb.policy != BudgetShedOldest || front == nil {
+			return ErrMemoryBudget
+		}
+		v := front.Value.(*budgetHolder)
+		mb.lru.Remove(front)
+		mb.used -= v.charged
+		v.charged = 0
+		v.elem = nil
+		v.shed.Store(true)
+		mb.shed++
+		victims = append(victims, v)
+	}
+	mb.used += int64(n)
+	h.charged = int64(n)
+	h.elem = mb.lru.PushBack(h)
+
+	return nil
+}
+
+// release returns the holder's charge, if any, to the budget.
+func (h *budgetHolder) release() {
+	mb := h.mb
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	if h.elem != nil {
+		mb.lru.Remove(h.elem)
+		mb.used -= h.charged
+		h.charged = 0
+		h.elem = nil
+	}
+}
+
+// charge returns the bytes currently charged by the holder.
+func (h *budgetHolder) charge() int64 {
+	h.mb.mu.Lock()
+	defer h.mb.mu.Unlock()
+
+	return h.charged
+}
+
+// restore returns the holder's charge to "n", as it was when a Parser checkpoint was
+// taken. A shed holder remains shed.
+func (h *budgetHolder) restore(n int64) error {
+	h.release()
+
+	return h.reserve(int(n))
+}
+
+// touch marks the holder as the most recently active.
+func (h *budgetHolder) touch() {
+	mb := h.mb
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	if h.elem != nil {
+		mb.lru.MoveToBack(h.elem)
+	}
+}
+
+// checkBudget is called by fill after each Read. A shed Decoder drops its value and fails
+// permanently and a Decoder which can no longer make progress returns its charge. A push
+// Decoder, as used by Parser and LintWriter, which is merely waiting for the next Feed
+// retains its charge.
+func (dec *Decoder) checkBudget() {
+	switch {
+	case dec.budget.shed.Load():
+		dec.inProgress = nil
+		dec.end = 0
+		dec.parseError = ErrMemoryBudget
+	case dec.parseError == errNeedMore:
+	case dec.parseError != nil && dec.end == 0:
+		dec.budget.release()
+	case dec.state == parseValue:
+		dec.budget.touch()
+	}
+}
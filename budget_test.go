@@ -0,0 +1,110 @@
+package netstring_test
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/markdingo/netstring"
+)
+
+// slowDecoder starts decoding from one end of a net.Pipe which has been sent "partial",
+// waits for the budget to reach "inUse" and returns the other end of the pipe along with
+// the channel which delivers the eventual Decode error.
+func slowDecoder(t *testing.T, mb *netstring.MemoryBudget, partial string, inUse int64) (net.Conn, <-chan error) {
+	c1, c2 := net.Pipe()
+	dec := netstring.NewDecoder(c1, netstring.WithMemoryBudget(mb), netstring.WithoutAutoBuffering())
+	errs := make(chan error, 1)
+	go func() {
+		_, err := dec.Decode()
+		errs <- err
+	}()
+	c2.Write([]byte(partial))
+	for ix := 0; mb.InUse() != inUse; ix++ {
+		if ix > 1000 {
+			t.Fatal("Budget never reached", inUse, "got", mb.InUse())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	return c2, errs
+}
+
+func TestMemoryBudgetReject(t *testing.T) {
+	mb := netstring.NewMemoryBudget(10, netstring.BudgetReject)
+	c2, errs := slowDecoder(t, mb, "8:abcd", 8)
+
+	dec := netstring.NewDecoder(strings.NewReader("2:hi,5:hello,"), netstring.WithMemoryBudget(mb))
+	ns, err := dec.Decode()
+	if err != nil || string(ns) != "hi" {
+		t.Error("Value within budget should decode", string(ns), err)
+	}
+	_, err = dec.Decode()
+	if err != netstring.ErrMemoryBudget {
+		t.Error("Expected ErrMemoryBudget, got", err)
+	}
+
+	c2.Write([]byte("efgh,")) // Slow decoder completes and returns its charge
+	if err := <-errs; err != nil {
+		t.Error("Slow decoder failed", err)
+	}
+	if mb.InUse() != 0 || mb.Shed() != 0 {
+		t.Error("Budget not returned", mb.InUse(), mb.Shed())
+	}
+
+	dec = netstring.NewDecoder(strings.NewReader("11:hello world,"), netstring.WithMemoryBudget(mb))
+	if _, err = dec.Decode(); err != netstring.ErrMemoryBudget {
+		t.Error("Value larger than budget should fail, got", err)
+	}
+}
+
+func TestMemoryBudgetShedOldest(t *testing.T) {
+	mb := netstring.NewMemoryBudget(10, netstring.BudgetShedOldest)
+	_, oldErrs := slowDecoder(t, mb, "4:ab", 4)
+	_, newErrs := slowDecoder(t, mb, "4:cd", 8)
+
+	dec := netstring.NewDecoder(strings.NewReader("5:hello,"), netstring.WithMemoryBudget(mb))
+	ns, err := dec.Decode()
+	if err != nil || string(ns) != "hello" {
+		t.Error("Shedding should make room", string(ns), err)
+	}
+	if err := <-oldErrs; err != netstring.ErrMemoryBudget {
+		t.Error("Oldest decoder expected ErrMemoryBudget, got", err)
+	}
+	select {
+	case err := <-newErrs:
+		t.Error("Newer decoder should not be shed", err)
+	default:
+	}
+	if mb.Shed() != 1 || mb.InUse() != 4 {
+		t.Error("Unexpected budget", mb.Shed(), mb.InUse())
+	}
+}
+
+func TestMemoryBudgetParser(t *testing.T) {
+	mb := netstring.NewMemoryBudget(100, netstring.BudgetReject)
+	var got string
+	p1 := netstring.NewParser(func(ns []byte) error {
+		got = string(ns)
+		return nil
+	}, netstring.WithMemoryBudget(mb))
+	if err := p1.Feed([]byte("90:" + strings.Repeat("x", 10))); err != nil {
+		t.Fatal(err)
+	}
+	if mb.InUse() != 90 {
+		t.Error("Charge not held across Feed calls", mb.InUse())
+	}
+
+	p2 := netstring.NewParser(func(ns []byte) error { return nil }, netstring.WithMemoryBudget(mb))
+	if err := p2.Feed([]byte("90:x")); err != netstring.ErrMemoryBudget {
+		t.Error("Expected ErrMemoryBudget for second Parser, got", err)
+	}
+
+	if err := p1.Feed([]byte(strings.Repeat("x", 80) + ",")); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 90 || mb.InUse() != 0 {
+		t.Error("Expected value returned and charge released", len(got), mb.InUse())
+	}
+}
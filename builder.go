@@ -0,0 +1,119 @@
+package netstring
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+)
+
+/*
+MessageBuilder assembles a complete message in memory so that it can be emitted with a
+single Write() or discarded in its entirety. A MessageBuilder *must* be constructed with
+[NewMessageBuilder].
+
+MessageBuilder embeds an Encoder so all the Encode*() functions and Marshal are available
+to assemble the message. Nothing is written to the destination io.Writer until [WriteTo]
+or [WriteToContext] is called, at which point the whole message is written with exactly
+one Write() call.
+
+The contract for cancellation is simple: if a message is abandoned part way through
+assembly, perhaps because a context was cancelled or an Encode*() returned an error, the
+caller calls [Abort] and nothing is written. The MessageBuilder is then immediately
+reusable for the next message. [WriteToContext] applies this contract automatically by
+aborting the message if the context is done prior to the Write().
+
+A MessageBuilder can check the assembled message against a Schema with [Validate] or, if
+constructed with WithSchema, automatically prior to every [WriteTo]. This catches
+producer-side bugs in the sender's process rather than as mysterious receiver errors.
+*/
+type MessageBuilder struct {
+	*Encoder
+	buf    bytes.Buffer
+	schema *Schema // Validate prior to WriteTo if set
+	sent   bool    // A message has been written, along with any provenance
+}
+
+// NewMessageBuilder constructs an empty MessageBuilder. All "opts", apart from
+// WithWriteBuffering, are applied to the embedded Encoder.
+func NewMessageBuilder(opts ...Option) *MessageBuilder {
+	mb := &MessageBuilder{schema: applyOptions(opts).schema}
+	opts = append(opts[:len(opts):len(opts)], WithWriteBuffering(0))
+	mb.Encoder = NewEncoder(&mb.buf, opts...)
+
+	return mb
+}
+
+// Len returns the number of bytes assembled thus far.
+func (mb *MessageBuilder) Len() int {
+	return mb.buf.Len()
+}
+
+// Bytes returns the assembled message. The returned slice is only valid until the next
+// modification of the MessageBuilder.
+func (mb *MessageBuilder) Bytes() []byte {
+	return mb.buf.Bytes()
+}
+
+// Abort discards the message assembled thus far. Nothing is written and the
+// MessageBuilder is ready to assemble a new message. Any WithStickyErrors error is
+// cleared and, with WithHMAC, the discarded netstrings are not covered by the next trailer.
+func (mb *MessageBuilder) Abort() {
+	mb.buf.Reset()
+	mb.discard(!mb.sent)
+}
+
+// Validate checks that the assembled message is exactly one message which conforms to
+// "schema". Any preceding netstrings, such as a message type, must be defined by the
+// Schema as they are considered part of the message. The returned error wraps
+// ErrSchemaViolation.
+func (mb *MessageBuilder) Validate(schema *Schema) error {
+	dec := NewDecoder(bytes.NewReader(mb.buf.Bytes()), WithMaximumLength(mb.maxLength))
+	m, err := dec.ReadMessage(schema.EOM)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSchemaViolation, err)
+	}
+	_, err = dec.Decode()
+	if err != io.EOF {
+		return fmt.Errorf("%w: data follows the end-of-message", ErrSchemaViolation)
+	}
+
+	return schema.Validate(&m)
+}
+
+// WriteTo writes the assembled message to "w" with a single Write() call and resets the
+// MessageBuilder ready for the next message. WriteTo implements io.WriterTo.
+//
+// The MessageBuilder is reset regardless of whether the Write() succeeds as a failed
+// Write() leaves the destination in an unknown state which retrying cannot rectify. If
+// the MessageBuilder was constructed with WithSchema and the message fails validation,
+// the message is discarded, nothing is written and the validation error is returned.
+func (mb *MessageBuilder) WriteTo(w io.Writer) (int64, error) {
+	defer mb.buf.Reset()
+	if mb.buf.Len() == 0 {
+		return 0, nil
+	}
+	if mb.schema != nil {
+		err := mb.Validate(mb.schema)
+		if err != nil {
+			mb.discard(!mb.sent)
+			return 0, err
+		}
+	}
+	mb.sent = true
+	n, err := w.Write(mb.buf.Bytes())
+
+	return int64(n), err
+}
+
+// WriteToContext is the same as WriteTo excepting that if "ctx" is done prior to the
+// Write(), the message is aborted, nothing is written and ctx.Err() is returned.
+func (mb *MessageBuilder) WriteToContext(ctx context.Context, w io.Writer) (int64, error) {
+	err := ctx.Err()
+	if err != nil {
+		mb.Abort()
+		return 0, err
+	}
+
+	return mb.WriteTo(w)
+}
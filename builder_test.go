@@ -0,0 +1,193 @@
+package netstring_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	cw.writes++
+	return cw.Buffer.Write(p)
+}
+
+func TestMessageBuilder(t *testing.T) {
+	var cw countingWriter
+	mb := netstring.NewMessageBuilder()
+	mb.EncodeInt('a', 21)
+	mb.EncodeString('C', "Iceland")
+	mb.EncodeBytes('z')
+	if mb.Len() != 21 {
+		t.Error("Len() returned", mb.Len())
+	}
+	if cw.Len() != 0 {
+		t.Error("Builder wrote before WriteTo", cw.String())
+	}
+
+	n, err := mb.WriteTo(&cw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := "3:a21,8:CIceland,1:z,"
+	if n != int64(len(exp)) || cw.String() != exp {
+		t.Error("WriteTo wrote", n, cw.String())
+	}
+	if cw.writes != 1 {
+		t.Error("Expected exactly one Write() call, not", cw.writes)
+	}
+	if mb.Len() != 0 {
+		t.Error("Builder not reset after WriteTo", mb.Len())
+	}
+
+	n, err = mb.WriteTo(&cw) // Empty message writes nothing
+	if n != 0 || err != nil || cw.writes != 1 {
+		t.Error("Empty WriteTo wrote", n, err, cw.writes)
+	}
+}
+
+func TestMessageBuilderAbort(t *testing.T) {
+	var cw countingWriter
+	mb := netstring.NewMessageBuilder()
+
+	// Abandon a message mid-assembly
+	mb.EncodeInt('a', 21)
+	mb.Abort()
+	if mb.Len() != 0 {
+		t.Error("Abort did not discard message", mb.Len())
+	}
+
+	// A cancelled context aborts the message and writes nothing
+	ctx, cancel := context.WithCancel(context.Background())
+	mb.EncodeString('n', "Bjorn")
+	cancel()
+	n, err := mb.WriteToContext(ctx, &cw)
+	if err != context.Canceled {
+		t.Error("Expected context.Canceled, not", err)
+	}
+	if n != 0 || cw.writes != 0 || mb.Len() != 0 {
+		t.Error("Cancelled WriteToContext wrote something", n, cw.writes, mb.Len())
+	}
+
+	// And the builder is reusable
+	mb.EncodeString('n', "Bob")
+	mb.EncodeBytes('z')
+	_, err = mb.WriteToContext(context.Background(), &cw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cw.String() != "4:nBob,1:z," {
+		t.Error("Reused builder wrote", cw.String())
+	}
+}
+
+func TestMessageBuilderAbortSticky(t *testing.T) {
+	var cw countingWriter
+	mb := netstring.NewMessageBuilder(netstring.WithStickyErrors())
+	err := mb.EncodeString('#', "bad")
+	if !errors.Is(err, netstring.ErrInvalidKey) {
+		t.Fatal("Expected ErrInvalidKey, not", err)
+	}
+	mb.Abort()
+	if mb.Err() != nil {
+		t.Error("Abort did not clear the sticky error", mb.Err())
+	}
+	err = mb.EncodeString('a', "good")
+	if err != nil {
+		t.Fatal("Builder not reusable after Abort", err)
+	}
+	mb.EncodeBytes('z')
+	mb.WriteTo(&cw)
+	if cw.String() != "5:agood,1:z," {
+		t.Error("Reused builder wrote", cw.String())
+	}
+}
+
+func TestMessageBuilderValidate(t *testing.T) {
+	schema, err := netstring.ParseSchema(strings.NewReader("eom z\nM Type string required\na Age int"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type testCase struct {
+		build  func(mb *netstring.MessageBuilder)
+		expect string // Empty means valid
+	}
+	testCases := []testCase{
+		{func(mb *netstring.MessageBuilder) {
+			mb.EncodeString('M', "person")
+			mb.EncodeInt('a', 42)
+			mb.EncodeBytes('z')
+		}, ""},
+		{func(mb *netstring.MessageBuilder) {
+			mb.EncodeInt('a', 42)
+			mb.EncodeBytes('z')
+		}, "Type ('M') is missing"},
+		{func(mb *netstring.MessageBuilder) {
+			mb.EncodeString('M', "person")
+			mb.EncodeString('a', "old")
+			mb.EncodeBytes('z')
+		}, "not a valid int"},
+		{func(mb *netstring.MessageBuilder) {
+			mb.EncodeString('M', "person")
+		}, "unexpected EOF"},
+		{func(mb *netstring.MessageBuilder) {
+			mb.EncodeString('M', "person")
+			mb.EncodeBytes('z')
+			mb.EncodeBytes('z')
+		}, "data follows"},
+		{func(mb *netstring.MessageBuilder) {
+			mb.EncodeString(netstring.NoKey, "")
+		}, "zero length"},
+	}
+
+	for ix, tc := range testCases {
+		mb := netstring.NewMessageBuilder()
+		tc.build(mb)
+		err := mb.Validate(schema)
+		if len(tc.expect) == 0 {
+			if err != nil {
+				t.Error(ix, "Unexpected error", err)
+			}
+			continue
+		}
+		if !errors.Is(err, netstring.ErrSchemaViolation) || !strings.Contains(err.Error(), tc.expect) {
+			t.Error(ix, "Expected", tc.expect, "got", err)
+		}
+	}
+}
+
+func TestMessageBuilderWithSchema(t *testing.T) {
+	schema, _ := netstring.ParseSchema(strings.NewReader("eom z\nM Type string required"))
+	mb := netstring.NewMessageBuilder(netstring.WithSchema(schema), netstring.WithMaximumLength(10))
+	var cw countingWriter
+
+	mb.EncodeBytes('z') // Missing type
+	n, err := mb.WriteTo(&cw)
+	if n != 0 || !errors.Is(err, netstring.ErrSchemaViolation) || cw.writes != 0 {
+		t.Error("Invalid message should not be written", n, err, cw.writes)
+	}
+	if mb.Len() != 0 {
+		t.Error("Invalid message should be discarded")
+	}
+
+	mb.EncodeString('M', "ping")
+	mb.EncodeBytes('z')
+	_, err = mb.WriteTo(&cw)
+	if err != nil || cw.String() != "5:Mping,1:z," {
+		t.Error("Valid message not written", err, cw.String())
+	}
+
+	err = mb.EncodeString('M', "0123456789")
+	if err != netstring.ErrValueToLong {
+		t.Error("Options not applied to embedded Encoder", err)
+	}
+}
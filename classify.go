@@ -0,0 +1,79 @@
+package netstring
+
+/*
+Class categorizes a decoded netstring so that consumers can distinguish the three forms
+of "empty" netstring which are otherwise easily confused:
+
+	0:,    an empty standard netstring (ClassEmptyStandard)
+	1:a,   a "keyed" netstring with a key but no value (ClassEmptyKeyed)
+	1:z,   an end-of-message sentinel, if 'z' is the sentinel (ClassEOM)
+
+All are one byte or less on the wire, yet they mean quite different things. An empty
+standard netstring cannot appear in a stream of "keyed" netstrings as it has no key,
+whereas an empty "keyed" netstring is a legitimate field with an empty value.
+*/
+type Class int
+
+// All Class values.
+const (
+	ClassValue         Class = iota // A netstring with a non-empty value or a key and value
+	ClassEmptyStandard              // A zero-length netstring
+	ClassEmptyKeyed                 // A "keyed" netstring with no value which is not the EOM
+	ClassEOM                        // A "keyed" netstring matching the end-of-message key
+)
+
+func (c Class) String() string {
+	switch c {
+	case ClassValue:
+		return "Value"
+	case ClassEmptyStandard:
+		return "EmptyStandard"
+	case ClassEmptyKeyed:
+		return "EmptyKeyed"
+	case ClassEOM:
+		return "EOM"
+	}
+
+	return "Bizarre Class"
+}
+
+// Classify returns the Class of the decoded netstring "ns" as returned by
+// Decoder.Decode. An "eom" of NoKey means no netstring is classified as ClassEOM. A
+// single byte netstring which is not a valid key is classified as ClassValue.
+func Classify(ns []byte, eom Key) Class {
+	switch {
+	case len(ns) == 0:
+		return ClassEmptyStandard
+	case len(ns) > 1:
+		return ClassValue
+	}
+
+	key := Key(ns[0])
+	if keyed, err := key.Assess(); !keyed || err != nil {
+		return ClassValue
+	}
+	if eom != NoKey && key == eom {
+		return ClassEOM
+	}
+
+	return ClassEmptyKeyed
+}
+
+// DecodeClassified is the same as Decode excepting that it also returns the Class of the
+// netstring relative to the "eom" end-of-message key.
+func (dec *Decoder) DecodeClassified(eom Key) (Class, []byte, error) {
+	ns, err := dec.Decode()
+	if err != nil {
+		return ClassValue, nil, err
+	}
+
+	return Classify(ns, eom), ns, nil
+}
+
+// EncodeEmpty encodes a netstring with no value. If key == netstring.NoKey the empty
+// standard netstring "0:," is encoded otherwise a "keyed" netstring containing only the
+// key is encoded, which is also how an end-of-message sentinel is encoded. "key" must
+// pass Key.Assess() otherwise an error is returned.
+func (enc *Encoder) EncodeEmpty(key Key) error {
+	return enc.EncodeBytes(key)
+}
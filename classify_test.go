@@ -0,0 +1,48 @@
+package netstring_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestClassify(t *testing.T) {
+	var bbuf bytes.Buffer
+	enc := netstring.NewEncoder(&bbuf)
+	enc.EncodeEmpty(netstring.NoKey)
+	enc.EncodeEmpty('a')
+	enc.EncodeString('a', "value")
+	enc.EncodeString(netstring.NoKey, "7")
+	enc.EncodeEmpty('z')
+	if bbuf.String() != "0:,1:a,6:avalue,1:7,1:z," {
+		t.Fatal("EncodeEmpty wrong", bbuf.String())
+	}
+	if err := enc.EncodeEmpty('*'); err != netstring.ErrInvalidKey {
+		t.Error("Expected ErrInvalidKey, not", err)
+	}
+
+	dec := netstring.NewDecoder(&bbuf)
+	for ix, expect := range []netstring.Class{netstring.ClassEmptyStandard,
+		netstring.ClassEmptyKeyed, netstring.ClassValue, netstring.ClassValue,
+		netstring.ClassEOM} {
+		c, _, err := dec.DecodeClassified('z')
+		if err != nil {
+			t.Fatal(ix, err)
+		}
+		if c != expect {
+			t.Error(ix, "Expected", expect, "got", c)
+		}
+	}
+	if _, _, err := dec.DecodeClassified('z'); err != io.EOF {
+		t.Error("Expected io.EOF, not", err)
+	}
+
+	if c := netstring.Classify([]byte("z"), netstring.NoKey); c != netstring.ClassEmptyKeyed {
+		t.Error("NoKey eom should never classify as EOM", c)
+	}
+	if netstring.Class(99).String() != "Bizarre Class" {
+		t.Error("Bizarre Class not bizarre")
+	}
+}
@@ -0,0 +1,362 @@
+package netstring
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+Client makes request/reply calls to one or more netstring Servers. Each Call sends one
+message and reads one reply message on a lazily dialed connection which is re-used by
+subsequent calls to the same server. Calls to the same server are serialized. A Client is
+safe for concurrent use.
+
+If multiple server addresses are configured, each Call is sent to the server chosen by the
+Balancer. A server which fails with a transport error is marked down for MarkDownFor and
+is not offered to the Balancer again until that time has passed, unless all servers are
+down.
+
+If the reply is a standard error-reply created by Encoder.EncodeError, Call returns it as
+a *RemoteError.
+
+If a call fails and the Retry policy permits, the call is retried on a new connection
+after a backoff delay. This relieves callers from hand-rolling retry loops around Call.
+*/
+type Client struct {
+	Network     string        // As per net.Dial
+	Address     string        // As per net.Dial
+	Dial        DialFunc      // Default uses net.Dialer
+	Addresses   []string      // Additional server addresses
+	Balancer    Balancer      // Default is RoundRobin
+	MarkDownFor time.Duration // Default 5s
+	EOM         Key           // End-of-message sentinel of requests and replies; DefaultEOM if NoKey
+	Options     []Option      // Applied to each connection
+	Retry       RetryPolicy   // The zero value means no retries
+
+	ShadowAddress  string        // Secondary address mirrored calls are sent to
+	ShadowFraction float64       // 0 to 1
+	ShadowTimeout  time.Duration // Default 5s
+
+	mu       sync.Mutex
+	backends []*backend
+	balancer Balancer
+	shadow   *Client
+	shadows  sync.WaitGroup
+}
+
+/*
+RetryPolicy controls whether and when a failed Client.Call is retried. A call is only
+retried if the error is classified as retryable by IsRetryable, or if the request is
+deemed idempotent by the Idempotent function and the error is a transport error. An
+error-reply from the Server is never retried unless it is classified as retryable.
+
+The delay before retry "n" (starting at 1) is InitialBackoff * Multiplier^(n-1), capped
+at MaxBackoff, with Jitter being the fraction of the delay which is randomized. A Jitter
+of 0.2 randomizes the delay within +/-20%.
+*/
+type RetryPolicy struct {
+	MaxAttempts    int                     // Total attempts including the first; <= 1 means no retries
+	InitialBackoff time.Duration           // Default 100ms
+	MaxBackoff     time.Duration           // Default 10s
+	Multiplier     float64                 // Default 2
+	Jitter         float64                 // 0 to 1
+	Idempotent     func(req *Message) bool // If nil, no request is considered idempotent
+}
+
+// Backoff returns the delay prior to retry "n" where the first retry is 1.
+func (p *RetryPolicy) Backoff(n int) time.Duration {
+	delay := float64(p.InitialBackoff)
+	if delay <= 0 {
+		delay = float64(100 * time.Millisecond)
+	}
+	limit := float64(p.MaxBackoff)
+	if limit <= 0 {
+		limit = float64(10 * time.Second)
+	}
+	mult := p.Multiplier
+	if mult < 1 {
+		mult = 2
+	}
+	for ; n > 1 && delay < limit; n-- {
+		delay *= mult
+	}
+	if delay > limit {
+		delay = limit
+	}
+	if p.Jitter > 0 {
+		jitter := p.Jitter
+		if jitter > 1 {
+			jitter = 1
+		}
+		delay += delay * jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(delay)
+}
+
+// IsRetryable classifies "err" as retryable regardless of whether the request is
+// idempotent. An error is retryable if the request cannot have been processed by the
+// Server, namely a failure to connect or a Server rejection with ErrServerBusy.
+func IsRetryable(err error) bool {
+	if errors.Is(err, ErrServerBusy) {
+		return true
+	}
+	var oe *net.OpError
+	if errors.As(err, &oe) {
+		return oe.Op == "dial"
+	}
+
+	return false
+}
+
+// isTransportError returns true if "err" relates to the connection rather than the
+// request or reply, i.e. a retry on a new connection may succeed.
+func isTransportError(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// Call sends "req" to the Server and returns the reply message. If req.EOM is NoKey, the
+// Client EOM is used. Failed calls are retried as permitted by the Retry policy.
+//
+// If "ctx" is done, any pending backoff or I/O is abandoned and ctx.Err() is returned.
+func (c *Client) Call(ctx context.Context, req *Message) (*Message, error) {
+	r := *req
+	if r.EOM == NoKey {
+		r.EOM = c.eom()
+	}
+	c.mirror(&r)
+	idempotent := c.Retry.Idempotent != nil && c.Retry.Idempotent(req)
+
+	for attempt := 1; ; attempt++ {
+		reply, err := c.call(ctx, &r)
+		if err == nil {
+			return reply, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if attempt >= c.Retry.MaxAttempts {
+			return nil, err
+		}
+		if !IsRetryable(err) && !(idempotent && isTransportError(err)) {
+			return nil, err
+		}
+		timer := time.NewTimer(c.Retry.Backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// call makes a single attempt at sending "req" to the Balancer's choice of backend and
+// reading the reply. The request is encoded by the Encoder of the backend connection so
+// that stream-scoped Options, such as WithProvenance, apply once per connection. Any error
+// other than an error-reply or an invalid request closes the connection so that the next
+// attempt dials afresh.
+func (c *Client) call(ctx context.Context, req *Message) (*Message, error) {
+	be := c.pick()
+	atomic.AddInt32(&be.inFlight, 1)
+	defer atomic.AddInt32(&be.inFlight, -1)
+	be.mu.Lock()
+	defer be.mu.Unlock()
+
+	if be.conn == nil {
+		dial := c.Dial
+		if dial == nil {
+			dial = dialNet
+		}
+		nc, err := dial(ctx, c.Network, be.address)
+		if err != nil {
+			c.markDown(ctx, be)
+			return nil, err
+		}
+		be.conn = nc
+		be.dec = NewDecoder(nc, c.Options...)
+		be.out.Reset()
+		be.enc = NewEncoder(&be.out, c.Options...)
+	}
+	be.out.Reset()
+	err := req.encode(be.enc) // Nothing is encoded if the request is invalid
+	if err == nil {
+		err = be.enc.Flush()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	dl, canDeadline := be.conn.(deadliner)
+	if canDeadline {
+		deadline, _ := ctx.Deadline()
+		dl.SetDeadline(deadline)
+	}
+	stop := make(chan struct{})
+	abandoned := make(chan bool, 1)
+	go func(t Transport) {
+		select {
+		case <-ctx.Done(): // Abandon pending I/O
+			if canDeadline {
+				dl.SetDeadline(time.Unix(1, 0))
+			} else {
+				t.Close()
+			}
+			abandoned <- true
+		case <-stop:
+			abandoned <- false
+		}
+	}(be.conn)
+	defer func() {
+		close(stop)
+		if <-abandoned && !canDeadline { // Don't re-use a closed Transport
+			be.close()
+		}
+	}()
+
+	_, err = be.conn.Write(be.out.Bytes())
+	var m Message
+	if err == nil {
+		m, err = be.dec.ReadMessage(c.eom())
+	}
+	if err != nil {
+		be.close()
+		c.markDown(ctx, be)
+		return nil, err
+	}
+	if re := remoteErrorOf(&m); re != nil {
+		if errors.Is(re, ErrServerBusy) { // Server closes rejected connections
+			be.close()
+		}
+		return nil, re
+	}
+
+	return &m, nil
+}
+
+// mirror sends a copy of "req" to the ShadowAddress if this call is selected by
+// ShadowFraction. The shadow call is independent of the caller's context and its reply is
+// discarded.
+func (c *Client) mirror(req *Message) {
+	if len(c.ShadowAddress) == 0 || rand.Float64() >= c.ShadowFraction {
+		return
+	}
+	c.mu.Lock()
+	if c.shadow == nil {
+		c.shadow = &Client{Network: c.Network, Address: c.ShadowAddress, Dial: c.Dial,
+			EOM: c.EOM, Options: c.Options}
+	}
+	shadow := c.shadow
+	c.shadows.Add(1)
+	c.mu.Unlock()
+
+	timeout := c.ShadowTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	m := Message{EOM: req.EOM, Fields: make([]KV, len(req.Fields))} // Caller may re-use req
+	for ix, kv := range req.Fields {
+		m.Fields[ix] = KV{kv.Key, append([]byte(nil), kv.Value...)}
+	}
+	go func() {
+		defer c.shadows.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		shadow.call(ctx, &m)
+	}()
+}
+
+// pick returns the backend chosen by the Balancer from amongst the healthy backends, or
+// from all backends if none are healthy.
+func (c *Client) pick() *backend {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.backends == nil {
+		for _, addr := range append([]string{c.Address}, c.Addresses...) {
+			if len(addr) > 0 {
+				c.backends = append(c.backends, &backend{address: addr})
+			}
+		}
+		if len(c.backends) == 0 { // Let Dial report the error
+			c.backends = append(c.backends, &backend{})
+		}
+		c.balancer = c.Balancer
+		if c.balancer == nil {
+			c.balancer = &RoundRobin{}
+		}
+	}
+
+	now := time.Now()
+	healthy := make([]*backend, 0, len(c.backends))
+	for _, be := range c.backends {
+		if !now.Before(be.downUntil) {
+			healthy = append(healthy, be)
+		}
+	}
+	if len(healthy) == 0 {
+		healthy = c.backends
+	}
+	if len(healthy) == 1 {
+		return healthy[0]
+	}
+	candidates := make([]Backend, len(healthy))
+	for ix, be := range healthy {
+		candidates[ix] = Backend{Address: be.address, InFlight: int(atomic.LoadInt32(&be.inFlight))}
+	}
+
+	return healthy[c.balancer.Pick(candidates)]
+}
+
+// markDown excludes "be" from selection for MarkDownFor. Failures caused by "ctx" are
+// not held against the backend.
+func (c *Client) markDown(ctx context.Context, be *backend) {
+	if ctx.Err() != nil {
+		return
+	}
+	down := c.MarkDownFor
+	if down <= 0 {
+		down = 5 * time.Second
+	}
+	c.mu.Lock()
+	be.downUntil = time.Now().Add(down)
+	c.mu.Unlock()
+}
+
+// Close waits for outstanding shadow calls then closes all current connections. The
+// Client remains usable and a subsequent Call dials new connections.
+func (c *Client) Close() error {
+	c.shadows.Wait()
+	c.mu.Lock()
+	backends := c.backends
+	shadow := c.shadow
+	c.mu.Unlock()
+
+	var err error
+	if shadow != nil {
+		err = shadow.Close()
+	}
+	for _, be := range backends {
+		be.mu.Lock()
+		if e := be.close(); e != nil && err == nil {
+			err = e
+		}
+		be.mu.Unlock()
+	}
+
+	return err
+}
+
+func (c *Client) eom() Key {
+	if c.EOM == NoKey {
+		return DefaultEOM
+	}
+
+	return c.EOM
+}
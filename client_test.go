@@ -0,0 +1,277 @@
+package netstring_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestClient(t *testing.T) {
+	addr := startServer(t, &netstring.Server{Handler: echoHandler})
+	c := &netstring.Client{Network: "tcp", Address: addr}
+	defer c.Close()
+
+	req := &netstring.Message{Fields: []netstring.KV{{Key: 'a', Value: []byte("hello")}}}
+	for ix := 0; ix < 3; ix++ {
+		m, err := c.Call(context.Background(), req)
+		if err != nil {
+			t.Fatal(ix, err)
+		}
+		if v, _ := m.Get('a'); string(v) != "HELLO" {
+			t.Error(ix, "Reply wrong", string(v))
+		}
+	}
+
+	country := &netstring.Message{Fields: []netstring.KV{{Key: 'C', Value: []byte("64")},
+		{Key: 'n', Value: []byte("nz")}}} // 'C' used as an ordinary data key
+	m, err := c.Call(context.Background(), country)
+	if err != nil {
+		t.Fatal("Normal reply with 'C' treated as an error-reply", err)
+	}
+	if v, _ := m.Get('n'); string(v) != "NZ" {
+		t.Error("Reply wrong", m)
+	}
+
+	req.Fields = append(req.Fields, netstring.KV{Key: 'p', Value: []byte("panic")})
+	_, err = c.Call(context.Background(), req)
+	var re *netstring.RemoteError
+	if !errors.As(err, &re) || !errors.Is(err, netstring.ErrHandlerPanic) {
+		t.Error("Expected RemoteError ErrHandlerPanic, not", err)
+	}
+}
+
+func TestClientHMAC(t *testing.T) {
+	opts := []netstring.Option{netstring.WithHMAC([]byte("secret"), sha256.New)}
+	handler := netstring.HandlerFunc(func(reply *netstring.MessageBuilder, req *netstring.Request) {
+		v, _ := req.Get('a')
+		reply.EncodeMessage('z', netstring.KV{Key: 'a', Value: bytes.ToUpper(v)})
+	})
+	addr := startServer(t, &netstring.Server{Handler: handler, Options: opts})
+	c := &netstring.Client{Network: "tcp", Address: addr, Options: opts}
+	defer c.Close()
+
+	req := &netstring.Message{Fields: []netstring.KV{{Key: 'a', Value: []byte("hello")}}}
+	for ix := 0; ix < 3; ix++ { // All on the one connection
+		m, err := c.Call(context.Background(), req)
+		if err != nil {
+			t.Fatal(ix, err)
+		}
+		if v, _ := m.Get('a'); string(v) != "HELLO" {
+			t.Error(ix, "Reply wrong", string(v))
+		}
+	}
+}
+
+func TestClientProvenance(t *testing.T) {
+	var marked []bool
+	handler := netstring.HandlerFunc(func(reply *netstring.MessageBuilder, req *netstring.Request) {
+		_, ok := req.Get(netstring.ProvenanceKey) // Server does not consume provenance
+		marked = append(marked, ok)
+		reply.EncodeBytes('z')
+	})
+	addr := startServer(t, &netstring.Server{Handler: handler})
+	c := &netstring.Client{Network: "tcp", Address: addr,
+		Options: []netstring.Option{netstring.WithProvenance(netstring.Provenance{Producer: "c"})}}
+	defer c.Close()
+
+	req := &netstring.Message{Fields: []netstring.KV{{Key: 'a', Value: []byte("hello")}}}
+	for ix := 0; ix < 3; ix++ {
+		if _, err := c.Call(context.Background(), req); err != nil {
+			t.Fatal(ix, err)
+		}
+	}
+	if len(marked) != 3 || !marked[0] || marked[1] || marked[2] {
+		t.Error("Expected provenance with the first request only", marked)
+	}
+}
+
+func TestClientRetryBusy(t *testing.T) {
+	srv := &netstring.Server{Handler: echoHandler, MaxConns: 1}
+	addr := startServer(t, srv)
+
+	hog, err := netstring.DialNetstring("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hog.EncodeBytes('z') // Make sure the hog is being served
+	_, err = hog.ReadMessage('z')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &netstring.Client{Network: "tcp", Address: addr,
+		Retry: netstring.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}}
+	defer c.Close()
+	req := &netstring.Message{Fields: []netstring.KV{{Key: 'a', Value: []byte("x")}}}
+	_, err = c.Call(context.Background(), req)
+	if !errors.Is(err, netstring.ErrServerBusy) {
+		t.Fatal("Expected ErrServerBusy, not", err)
+	}
+
+	c.Retry.MaxAttempts = 50
+	c.Retry.InitialBackoff = 5 * time.Millisecond
+	c.Retry.MaxBackoff = 20 * time.Millisecond
+	time.AfterFunc(20*time.Millisecond, func() { hog.Close() })
+	_, err = c.Call(context.Background(), req)
+	if err != nil {
+		t.Error("Retry should have succeeded once the hog closed", err)
+	}
+}
+
+// flakyServer accepts connections and drops the first "drops" requests without
+// replying. Subsequent requests get an empty reply.
+func flakyServer(t *testing.T, drops int32) (string, *int32) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	var accepts int32
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			n := atomic.AddInt32(&accepts, 1)
+			conn := netstring.NewConn(nc)
+			_, err = conn.ReadMessage('z')
+			if err == nil && n > drops {
+				conn.EncodeBytes('z')
+			}
+			conn.Close()
+		}
+	}()
+
+	return ln.Addr().String(), &accepts
+}
+
+func TestClientIdempotent(t *testing.T) {
+	idempotent := func(req *netstring.Message) bool {
+		v, _ := req.Get('M')
+		return string(v) == "get"
+	}
+	testCases := []struct {
+		mtype   string
+		err     error
+		accepts int32
+	}{
+		{"put", io.EOF, 1},
+		{"get", nil, 3},
+	}
+
+	for ix, tc := range testCases {
+		addr, accepts := flakyServer(t, 2)
+		c := &netstring.Client{Network: "tcp", Address: addr,
+			Retry: netstring.RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Millisecond,
+				Idempotent: idempotent}}
+		req := &netstring.Message{Fields: []netstring.KV{{Key: 'M', Value: []byte(tc.mtype)}}}
+		_, err := c.Call(context.Background(), req)
+		c.Close()
+		if !errors.Is(err, tc.err) {
+			t.Error(ix, "Expected", tc.err, "got", err)
+		}
+		if got := atomic.LoadInt32(accepts); got != tc.accepts {
+			t.Error(ix, "Expected", tc.accepts, "connections, got", got)
+		}
+	}
+}
+
+func TestClientContext(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() { // Accept but never reply
+		nc, err := ln.Accept()
+		if err == nil {
+			io.Copy(io.Discard, nc)
+		}
+	}()
+
+	c := &netstring.Client{Network: "tcp", Address: ln.Addr().String()}
+	defer c.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	_, err = c.Call(ctx, &netstring.Message{})
+	if err != context.Canceled {
+		t.Error("Expected context.Canceled, not", err)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := netstring.RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	for ix, expect := range []time.Duration{10, 20, 40, 50, 50} {
+		got := p.Backoff(ix + 1)
+		if got != expect*time.Millisecond {
+			t.Error(ix, "Backoff", got, "expected", expect*time.Millisecond)
+		}
+	}
+
+	p.Jitter = 0.5
+	for ix := 0; ix < 100; ix++ {
+		got := p.Backoff(2)
+		if got < 10*time.Millisecond || got > 30*time.Millisecond {
+			t.Error(ix, "Jittered backoff out of range", got)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	testCases := []struct {
+		err    error
+		expect bool
+	}{
+		{netstring.ErrServerBusy, true},
+		{&netstring.RemoteError{Code: netstring.CodeServerBusy}, true},
+		{&netstring.RemoteError{Code: netstring.CodeHandlerPanic}, false},
+		{&net.OpError{Op: "dial", Err: errors.New("refused")}, true},
+		{&net.OpError{Op: "read", Err: errors.New("reset")}, false},
+		{io.EOF, false},
+		{nil, false},
+	}
+
+	for ix, tc := range testCases {
+		if got := netstring.IsRetryable(tc.err); got != tc.expect {
+			t.Error(ix, tc.err, "got", got)
+		}
+	}
+}
+
+func TestClientShadow(t *testing.T) {
+	testCases := []struct {
+		fraction float64
+		expect   int
+	}{
+		{0, 0},
+		{1, 5},
+	}
+
+	for ix, tc := range testCases {
+		var primary, shadow int
+		a1 := startServer(t, &netstring.Server{Handler: countingHandler(&primary)})
+		a2 := startServer(t, &netstring.Server{Handler: countingHandler(&shadow)})
+		c := &netstring.Client{Network: "tcp", Address: a1,
+			ShadowAddress: a2, ShadowFraction: tc.fraction}
+		for jx := 0; jx < 5; jx++ {
+			_, err := c.Call(context.Background(), &netstring.Message{})
+			if err != nil {
+				t.Fatal(ix, jx, err)
+			}
+		}
+		c.Close() // Waits for shadow calls
+
+		if primary != 5 || shadow != tc.expect {
+			t.Error(ix, "Expected 5 and", tc.expect, "got", primary, shadow)
+		}
+	}
+}
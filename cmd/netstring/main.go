@@ -0,0 +1,118 @@
+// Command netstring provides command line access to the netstring package.
+//
+// Usage:
+//
+//	netstring dump [-keyed] [-time] [file]
+//	netstring harness [-timeout duration] -script file command [args...]
+//
+// The "dump" subcommand prints the netstrings found in "file", or stdin, as per
+// netstring.Dump. With -time, each line is prefixed with the time it was decoded, which
+// is only meaningful when stdin is a live stream. The "harness" subcommand runs a
+// scripted conversation against "command" as per the harness package and exits non-zero
+// if the conversation fails.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/markdingo/netstring"
+	"github.com/markdingo/netstring/harness"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func usage(stderr io.Writer) int {
+	fmt.Fprintln(stderr, "Usage: netstring dump [-keyed] [-time] [file]")
+	fmt.Fprintln(stderr, "       netstring harness [-timeout duration] -script file command [args...]")
+
+	return 2
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		return usage(stderr)
+	}
+	switch args[0] {
+	case "dump":
+		return dump(args[1:], stdin, stdout, stderr)
+	case "harness":
+		return runHarness(args[1:], stderr)
+	}
+
+	return usage(stderr)
+}
+
+func dump(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("dump", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	keyed := fs.Bool("keyed", false, "Display netstrings as keyed netstrings")
+	stamp := fs.Bool("time", false, "Prefix each line with the time it was decoded")
+	if fs.Parse(args) != nil || fs.NArg() > 1 {
+		return usage(stderr)
+	}
+
+	rdr := stdin
+	if fs.NArg() == 1 {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		defer f.Close()
+		rdr = f
+	}
+
+	var clock func(int) time.Time
+	if *stamp {
+		clock = func(int) time.Time { return time.Now() }
+	}
+	err := netstring.Dump(stdout, rdr, *keyed, clock)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+func runHarness(args []string, stderr io.Writer) int {
+	fs := flag.NewFlagSet("harness", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	timeout := fs.Duration("timeout", time.Minute, "Maximum duration of the conversation")
+	scriptPath := fs.String("script", "", "Script file")
+	if fs.Parse(args) != nil || len(*scriptPath) == 0 || fs.NArg() == 0 {
+		return usage(stderr)
+	}
+
+	f, err := os.Open(*scriptPath)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	script, err := harness.ParseScript(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintln(stderr, *scriptPath+":", err)
+		return 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	cmd := exec.Command(fs.Arg(0), fs.Args()[1:]...)
+	cmd.Stderr = stderr
+	err = harness.Run(ctx, cmd, script)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunUsage(t *testing.T) {
+	for ix, args := range [][]string{{}, {"bogus"}, {"dump", "a", "b"}, {"harness", "cat"}} {
+		var stderr bytes.Buffer
+		if run(args, nil, nil, &stderr) != 2 || !strings.Contains(stderr.String(), "Usage") {
+			t.Error(ix, "Expected usage from", args)
+		}
+	}
+}
+
+func TestRunDump(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"dump", "-keyed"}, strings.NewReader("6:ahello,"), &stdout, &stderr)
+	if code != 0 || !strings.Contains(stdout.String(), `a "hello"`) {
+		t.Error("Dump wrong", code, stdout.String(), stderr.String())
+	}
+
+	stdout.Reset()
+	code = run([]string{"dump", "-time"}, strings.NewReader("6:ahello,"), &stdout, &stderr)
+	if code != 0 || !strings.Contains(stdout.String(), `Z       0     6 "ahello"`) {
+		t.Error("Timestamped dump wrong", code, stdout.String(), stderr.String())
+	}
+
+	code = run([]string{"dump", "/nonexistent/file"}, nil, &stdout, &stderr)
+	if code != 1 {
+		t.Error("Expected failure from missing file")
+	}
+}
+
+func TestRunHarness(t *testing.T) {
+	cat := "/bin/cat"
+	if _, err := os.Stat(cat); err != nil {
+		t.Skip("No", cat)
+	}
+	path := filepath.Join(t.TempDir(), "echo.script")
+	os.WriteFile(path, []byte("send \"hi\"\nexpect \"hi\"\nclose\neof\n"), 0600)
+
+	var stderr bytes.Buffer
+	code := run([]string{"harness", "-timeout", "10s", "-script", path, cat}, nil, nil, &stderr)
+	if code != 0 {
+		t.Error("Harness against cat failed", stderr.String())
+	}
+
+	os.WriteFile(path, []byte("send \"hi\"\nexpect \"bye\"\n"), 0600)
+	stderr.Reset()
+	code = run([]string{"harness", "-script", path, cat}, nil, nil, &stderr)
+	if code != 1 || !strings.Contains(stderr.String(), "line 2") {
+		t.Error("Expected harness failure", code, stderr.String())
+	}
+}
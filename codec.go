@@ -0,0 +1,125 @@
+package netstring
+
+import (
+	"reflect"
+	"sync"
+)
+
+// EncodeFunc converts a value of a registered type to the netstring value emitted by
+// Marshal. "v" is always of the type passed to RegisterCodec.
+type EncodeFunc func(v any) ([]byte, error)
+
+// DecodeFunc converts a netstring value back to a value of a registered type for
+// Unmarshal. The returned value must be of the type passed to RegisterCodec.
+type DecodeFunc func(b []byte) (any, error)
+
+type codec struct {
+	encode EncodeFunc
+	decode DecodeFunc
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = make(map[reflect.Type]codec)
+)
+
+// RegisterCodec teaches Marshal and Unmarshal how to convert fields of type "rt" to and
+// from netstring values, thus extending the "basic-struct" beyond the hard-coded basic
+// kinds. It is typically called from an init() function. For example, to support
+// time.Time:
+//
+//	netstring.RegisterCodec(reflect.TypeOf(time.Time{}),
+//	    func(v any) ([]byte, error) {
+//	        return v.(time.Time).MarshalText()
+//	    },
+//	    func(b []byte) (any, error) {
+//	        var t time.Time
+//	        err := t.UnmarshalText(b)
+//	        return t, err
+//	    })
+//
+// A registered codec takes precedence over the built-in handling of a type, so named
+// types such as integer-backed enums can be given their own representation. Registering
+// a type again replaces the previous codec and passing nil for both functions removes the
+// registration. Registration applies to all Encoders and Decoders and is safe for
+// concurrent use.
+func RegisterCodec(rt reflect.Type, encode EncodeFunc, decode DecodeFunc) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	if encode == nil && decode == nil {
+		delete(codecs, rt)
+		return
+	}
+	codecs[rt] = codec{encode, decode}
+}
+
+// lookupCodec returns the codec registered for "rt", if any.
+func lookupCodec(rt reflect.Type) (codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	c, ok := codecs[rt]
+
+	return c, ok
+}
+
+// Marshaler is the interface implemented by types which can encode themselves as a
+// netstring value. Encode and Marshal use MarshalNetstring in preference to their
+// built-in handling of a type, so applications can plug custom types, such as UUIDs,
+// money types and enums, into the existing encoders rather than receiving
+// ErrUnsupportedType. A codec registered with RegisterCodec takes precedence over
+// Marshaler in Marshal.
+type Marshaler interface {
+	MarshalNetstring() ([]byte, error)
+}
+
+// Unmarshaler is the interface implemented by types which can decode a netstring value
+// produced by their MarshalNetstring method. Unmarshal calls UnmarshalNetstring for
+// fields whose type, or pointer to type, implements Unmarshaler, allocating the field
+// first if it is a nil pointer. UnmarshalNetstring must copy the value if it wishes to
+// retain it. A codec registered with RegisterCodec takes precedence over Unmarshaler.
+type Unmarshaler interface {
+	UnmarshalNetstring(val []byte) error
+}
+
+var (
+	marshalerType   = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+)
+
+// asMarshaler returns the Marshaler of "v", which may be the address of "v". The returned
+// bool is false if "v" does not implement Marshaler or if it is a nil pointer.
+func asMarshaler(v reflect.Value) (Marshaler, bool) {
+	if v.Type().Implements(marshalerType) {
+		if v.Kind() == reflect.Pointer && v.IsNil() {
+			return nil, false
+		}
+		return v.Interface().(Marshaler), true
+	}
+	if v.CanAddr() && reflect.PointerTo(v.Type()).Implements(marshalerType) {
+		return v.Addr().Interface().(Marshaler), true
+	}
+
+	return nil, false
+}
+
+// isUnmarshaler returns true if a field of type "rt" can be populated via Unmarshaler.
+func isUnmarshaler(rt reflect.Type) bool {
+	if rt.Kind() == reflect.Pointer && rt.Implements(unmarshalerType) {
+		return true
+	}
+
+	return reflect.PointerTo(rt).Implements(unmarshalerType)
+}
+
+// asUnmarshaler returns the Unmarshaler of the addressable field "v" for which
+// isUnmarshaler is true, allocating "v" if it is a nil pointer.
+func asUnmarshaler(v reflect.Value) Unmarshaler {
+	if v.Kind() == reflect.Pointer && v.Type().Implements(unmarshalerType) {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return v.Interface().(Unmarshaler)
+	}
+
+	return v.Addr().Interface().(Unmarshaler)
+}
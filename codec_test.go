@@ -0,0 +1,173 @@
+package netstring_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/markdingo/netstring"
+)
+
+type codecPoint struct{ X, Y int }
+
+func registerTimeCodec(t *testing.T) {
+	rt := reflect.TypeOf(time.Time{})
+	netstring.RegisterCodec(rt,
+		func(v any) ([]byte, error) { return v.(time.Time).MarshalText() },
+		func(b []byte) (any, error) {
+			var tm time.Time
+			err := tm.UnmarshalText(b)
+			return tm, err
+		})
+	t.Cleanup(func() { netstring.RegisterCodec(rt, nil, nil) })
+}
+
+func TestRegisterCodec(t *testing.T) {
+	registerTimeCodec(t)
+
+	type event struct {
+		When time.Time `netstring:"w"`
+		Name string    `netstring:"n"`
+	}
+	out := event{time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC), "launch"}
+	var bbuf bytes.Buffer
+	err := netstring.NewEncoder(&bbuf).Marshal('z', &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := "21:w2023-01-02T03:04:05Z,7:nlaunch,1:z,"
+	if bbuf.String() != exp {
+		t.Error("Marshal wrong", bbuf.String())
+	}
+
+	var in event
+	_, err = netstring.NewDecoder(&bbuf).Unmarshal('z', &in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !in.When.Equal(out.When) || in.Name != out.Name {
+		t.Error("Round trip mismatch", in, out)
+	}
+
+	_, err = netstring.NewDecoder(strings.NewReader("4:wnow,1:z,")).Unmarshal('z', &in)
+	if err == nil || !strings.Contains(err.Error(), "When codec decode failed") {
+		t.Error("Expected codec decode error, not", err)
+	}
+}
+
+func TestRegisterCodecErrors(t *testing.T) {
+	type shape struct {
+		P codecPoint `netstring:"p"`
+	}
+	rt := reflect.TypeOf(codecPoint{})
+
+	// Unregistered struct fields are unsupported
+	err := netstring.NewEncoder(&bytes.Buffer{}).Marshal('z', &shape{})
+	if err == nil || !strings.Contains(err.Error(), "unsupported") {
+		t.Error("Expected unsupported error, not", err)
+	}
+
+	failure := errors.New("no can do")
+	netstring.RegisterCodec(rt,
+		func(v any) ([]byte, error) { return nil, failure },
+		func(b []byte) (any, error) { return "wrong type", nil })
+	defer netstring.RegisterCodec(rt, nil, nil)
+
+	err = netstring.NewEncoder(&bytes.Buffer{}).Marshal('z', &shape{})
+	if !errors.Is(err, failure) {
+		t.Error("Expected codec encode error, not", err)
+	}
+	var s shape
+	_, err = netstring.NewDecoder(strings.NewReader("2:p1,1:z,")).Unmarshal('z', &s)
+	if err == nil || !strings.Contains(err.Error(), "returned string") {
+		t.Error("Expected wrong type error, not", err)
+	}
+
+	// Removal reverts to the built-in behaviour
+	netstring.RegisterCodec(rt, nil, nil)
+	_, err = netstring.NewDecoder(strings.NewReader("2:p1,1:z,")).Unmarshal('z', &s)
+	if err == nil || !strings.Contains(err.Error(), "unsupported") {
+		t.Error("Expected unsupported error after removal, not", err)
+	}
+}
+
+// money implements Marshaler and Unmarshaler as a number of cents.
+type money int64
+
+func (m money) MarshalNetstring() ([]byte, error) {
+	if m < 0 {
+		return nil, errors.New("negative money")
+	}
+	return []byte(strconv.FormatInt(int64(m), 10) + "c"), nil
+}
+
+func (m *money) UnmarshalNetstring(val []byte) error {
+	s, ok := strings.CutSuffix(string(val), "c")
+	if !ok {
+		return errors.New("no cents suffix")
+	}
+	c, err := strconv.ParseInt(s, 10, 64)
+	*m = money(c)
+	return err
+}
+
+type wallet struct {
+	Cash    money  `netstring:"c"`
+	Savings *money `netstring:"s"`
+	Owner   string `netstring:"o"`
+}
+
+func TestMarshaler(t *testing.T) {
+	var bb bytes.Buffer
+	enc := netstring.NewEncoder(&bb)
+	err := enc.Encode('m', money(250))
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if bb.String() != "5:m250c," {
+		t.Error("Encode did not use MarshalNetstring", bb.String())
+	}
+	err = enc.Encode('m', money(-1))
+	if err == nil || !strings.Contains(err.Error(), "negative money") {
+		t.Error("Expected MarshalNetstring error, not", err)
+	}
+
+	bb.Reset()
+	savings := money(1000)
+	err = enc.Marshal('z', &wallet{Cash: 5, Savings: &savings, Owner: "Bob"})
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	exp := "3:c5c,6:s1000c,4:oBob,1:z,"
+	if bb.String() != exp {
+		t.Error("Expected", exp, "got", bb.String())
+	}
+
+	var w wallet
+	_, err = netstring.NewDecoder(&bb).Unmarshal('z', &w)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if w.Cash != 5 || w.Savings == nil || *w.Savings != 1000 || w.Owner != "Bob" {
+		t.Error("Unmarshal mismatch", w)
+	}
+
+	bb.Reset()
+	err = enc.Marshal('z', wallet{Owner: "Alice"}) // Nil Marshaler is omitted
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	exp = "3:c0c,6:oAlice,1:z,"
+	if bb.String() != exp {
+		t.Error("Expected", exp, "got", bb.String())
+	}
+
+	_, err = netstring.NewDecoder(strings.NewReader("4:cbad,1:z,")).Unmarshal('z', &w)
+	if err == nil || !strings.Contains(err.Error(), "UnmarshalNetstring") {
+		t.Error("Expected UnmarshalNetstring error, not", err)
+	}
+}
@@ -1,7 +1,7 @@
 package netstring
 
 import (
-	"errors"
+	"math"
 )
 
 // MaximumLength defines the maximum length of a value in a netstring.
@@ -15,6 +15,15 @@ import (
 // less than 2^30, so safe for any int32/uint32 storage.
 const MaximumLength = 999999999
 
+// ProgressFunc is the signature of the callback supplied to Encoder.SetProgress and
+// Decoder.SetProgress. It is called each time more bytes of a netstring value have been
+// transferred. "done" is the number of value bytes transferred thus far and "total" is
+// the length of the complete value, or -1 if the total is not known in advance.
+//
+// The callback is made synchronously from within the Encoder or Decoder so it should
+// return promptly.
+type ProgressFunc func(done, total int64)
+
 const (
 	leadingColon  byte = ':'
 	trailingComma byte = ','
@@ -26,23 +35,25 @@ var (
 	trueByte  = []byte{'T'}
 	falseByte = []byte{'f'}
 
+	// boolValues are the true and false values of each BoolFormat
+	boolValues = [...][2][]byte{
+		BoolShorthand: {trueByte, falseByte},
+		BoolWords:     {[]byte("true"), []byte("false")},
+		BoolDigits:    {[]byte("1"), []byte("0")},
+	}
+
 	leadingDelimiter  = []byte{leadingColon}
 	trailingDelimiter = []byte{trailingComma}
 )
 
-var ErrLengthNotDigit = errors.New(errorPrefix + "Length does not start with a digit")
-var ErrLeadingZero = errors.New(errorPrefix + "Non-zero length cannot have a leading zero")
-var ErrLengthToLong = errors.New(errorPrefix + "Length contains more bytes than maximum allowed")
-var ErrValueToLong = errors.New(errorPrefix + "Length of value is longer than maximum allowed")
-var ErrColonExpected = errors.New(errorPrefix + "Leading colon delimiter not found after length")
-var ErrCommaExpected = errors.New(errorPrefix + "Trailing comma delimeter not found after value")
-
-var ErrNoKey = errors.New(errorPrefix + "Keyed netstring cannot be NoKey")
-var ErrUnsupportedType = errors.New(errorPrefix + "Unsupported go type supplied to Encode()")
-var ErrZeroKey = errors.New(errorPrefix + "Keyed netstring is zero length (thus has no key)")
-var ErrInvalidKey = errors.New(errorPrefix + "Key is not in range 'a'-'z' or 'A'-'Z'")
-
-var ErrBadMarshalValue = errors.New(errorPrefix + "Marshal only accepts struct{} and *struct{}")
-var ErrBadMarshalTag = errors.New(errorPrefix + "struct tag is not a valid netstring.Key")
-var ErrBadUnmarshalMsg = errors.New(errorPrefix + "Unmarshal only accepts *struct{}")
-var ErrBadMarshalEOM = errors.New(errorPrefix + "End-of-Message Key is invalid")
+// appendDigit returns "n" with the decimal digit "b" appended. It returns false rather than
+// wrapping if the result does not fit in an int, as lengths otherwise do on 32-bit
+// platforms once the maximum length is raised.
+func appendDigit(n int, b byte) (int, bool) {
+	d := int(b - '0')
+	if n > (math.MaxInt-d)/10 {
+		return n, false
+	}
+
+	return n*10 + d, true
+}
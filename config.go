@@ -0,0 +1,179 @@
+package netstring
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+/*
+Config holds codec settings in a form suitable for deployment configuration so that
+services can tune Encoder and Decoder behaviour without code changes. A Config is
+typically created with [ConfigFromEnv] or [ConfigFromJSON] and passed to
+[NewEncoderWithConfig] and [NewDecoderWithConfig].
+
+The zero value of every field means "use the package default". Settings which only make
+sense for one side of a connection are ignored by the other.
+
+Note that the netstring delimiters are fixed by the specification and are deliberately not
+configurable.
+*/
+type Config struct {
+	MaximumLength    int        // Maximum value length. 0 means MaximumLength
+	DiscardOversized bool       // Decoder skips oversized netstrings, see WithDiscardOversized
+	ReadBufferSize   int        // Decoder wraps its io.Reader in a bufio.Reader of this size
+	KeyPolicy        *KeyPolicy // Decoder key restrictions, see SetKeyPolicy
+}
+
+// Environment variable suffixes used by ConfigFromEnv.
+const (
+	EnvMaximumLength    = "MAXIMUM_LENGTH"
+	EnvDiscardOversized = "DISCARD_OVERSIZED"
+	EnvReadBufferSize   = "READ_BUFFER_SIZE"
+	EnvAllowKeys        = "ALLOW_KEYS"
+	EnvDenyKeys         = "DENY_KEYS"
+	EnvDenyByDefault    = "DENY_BY_DEFAULT"
+	EnvTerminateOnDeny  = "TERMINATE_ON_DENY"
+)
+
+// ConfigFromEnv constructs a Config from environment variables named "prefix" followed by
+// one of the Env* suffixes, e.g. with a prefix of "MYAPP_NETSTRING_" the maximum length
+// is taken from MYAPP_NETSTRING_MAXIMUM_LENGTH. Unset variables leave the default in
+// place. Boolean variables are parsed with strconv.ParseBool. A KeyPolicy is only created
+// if at least one of the key policy variables is set.
+func ConfigFromEnv(prefix string) (cfg Config, err error) {
+	if v, ok := os.LookupEnv(prefix + EnvMaximumLength); ok {
+		cfg.MaximumLength, err = strconv.Atoi(v)
+		if err != nil {
+			return cfg, fmt.Errorf(errorPrefix+"%s%s: %w", prefix, EnvMaximumLength, err)
+		}
+	}
+	if v, ok := os.LookupEnv(prefix + EnvDiscardOversized); ok {
+		cfg.DiscardOversized, err = strconv.ParseBool(v)
+		if err != nil {
+			return cfg, fmt.Errorf(errorPrefix+"%s%s: %w", prefix, EnvDiscardOversized, err)
+		}
+	}
+	if v, ok := os.LookupEnv(prefix + EnvReadBufferSize); ok {
+		cfg.ReadBufferSize, err = strconv.Atoi(v)
+		if err != nil {
+			return cfg, fmt.Errorf(errorPrefix+"%s%s: %w", prefix, EnvReadBufferSize, err)
+		}
+	}
+
+	var kp KeyPolicy
+	var havePolicy bool
+	if v, ok := os.LookupEnv(prefix + EnvAllowKeys); ok {
+		kp.Allow = v
+		havePolicy = true
+	}
+	if v, ok := os.LookupEnv(prefix + EnvDenyKeys); ok {
+		kp.Deny = v
+		havePolicy = true
+	}
+	if v, ok := os.LookupEnv(prefix + EnvDenyByDefault); ok {
+		kp.DenyByDefault, err = strconv.ParseBool(v)
+		if err != nil {
+			return cfg, fmt.Errorf(errorPrefix+"%s%s: %w", prefix, EnvDenyByDefault, err)
+		}
+		havePolicy = true
+	}
+	if v, ok := os.LookupEnv(prefix + EnvTerminateOnDeny); ok {
+		kp.Terminate, err = strconv.ParseBool(v)
+		if err != nil {
+			return cfg, fmt.Errorf(errorPrefix+"%s%s: %w", prefix, EnvTerminateOnDeny, err)
+		}
+		havePolicy = true
+	}
+	if havePolicy {
+		cfg.KeyPolicy = &kp
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// ConfigFromJSON constructs a Config from a JSON object whose member names match the
+// Config field names, e.g.:
+//
+//	{"MaximumLength": 4096, "KeyPolicy": {"Allow": "abz", "DenyByDefault": true}}
+//
+// Unknown members are rejected to catch misspelt settings.
+func ConfigFromJSON(data []byte) (cfg Config, err error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&cfg)
+	if err != nil {
+		return cfg, fmt.Errorf(errorPrefix+"Config: %w", err)
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Validate checks that all settings in the Config are within range.
+func (cfg *Config) Validate() error {
+	if cfg.MaximumLength < 0 {
+		return fmt.Errorf(errorPrefix+"Config MaximumLength %d is negative", cfg.MaximumLength)
+	}
+	if cfg.ReadBufferSize < 0 {
+		return fmt.Errorf(errorPrefix+"Config ReadBufferSize %d is negative", cfg.ReadBufferSize)
+	}
+	if cfg.KeyPolicy != nil {
+		for _, keys := range []string{cfg.KeyPolicy.Allow, cfg.KeyPolicy.Deny} {
+			for ix := 0; ix < len(keys); ix++ {
+				keyed, err := Key(keys[ix]).Assess()
+				if err != nil || !keyed {
+					return fmt.Errorf(errorPrefix+"Config KeyPolicy key '%s' is invalid",
+						keys[ix:ix+1])
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Options returns the Options equivalent to the Config settings. This allows a Config to
+// be combined with other Options, e.g.:
+//
+//	enc := netstring.NewEncoder(w, append(cfg.Options(), netstring.WithTrace(fn))...)
+//
+// Options does not validate the Config.
+func (cfg *Config) Options() []Option {
+	opts := []Option{WithMaximumLength(-1), WithBuffering(cfg.ReadBufferSize)}
+	if cfg.MaximumLength > 0 {
+		opts[0] = WithMaximumLength(cfg.MaximumLength)
+	}
+	if cfg.DiscardOversized {
+		opts = append(opts, WithDiscardOversized())
+	}
+	if cfg.KeyPolicy != nil {
+		kp := *cfg.KeyPolicy // Isolate from subsequent changes to cfg
+		opts = append(opts, WithKeyPolicy(&kp))
+	}
+
+	return opts
+}
+
+// NewEncoderWithConfig constructs an Encoder with the settings in "cfg". Values longer
+// than cfg.MaximumLength are rejected with ErrValueToLong.
+func NewEncoderWithConfig(output io.Writer, cfg Config) (*Encoder, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewEncoder(output, cfg.Options()...), nil
+}
+
+// NewDecoderWithConfig constructs a Decoder with the settings in "cfg".
+func NewDecoderWithConfig(rdr io.Reader, cfg Config) (*Decoder, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewDecoder(rdr, cfg.Options()...), nil
+}
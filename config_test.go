@@ -0,0 +1,133 @@
+package netstring_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	cfg, err := netstring.ConfigFromEnv("NSTEST_")
+	if err != nil || cfg.MaximumLength != 0 || cfg.KeyPolicy != nil {
+		t.Error("Empty environment should produce default Config", cfg, err)
+	}
+
+	t.Setenv("NSTEST_MAXIMUM_LENGTH", "10")
+	t.Setenv("NSTEST_DISCARD_OVERSIZED", "true")
+	t.Setenv("NSTEST_READ_BUFFER_SIZE", "4096")
+	t.Setenv("NSTEST_ALLOW_KEYS", "az")
+	t.Setenv("NSTEST_DENY_BY_DEFAULT", "1")
+	cfg, err = netstring.ConfigFromEnv("NSTEST_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MaximumLength != 10 || !cfg.DiscardOversized || cfg.ReadBufferSize != 4096 {
+		t.Error("Config settings wrong", cfg)
+	}
+	if cfg.KeyPolicy == nil || cfg.KeyPolicy.Allow != "az" || !cfg.KeyPolicy.DenyByDefault ||
+		cfg.KeyPolicy.Terminate {
+		t.Error("Config KeyPolicy wrong", cfg.KeyPolicy)
+	}
+
+	type testCase struct {
+		name, value, expect string
+	}
+	testCases := []testCase{
+		{"NSTEST_MAXIMUM_LENGTH", "ten", "MAXIMUM_LENGTH"},
+		{"NSTEST_MAXIMUM_LENGTH", "-1", "negative"},
+		{"NSTEST_DISCARD_OVERSIZED", "maybe", "DISCARD_OVERSIZED"},
+		{"NSTEST_READ_BUFFER_SIZE", "big", "READ_BUFFER_SIZE"},
+		{"NSTEST_DENY_BY_DEFAULT", "no way", "DENY_BY_DEFAULT"},
+		{"NSTEST_TERMINATE_ON_DENY", "perhaps", "TERMINATE_ON_DENY"},
+		{"NSTEST_DENY_KEYS", "a1", "'1' is invalid"},
+	}
+	for ix, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(tc.name, tc.value)
+			_, err := netstring.ConfigFromEnv("NSTEST_")
+			if err == nil || !strings.Contains(err.Error(), tc.expect) {
+				t.Error(ix, "Expected", tc.expect, "got", err)
+			}
+		})
+	}
+}
+
+func TestConfigFromJSON(t *testing.T) {
+	cfg, err := netstring.ConfigFromJSON([]byte(
+		`{"MaximumLength": 5, "KeyPolicy": {"Deny": "x", "Terminate": true}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MaximumLength != 5 || cfg.KeyPolicy == nil || cfg.KeyPolicy.Deny != "x" ||
+		!cfg.KeyPolicy.Terminate {
+		t.Error("Config wrong", cfg, cfg.KeyPolicy)
+	}
+
+	for ix, js := range []string{
+		`{"MaxLength": 5}`, // Misspelt
+		`{"MaximumLength": -5}`,
+		`{"ReadBufferSize": -1}`,
+		`not json`,
+	} {
+		_, err = netstring.ConfigFromJSON([]byte(js))
+		if err == nil {
+			t.Error(ix, "Expected error from", js)
+		}
+	}
+}
+
+func TestCodecWithConfig(t *testing.T) {
+	cfg := netstring.Config{MaximumLength: 5, ReadBufferSize: 16,
+		KeyPolicy: &netstring.KeyPolicy{Deny: "x"}}
+
+	var bbuf bytes.Buffer
+	enc, err := netstring.NewEncoderWithConfig(&bbuf, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = enc.EncodeString('a', "abcd")
+	if err != nil {
+		t.Error("Value at limit should encode", err)
+	}
+	err = enc.EncodeString('a', "abcde")
+	if err != netstring.ErrValueToLong {
+		t.Error("Expected ErrValueToLong, not", err)
+	}
+	enc.EncodeString('x', "xx")
+
+	dec, err := netstring.NewDecoderWithConfig(&bbuf, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.KeyPolicy.Deny = "" // Must not affect the Decoder
+	k, v, err := dec.DecodeKeyed()
+	if err != nil || k != 'a' || string(v) != "abcd" {
+		t.Error("First netstring wrong", k, string(v), err)
+	}
+	_, _, err = dec.DecodeKeyed()
+	if err != netstring.ErrKeyNotPermitted {
+		t.Error("Expected ErrKeyNotPermitted, not", err)
+	}
+
+	dec, _ = netstring.NewDecoderWithConfig(strings.NewReader("6:abcdef,1:b,"),
+		netstring.Config{MaximumLength: 5, DiscardOversized: true})
+	_, err = dec.Decode()
+	if err != netstring.ErrValueTooLarge {
+		t.Error("Expected ErrValueTooLarge, not", err)
+	}
+	v, err = dec.Decode()
+	if err != nil || string(v) != "b" {
+		t.Error("Expected 'b' after discard, not", string(v), err)
+	}
+
+	_, err = netstring.NewEncoderWithConfig(&bbuf, netstring.Config{MaximumLength: -1})
+	if err == nil {
+		t.Error("Expected error from negative MaximumLength")
+	}
+	_, err = netstring.NewDecoderWithConfig(&bbuf, netstring.Config{ReadBufferSize: -1})
+	if err == nil {
+		t.Error("Expected error from negative ReadBufferSize")
+	}
+}
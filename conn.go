@@ -0,0 +1,90 @@
+package netstring
+
+import (
+	"io"
+	"os/exec"
+)
+
+/*
+Conn pairs an Encoder and a Decoder on a single bi-directional connection, such as a
+net.Conn or the pipes of a child process. All Encode*() and Decode*() functions, along
+with Marshal and Unmarshal, are available directly on the Conn. A Conn *must* be
+constructed with [NewConn] or [NewCommandConn].
+
+Methods which exist on both Encoder and Decoder, such as SetProgress, are ambiguous and
+must be called via the Encoder or Decoder field, e.g. conn.Decoder.SetProgress(fn).
+*/
+type Conn struct {
+	*Encoder
+	*Decoder
+	rwc Transport
+}
+
+// NewConn constructs a Conn which encodes to and decodes from "rwc", which can be any
+// Transport such as a net.Conn. All "opts" are applied to both the Encoder and the
+// Decoder.
+func NewConn(rwc Transport, opts ...Option) *Conn {
+	return &Conn{Encoder: NewEncoder(rwc, opts...), Decoder: NewDecoder(rwc, opts...), rwc: rwc}
+}
+
+// Close flushes any output buffered by WithWriteBuffering then closes the underlying
+// io.ReadWriteCloser. The first error is returned.
+func (c *Conn) Close() error {
+	ferr := c.Encoder.Flush()
+	err := c.rwc.Close()
+	if ferr != nil {
+		return ferr
+	}
+
+	return err
+}
+
+// cmdPipes adapts the stdin and stdout pipes of a child process to an
+// io.ReadWriteCloser.
+type cmdPipes struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+}
+
+func (cp *cmdPipes) Read(p []byte) (int, error) {
+	return cp.stdout.Read(p)
+}
+
+func (cp *cmdPipes) Write(p []byte) (int, error) {
+	return cp.stdin.Write(p)
+}
+
+// Close closes the child's stdin, which is the conventional signal for a netstring
+// plugin to exit, then waits for the child to exit.
+func (cp *cmdPipes) Close() error {
+	cp.stdin.Close()
+
+	return cp.cmd.Wait()
+}
+
+// NewCommandConn starts "cmd" with its stdin and stdout connected to the Encoder and
+// Decoder of the returned Conn respectively. This suits plugin and sidecar protocols where
+// the child process exchanges netstrings over its standard I/O. The child's stderr is left
+// as configured by the caller.
+//
+// Conn.Close closes the child's stdin and waits for the child to exit, returning the
+// error from exec.Cmd.Wait. Applications which need to terminate an uncooperative child
+// can do so via cmd.Process.
+func NewCommandConn(cmd *exec.Cmd, opts ...Option) (*Conn, error) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		stdin.Close()
+		return nil, err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewConn(&cmdPipes{cmd, stdin, stdout}, opts...), nil
+}
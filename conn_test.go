@@ -0,0 +1,93 @@
+package netstring_test
+
+import (
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestConn(t *testing.T) {
+	c1, c2 := net.Pipe()
+	a := netstring.NewConn(c1)
+	b := netstring.NewConn(c2, netstring.WithMaximumLength(3))
+
+	go func() {
+		a.EncodeString('a', "ok")
+		a.EncodeString('a', "toolong")
+	}()
+	k, v, err := b.DecodeKeyed()
+	if err != nil || k != 'a' || string(v) != "ok" {
+		t.Error("Conn decode wrong", k, string(v), err)
+	}
+	_, err = b.Decode()
+	if err != netstring.ErrLengthToLong {
+		t.Error("Options not applied to Decoder", err)
+	}
+
+	err = b.EncodeString('b', "long") // Options also apply to the Encoder
+	if err != netstring.ErrValueToLong {
+		t.Error("Options not applied to Encoder", err)
+	}
+
+	a.Close()
+	b.Close()
+}
+
+func TestConnCloseFlushes(t *testing.T) {
+	c1, c2 := net.Pipe()
+	a := netstring.NewConn(c1, netstring.WithWriteBuffering(64))
+	go func() {
+		a.EncodeString('a', "hi")
+		a.Close()
+	}()
+	k, v, err := netstring.NewDecoder(c2).DecodeKeyed()
+	if err != nil || k != 'a' || string(v) != "hi" {
+		t.Error("Close did not flush", k, string(v), err)
+	}
+	c2.Close()
+}
+
+func TestCommandConn(t *testing.T) {
+	cat := "/bin/cat"
+	if _, err := os.Stat(cat); err != nil {
+		t.Skip("No", cat)
+	}
+	conn, err := netstring.NewCommandConn(exec.Command(cat))
+	if err != nil {
+		t.Fatal(err)
+	}
+	type ping struct {
+		Seq int `netstring:"s"`
+	}
+	err = conn.Marshal('z', &ping{42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p ping
+	_, err = conn.Unmarshal('z', &p)
+	if err != nil || p.Seq != 42 {
+		t.Error("Round trip through child failed", p, err)
+	}
+	err = conn.Close()
+	if err != nil {
+		t.Error("Close should report clean exit, not", err)
+	}
+
+	_, err = netstring.NewCommandConn(exec.Command("/nonexistent/command"))
+	if err == nil {
+		t.Error("Expected start error")
+	}
+
+	conn, _ = netstring.NewCommandConn(exec.Command("/bin/sh", "-c", "exit 3"))
+	_, err = conn.Decode()
+	if err != io.EOF {
+		t.Error("Expected io.EOF from exited child, not", err)
+	}
+	if conn.Close() == nil {
+		t.Error("Expected exit status error from Close")
+	}
+}
@@ -0,0 +1,215 @@
+package netstring
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// constraints are the optional validation rules which follow the key in a "netstring"
+// tag, e.g. `netstring:"a,min=0,max=150"`. Which rules are valid depends on the kind of
+// the field: "min" and "max" apply to ints, uints and floats whereas "maxlen" and "enum"
+// apply to strings and byte slices.
+type constraints struct {
+	hasMin, hasMax bool
+	minI, maxI     int64
+	minU, maxU     uint64
+	minF, maxF     float64
+	maxLen         int // -1 if not set
+	enum           []string
+}
+
+// splitTag separates the key of a "netstring" tag from any trailing constraints.
+func splitTag(tag string) (key, rest string) {
+	key, rest, _ = strings.Cut(tag, ",")
+
+	return
+}
+
+// parseConstraints parses the comma separated constraints "spec" for the struct field
+// "sf". It returns nil if "spec" is empty.
+func parseConstraints(sf reflect.StructField, spec string) (*constraints, error) {
+	if len(spec) == 0 {
+		return nil, nil
+	}
+
+	kind := sf.Type.Kind()
+	var numeric, textual bool
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		numeric = true
+	case reflect.String:
+		textual = true
+	case reflect.Slice:
+		textual = sf.Type.Elem().Kind() == reflect.Uint8
+	}
+
+	c := &constraints{maxLen: -1}
+	for _, item := range strings.Split(spec, ",") {
+		name, val, ok := strings.Cut(item, "=")
+		if !ok || len(val) == 0 {
+			return nil, fmt.Errorf(errorPrefix+"%s tag constraint '%s' is not name=value",
+				sf.Name, item)
+		}
+		var err error
+		switch name {
+		case "min", "max":
+			if !numeric {
+				return nil, fmt.Errorf(errorPrefix+"%s tag constraint '%s' requires a numeric field",
+					sf.Name, name)
+			}
+			if name == "min" {
+				c.hasMin = true
+				err = parseLimit(kind, val, &c.minI, &c.minU, &c.minF)
+			} else {
+				c.hasMax = true
+				err = parseLimit(kind, val, &c.maxI, &c.maxU, &c.maxF)
+			}
+
+		case "maxlen":
+			if !textual {
+				return nil, fmt.Errorf(errorPrefix+"%s tag constraint '%s' requires a string or []byte field",
+					sf.Name, name)
+			}
+			c.maxLen, err = strconv.Atoi(val)
+			if err == nil && c.maxLen < 0 {
+				err = strconv.ErrRange
+			}
+
+		case "enum":
+			if !textual {
+				return nil, fmt.Errorf(errorPrefix+"%s tag constraint '%s' requires a string or []byte field",
+					sf.Name, name)
+			}
+			c.enum = strings.Split(val, "|")
+
+		default:
+			return nil, fmt.Errorf(errorPrefix+"%s tag constraint '%s' is unknown", sf.Name, name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf(errorPrefix+"%s tag constraint '%s' value '%s' is invalid",
+				sf.Name, name, val)
+		}
+	}
+
+	return c, nil
+}
+
+// parseLimit converts "val" to the type appropriate for "kind" and stores it in the
+// corresponding destination.
+func parseLimit(kind reflect.Kind, val string, i *int64, u *uint64, f *float64) (err error) {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		*i, err = strconv.ParseInt(val, 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		*u, err = strconv.ParseUint(val, 10, 64)
+	default:
+		*f, err = strconv.ParseFloat(val, 64)
+	}
+
+	return
+}
+
+// check returns an error wrapping ErrFieldConstraint if "vf", the value of the field
+// called "name", violates any of the constraints.
+func (c *constraints) check(name string, vf reflect.Value) error {
+	var low, high bool
+	switch vf.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v := vf.Int()
+		low, high = c.hasMin && v < c.minI, c.hasMax && v > c.maxI
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v := vf.Uint()
+		low, high = c.hasMin && v < c.minU, c.hasMax && v > c.maxU
+	case reflect.Float32, reflect.Float64:
+		v := vf.Float()
+		low, high = c.hasMin && v < c.minF, c.hasMax && v > c.maxF
+
+	default: // String or []byte
+		var s string
+		if vf.Kind() == reflect.String {
+			s = vf.String()
+		} else {
+			s = string(vf.Bytes())
+		}
+		if c.maxLen >= 0 && len(s) > c.maxLen {
+			return fmt.Errorf("%w: %s length %d exceeds maxlen=%d",
+				ErrFieldConstraint, name, len(s), c.maxLen)
+		}
+		if c.enum != nil && !contains(c.enum, s) {
+			return fmt.Errorf("%w: %s value '%s' is not one of %s",
+				ErrFieldConstraint, name, s, strings.Join(c.enum, "|"))
+		}
+		return nil
+	}
+
+	if low {
+		return fmt.Errorf("%w: %s value %v is less than min", ErrFieldConstraint, name, vf)
+	}
+	if high {
+		return fmt.Errorf("%w: %s value %v is greater than max", ErrFieldConstraint, name, vf)
+	}
+
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Validate checks the exported fields of "message", a "basic-struct" or pointer to a
+// "basic-struct", against the constraints in their "netstring" tags. Constraints follow
+// the key in the tag, separated by commas:
+//
+//	type person struct {
+//	  Age     int    `netstring:"a,min=0,max=150"`
+//	  Name    string `netstring:"n,maxlen=64"`
+//	  Country string `netstring:"c,enum=IS|NZ|AU"`
+//	}
+//
+// "min" and "max" apply to numeric fields and are inclusive. "maxlen" is the maximum
+// length in bytes of a string or []byte field and "enum" is a '|' separated list of
+// acceptable string or []byte values.
+//
+// Unmarshal always enforces constraints as each field is populated. Marshal only enforces
+// them if the Encoder was constructed with WithValidation. A violation returns an error
+// wrapping ErrFieldConstraint which names the offending field.
+func Validate(message any) error {
+	vo := reflect.ValueOf(message)
+	if vo.Kind() == reflect.Pointer {
+		vo = vo.Elem()
+	}
+	if vo.Kind() != reflect.Struct {
+		return ErrBadMarshalValue
+	}
+
+	to := vo.Type()
+	for ix := 0; ix < to.NumField(); ix++ {
+		sf := to.Field(ix)
+		if !sf.IsExported() {
+			continue
+		}
+		_, spec := splitTag(sf.Tag.Get("netstring"))
+		c, err := parseConstraints(sf, spec)
+		if err != nil {
+			return err
+		}
+		if c != nil {
+			err = c.check(sf.Name, vo.Field(ix))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
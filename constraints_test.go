@@ -0,0 +1,147 @@
+package netstring_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+type constrained struct {
+	Age     int     `netstring:"a,min=0,max=150"`
+	Count   uint8   `netstring:"u,min=1"`
+	Ratio   float64 `netstring:"r,min=-1.5,max=1.5"`
+	Name    string  `netstring:"n,maxlen=5"`
+	Country []byte  `netstring:"c,enum=IS|NZ|AU"`
+	Free    string  `netstring:"f"`
+}
+
+func TestValidate(t *testing.T) {
+	good := constrained{Age: 150, Count: 1, Ratio: -1.5, Name: "Bjorn", Country: []byte("IS")}
+	type testCase struct {
+		modify func(*constrained)
+		expect string // Empty means valid
+	}
+	testCases := []testCase{
+		{func(*constrained) {}, ""},
+		{func(c *constrained) { c.Age = -1 }, "Age value -1 is less than min"},
+		{func(c *constrained) { c.Age = 151 }, "Age value 151 is greater than max"},
+		{func(c *constrained) { c.Count = 0 }, "Count value 0 is less than min"},
+		{func(c *constrained) { c.Ratio = 1.6 }, "Ratio value 1.6 is greater than max"},
+		{func(c *constrained) { c.Name = "Bjornx" }, "Name length 6 exceeds maxlen=5"},
+		{func(c *constrained) { c.Country = []byte("US") }, "Country value 'US' is not one of IS|NZ|AU"},
+	}
+	for ix, tc := range testCases {
+		c := good
+		tc.modify(&c)
+		err := netstring.Validate(&c)
+		if len(tc.expect) == 0 {
+			if err != nil {
+				t.Error(ix, "Unexpected error", err)
+			}
+			continue
+		}
+		if !errors.Is(err, netstring.ErrFieldConstraint) || !strings.Contains(err.Error(), tc.expect) {
+			t.Error(ix, "Expected", tc.expect, "got", err)
+		}
+	}
+
+	if netstring.Validate(42) != netstring.ErrBadMarshalValue {
+		t.Error("Expected ErrBadMarshalValue for non-struct")
+	}
+}
+
+func TestConstraintTagErrors(t *testing.T) {
+	type testCase struct {
+		message any
+		expect  string
+	}
+	testCases := []testCase{
+		{&struct {
+			A int `netstring:"a,min"`
+		}{}, "not name=value"},
+		{&struct {
+			A int `netstring:"a,min=x"`
+		}{}, "value 'x' is invalid"},
+		{&struct {
+			A uint `netstring:"a,max=-1"`
+		}{}, "value '-1' is invalid"},
+		{&struct {
+			A string `netstring:"a,min=1"`
+		}{}, "requires a numeric field"},
+		{&struct {
+			A int `netstring:"a,maxlen=1"`
+		}{}, "requires a string or []byte field"},
+		{&struct {
+			A float32 `netstring:"a,enum=1|2"`
+		}{}, "requires a string or []byte field"},
+		{&struct {
+			A string `netstring:"a,maxlen=-1"`
+		}{}, "value '-1' is invalid"},
+		{&struct {
+			A string `netstring:"a,regex=.*"`
+		}{}, "'regex' is unknown"},
+	}
+	for ix, tc := range testCases {
+		err := netstring.Validate(tc.message)
+		if err == nil || !strings.Contains(err.Error(), tc.expect) {
+			t.Error(ix, "Validate expected", tc.expect, "got", err)
+		}
+		err = netstring.NewEncoder(&bytes.Buffer{}).Marshal('z', tc.message)
+		if err == nil || !strings.Contains(err.Error(), tc.expect) {
+			t.Error(ix, "Marshal expected", tc.expect, "got", err)
+		}
+		_, err = netstring.NewDecoder(strings.NewReader("1:z,")).Unmarshal('z', tc.message)
+		if err == nil || !strings.Contains(err.Error(), tc.expect) {
+			t.Error(ix, "Unmarshal expected", tc.expect, "got", err)
+		}
+	}
+}
+
+func TestUnmarshalConstraints(t *testing.T) {
+	type testCase struct {
+		input  string
+		expect string // Empty means valid
+	}
+	testCases := []testCase{
+		{"3:a42,2:u1,1:z,", ""},
+		{"4:a151,1:z,", "Age value 151 is greater than max"},
+		{"2:u0,1:z,", "Count value 0 is less than min"},
+		{"8:nToolong,1:z,", "Name length 7 exceeds maxlen=5"},
+		{"3:cUK,1:z,", "Country value 'UK' is not one of"},
+	}
+	for ix, tc := range testCases {
+		var c constrained
+		dec := netstring.NewDecoder(strings.NewReader(tc.input))
+		_, err := dec.Unmarshal('z', &c)
+		if len(tc.expect) == 0 {
+			if err != nil {
+				t.Error(ix, "Unexpected error", err)
+			}
+			continue
+		}
+		if !errors.Is(err, netstring.ErrFieldConstraint) || !strings.Contains(err.Error(), tc.expect) {
+			t.Error(ix, "Expected", tc.expect, "got", err)
+		}
+	}
+}
+
+func TestMarshalWithValidation(t *testing.T) {
+	c := constrained{Age: 200, Count: 1, Country: []byte("AU")}
+	var bbuf bytes.Buffer
+	err := netstring.NewEncoder(&bbuf).Marshal('z', &c)
+	if err != nil {
+		t.Error("Marshal should not validate by default", err)
+	}
+
+	bbuf.Reset()
+	err = netstring.NewEncoder(&bbuf, netstring.WithValidation()).Marshal('z', &c)
+	if !errors.Is(err, netstring.ErrFieldConstraint) {
+		t.Error("Expected ErrFieldConstraint, not", err)
+	}
+	if bbuf.Len() != 0 {
+		t.Error("Nothing should be encoded when validation fails", bbuf.String())
+	}
+}
@@ -0,0 +1,160 @@
+package netstring
+
+import (
+	"sort"
+	"sync"
+)
+
+/*
+Coverage records which keys of a protocol are actually used over a period so that teams
+can safely prune dead protocol fields. A Coverage is constructed from a Schema with
+[NewSchemaCoverage] or from a Registry with [NewRegistryCoverage], fed each message with
+Observe and queried with Report, typically after running for some days in production:
+
+	cov := netstring.NewRegistryCoverage(reg)
+	...
+	m, err := dec.ReadMessage('z')
+	cov.Observe(&m)
+	...
+	for _, fc := range cov.Report() {
+		if fc.NeverSeen() || fc.AlwaysEmpty() {
+			log.Println("Candidate for removal:", fc.Type, fc.Name)
+		}
+	}
+
+A Coverage is safe for concurrent use.
+*/
+type Coverage struct {
+	typeKey Key // NoKey for a Schema
+	mu      sync.Mutex
+	types   map[string]*typeCoverage // By message type; "" for a Schema
+}
+
+type typeCoverage struct {
+	messages int64
+	fields   []FieldCoverage // In definition order
+}
+
+// FieldCoverage is the usage of a single field as returned by Coverage.Report.
+type FieldCoverage struct {
+	Type     string // Registered message type, empty for a Schema
+	Key      Key
+	Name     string // Schema field name or struct field name
+	Messages int64  // Messages of this type observed
+	Seen     int64  // Messages which contained the key
+	Empty    int64  // Messages in which every value of the key was empty
+}
+
+// NeverSeen returns true if the key has not been observed in any message.
+func (fc FieldCoverage) NeverSeen() bool {
+	return fc.Seen == 0
+}
+
+// AlwaysEmpty returns true if the key has been observed but only ever with empty values.
+func (fc FieldCoverage) AlwaysEmpty() bool {
+	return fc.Seen > 0 && fc.Empty == fc.Seen
+}
+
+// NewSchemaCoverage constructs a Coverage of the fields defined by "s".
+func NewSchemaCoverage(s *Schema) *Coverage {
+	tc := &typeCoverage{}
+	for _, sf := range s.Fields {
+		tc.fields = append(tc.fields, FieldCoverage{Key: sf.Key, Name: sf.Name})
+	}
+
+	return &Coverage{types: map[string]*typeCoverage{"": tc}}
+}
+
+// NewRegistryCoverage constructs a Coverage of the tagged fields of every type registered
+// with "reg". Types registered after the Coverage is constructed are not covered.
+// Messages are attributed to a type by the value of their message-type netstring.
+func NewRegistryCoverage(reg *Registry) *Coverage {
+	c := &Coverage{typeKey: reg.typeKey, types: make(map[string]*typeCoverage)}
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	for name, rt := range reg.types {
+		tc := &typeCoverage{}
+		for ix := 0; ix < rt.NumField(); ix++ {
+			sf := rt.Field(ix)
+			tag, _ := splitTag(sf.Tag.Get("netstring"))
+			if sf.IsExported() && len(tag) == 1 {
+				tc.fields = append(tc.fields, FieldCoverage{Type: name, Key: Key(tag[0]),
+					Name: sf.Name})
+			}
+		}
+		c.types[name] = tc
+	}
+
+	return c
+}
+
+// Observe records the keys present in "m". Messages of an unknown type are ignored, as are
+// keys which are not covered.
+func (c *Coverage) Observe(m *Message) {
+	name := ""
+	if c.typeKey != NoKey {
+		v, ok := m.Get(c.typeKey)
+		if !ok {
+			return
+		}
+		name = string(v)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tc, ok := c.types[name]
+	if !ok {
+		return
+	}
+	tc.messages++
+	for ix := range tc.fields {
+		fc := &tc.fields[ix]
+		vals := m.GetAll(fc.Key)
+		if len(vals) == 0 {
+			continue
+		}
+		fc.Seen++
+		empty := true
+		for _, v := range vals {
+			empty = empty && len(v) == 0
+		}
+		if empty {
+			fc.Empty++
+		}
+	}
+}
+
+// Report returns the coverage of every field ordered by message type then by the order
+// in which fields are defined.
+func (c *Coverage) Report() []FieldCoverage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := make([]string, 0, len(c.types))
+	for name := range c.types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var fcs []FieldCoverage
+	for _, name := range names {
+		tc := c.types[name]
+		for _, fc := range tc.fields {
+			fc.Messages = tc.messages
+			fcs = append(fcs, fc)
+		}
+	}
+
+	return fcs
+}
+
+// Reset discards all observations so that a new period can begin.
+func (c *Coverage) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, tc := range c.types {
+		tc.messages = 0
+		for ix := range tc.fields {
+			tc.fields[ix].Seen, tc.fields[ix].Empty = 0, 0
+		}
+	}
+}
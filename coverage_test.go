@@ -0,0 +1,82 @@
+package netstring_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+// summarize returns the coverage report in a compact form.
+func summarize(fcs []netstring.FieldCoverage) string {
+	var parts []string
+	for _, fc := range fcs {
+		s := fmt.Sprintf("%s%s:%d/%d/%d", fc.Type, fc.Key, fc.Messages, fc.Seen, fc.Empty)
+		if fc.NeverSeen() {
+			s += "N"
+		}
+		if fc.AlwaysEmpty() {
+			s += "E"
+		}
+		parts = append(parts, s)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func TestSchemaCoverage(t *testing.T) {
+	s, err := netstring.ParseSchema(strings.NewReader(loginSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cov := netstring.NewSchemaCoverage(s)
+	dec := newWith("2:tL,4:uBob,1:p,1:z,2:tL,4:uAnn,1:a,3:a42,1:z,")
+	for ix := 0; ix < 2; ix++ {
+		m, err := dec.ReadMessage('z')
+		if err != nil {
+			t.Fatal(ix, err)
+		}
+		cov.Observe(&m)
+	}
+
+	exp := "t:2/2/0 u:2/2/0 a:2/1/0 h:2/0/0N v:2/0/0N p:2/1/1E"
+	if got := summarize(cov.Report()); got != exp {
+		t.Error("Report got", got, "expected", exp)
+	}
+
+	cov.Reset()
+	exp = "t:0/0/0N u:0/0/0N a:0/0/0N h:0/0/0N v:0/0/0N p:0/0/0N"
+	if got := summarize(cov.Report()); got != exp {
+		t.Error("Reset got", got, "expected", exp)
+	}
+}
+
+func TestRegistryCoverage(t *testing.T) {
+	type login struct {
+		User string `netstring:"u"`
+		Pass string `netstring:"p"`
+		Note string // Not covered
+	}
+	type logout struct {
+		User string `netstring:"u"`
+	}
+	reg := netstring.NewRegistry('M')
+	reg.Register("in", login{})
+	reg.Register("out", &logout{})
+	cov := netstring.NewRegistryCoverage(reg)
+
+	dec := newWith("3:Min,4:uBob,1:p,1:z,4:Mout,4:uBob,1:z,4:Mbad,1:u,1:z,4:uBob,1:z,")
+	for ix := 0; ix < 4; ix++ {
+		m, err := dec.ReadMessage('z')
+		if err != nil {
+			t.Fatal(ix, err)
+		}
+		cov.Observe(&m)
+	}
+
+	exp := "inu:1/1/0 inp:1/1/1E outu:1/1/0"
+	if got := summarize(cov.Report()); got != exp {
+		t.Error("Report got", got, "expected", exp)
+	}
+}
@@ -0,0 +1,124 @@
+package netstring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// EncodeBytesContext is the same as EncodeBytes excepting that encoding is abandoned if
+// "ctx" is done. If the io.Writer supplied to NewEncoder has a SetWriteDeadline method,
+// as a net.Conn does, the deadline of "ctx" is applied to the writes and a write blocked
+// on a dead peer is interrupted as soon as "ctx" is cancelled. Otherwise "ctx" is only
+// checked before each of the Write calls which make up the netstring.
+//
+// If "ctx" is done before anything is written, nothing is written and ctx.Err() is
+// returned. If "ctx" is done part way through, the returned error wraps ctx.Err() and,
+// as a partial netstring has been written, the output stream is in an indeterminate state
+// and should be abandoned.
+//
+// The write deadline is cleared on return. With WithLocking, concurrent context-aware calls
+// are serialized so that each applies its own "ctx" and deadline.
+func (enc *Encoder) EncodeBytesContext(ctx context.Context, key Key, val ...[]byte) error {
+	return enc.withContext(ctx, func() error {
+		return enc.EncodeBytes(key, val...)
+	})
+}
+
+// MarshalContext is the context-aware variant of Marshal. "ctx" is applied as described
+// in EncodeBytesContext. As with Marshal, the message is written as a series of
+// netstrings, so with WithLocking "ctx" also applies to netstrings written by other
+// goroutines while MarshalContext is running.
+func (enc *Encoder) MarshalContext(ctx context.Context, eom Key, message any) error {
+	return enc.withContext(ctx, func() error {
+		return enc.Marshal(eom, message)
+	})
+}
+
+// ctxWriter fails each Write once its context is done. failed records any Write error as
+// Marshal does not return the errors of individual netstrings.
+type ctxWriter struct {
+	ctx    context.Context
+	out    io.Writer
+	failed bool
+}
+
+func (cw *ctxWriter) Write(p []byte) (int, error) {
+	if err := cw.ctx.Err(); err != nil {
+		cw.failed = true
+		return 0, err
+	}
+	n, err := cw.out.Write(p)
+	if err != nil {
+		cw.failed = true
+	}
+
+	return n, err
+}
+
+// withContext calls "fn" with "ctx" applied to the Encoder's output. Calls are serialized
+// WithLocking as enc.out and the write deadline are replaced for the duration of "fn".
+func (enc *Encoder) withContext(ctx context.Context, fn func() error) error {
+	if enc.ctxMu != nil {
+		enc.ctxMu.Lock()
+		defer enc.ctxMu.Unlock()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if enc.mu != nil {
+		enc.mu.Lock()
+	}
+	out := enc.out
+	cw := &ctxWriter{ctx: ctx, out: out}
+	enc.out = cw
+	if enc.mu != nil {
+		enc.mu.Unlock()
+	}
+	defer func() {
+		if enc.mu != nil {
+			enc.mu.Lock()
+			defer enc.mu.Unlock()
+		}
+		enc.out = out
+	}()
+
+	if wd, ok := enc.raw.(writeDeadliner); ok {
+		deadline, _ := ctx.Deadline()
+		wd.SetWriteDeadline(deadline)
+		stop := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			select {
+			case <-ctx.Done(): // Interrupt any blocked Write
+				wd.SetWriteDeadline(time.Unix(1, 0))
+			case <-stop:
+			}
+		}()
+		defer func() {
+			close(stop)
+			<-done
+			wd.SetWriteDeadline(time.Time{})
+		}()
+	}
+
+	err := fn()
+	if err == nil && cw.failed {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		cause := ctx.Err()
+		if cause == nil && errors.Is(err, os.ErrDeadlineExceeded) { // Beat ctx to it
+			cause = context.DeadlineExceeded
+		}
+		if cause != nil {
+			return fmt.Errorf(errorPrefix+"Encoder abandoned: %w: %v", cause, err)
+		}
+	}
+
+	return err
+}
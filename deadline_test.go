@@ -0,0 +1,154 @@
+package netstring_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/markdingo/netstring"
+)
+
+// cancellingWriter cancels its context on the first Write.
+type cancellingWriter struct {
+	bytes.Buffer
+	cancel context.CancelFunc
+}
+
+func (cw *cancellingWriter) Write(p []byte) (int, error) {
+	cw.cancel()
+	return cw.Buffer.Write(p)
+}
+
+func TestEncodeBytesContext(t *testing.T) {
+	var bb bytes.Buffer
+	enc := netstring.NewEncoder(&bb)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := enc.EncodeBytesContext(ctx, 'a', []byte("ok")); err != nil {
+		t.Error("Live context", err)
+	}
+	type msg struct {
+		Name string `netstring:"n"`
+	}
+	if err := enc.MarshalContext(ctx, 'z', msg{"bob"}); err != nil {
+		t.Error("MarshalContext", err)
+	}
+	if bb.String() != "3:aok,4:nbob,1:z," {
+		t.Error("Unexpected output", bb.String())
+	}
+	cancel()
+	if err := enc.EncodeBytesContext(ctx, 'a', []byte("no")); err != context.Canceled {
+		t.Error("Expected context.Canceled, got", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cw := &cancellingWriter{cancel: cancel}
+	enc = netstring.NewEncoder(cw) // Abandoned between partial writes
+	err := enc.EncodeBytesContext(ctx, 'a', []byte("abc"))
+	if !errors.Is(err, context.Canceled) {
+		t.Error("Expected wrapped context.Canceled, got", err)
+	}
+	if cw.String() != "4" {
+		t.Error("Expected only the length to be written, got", cw.String())
+	}
+	cw.Reset()
+	if err := enc.EncodeBytes('a', []byte("abc")); err != nil || cw.String() != "4:aabc," {
+		t.Error("Context should not outlive the call", cw.String(), err)
+	}
+}
+
+func TestEncodeBytesContextDeadline(t *testing.T) {
+	c1, c2 := net.Pipe() // Nobody reads c2 so writes to c1 block
+	defer c1.Close()
+	defer c2.Close()
+	enc := netstring.NewEncoder(c1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := enc.EncodeBytesContext(ctx, 'a', []byte("stuck"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("Expected wrapped context.DeadlineExceeded, got", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	err = enc.MarshalContext(ctx, 'z', struct {
+		V string `netstring:"v"`
+	}{"stuck"})
+	if !errors.Is(err, context.Canceled) {
+		t.Error("Expected wrapped context.Canceled, got", err)
+	}
+
+	go io.Copy(io.Discard, c2) // Deadline is cleared on return
+	if err := enc.EncodeBytes(netstring.NoKey); err != nil {
+		t.Error("Write deadline not cleared", err)
+	}
+}
+
+// deadlineWriter records whether any Write occurred without a write deadline.
+type deadlineWriter struct {
+	mu       sync.Mutex
+	deadline time.Time
+	missed   bool
+}
+
+func (dw *deadlineWriter) Write(p []byte) (int, error) {
+	runtime.Gosched() // Widen the window for concurrent calls
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+	if dw.deadline.IsZero() {
+		dw.missed = true
+	}
+	return len(p), nil
+}
+
+func (dw *deadlineWriter) SetWriteDeadline(t time.Time) error {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+	dw.deadline = t
+	return nil
+}
+
+func TestEncodeBytesContextLocking(t *testing.T) {
+	dw := &deadlineWriter{}
+	enc := netstring.NewEncoder(dw, netstring.WithLocking())
+	var wg sync.WaitGroup
+	for ix := 0; ix < 4; ix++ {
+		wg.Add(1)
+		go func(ix int) {
+			defer wg.Done()
+			for jx := 0; jx < 200; jx++ {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+				var err error
+				if ix%2 == 0 {
+					err = enc.EncodeBytesContext(ctx, 'a', []byte("abc"))
+				} else {
+					err = enc.MarshalContext(ctx, 'z', struct {
+						V string `netstring:"v"`
+					}{"abc"})
+				}
+				cancel() // Must not affect subsequent calls
+				if err != nil {
+					t.Error(ix, jx, err)
+					return
+				}
+			}
+		}(ix)
+	}
+	wg.Wait()
+
+	dw.mu.Lock()
+	if dw.missed {
+		t.Error("A concurrent call cleared the write deadline of another")
+	}
+	dw.mu.Unlock()
+	dw.SetWriteDeadline(time.Time{})
+	if err := enc.EncodeString('a', "plain"); err != nil {
+		t.Error("Encoder left bound to a cancelled context", err)
+	}
+}
@@ -1,7 +1,14 @@
 package netstring
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"fmt"
 	"io"
+	"math"
+	"net"
+	"strings"
 )
 
 // parseState represents the state transitions for parsing a netstring. Different
@@ -14,6 +21,9 @@ const (
 	parseColon
 	parseValue // ns.value
 	parseComma
+	parseDiscard // Skipping an oversized value, discardRemaining
+	parsePending // Length refused by DecodeAtMost, value not yet consumed
+	parseStream  // Value being read via ValueReader, streamRemaining
 )
 
 // Only used for debugging purposes
@@ -29,6 +39,12 @@ func (t parseState) String() string {
 		return "parseValue"
 	case parseComma:
 		return "parseComma"
+	case parseDiscard:
+		return "parseDiscard"
+	case parsePending:
+		return "parsePending"
+	case parseStream:
+		return "parseStream"
 	}
 
 	return "Bizarre parseState"
@@ -51,7 +67,8 @@ available and [Unmarshal] is used to decoded a complete "message" containing a s
 "keyed" netstrings (including an end-of-message sentinel) into a "basic-struct".
 
 It is often good practice to wrap the input [io.Reader] in a [bufio.Reader] as this can
-improve parsing performance.
+improve parsing performance. NewDecoder does this automatically for a [net.Conn]; see
+[WithoutAutoBuffering].
 
 If the Decoder detects a malformed netstring, it stops parsing, returns an error and
 effective stops all future parsing for that byte stream because once synchronization is
@@ -65,20 +82,151 @@ valid netstring or message.
 */
 type Decoder struct {
 	rdr     io.Reader
-	buf     [1024]byte // Staging area for yet-to-be-parsed bytes from io.Reader
-	at, end int        // Current and last byte of buf not yet parsed
+	counter *readCounter // Wraps the io.Reader supplied to NewDecoder
+	buf     [1024]byte   // Staging area for yet-to-be-parsed bytes from io.Reader
+	at, end int          // Current and last byte of buf not yet parsed
 
 	parseError      error // Once a parse error has occurred, all bets are off forever
 	state           parseState
 	length          int    // Currently computed netstring length
 	lengthValueRead int    // How many bytes of value have we read thus far?
 	inProgress      []byte // The currently-being-parsed netstring
+	progress        ProgressFunc
+	statsHook       func(KeyStats)
+	keyPolicy       *KeyPolicy
+	maxLength       int // Maximum value length accepted
+	trace           TraceFunc
+	announce        AnnounceFunc
+	soft            *softState    // nil unless WithSoftLimits
+	watchdog        *watchdog     // nil unless WithWatchdog
+	mac             *decoderMAC   // nil unless WithHMAC
+	budget          *budgetHolder // nil unless WithMemoryBudget
+	interner        *Interner     // nil unless WithInterning
+	registry        *Registry     // nil unless WithRegistry
+	escaping        bool          // Apply UnescapeValue to every value
+	foldKeys        bool          // Unmarshal matches keys case-insensitively
+	padding         string        // Skipped between netstrings. See WithFramePadding
+	longKeys        bool          // Keys are followed by a separator. See WithLongKeys
+	nulPolicy       NULPolicy
+	traceSampler    sampler
+	statsSampler    sampler
+	statsSampled    bool // Current message is sampled for the stats hook
+
+	discardOversized bool // Skip oversized netstrings rather than failing
+	discarding       bool // Current netstring is oversized and being skipped
+	discardOnly      bool // Return from parse() as soon as the skip completes
+	discardRemaining int  // Bytes of oversized value yet to be skipped
+
+	atMost          int // Limit set by DecodeAtMost, -1 otherwise
+	streamRemaining int // Bytes of value yet to be read via ValueReader
 }
 
 // NewDecoder constructs a Decoder which accepts a byte stream via its io.Reader interface
-// and presents decoded netstrings via Decode(), DecodeKeyed() and Unmarshal()
-func NewDecoder(rdr io.Reader) *Decoder {
-	return &Decoder{rdr: rdr}
+// and presents decoded netstrings via Decode(), DecodeKeyed() and Unmarshal(). The
+// behaviour of the Decoder can be modified with Options such as WithMaximumLength.
+func NewDecoder(rdr io.Reader, opts ...Option) *Decoder {
+	o := applyOptions(opts)
+	counter := &readCounter{rdr: rdr}
+	rdr = counter
+	if o.bufferSize > 0 {
+		rdr = bufio.NewReaderSize(rdr, o.bufferSize)
+	} else if _, ok := counter.rdr.(net.Conn); ok && !o.noAutoBuffer {
+		rdr = bufio.NewReaderSize(rdr, AutoBufferSize)
+	}
+
+	dec := &Decoder{rdr: rdr, counter: counter, atMost: -1, maxLength: o.maxLength, discardOversized: o.discardOversized,
+		progress: o.progress, statsHook: o.statsHook, keyPolicy: o.keyPolicy, trace: o.trace,
+		announce: o.announce, escaping: o.escaping, foldKeys: o.foldKeys, padding: o.padding,
+		longKeys: o.longKeys, nulPolicy: o.nulPolicy, traceSampler: sampler{every: o.sampleEvery},
+		statsSampler: sampler{every: o.sampleEvery}, interner: o.interner, registry: o.registry}
+	if o.softLimits != nil {
+		dec.soft = &softState{limits: *o.softLimits}
+	}
+	dec.watchdog = newWatchdog(&o)
+	if o.budget != nil {
+		dec.budget = o.budget.newHolder(counter.rdr)
+	}
+	if o.hmacHash != nil {
+		dec.mac = &decoderMAC{mac: hmac.New(o.hmacHash, o.hmacKey)}
+	}
+
+	return dec
+}
+
+// ReadStats counts the Read calls made to the io.Reader supplied to NewDecoder. Comparing
+// Bytes to Reads shows how well reads are being coalesced: a low average suggests the
+// Decoder would benefit from WithBuffering.
+type ReadStats struct {
+	Reads int64 // Number of Read calls
+	Bytes int64 // Total bytes returned by those calls
+}
+
+// readCounter is the io.Reader wrapper which accumulates ReadStats.
+type readCounter struct {
+	rdr   io.Reader
+	stats ReadStats
+}
+
+func (rc *readCounter) Read(p []byte) (int, error) {
+	n, err := rc.rdr.Read(p)
+	rc.stats.Reads++
+	rc.stats.Bytes += int64(n)
+
+	return n, err
+}
+
+// consumed returns the number of bytes read from the io.Reader which have been parsed. It
+// is only exact if the Decoder is unbuffered.
+func (dec *Decoder) consumed() int64 {
+	return dec.counter.stats.Bytes - int64(dec.end-dec.at)
+}
+
+// ReadStats returns the statistics of Read calls made to the io.Reader supplied to
+// NewDecoder. When the Decoder is buffered, these are the Reads made by the bufio.Reader.
+func (dec *Decoder) ReadStats() ReadStats {
+	return dec.counter.stats
+}
+
+// MaximumLength returns the maximum value length this Decoder accepts, as set by
+// WithMaximumLength.
+func (dec *Decoder) MaximumLength() int {
+	return dec.maxLength
+}
+
+// SetProgress arranges for "fn" to be called as bytes of each netstring value are read
+// from the io.Reader so that applications can report on the transfer of large
+// values. Passing nil disables progress reporting. See ProgressFunc for details.
+func (dec *Decoder) SetProgress(fn ProgressFunc) {
+	dec.progress = fn
+}
+
+// SetStatsHook arranges for "fn" to be called with the KeyStats of every message
+// completed by ReadMessage or Unmarshal. Passing nil disables the hook. When no hook is
+// set, no statistics are gathered.
+//
+// The hook is called synchronously just before the message is returned to the caller.
+func (dec *Decoder) SetStatsHook(fn func(KeyStats)) {
+	dec.statsHook = fn
+}
+
+// wantStats returns true if message-level functions need to gather KeyStats for
+// endOfMessage. It must be called exactly once per message as it makes the sampling
+// decision for the stats hook.
+func (dec *Decoder) wantStats() bool {
+	dec.statsSampled = dec.statsHook != nil && dec.statsSampler.next()
+
+	return dec.statsSampled || dec.soft != nil
+}
+
+// endOfMessage is called by message-level functions once a complete message has been
+// decoded.
+func (dec *Decoder) endOfMessage(stats KeyStats) {
+	if dec.soft != nil {
+		dec.soft.checkMessage(stats)
+	}
+	if dec.statsSampled {
+		dec.statsHook(stats)
+	}
 }
 
 // parse picks up parsing from where it last left off and consumes bytes from the
@@ -96,13 +244,16 @@ func NewDecoder(rdr io.Reader) *Decoder {
 // netstring is nil. The reason for this slightly non idiomatic approach is that we want to
 // make the error "sticky" *after* the error as it could be, e.g., io.EOF which should only
 // be noticed after all bytes have been parsed.
-func (dec *Decoder) parse() (good []byte) {
+//
+// The "temporary" return is only ever set to a non-sticky error which applies to the
+// current netstring alone, such as ErrValueTooLarge, after which parsing can continue.
+func (dec *Decoder) parse() (good []byte, temporary error) {
 	if dec.parseError != nil {
 		return
 	}
 	for { // Parse until error, EOF or netstring found
 		if dec.at == dec.end { // Buffer empty?
-			dec.end, dec.parseError = dec.rdr.Read(dec.buf[:])
+			dec.fill()
 			if dec.end == 0 { // dec.parseError better not be nil!
 				return
 			}
@@ -117,10 +268,20 @@ func (dec *Decoder) parse() (good []byte) {
 				b = dec.buf[dec.at]
 				dec.at++
 				if b < '0' || b > '9' { // A length digit?
+					if len(dec.padding) > 0 && strings.IndexByte(dec.padding, b) >= 0 {
+						continue // Inter-frame padding
+					}
 					dec.parseError = ErrLengthNotDigit
 					return
 				}
 				dec.length = int(b - '0')
+				if dec.lengthExceeded() {
+					dec.parseError = ErrLengthToLong
+					return
+				}
+				if dec.watchdog != nil {
+					dec.watchdog.begin()
+				}
 				dec.state = parseLength
 
 			case parseLength: // Second and subsequent length bytes
@@ -132,11 +293,16 @@ func (dec *Decoder) parse() (good []byte) {
 						return
 					}
 
-					dec.length = dec.length*10 + int(b-'0')
-					if dec.length > MaximumLength {
+					if dec.digitExceeds(b) {
 						dec.parseError = ErrLengthToLong
 						return
 					}
+					var ok bool
+					dec.length, ok = appendDigit(dec.length, b)
+					if !ok {
+						dec.parseError = ErrLengthOverflow
+						return
+					}
 					continue
 				}
 
@@ -148,20 +314,68 @@ func (dec *Decoder) parse() (good []byte) {
 					dec.parseError = ErrColonExpected
 					return
 				}
+				if dec.soft != nil {
+					dec.soft.checkValue(dec.length)
+				}
+				if dec.announce != nil {
+					dec.parseError = dec.announce(dec.length)
+					if dec.parseError != nil {
+						return
+					}
+				}
+				if dec.length > dec.maxLength { // Only possible if discardOversized
+					dec.discarding = true
+					dec.discardRemaining = dec.length
+					dec.state = parseDiscard
+					temporary = ErrValueTooLarge
+					return
+				}
+				if dec.atMost >= 0 && dec.length > dec.atMost {
+					dec.state = parsePending
+					temporary = ErrValueTooLarge
+					return
+				}
+				if dec.budget != nil {
+					dec.parseError = dec.budget.reserve(dec.length)
+					if dec.parseError != nil {
+						return
+					}
+				}
 				dec.inProgress = make([]byte, dec.length) // Container to return to caller
 				dec.state = parseValue
 
+			case parsePending: // Caller chose to accept the value after all
+				if dec.budget != nil {
+					dec.parseError = dec.budget.reserve(dec.length)
+					if dec.parseError != nil {
+						return
+					}
+				}
+				dec.inProgress = make([]byte, dec.length)
+				dec.state = parseValue
+
+			case parseStream: // Caller abandoned the ValueReader so skip the rest
+				dec.discarding = true
+				dec.discardRemaining = dec.streamRemaining
+				dec.state = parseDiscard
+
 			case parseValue:
 				vr := dec.lengthValueRead // Current value length
 				want := dec.length - vr   // How many bytes to complete the value?
 				got := copy(dec.inProgress[vr:vr+want], dec.buf[dec.at:dec.end])
 				dec.at += got
 				dec.lengthValueRead += got
+				if dec.progress != nil && got > 0 {
+					dec.progress(int64(dec.lengthValueRead), int64(dec.length))
+				}
 				if got == want { // Did we get all remaining bytes for this value?
 					dec.state = parseComma // Yep, transition to next state
 				}
 
 			case parseComma:
+				if dec.budget != nil {
+					dec.budget.release() // Either the caller owns the value or it's an error
+				}
 				b = dec.buf[dec.at]
 				dec.at++
 				if b != trailingComma {
@@ -176,9 +390,60 @@ func (dec *Decoder) parse() (good []byte) {
 				dec.state = parseFirstByte
 				dec.length = 0
 				dec.lengthValueRead = 0
+				if dec.discarding { // Nothing to return so keep parsing
+					dec.discarding = false
+					if dec.discardOnly {
+						return
+					}
+					continue
+				}
+				if dec.escaping || dec.nulPolicy == NULEscape {
+					good, temporary = UnescapeValue(good)
+					if temporary != nil {
+						return
+					}
+				}
+				if dec.nulPolicy == NULReject && bytes.IndexByte(good, 0) >= 0 {
+					return nil, ErrNULValue
+				}
+				if dec.trace != nil && dec.traceSampler.next() {
+					dec.trace(good)
+				}
 				return
+
+			case parseDiscard:
+				got := dec.end - dec.at
+				if got > dec.discardRemaining {
+					got = dec.discardRemaining
+				}
+				dec.at += got
+				dec.discardRemaining -= got
+				if dec.discardRemaining == 0 {
+					dec.state = parseComma
+				}
+			}
+		}
+	}
+}
+
+// next returns the next netstring from parse. If the Decoder was constructed WithHMAC,
+// trailers are verified and skipped.
+func (dec *Decoder) next() ([]byte, error) {
+	for {
+		ns, err := dec.parse()
+		if ns == nil || dec.mac == nil {
+			return ns, err
+		}
+		if trailer, ok := dec.hmacTrailer(ns); ok {
+			if !dec.macVerify(trailer) {
+				dec.parseError = ErrBadHMAC
+				return nil, nil
 			}
+			continue
 		}
+		dec.macAdd(ns)
+
+		return ns, err
 	}
 }
 
@@ -191,8 +456,8 @@ func (dec *Decoder) parse() (good []byte) {
 // The [DecodeKeyed] function is better suited if the application is using "keyed"
 // netstrings.
 func (dec *Decoder) Decode() (ns []byte, err error) {
-	ns = dec.parse()
-	if ns != nil {
+	ns, err = dec.next()
+	if ns != nil || err != nil {
 		return // Do not look at parseError until all netstrings consumed
 	}
 
@@ -215,9 +480,13 @@ func (dec *Decoder) Decode() (ns []byte, err error) {
 //
 // This function returns non-persistent errors if a non-keyed netstring is parsed. A
 // non-keyed netstring is either zero length or the first byte is not an isalpha() key
-// value.
+// value. ErrKeyNotPermitted is returned if the key is rejected by the KeyPolicy set with
+// SetKeyPolicy.
 func (dec *Decoder) DecodeKeyed() (Key, []byte, error) {
-	ns := dec.parse()
+	ns, err := dec.next()
+	if err != nil {
+		return NoKey, nil, err
+	}
 	if ns == nil {
 		return NoKey, nil, dec.parseError
 	}
@@ -234,6 +503,69 @@ func (dec *Decoder) DecodeKeyed() (Key, []byte, error) {
 	if !keyed { // Caller is expecting a "keyed" netstring
 		return NoKey, nil, ErrInvalidKey
 	}
+	val := ns[1:]
+	if dec.longKeys {
+		lk, lv, err := splitLongKey(ns)
+		if err != nil {
+			return NoKey, nil, err
+		}
+		if len(lk) > 1 {
+			return NoKey, nil, fmt.Errorf("%w: long key '%s' requires DecodeLongKeyed",
+				ErrInvalidKey, lk)
+		}
+		val = lv
+	}
+	if dec.keyPolicy != nil && !dec.keyPolicy.Permits(key) {
+		if dec.keyPolicy.Terminate {
+			dec.parseError = ErrKeyNotPermitted
+		}
+		return NoKey, nil, ErrKeyNotPermitted
+	}
+
+	return key, val, nil
+}
+
+// lengthExceeded returns true if the length accumulated thus far makes the netstring
+// unacceptable. Oversized netstrings are acceptable if they are to be discarded.
+func (dec *Decoder) lengthExceeded() bool {
+	return dec.length > dec.maxLength && !dec.discardOversized
+}
+
+// digitExceeds is the overflow-safe equivalent of appending the digit "b" to the length
+// then calling lengthExceeded. A limit of math.MaxInt is only exceeded by overflow so it
+// is left to appendDigit. The length is never zero here as leading zeros are rejected.
+func (dec *Decoder) digitExceeds(b byte) bool {
+	if dec.discardOversized || dec.maxLength == math.MaxInt {
+		return false
+	}
+
+	return dec.length > (dec.maxLength-int(b-'0'))/10
+}
+
+// DiscardValue immediately skips the remainder of an oversized netstring for which
+// ErrValueTooLarge was just returned. It is only meaningful for a Decoder constructed with
+// WithDiscardOversized or after DecodeAtMost refused a netstring. With
+// WithDiscardOversized, calling DiscardValue is optional as the next Decode*() call skips
+// any oversized value automatically; DiscardValue merely allows the caller to control when
+// the bytes are consumed from the io.Reader.
+//
+// DiscardValue returns nil if there is nothing to skip or the value was skipped
+// successfully, otherwise it returns the error which prevented the skip.
+func (dec *Decoder) DiscardValue() error {
+	if dec.state == parsePending {
+		dec.discarding = true
+		dec.discardRemaining = dec.length
+		dec.state = parseDiscard
+	}
+	if !dec.discarding {
+		return nil
+	}
+	dec.discardOnly = true
+	dec.parse()
+	dec.discardOnly = false
+	if dec.discarding {
+		return dec.parseError
+	}
 
-	return key, ns[1:], nil
+	return nil
 }
@@ -3,6 +3,10 @@ package netstring_test
 import (
 	"bytes"
 	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/markdingo/netstring"
@@ -234,3 +238,175 @@ func TestDecodeKeyedWithNil(t *testing.T) {
 		t.Error("Expected EOF from empty parse but got", k, v, e)
 	}
 }
+
+func TestDecoderDiscardOversized(t *testing.T) {
+	mr := newMyReader()
+	dec := netstring.NewDecoder(mr, netstring.WithMaximumLength(5), netstring.WithDiscardOversized())
+	mr.set([]byte("3:abc,12:abcdef"))
+	mr.set([]byte("ghijkl,2:xy,10:0123456789,1:z,"))
+	mr.close()
+
+	exp := []struct {
+		val string
+		err error
+	}{
+		{"abc", nil},
+		{"", netstring.ErrValueTooLarge},
+		{"xy", nil},
+		{"", netstring.ErrValueTooLarge},
+		{"z", nil},
+		{"", io.EOF},
+	}
+	for ix, e := range exp {
+		if ix == 3 {
+			if err := dec.DiscardValue(); err != nil { // Explicit discard
+				t.Fatal("DiscardValue failed", err)
+			}
+		}
+		ns, err := dec.Decode()
+		if err != e.err || string(ns) != e.val {
+			t.Error(ix, "Got", string(ns), err, "expected", e.val, e.err)
+		}
+	}
+
+	if err := dec.DiscardValue(); err != nil {
+		t.Error("DiscardValue with nothing pending returned", err)
+	}
+
+	// Oversized value with bad terminator is still fatal
+	dec = netstring.NewDecoder(bytes.NewBufferString("6:abcdefX1:z,"),
+		netstring.WithMaximumLength(5), netstring.WithDiscardOversized())
+	_, err := dec.Decode()
+	if err != netstring.ErrValueTooLarge {
+		t.Fatal("Expected ErrValueTooLarge, not", err)
+	}
+	err = dec.DiscardValue()
+	if err != netstring.ErrCommaExpected {
+		t.Error("Expected ErrCommaExpected from DiscardValue, not", err)
+	}
+	_, err = dec.Decode()
+	if err != netstring.ErrCommaExpected {
+		t.Error("Expected permanent ErrCommaExpected, not", err)
+	}
+
+	// Without the option, oversized remains permanent
+	dec = netstring.NewDecoder(bytes.NewBufferString("6:abcdef,1:z,"), netstring.WithMaximumLength(5))
+	_, err = dec.Decode()
+	if err != netstring.ErrLengthToLong {
+		t.Error("Expected ErrLengthToLong, not", err)
+	}
+}
+
+func TestDecoderLengthOverflow(t *testing.T) {
+	maxInt := strconv.Itoa(math.MaxInt)
+	pastMaxInt := strconv.FormatUint(uint64(math.MaxInt)+1, 10)
+	testCases := []struct {
+		ns   string
+		opts []netstring.Option
+		err  error
+	}{
+		{pastMaxInt + ":", nil, netstring.ErrLengthToLong},
+		{pastMaxInt + ":", []netstring.Option{netstring.WithMaximumLength(math.MaxInt)},
+			netstring.ErrLengthOverflow},
+		{pastMaxInt + ":", []netstring.Option{netstring.WithDiscardOversized()},
+			netstring.ErrLengthOverflow},
+		{maxInt + "0:", []netstring.Option{netstring.WithDiscardOversized()},
+			netstring.ErrLengthOverflow},
+		{maxInt + ":", []netstring.Option{netstring.WithDiscardOversized()},
+			netstring.ErrValueTooLarge},
+		{"99999999999999999999999:x,", nil, netstring.ErrLengthToLong}, // Limit reached first
+		{"99999999999999999999999:x,", []netstring.Option{netstring.WithMaximumLength(100)},
+			netstring.ErrLengthToLong},
+		{"101:", []netstring.Option{netstring.WithMaximumLength(100)}, netstring.ErrLengthToLong},
+		{"100:x", []netstring.Option{netstring.WithMaximumLength(100)}, io.EOF},
+	}
+
+	for ix, tc := range testCases {
+		dec := netstring.NewDecoder(bytes.NewBufferString(tc.ns), tc.opts...)
+		_, err := dec.Decode()
+		if err != tc.err {
+			t.Error(ix, "Expected", tc.err, "got", err)
+		}
+	}
+
+	enc := netstring.NewEncoder(io.Discard, netstring.WithMaximumLength(math.MaxInt))
+	err := enc.EncodeReader('a', strings.NewReader(""), math.MaxInt64)
+	if err != netstring.ErrValueToLong {
+		t.Error("Expected EncodeReader ErrValueToLong, got", err)
+	}
+}
+
+func TestDecoderAutoBuffering(t *testing.T) {
+	ns := "3000:" + strings.Repeat("x", 3000) + ","
+	testCases := []struct {
+		opts  []netstring.Option
+		reads int64
+	}{
+		{nil, 1}, // Auto-wrapped in an AutoBufferSize bufio.Reader
+		{[]netstring.Option{netstring.WithoutAutoBuffering()}, 3},
+		{[]netstring.Option{netstring.WithBuffering(2048)}, 2},
+	}
+
+	for ix, tc := range testCases {
+		client, server := net.Pipe()
+		go func() {
+			client.Write([]byte(ns))
+			client.Close()
+		}()
+		dec := netstring.NewDecoder(server, tc.opts...)
+		v, err := dec.Decode()
+		if err != nil || len(v) != 3000 {
+			t.Fatal(ix, "Decode failed", len(v), err)
+		}
+		st := dec.ReadStats()
+		if st.Reads != tc.reads || st.Bytes != int64(len(ns)) {
+			t.Error(ix, "ReadStats", st, "expected", tc.reads, len(ns))
+		}
+		server.Close()
+	}
+
+	dec := netstring.NewDecoder(strings.NewReader("1:a,"))
+	dec.Decode()
+	dec.Decode()
+	st := dec.ReadStats()
+	if st.Reads != 2 || st.Bytes != 4 { // Second Read returns io.EOF
+		t.Error("ReadStats of plain reader", st)
+	}
+}
+
+func TestDecoderFramePadding(t *testing.T) {
+	dec := netstring.NewDecoder(strings.NewReader("\r\n3:abc,\n \n2:de,\r\n"),
+		netstring.WithFramePadding(""))
+	for ix, expect := range []string{"abc", "de"} {
+		ns, err := dec.Decode()
+		if err != nil || string(ns) != expect {
+			t.Error(ix, "Got", string(ns), err, "expected", expect)
+		}
+	}
+	if _, err := dec.Decode(); err != io.EOF { // Trailing padding is not a netstring
+		t.Error("Expected io.EOF, got", err)
+	}
+
+	testCases := []struct {
+		input   string
+		padding string
+		expect  error
+	}{
+		{"3 :abc,", "", netstring.ErrColonExpected}, // Strict within frames
+		{"3:abc ,", "", netstring.ErrCommaExpected},
+		{"\t3:abc,", "", netstring.ErrLengthNotDigit}, // Tab is not default padding
+		{"\t3:abc,", "\t", nil},
+		{"1:abc,", "1", netstring.ErrCommaExpected}, // Digits are never padding
+	}
+	for ix, tc := range testCases {
+		dec := netstring.NewDecoder(strings.NewReader(tc.input), netstring.WithFramePadding(tc.padding))
+		_, err := dec.Decode()
+		if err != tc.expect {
+			t.Error(ix, "Expected", tc.expect, "got", err)
+		}
+	}
+
+	if _, err := newWith("\n3:abc,").Decode(); err != netstring.ErrLengthNotDigit {
+		t.Error("Padding should be rejected by default, got", err)
+	}
+}
@@ -0,0 +1,80 @@
+package netstring
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Dump reads a raw byte stream from "rdr" and writes a human-readable, one line per
+// netstring, rendition of the conversation to "out". It is intended as a debugging aid
+// for operators examining production traffic, such as one direction of a TCP connection
+// extracted from a pcap file or bytes captured directly from a socket.
+//
+// Each line contains the byte offset of the netstring within the stream, the length of
+// the value and the value itself rendered with strconv.Quote so that binary and control
+// characters are visible. If "keyed" is true, each netstring is also shown as a "keyed"
+// netstring with the key separated from the rest of the value.
+//
+// If "clock" is not nil, each line is prefixed with the time it returns for the offset of
+// the netstring. Only the caller knows when the bytes were captured, such as from the
+// packet timestamps of a pcap file, so Dump never invents times. For a live connection,
+// a "clock" which returns time.Now() is usually good enough. Example lines for a "keyed"
+// stream without and with a "clock" are:
+//
+//	     37     6 n "Bjorn"
+//	2023-05-17T10:11:12.123456Z      37     6 n "Bjorn"
+//
+// Dump returns nil when "rdr" reaches io.EOF on a netstring boundary, otherwise it
+// returns the error which stopped decoding along with the offset of the offending
+// netstring.
+func Dump(out io.Writer, rdr io.Reader, keyed bool, clock func(offset int) time.Time) error {
+	dec := NewDecoder(rdr)
+	var offset int
+	for {
+		ns, err := dec.Decode()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf(errorPrefix+"Dump stopped at offset %d: %w", offset, err)
+		}
+
+		var ts string
+		if clock != nil {
+			ts = clock(offset).UTC().Format("2006-01-02T15:04:05.000000Z ")
+		}
+		if keyed {
+			key := "-" // Standard netstring or invalid key
+			val := ns
+			if len(ns) > 0 {
+				if k, e := Key(ns[0]).Assess(); k && e == nil {
+					key = Key(ns[0]).String()
+					val = ns[1:]
+				}
+			}
+			_, err = fmt.Fprintf(out, "%s%7d %5d %s %s\n",
+				ts, offset, len(ns), key, strconv.Quote(string(val)))
+		} else {
+			_, err = fmt.Fprintf(out, "%s%7d %5d %s\n",
+				ts, offset, len(ns), strconv.Quote(string(ns)))
+		}
+		if err != nil {
+			return err
+		}
+
+		offset += encodedLength(len(ns))
+	}
+}
+
+// encodedLength returns the number of bytes occupied by a netstring with a value of
+// length "l", including the length digits and delimiters.
+func encodedLength(l int) int {
+	digits := 1
+	for v := l; v >= 10; v /= 10 {
+		digits++
+	}
+
+	return digits + 1 + l + 1
+}
@@ -0,0 +1,70 @@
+package netstring_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestDump(t *testing.T) {
+	var out bytes.Buffer
+	err := netstring.Dump(&out, bytes.NewBufferString("3:a21,8:CIceland,0:,2:$\n,1:z,"), true, nil)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 5 {
+		t.Fatal("Expected 5 lines, got", len(lines), out.String())
+	}
+
+	type testCase struct {
+		line string
+	}
+	testCases := []testCase{
+		{"      0     3 a \"21\""},
+		{"      6     8 C \"Iceland\""},
+		{"     17     0 - \"\""},
+		{"     20     2 - \"$\\n\""},
+		{"     25     1 z \"\""},
+	}
+	for ix, tc := range testCases {
+		if lines[ix] != tc.line {
+			t.Errorf("%d: Got %q, expected %q", ix, lines[ix], tc.line)
+		}
+	}
+
+	out.Reset()
+	err = netstring.Dump(&out, bytes.NewBufferString("3:a21,"), false, nil)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if out.String() != "      0     3 \"a21\"\n" {
+		t.Error("Standard dump wrong", out.String())
+	}
+
+	// Capture times supplied by the caller, such as from a pcap file
+	out.Reset()
+	start := time.Date(2023, 5, 17, 10, 11, 12, 0, time.UTC)
+	clock := func(offset int) time.Time { return start.Add(time.Duration(offset) * time.Millisecond) }
+	err = netstring.Dump(&out, bytes.NewBufferString("3:a21,1:z,"), true, clock)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	exp := "2023-05-17T10:11:12.000000Z       0     3 a \"21\"\n" +
+		"2023-05-17T10:11:12.006000Z       6     1 z \"\"\n"
+	if out.String() != exp {
+		t.Errorf("Timestamped dump wrong. Got %q, expected %q", out.String(), exp)
+	}
+
+	out.Reset()
+	err = netstring.Dump(&out, bytes.NewBufferString("3:a21,03:abc,"), false, nil)
+	if err == nil {
+		t.Fatal("Expected an error from leading zero")
+	}
+	if !strings.Contains(err.Error(), "offset 6") {
+		t.Error("Error does not contain offset", err)
+	}
+}
@@ -1,9 +1,20 @@
 package netstring
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"encoding"
 	"fmt"
+	"hash"
 	"io"
+	"math/big"
+	"net"
+	"net/netip"
 	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
 )
 
 /*
@@ -35,17 +46,256 @@ Almost all error returns will be errors from the underlying io.Writer which tend
 a Write() to a network socket failed.
 */
 type Encoder struct {
-	formatBuffer [40]byte // Easily fits MaximumLength bytes (and 2^64 as well)
-	out          io.Writer
+	formatBuffer  [40]byte // Easily fits MaximumLength bytes (and 2^64 as well)
+	numberBuffer  [40]byte // Formatted value of EncodeNumber
+	out           io.Writer
+	raw           io.Writer // As supplied to NewEncoder or Reset
+	progress      ProgressFunc
+	maxLength     int // Maximum value length accepted
+	trace         TraceFunc
+	validate      bool   // Marshal calls Validate first
+	atomic        bool   // Emit each netstring with a single Write
+	atomicBuffer  []byte // Re-used to assemble each netstring when atomic
+	deterministic bool
+	escaping      bool // Apply EscapeValue to every value
+	nulPolicy     NULPolicy
+	boolFormat    BoolFormat
+	longKeys      bool      // Set by WithLongKeys
+	registry      *Registry // Set by WithRegistry
+	traceSampler  sampler
+	sticky        bool        // Retain the first error. See WithStickyErrors
+	err           error       // The retained error when sticky
+	provenance    *Provenance // Set by WithProvenance
+	pending       bool        // Provenance yet to be emitted
+	mu            *sync.Mutex // Set by WithLocking
+	ctxMu         *sync.Mutex // Serializes context-aware calls WithLocking
+	stats         EncoderStats
+	hook          func(key Key, length int)
+	mac           hash.Hash     // Set by WithHMAC
+	mw            *macWriter    // Tees enc.out into mac
+	macTrailed    bool          // The most recent netstring was an HMAC trailer
+	bw            *bufio.Writer // Set by WithWriteBuffering
+	pretty        bool          // Set by WithPrettyOutput
+	prettyEOM     Key
 }
 
 // NewEncoder constructs a netstring encoder. An Encoder *must* be constructed with
 // NewEncoder otherwise subsequent calls will panic.
 //
 // Each call to a Encode*() function results in a netstring being written to the
-// io.Writer, quite possibly with multiple Write() calls.
-func NewEncoder(output io.Writer) *Encoder {
-	return &Encoder{out: output}
+// io.Writer, quite possibly with multiple Write() calls. The behaviour of the Encoder can
+// be modified with Options such as WithMaximumLength.
+func NewEncoder(output io.Writer, opts ...Option) *Encoder {
+	o := applyOptions(opts)
+
+	enc := &Encoder{out: output, raw: output, maxLength: o.maxLength, progress: o.progress, trace: o.trace,
+		validate: o.validate, atomic: o.atomic, deterministic: o.deterministic,
+		escaping: o.escaping, nulPolicy: o.nulPolicy, boolFormat: o.boolFormat,
+		traceSampler: sampler{every: o.sampleEvery}, sticky: o.sticky, longKeys: o.longKeys,
+		provenance: o.provenance, pending: o.provenance != nil, pretty: o.pretty,
+		prettyEOM: o.prettyEOM, registry: o.registry}
+	if o.locking {
+		enc.mu = &sync.Mutex{}
+		enc.ctxMu = &sync.Mutex{}
+	}
+	if o.writeBufferSize > 0 {
+		enc.bw = bufio.NewWriterSize(output, o.writeBufferSize)
+		enc.out = enc.bw
+	}
+	if o.hmacHash != nil {
+		enc.mac = hmac.New(o.hmacHash, o.hmacKey)
+		enc.mw = &macWriter{out: enc.out, mac: enc.mac}
+		enc.out = enc.mw
+	}
+
+	return enc
+}
+
+// Err returns the first error returned by any Encode*() function or Marshal of an Encoder
+// constructed with WithStickyErrors. It returns nil if no error has occurred or if the
+// Encoder was constructed without WithStickyErrors.
+func (enc *Encoder) Err() error {
+	if enc.mu != nil {
+		enc.mu.Lock()
+		defer enc.mu.Unlock()
+	}
+
+	return enc.err
+}
+
+// stick retains *err as the sticky error if it is the first error of a sticky Encoder.
+// The caller must not hold the lock, if any.
+func (enc *Encoder) stick(err *error) {
+	if enc.mu != nil {
+		enc.mu.Lock()
+		defer enc.mu.Unlock()
+	}
+	if enc.sticky && enc.err == nil && *err != nil {
+		enc.err = *err
+	}
+}
+
+// SetProgress arranges for "fn" to be called as each value is written to the io.Writer so
+// that applications can report on the transfer of large values. Passing nil disables
+// progress reporting. See ProgressFunc for details.
+func (enc *Encoder) SetProgress(fn ProgressFunc) {
+	enc.progress = fn
+}
+
+// SetHook arranges for "fn" to be called after every netstring which is completely
+// written to the io.Writer, with the key and the length of the value excluding the key.
+// "key" is NoKey for standard netstrings. This suits audit logging and protocol tracing
+// without the need to wrap the io.Writer and re-parse the output. Passing nil disables
+// the hook.
+//
+// "fn" is called synchronously, and with the lock held if the Encoder was constructed
+// WithLocking, so it must return promptly and must not call the Encoder.
+func (enc *Encoder) SetHook(fn func(key Key, length int)) {
+	enc.hook = fn
+}
+
+// Reset discards any state of the Encoder and switches it to writing to "w", in the same
+// way as bufio.Writer.Reset. This permits a single Encoder to be re-used, such as across
+// connections or from a sync.Pool, rather than allocating a new Encoder each time.
+//
+// All Options and callbacks supplied at construction or subsequently set are retained,
+// whereas the sticky error, if any, and Stats are cleared and the provenance message of
+// WithProvenance is emitted again before the next netstring. Output buffered by
+// WithWriteBuffering and not yet flushed is discarded.
+func (enc *Encoder) Reset(w io.Writer) {
+	if enc.mu != nil {
+		enc.mu.Lock()
+		defer enc.mu.Unlock()
+	}
+	enc.raw = w
+	if enc.bw != nil {
+		enc.bw.Reset(w)
+		w = enc.bw
+	}
+	enc.out = w
+	if enc.mac != nil {
+		enc.mac.Reset()
+		enc.macTrailed = false
+		enc.mw = &macWriter{out: w, mac: enc.mac}
+		enc.out = enc.mw
+	}
+	enc.err = nil
+	enc.pending = enc.provenance != nil
+	enc.traceSampler.count = 0
+	enc.stats = EncoderStats{}
+}
+
+// discard clears the per-message state of a message abandoned by a MessageBuilder so that
+// the next message is encoded afresh. The sticky error, if any, is cleared, the running
+// HMAC restarts and, if "unsent", any provenance message discarded along with the message
+// is emitted again.
+func (enc *Encoder) discard(unsent bool) {
+	if enc.mu != nil {
+		enc.mu.Lock()
+		defer enc.mu.Unlock()
+	}
+	if enc.mac != nil {
+		enc.mac.Reset()
+		enc.macTrailed = false
+		enc.mw.skip = 0
+	}
+	enc.err = nil
+	if unsent {
+		enc.pending = enc.provenance != nil
+	}
+}
+
+// Flush writes any netstrings buffered by WithWriteBuffering to the io.Writer. Flush
+// does nothing if the Encoder was constructed without WithWriteBuffering.
+func (enc *Encoder) Flush() (err error) {
+	defer enc.stick(&err)
+	if enc.mu != nil {
+		enc.mu.Lock()
+		defer enc.mu.Unlock()
+	}
+	if enc.err != nil {
+		return enc.err
+	}
+	if enc.bw == nil {
+		return nil
+	}
+
+	return enc.bw.Flush()
+}
+
+// EncoderStats are the cumulative counts of netstrings written by an Encoder as returned
+// by Encoder.Stats. Only netstrings which are completely written are counted.
+type EncoderStats struct {
+	Netstrings int64    // Number of netstrings written
+	Bytes      int64    // Total bytes written, including lengths, delimiters and keys
+	Keys       KeyStats // Per-key counts. Standard netstrings are counted under NoKey
+}
+
+// add counts a netstring with a value length of "l", of which the first "prefix" bytes
+// are the key and, WithLongKeys, its separator.
+func (st *EncoderStats) add(key Key, keyed bool, prefix, l int64) {
+	if st.Keys == nil {
+		st.Keys = make(KeyStats)
+	}
+	st.Netstrings++
+	st.Bytes += l + 2       // Value and both delimiters
+	for d := l; ; d /= 10 { // Plus length digits
+		st.Bytes++
+		if d < 10 {
+			break
+		}
+	}
+	if !keyed {
+		key = NoKey
+	}
+	st.Keys.add(key, int(l-prefix))
+}
+
+// written accounts for a completely written netstring with a value length of "l", which
+// includes any key.
+func (enc *Encoder) written(key Key, keyed bool, l int64) {
+	var prefix int64
+	if keyed {
+		prefix = 1
+		if enc.longKeys {
+			prefix++
+		}
+	}
+	enc.stats.add(key, keyed, prefix, l)
+	if enc.mac != nil {
+		enc.macSentinel()
+	}
+	if enc.hook != nil {
+		if keyed {
+			enc.hook(key, int(l-prefix))
+		} else {
+			enc.hook(NoKey, int(l))
+		}
+	}
+}
+
+// MaximumLength returns the maximum value length this Encoder produces, as set by
+// WithMaximumLength. Applications which split large payloads across netstrings use this
+// to size each piece.
+func (enc *Encoder) MaximumLength() int {
+	return enc.maxLength
+}
+
+// Stats returns a copy of the counts of netstrings written by the Encoder since it was
+// constructed or last Reset. This saves wrapping the io.Writer to monitor the volume of
+// traffic per connection.
+func (enc *Encoder) Stats() EncoderStats {
+	if enc.mu != nil {
+		enc.mu.Lock()
+		defer enc.mu.Unlock()
+	}
+	st := enc.stats
+	st.Keys = make(KeyStats, len(enc.stats.Keys))
+	for k, v := range enc.stats.Keys {
+		st.Keys[k] = v
+	}
+
+	return st
 }
 
 // EncodeBytes encodes the variadic arguments as a series of bytes in a single netstring.
@@ -60,20 +310,37 @@ func NewEncoder(output io.Writer) *Encoder {
 //	EncodeBytes('z')
 //
 // generates the appropriate "keyed" netstring.
-func (enc *Encoder) EncodeBytes(key Key, val ...[]byte) error {
-	var l uint64 // Calculate the length of the netstring
+func (enc *Encoder) EncodeBytes(key Key, val ...[]byte) (err error) {
+	defer enc.stick(&err)
+	if enc.mu != nil {
+		enc.mu.Lock()
+		defer enc.mu.Unlock()
+	}
+	if enc.err != nil {
+		return enc.err
+	}
+
+	return enc.encodeBytes(key, val)
+}
+
+// encodeBytes is the guts of EncodeBytes. The caller must hold the lock, if any.
+func (enc *Encoder) encodeBytes(key Key, val [][]byte) (err error) {
+	if enc.pending {
+		err = enc.encodeProvenance()
+		if err != nil {
+			return err
+		}
+	}
 	keyed, err := key.Assess()
 	if err != nil {
 		return err
 	}
-	if keyed {
-		l++
-	}
-	for _, subVal := range val {
-		l += uint64(len(subVal))
+	val, l, err := enc.transform(keyed, val)
+	if err != nil {
+		return err
 	}
-	if l > MaximumLength {
-		return ErrValueToLong
+	if enc.atomic {
+		return enc.encodeAtomic(key, keyed, l, val)
 	}
 
 	// Write the decimal length of the value (via formatBuffer for performance reasons)
@@ -101,12 +368,20 @@ func (enc *Encoder) EncodeBytes(key Key, val ...[]byte) error {
 	}
 
 	// Write the values
+	var done int64 // Bytes of the netstring value written thus far
+	if keyed {
+		done++
+	}
 	for _, subVal := range val {
 		if len(subVal) > 0 {
 			_, err = enc.out.Write(subVal)
 			if err != nil {
 				return fmt.Errorf(errorPrefix+"Encoder write value failed: %w", err)
 			}
+			if enc.progress != nil {
+				done += int64(len(subVal))
+				enc.progress(done, int64(l))
+			}
 		}
 	}
 
@@ -115,6 +390,227 @@ func (enc *Encoder) EncodeBytes(key Key, val ...[]byte) error {
 	if err != nil {
 		return fmt.Errorf(errorPrefix+"Encoder write trailing delimiter failed: %w", err)
 	}
+	err = enc.writePadding(key, keyed, int64(l))
+	if err != nil {
+		return err
+	}
+
+	if enc.trace != nil && enc.traceSampler.next() {
+		ns := make([]byte, 0, l)
+		if keyed {
+			ns = append(ns, byte(key))
+		}
+		for _, subVal := range val {
+			ns = append(ns, subVal...)
+		}
+		enc.trace(ns)
+	}
+	enc.written(key, keyed, int64(l))
+
+	return nil
+}
+
+// transform returns "val" as it is written, with the long key separator, NULPolicy and
+// escaping applied, along with the length of the netstring value. ErrValueToLong is
+// returned if the transformed value exceeds the maximum length.
+func (enc *Encoder) transform(keyed bool, val [][]byte) ([][]byte, uint64, error) {
+	if keyed && enc.longKeys { // The key is followed by its separator
+		val = append([][]byte{longKeySeparator}, val...)
+	}
+	if enc.nulPolicy == NULReject {
+		for _, subVal := range val {
+			if bytes.IndexByte(subVal, 0) >= 0 {
+				return nil, 0, ErrNULValue
+			}
+		}
+	}
+	if enc.escaping || enc.nulPolicy == NULEscape {
+		var joined []byte
+		for _, subVal := range val {
+			joined = append(joined, subVal...)
+		}
+		if enc.escaping {
+			val = [][]byte{EscapeValue(joined)}
+		} else {
+			val = [][]byte{escapeNUL(joined)}
+		}
+	}
+	var l uint64 // Calculate the length of the netstring
+	if keyed {
+		l++
+	}
+	for _, subVal := range val {
+		l += uint64(len(subVal))
+	}
+	if l > uint64(enc.maxLength) {
+		return nil, 0, ErrValueToLong
+	}
+
+	return val, l, nil
+}
+
+// encodeAtomic is the WithAtomicWrites variant of EncodeBytes which assembles the complete
+// netstring in atomicBuffer and writes it with a single Write.
+func (enc *Encoder) encodeAtomic(key Key, keyed bool, l uint64, val [][]byte) error {
+	b := appendNetstring(enc.atomicBuffer[:0], key, keyed, l, val)
+	start := len(b) - int(l) - len(trailingDelimiter)
+	pad := enc.prettyPadding(key, keyed, int64(l))
+	b = append(b, pad...)
+	enc.atomicBuffer = b
+	enc.skipMAC(len(pad))
+
+	_, err := enc.out.Write(b)
+	enc.skipMAC(0)
+	if err != nil {
+		return fmt.Errorf(errorPrefix+"Encoder write netstring failed: %w", err)
+	}
+	if enc.progress != nil && l > 0 && !(keyed && l == 1) { // Only if there is a value
+		enc.progress(int64(l), int64(l))
+	}
+	if enc.trace != nil && enc.traceSampler.next() {
+		enc.trace(b[start : len(b)-len(pad)-len(trailingDelimiter)])
+	}
+	enc.written(key, keyed, int64(l))
+
+	return nil
+}
+
+// EncodeReader encodes exactly "n" bytes read from "r" as the value of a netstring.
+// The length, delimiter and key are written first then the value is streamed from "r" to
+// the io.Writer in chunks so that large values, such as file contents, need not be
+// materialized in memory. Progress, if set, is reported after each chunk. Trace is not
+// called as the value is never held in its entirety.
+//
+// If "r" returns fewer than "n" bytes, the returned error wraps io.ErrUnexpectedEOF. As
+// the length has already been written, the output stream is then in an indeterminate
+// state and should be abandoned.
+func (enc *Encoder) EncodeReader(key Key, r io.Reader, n int64) (err error) {
+	defer enc.stick(&err)
+	if enc.mu != nil {
+		enc.mu.Lock()
+		defer enc.mu.Unlock()
+	}
+	if enc.err != nil {
+		return enc.err
+	}
+
+	return enc.encodeReader(key, r, n)
+}
+
+// encodeReader is the guts of EncodeReader. The caller must hold the lock, if any.
+func (enc *Encoder) encodeReader(key Key, r io.Reader, n int64) (err error) {
+	if enc.pending {
+		err = enc.encodeProvenance()
+		if err != nil {
+			return err
+		}
+	}
+	keyed, err := key.Assess()
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return fmt.Errorf(errorPrefix+"EncodeReader length %d is negative", n)
+	}
+	var prefix int64
+	if keyed {
+		prefix = int64(enc.keyPrefix())
+	}
+	if n > int64(enc.maxLength)-prefix { // Rather than n+prefix which can overflow
+		return ErrValueToLong
+	}
+	l := n + prefix
+	transform := enc.escaping || enc.nulPolicy != NULPass
+	if enc.atomic || transform || enc.longKeys {
+		val := make([]byte, n)
+		c, err := io.ReadFull(r, val)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return fmt.Errorf(errorPrefix+"EncodeReader read %d of %d bytes: %w", c, n, err)
+		}
+		if transform || enc.longKeys {
+			return enc.encodeBytes(key, [][]byte{val})
+		}
+		return enc.encodeAtomic(key, keyed, uint64(l), [][]byte{val})
+	}
+
+	ls := enc.formatBuffer[0:0:len(enc.formatBuffer)]
+	ls = strconv.AppendInt(ls, l, 10)
+	ls = append(ls, leadingDelimiter...)
+	if keyed {
+		ls = append(ls, byte(key))
+	}
+	_, err = enc.out.Write(ls)
+	if err != nil {
+		return fmt.Errorf(errorPrefix+"Encoder write header failed: %w", err)
+	}
+
+	const chunkSize = 32 * 1024
+	done := l - n
+	for done < l {
+		chunk := l - done
+		if chunk > chunkSize {
+			chunk = chunkSize
+		}
+		c, err := io.CopyN(enc.out, r, chunk)
+		done += c
+		if err == io.EOF {
+			return fmt.Errorf(errorPrefix+"EncodeReader read %d of %d bytes: %w",
+				done-(l-n), n, io.ErrUnexpectedEOF)
+		}
+		if err != nil {
+			return fmt.Errorf(errorPrefix+"Encoder copy value failed: %w", err)
+		}
+		if enc.progress != nil {
+			enc.progress(done, l)
+		}
+	}
+
+	_, err = enc.out.Write(trailingDelimiter)
+	if err != nil {
+		return fmt.Errorf(errorPrefix+"Encoder write trailing delimiter failed: %w", err)
+	}
+	err = enc.writePadding(key, keyed, l)
+	if err != nil {
+		return err
+	}
+	enc.written(key, keyed, l)
+
+	return nil
+}
+
+// EncodeKV encodes each KV as a "keyed" netstring in slice order. All keys and value
+// lengths are validated before anything is written so an invalid KV results in an error
+// with nothing written, unlike a series of EncodeBytes calls which may fail part way
+// through. A KV with a Key of NoKey returns ErrNoKey.
+//
+// Errors from the io.Writer can still occur part way through the series.
+func (enc *Encoder) EncodeKV(kvs []KV) (err error) {
+	defer enc.stick(&err)
+	if err = enc.Err(); err != nil {
+		return err
+	}
+	for _, kv := range kvs {
+		keyed, err := kv.Key.Assess()
+		if err != nil {
+			return err
+		}
+		if !keyed {
+			return ErrNoKey
+		}
+		_, _, err = enc.transform(keyed, [][]byte{kv.Value}) // As written by EncodeBytes
+		if err != nil {
+			return err
+		}
+	}
+	for _, kv := range kvs {
+		err := enc.EncodeBytes(kv.Key, kv.Value)
+		if err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
@@ -126,18 +622,53 @@ func (enc *Encoder) EncodeString(key Key, val string) error {
 	return enc.EncodeBytes(key, []byte(val))
 }
 
+// EncodeStrings encodes each element of "vals" as a separate netstring with the same
+// "key", which is the package's way of representing a list. An empty "vals" encodes
+// nothing. Use Message.GetStrings to collect the elements back into a slice. Encoding
+// stops at the first error. "key" must pass Key.Assess() otherwise an error is returned.
+func (enc *Encoder) EncodeStrings(key Key, vals []string) error {
+	for _, val := range vals {
+		err := enc.EncodeString(key, val)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // EncodeBool encodes a boolean value as a netstring. If key == netstring.NoKey a standard
 // netstring is encoded otherwise a "keyed" netstring is encoded. "key" must pass
 // Key.Assess() otherwise an error is returned.
 //
-// Accepted strconv shorthand of 'T' and 'f' represents true and false
-// respectively. Recommended conversion back to boolean is via strconv.ParseBool()
+// Accepted strconv shorthand of 'T' and 'f' represents true and false respectively,
+// unless the Encoder was constructed WithBoolFormat. Recommended conversion back to
+// boolean is via strconv.ParseBool()
 func (enc *Encoder) EncodeBool(key Key, val bool) error {
+	return enc.EncodeBoolFormat(key, val, enc.boolFormat)
+}
+
+// BoolFormat selects the values which represent true and false in the netstrings
+// produced by EncodeBool. All formats are accepted by strconv.ParseBool.
+type BoolFormat int
+
+const (
+	BoolShorthand BoolFormat = iota // "T" and "f", the default
+	BoolWords                       // "true" and "false"
+	BoolDigits                      // "1" and "0"
+)
 
+// EncodeBoolFormat encodes a boolean value as per EncodeBool, but with "format"
+// regardless of WithBoolFormat. An error is returned if "format" is not a known
+// BoolFormat.
+func (enc *Encoder) EncodeBoolFormat(key Key, val bool, format BoolFormat) error {
+	if format < 0 || int(format) >= len(boolValues) {
+		return fmt.Errorf(errorPrefix+"Unknown BoolFormat %d", format)
+	}
 	if val {
-		return enc.EncodeBytes(key, trueByte)
+		return enc.EncodeBytes(key, boolValues[format][0])
 	}
-	return enc.EncodeBytes(key, falseByte)
+	return enc.EncodeBytes(key, boolValues[format][1])
 }
 
 // EncodeInt encodes an int as a netstring using strconv.FormatInt. Recommended conversion
@@ -185,6 +716,9 @@ func (enc *Encoder) EncodeUint64(key Key, val uint64) error {
 // format. Recommended conversion back to float32 is via strconv.ParseFloat(). "key" must
 // pass Key.Assess() otherwise an error is returned.
 func (enc *Encoder) EncodeFloat32(key Key, val float32) error {
+	if enc.deterministic && val == 0 {
+		val = 0 // Drop the sign of negative zero
+	}
 	return enc.EncodeString(key, strconv.FormatFloat(float64(val), 'f', -1, 32))
 }
 
@@ -192,15 +726,157 @@ func (enc *Encoder) EncodeFloat32(key Key, val float32) error {
 // format. Recommended conversion back to float64 is via strconv.ParseFloat(). "key" must
 // pass Key.Assess() otherwise an error is returned.
 func (enc *Encoder) EncodeFloat64(key Key, val float64) error {
+	if enc.deterministic && val == 0 {
+		val = 0 // Drop the sign of negative zero
+	}
 	return enc.EncodeString(key, strconv.FormatFloat(val, 'f', -1, 64))
 }
 
+// EncodeFloat64Format encodes a float64 as a netstring using strconv.FormatFloat with the
+// caller supplied "format" and "prec". This allows formats such as 'e' and 'g' which remain
+// compact for very small or very large magnitudes, unlike the 'f' format used by
+// EncodeFloat64. "format" must be one of the formats accepted by strconv.FormatFloat
+// otherwise an error is returned. Recommended conversion back to float64 is via
+// strconv.ParseFloat() which accepts all formats. "key" must pass Key.Assess() otherwise
+// an error is returned.
+func (enc *Encoder) EncodeFloat64Format(key Key, val float64, format byte, prec int) error {
+	switch format {
+	case 'b', 'e', 'E', 'f', 'g', 'G', 'x', 'X':
+	default:
+		return fmt.Errorf(errorPrefix+"EncodeFloat64Format format '%c' is not valid", format)
+	}
+	if enc.deterministic && val == 0 {
+		val = 0 // Drop the sign of negative zero
+	}
+
+	return enc.EncodeString(key, strconv.FormatFloat(val, format, prec, 64))
+}
+
+// EncodeComplex64 encodes a complex64 as a netstring using strconv.FormatComplex with the
+// 'f' format, e.g. "(1.5-2i)". Recommended conversion back to complex64 is via
+// strconv.ParseComplex() with a bitSize of 64. "key" must pass Key.Assess() otherwise an
+// error is returned.
+func (enc *Encoder) EncodeComplex64(key Key, val complex64) error {
+	return enc.EncodeString(key, strconv.FormatComplex(enc.dropNegativeZero(complex128(val)), 'f', -1, 64))
+}
+
+// EncodeComplex128 encodes a complex128 as a netstring using strconv.FormatComplex with
+// the 'f' format, e.g. "(1.5-2i)". Recommended conversion back to complex128 is via
+// strconv.ParseComplex() with a bitSize of 128. "key" must pass Key.Assess() otherwise an
+// error is returned.
+func (enc *Encoder) EncodeComplex128(key Key, val complex128) error {
+	return enc.EncodeString(key, strconv.FormatComplex(enc.dropNegativeZero(val), 'f', -1, 128))
+}
+
+// dropNegativeZero drops the sign of negative zero from both parts of "val" if the
+// Encoder is deterministic.
+func (enc *Encoder) dropNegativeZero(val complex128) complex128 {
+	if !enc.deterministic {
+		return val
+	}
+	re, im := real(val), imag(val)
+	if re == 0 {
+		re = 0
+	}
+	if im == 0 {
+		im = 0
+	}
+
+	return complex(re, im)
+}
+
+// EncodeBigInt encodes an arbitrary-precision integer as a netstring in its decimal text
+// form. Recommended conversion back to *big.Int is via big.Int.SetString() with base
+// 10. "key" must pass Key.Assess() otherwise an error is returned. A nil "val" returns
+// ErrUnsupportedType.
+func (enc *Encoder) EncodeBigInt(key Key, val *big.Int) (err error) {
+	defer enc.stick(&err)
+	if err = enc.Err(); err != nil {
+		return err
+	}
+	if val == nil {
+		return ErrUnsupportedType
+	}
+
+	return enc.EncodeBytes(key, val.Append(nil, 10))
+}
+
+// EncodeBigFloat encodes an arbitrary-precision float as a netstring using
+// big.Float.Text with the 'f' format and the fewest digits which uniquely represent
+// "val" at its precision. Recommended conversion back to *big.Float is via
+// big.Float.SetString() with a suitable precision set beforehand. "key" must pass
+// Key.Assess() otherwise an error is returned. A nil "val" returns ErrUnsupportedType.
+func (enc *Encoder) EncodeBigFloat(key Key, val *big.Float) (err error) {
+	defer enc.stick(&err)
+	if err = enc.Err(); err != nil {
+		return err
+	}
+	if val == nil {
+		return ErrUnsupportedType
+	}
+
+	return enc.EncodeBytes(key, val.Append(nil, 'f', -1))
+}
+
+// EncodeAddr encodes an IP address as a netstring in its canonical textual form, as
+// returned by netip.Addr.String, e.g. "192.0.2.1" or "2001:db8::1". The zero Addr is
+// encoded as an empty value. Recommended conversion back to netip.Addr is via
+// netip.ParseAddr(). "key" must pass Key.Assess() otherwise an error is returned.
+func (enc *Encoder) EncodeAddr(key Key, addr netip.Addr) error {
+	if !addr.IsValid() {
+		return enc.EncodeBytes(key)
+	}
+
+	return enc.EncodeBytes(key, addr.AppendTo(nil))
+}
+
+// EncodeAddrPort encodes an IP address and port as a netstring in its canonical textual
+// form, as returned by netip.AddrPort.String, e.g. "192.0.2.1:53" or "[2001:db8::1]:53".
+// The zero AddrPort is encoded as an empty value. Recommended conversion back to
+// netip.AddrPort is via netip.ParseAddrPort(). "key" must pass Key.Assess() otherwise an
+// error is returned.
+func (enc *Encoder) EncodeAddrPort(key Key, ap netip.AddrPort) error {
+	if !ap.IsValid() {
+		return enc.EncodeBytes(key)
+	}
+
+	return enc.EncodeBytes(key, ap.AppendTo(nil))
+}
+
+// EncodeTime encodes "t" as a netstring formatted with time.Time.Format using "layout".
+// An empty "layout" means time.RFC3339Nano. "key" must pass Key.Assess() otherwise an
+// error is returned.
+//
+// The time is encoded in its own location, so callers wanting a canonical representation
+// should pass t.UTC(). Recommended conversion back to time.Time is via time.Parse() with
+// the same layout; note that layouts other than RFC3339Nano may lose precision or the
+// location.
+func (enc *Encoder) EncodeTime(key Key, t time.Time, layout string) error {
+	if len(layout) == 0 {
+		layout = time.RFC3339Nano
+	}
+
+	return enc.EncodeString(key, t.Format(layout))
+}
+
 // EncodeByte encodes a single byte as a netstring. "key" must pass Key.Assess() otherwise
 // an error is returned.
 func (enc *Encoder) EncodeByte(key Key, val byte) error {
 	return enc.EncodeBytes(key, []byte{val})
 }
 
+// EncodeRune encodes a single rune as a netstring containing its UTF-8 encoding, thus
+// EncodeRune(0, 'é') produces "2:é,". This is the way to encode a character literal as
+// Encode() receives a rune as an int32 and encodes its integer value. An invalid rune is
+// encoded as utf8.RuneError. Recommended conversion back to a rune is via
+// utf8.DecodeRune(). "key" must pass Key.Assess() otherwise an error is returned.
+func (enc *Encoder) EncodeRune(key Key, r rune) error {
+	var b [utf8.UTFMax]byte
+	n := utf8.EncodeRune(b[:], r)
+
+	return enc.EncodeBytes(key, b[:n])
+}
+
 // Encode is the type-generic function which encodes most basic go types. Encode() uses go
 // type-casting of val.(type) to determine the type-specific encoder to call. "key" must
 // pass Key.Assess() otherwise an error is returned.
@@ -210,10 +886,32 @@ func (enc *Encoder) EncodeByte(key Key, val byte) error {
 // string representation of its integer value. Recipient applications need to be aware of
 // this conversion if they want to reconstruct the original rune.
 //
-// A better strategy is to pass unicode characters to Encode() as a string and single
-// bytes should be cast as a byte, e.g. Encode(0, byte('Z')). When in doubt it's best to
-// use type-specific functions such as EncodeByte() and EncodeString().
-func (enc *Encoder) Encode(key Key, val any) error {
+// A better strategy is to encode unicode characters with EncodeRune(), which emits their
+// UTF-8 encoding, and single bytes should be cast as a byte, e.g. Encode(0, byte('Z')).
+// When in doubt it's best to use type-specific functions such as EncodeByte(),
+// EncodeRune() and EncodeString().
+//
+// A time.Time is encoded by EncodeTime() with the default layout and *big.Int and
+// *big.Float are encoded by EncodeBigInt() and EncodeBigFloat() respectively. IP
+// addresses, as netip.Addr, netip.AddrPort or net.IP, are encoded by EncodeAddr() and
+// EncodeAddrPort(); an IPv4 net.IP is encoded in its IPv4 form.
+//
+// Any other type which implements encoding.TextMarshaler is encoded as the output of its
+// MarshalText method, which enables uuid types and countless other third-party types.
+// A type which implements Marshaler is encoded as the output of its MarshalNetstring
+// method in preference to all of the above.
+func (enc *Encoder) Encode(key Key, val any) (err error) {
+	defer enc.stick(&err)
+	if err = enc.Err(); err != nil {
+		return err
+	}
+	if m, ok := val.(Marshaler); ok {
+		b, err := m.MarshalNetstring()
+		if err != nil {
+			return fmt.Errorf(errorPrefix+"%T MarshalNetstring failed: %w", val, err)
+		}
+		return enc.EncodeBytes(key, b)
+	}
 	switch tval := val.(type) {
 	case byte:
 		return enc.EncodeByte(key, tval)
@@ -239,6 +937,29 @@ func (enc *Encoder) Encode(key Key, val any) error {
 		return enc.EncodeFloat32(key, tval)
 	case float64:
 		return enc.EncodeFloat64(key, tval)
+	case complex64:
+		return enc.EncodeComplex64(key, tval)
+	case complex128:
+		return enc.EncodeComplex128(key, tval)
+	case time.Time:
+		return enc.EncodeTime(key, tval, "")
+	case *big.Int:
+		return enc.EncodeBigInt(key, tval)
+	case *big.Float:
+		return enc.EncodeBigFloat(key, tval)
+	case netip.Addr:
+		return enc.EncodeAddr(key, tval)
+	case netip.AddrPort:
+		return enc.EncodeAddrPort(key, tval)
+	case net.IP:
+		addr, _ := netip.AddrFromSlice(tval)
+		return enc.EncodeAddr(key, addr.Unmap())
+	case encoding.TextMarshaler: // Must follow all types which are also TextMarshalers
+		b, err := tval.MarshalText()
+		if err != nil {
+			return fmt.Errorf(errorPrefix+"%T MarshalText failed: %w", val, err)
+		}
+		return enc.EncodeBytes(key, b)
 	}
 
 	return ErrUnsupportedType
@@ -3,8 +3,16 @@ package netstring_test
 import (
 	"bytes"
 	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net"
+	"net/netip"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/markdingo/netstring"
 )
@@ -61,6 +69,12 @@ func TestEncoderTypeSpecific(t *testing.T) {
 	e.EncodeFloat64(netstring.NoKey, 12.3456789012345)
 	exp += "16:12.3456789012345,"
 
+	e.EncodeFloat64Format(netstring.NoKey, 1.5e-300, 'e', -1)
+	exp += "8:1.5e-300,"
+
+	e.EncodeFloat64Format(netstring.NoKey, 3.14159, 'f', 2)
+	exp += "4:3.14,"
+
 	e.EncodeByte(netstring.NoKey, 'Z')
 	exp += "1:Z,"
 
@@ -70,6 +84,56 @@ func TestEncoderTypeSpecific(t *testing.T) {
 	}
 }
 
+func TestEncodeComplex(t *testing.T) {
+	var bbuf bytes.Buffer
+	e := netstring.NewEncoder(&bbuf, netstring.WithDeterministic())
+	negZero := math.Copysign(0, -1)
+	e.EncodeComplex128(netstring.NoKey, complex(negZero, negZero))
+	e.EncodeComplex64(netstring.NoKey, complex64(complex(3, negZero)))
+	exp := "6:(0+0i),6:(3+0i),"
+	if bbuf.String() != exp {
+		t.Fatal("Got", bbuf.String(), "expected", exp)
+	}
+
+	in := complex(-1.25, 7.5e-9)
+	bbuf.Reset()
+	e.EncodeComplex128(netstring.NoKey, in)
+	ns, err := netstring.NewDecoder(&bbuf).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := strconv.ParseComplex(string(ns), 128)
+	if err != nil || out != in {
+		t.Error("ParseComplex round-trip", string(ns), out, err)
+	}
+}
+
+func TestEncodeRune(t *testing.T) {
+	var bbuf bytes.Buffer
+	e := netstring.NewEncoder(&bbuf)
+	e.EncodeRune(netstring.NoKey, 'Z')
+	e.EncodeRune('r', 'é')
+	e.EncodeRune(netstring.NoKey, '世')
+	e.EncodeRune(netstring.NoKey, -1)
+	e.Encode(netstring.NoKey, 'Z') // The int32 trap
+	exp := "1:Z,3:ré,3:世,3:\uFFFD,2:90,"
+	if bbuf.String() != exp {
+		t.Error("Got", bbuf.String(), "expected", exp)
+	}
+}
+
+func TestEncodeFloat64FormatBad(t *testing.T) {
+	var bbuf bytes.Buffer
+	e := netstring.NewEncoder(&bbuf)
+	err := e.EncodeFloat64Format(netstring.NoKey, 1, 'q', -1)
+	if err == nil {
+		t.Error("Expected error for invalid format")
+	}
+	if bbuf.Len() != 0 {
+		t.Error("Invalid format should not write", bbuf.String())
+	}
+}
+
 func TestEncoderGeneric(t *testing.T) {
 	var bbuf bytes.Buffer
 	e := netstring.NewEncoder(&bbuf)
@@ -146,6 +210,18 @@ func TestEncoderGeneric(t *testing.T) {
 	}
 	exp += "16:12.3456789012345,"
 
+	err = e.Encode(0, complex64(1.5-2i))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp += "8:(1.5-2i),"
+
+	err = e.Encode(0, complex(0.25, 1e-3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp += "13:(0.25+0.001i),"
+
 	err = e.Encode(0, []byte{'Z'})
 	if err != nil {
 		t.Fatal(err)
@@ -391,3 +467,408 @@ func TestEncoderInvalidKey(t *testing.T) {
 		}
 	}
 }
+
+func TestEncoderReader(t *testing.T) {
+	big := strings.Repeat("0123456789", 10000) // Exceeds one chunk
+	testCases := []struct {
+		key    netstring.Key
+		val    string
+		n      int64
+		expect string
+		err    error
+	}{
+		{netstring.NoKey, "hello", 5, "5:hello,", nil},
+		{'k', "hello", 5, "6:khello,", nil},
+		{'k', "hello world", 5, "6:khello,", nil}, // Only n bytes are read
+		{'k', "", 0, "1:k,", nil},
+		{netstring.NoKey, big, int64(len(big)), "100000:" + big + ",", nil},
+		{'k', "hi", 5, "6:khi", io.ErrUnexpectedEOF},
+		{'*', "hello", 5, "", netstring.ErrInvalidKey},
+	}
+
+	for ix, tc := range testCases {
+		var bbuf bytes.Buffer
+		enc := netstring.NewEncoder(&bbuf)
+		err := enc.EncodeReader(tc.key, strings.NewReader(tc.val), tc.n)
+		if !errors.Is(err, tc.err) {
+			t.Error(ix, "Expected", tc.err, "got", err)
+		}
+		if bbuf.String() != tc.expect {
+			t.Error(ix, "Output wrong", len(bbuf.String()), len(tc.expect))
+		}
+	}
+
+	enc := netstring.NewEncoder(io.Discard, netstring.WithMaximumLength(10))
+	if err := enc.EncodeReader('k', strings.NewReader(""), 10); err != netstring.ErrValueToLong {
+		t.Error("Expected ErrValueToLong, not", err)
+	}
+	if err := enc.EncodeReader('k', strings.NewReader(""), -1); err == nil {
+		t.Error("Expected error for negative length")
+	}
+
+	var calls int
+	enc = netstring.NewEncoder(io.Discard)
+	enc.SetProgress(func(done, total int64) {
+		calls++
+		if total != int64(len(big)) {
+			t.Error("Progress total wrong", total)
+		}
+	})
+	enc.EncodeReader(netstring.NoKey, strings.NewReader(big), int64(len(big)))
+	if calls != 4 {
+		t.Error("Expected 4 progress calls, got", calls)
+	}
+}
+
+func TestEncoderTime(t *testing.T) {
+	tm := time.Date(2024, 2, 29, 13, 14, 15, 123000000, time.FixedZone("NZDT", 13*3600))
+	testCases := []struct {
+		layout string
+		expect string
+	}{
+		{"", "30:t2024-02-29T13:14:15.123+13:00,"},
+		{time.RFC3339, "26:t2024-02-29T13:14:15+13:00,"},
+		{time.DateOnly, "11:t2024-02-29,"},
+		{"15:04 MST", "11:t13:14 NZDT,"},
+	}
+
+	for ix, tc := range testCases {
+		var bbuf bytes.Buffer
+		enc := netstring.NewEncoder(&bbuf)
+		err := enc.EncodeTime('t', tm, tc.layout)
+		if err != nil {
+			t.Fatal(ix, err)
+		}
+		if bbuf.String() != tc.expect {
+			t.Error(ix, "Expected", tc.expect, "got", bbuf.String())
+		}
+	}
+
+	var bbuf bytes.Buffer
+	netstring.NewEncoder(&bbuf).Encode('t', tm.UTC())
+	if bbuf.String() != "25:t2024-02-29T00:14:15.123Z," {
+		t.Error("Generic Encode of time.Time wrong", bbuf.String())
+	}
+	dec := netstring.NewDecoder(&bbuf)
+	_, v, _ := dec.DecodeKeyed()
+	back, err := time.Parse(time.RFC3339Nano, string(v))
+	if err != nil || !back.Equal(tm) {
+		t.Error("Round trip failed", back, err)
+	}
+}
+
+func TestEncoderBig(t *testing.T) {
+	huge, _ := new(big.Int).SetString("-123456789012345678901234567890", 10)
+	third := new(big.Float).SetPrec(100).Quo(big.NewFloat(1), big.NewFloat(3))
+	testCases := []struct {
+		val    any
+		expect string
+		err    error
+	}{
+		{huge, "32:b-123456789012345678901234567890,", nil},
+		{big.NewInt(0), "2:b0,", nil},
+		{big.NewFloat(1.5), "4:b1.5,", nil},
+		{new(big.Float).SetInf(true), "5:b-Inf,", nil},
+		{third, "34:b0.3333333333333333333333333333335,", nil},
+		{(*big.Int)(nil), "", netstring.ErrUnsupportedType},
+		{(*big.Float)(nil), "", netstring.ErrUnsupportedType},
+	}
+
+	for ix, tc := range testCases {
+		var bbuf bytes.Buffer
+		err := netstring.NewEncoder(&bbuf).Encode('b', tc.val)
+		if err != tc.err {
+			t.Error(ix, "Expected", tc.err, "got", err)
+		}
+		if bbuf.String() != tc.expect {
+			t.Error(ix, "Expected", tc.expect, "got", bbuf.String())
+		}
+	}
+
+	var bbuf bytes.Buffer
+	netstring.NewEncoder(&bbuf).EncodeBigInt('b', huge)
+	_, v, _ := netstring.NewDecoder(&bbuf).DecodeKeyed()
+	back, ok := new(big.Int).SetString(string(v), 10)
+	if !ok || back.Cmp(huge) != 0 {
+		t.Error("Round trip failed", back)
+	}
+}
+
+func TestEncoderKV(t *testing.T) {
+	testCases := []struct {
+		kvs    []netstring.KV
+		expect string
+		err    error
+	}{
+		{nil, "", nil},
+		{[]netstring.KV{{Key: 'b', Value: []byte("2")}, {Key: 'a', Value: []byte("1")},
+			{Key: 'b', Value: nil}}, "2:b2,2:a1,1:b,", nil},
+		{[]netstring.KV{{Key: 'a', Value: []byte("1")}, {Key: '*'}}, "", netstring.ErrInvalidKey},
+		{[]netstring.KV{{Key: 'a', Value: []byte("1")}, {}}, "", netstring.ErrNoKey},
+		{[]netstring.KV{{Key: 'a', Value: []byte("1")}, {Key: 'x', Value: []byte("toolong")}},
+			"", netstring.ErrValueToLong},
+	}
+
+	for ix, tc := range testCases {
+		var bbuf bytes.Buffer
+		enc := netstring.NewEncoder(&bbuf, netstring.WithMaximumLength(5))
+		err := enc.EncodeKV(tc.kvs)
+		if err != tc.err {
+			t.Error(ix, "Expected", tc.err, "got", err)
+		}
+		if bbuf.String() != tc.expect {
+			t.Error(ix, "Expected", tc.expect, "got", bbuf.String())
+		}
+	}
+
+	// Values are validated as transformed so nothing is written
+	kvs := []netstring.KV{{Key: 'a', Value: []byte("ok")}, {Key: 'b', Value: []byte("   \x00")}}
+	for ix, opt := range []netstring.Option{netstring.WithEscaping(),
+		netstring.WithNULPolicy(netstring.NULEscape), netstring.WithNULPolicy(netstring.NULReject)} {
+		var bbuf bytes.Buffer
+		enc := netstring.NewEncoder(&bbuf, opt, netstring.WithMaximumLength(6))
+		err := enc.EncodeKV(kvs)
+		if err == nil || bbuf.Len() > 0 {
+			t.Error(ix, "Expected error with nothing written, got", err, bbuf.String())
+		}
+	}
+}
+
+func TestEncoderAddr(t *testing.T) {
+	testCases := []struct {
+		val    any
+		expect string
+	}{
+		{netip.MustParseAddr("192.0.2.1"), "10:i192.0.2.1,"},
+		{netip.MustParseAddr("2001:DB8:0:0::1"), "12:i2001:db8::1,"},
+		{netip.MustParseAddr("fe80::1%eth0"), "13:ife80::1%eth0,"},
+		{netip.Addr{}, "1:i,"},
+		{netip.MustParseAddrPort("192.0.2.1:53"), "13:i192.0.2.1:53,"},
+		{netip.MustParseAddrPort("[2001:db8::1]:53"), "17:i[2001:db8::1]:53,"},
+		{netip.AddrPort{}, "1:i,"},
+		{net.ParseIP("192.0.2.1"), "10:i192.0.2.1,"}, // 16 byte form
+		{net.IP(nil), "1:i,"},
+	}
+
+	for ix, tc := range testCases {
+		var bbuf bytes.Buffer
+		err := netstring.NewEncoder(&bbuf).Encode('i', tc.val)
+		if err != nil {
+			t.Fatal(ix, err)
+		}
+		if bbuf.String() != tc.expect {
+			t.Error(ix, "Expected", tc.expect, "got", bbuf.String())
+		}
+	}
+}
+
+// textColour implements encoding.TextMarshaler.
+type textColour int
+
+func (c textColour) MarshalText() ([]byte, error) {
+	if c < 0 {
+		return nil, errors.New("negative colour")
+	}
+	return []byte([]string{"red", "green", "blue"}[c]), nil
+}
+
+func TestEncoderTextMarshaler(t *testing.T) {
+	testCases := []struct {
+		val    any
+		expect string
+		err    bool
+	}{
+		{textColour(1), "6:cgreen,", false},
+		{netstring.Key('Q'), "2:cQ,", false},
+		{time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), "21:c2024-01-02T03:04:05Z,", false},
+		{textColour(-1), "", true},
+	}
+
+	for ix, tc := range testCases {
+		var bbuf bytes.Buffer
+		err := netstring.NewEncoder(&bbuf).Encode('c', tc.val)
+		if (err != nil) != tc.err {
+			t.Error(ix, "Unexpected error return", err)
+		}
+		if bbuf.String() != tc.expect {
+			t.Error(ix, "Expected", tc.expect, "got", bbuf.String())
+		}
+	}
+}
+
+func TestEncoderReset(t *testing.T) {
+	var first, second bytes.Buffer
+	enc := netstring.NewEncoder(&first, netstring.WithStickyErrors(),
+		netstring.WithProvenance(netstring.Provenance{Producer: "p"}))
+	enc.EncodeString('a', "one")
+	enc.EncodeInt('!', 1) // Make an error sticky
+
+	enc.Reset(&second)
+	if enc.Err() != nil {
+		t.Error("Reset should clear the sticky error", enc.Err())
+	}
+	err := enc.EncodeString('b', "two")
+	if err != nil {
+		t.Fatal("Unexpected error after Reset", err)
+	}
+	provenance := strings.TrimSuffix(first.String(), "4:aone,")
+	if second.String() != provenance+"4:btwo," {
+		t.Error("Expected provenance to be emitted again", first.String(), second.String())
+	}
+}
+
+func TestEncoderStats(t *testing.T) {
+	var bb bytes.Buffer
+	for ix, atomic := range []bool{false, true} {
+		bb.Reset()
+		var opts []netstring.Option
+		if atomic {
+			opts = append(opts, netstring.WithAtomicWrites())
+		}
+		enc := netstring.NewEncoder(&bb, opts...)
+		enc.EncodeString('a', "hello")
+		enc.EncodeString('a', "0123456789")
+		enc.EncodeString(netstring.NoKey, "std")
+		enc.EncodeReader('r', strings.NewReader("reader"), 6)
+		enc.EncodeInt('!', 1) // Errors are not counted
+		enc.EncodeBytes('z')
+
+		st := enc.Stats()
+		if st.Netstrings != 5 {
+			t.Error(ix, "Expected 5 netstrings, got", st.Netstrings)
+		}
+		if st.Bytes != int64(bb.Len()) {
+			t.Error(ix, "Expected Bytes of", bb.Len(), "got", st.Bytes)
+		}
+		exp := netstring.KeyStats{'a': {2, 15}, netstring.NoKey: {1, 3}, 'r': {1, 6}, 'z': {1, 0}}
+		if len(st.Keys) != len(exp) {
+			t.Error(ix, "Keys length mismatch", st.Keys)
+		}
+		for k, v := range exp {
+			if st.Keys[k] != v {
+				t.Error(ix, "Key", k, "expected", v, "got", st.Keys[k])
+			}
+		}
+
+		st.Keys['a'] = netstring.KeyStat{} // Stats must be a copy
+		if enc.Stats().Keys['a'].Count != 2 {
+			t.Error(ix, "Stats did not return a copy")
+		}
+		enc.Reset(io.Discard)
+		if enc.Stats().Netstrings != 0 {
+			t.Error(ix, "Reset did not clear Stats")
+		}
+	}
+}
+
+func TestEncoderSetHook(t *testing.T) {
+	var bb bytes.Buffer
+	for ix, atomic := range []bool{false, true} {
+		var opts []netstring.Option
+		if atomic {
+			opts = append(opts, netstring.WithAtomicWrites())
+		}
+		enc := netstring.NewEncoder(&bb, opts...)
+		var got string
+		enc.SetHook(func(key netstring.Key, length int) {
+			if key == netstring.NoKey {
+				key = '-'
+			}
+			got += fmt.Sprintf("%s%d ", key, length)
+		})
+		enc.EncodeString('a', "hello")
+		enc.EncodeString(netstring.NoKey, "std")
+		enc.EncodeReader('r', strings.NewReader("reader"), 6)
+		enc.EncodeInt('!', 1) // Errors are not reported
+		enc.EncodeBytes('z')
+		exp := "a5 -3 r6 z0 "
+		if got != exp {
+			t.Error(ix, "Hook got", got, "expected", exp)
+		}
+
+		enc.SetHook(nil)
+		enc.EncodeBytes('z')
+		if got != exp {
+			t.Error(ix, "Hook called after removal", got)
+		}
+	}
+}
+
+func TestEncoderWriteBuffering(t *testing.T) {
+	var cw countingWriter
+	enc := netstring.NewEncoder(&cw, netstring.WithWriteBuffering(64))
+	for ix := 0; ix < 5; ix++ {
+		enc.EncodeInt('a', ix)
+	}
+	if cw.writes != 0 {
+		t.Error("Nothing should be written before Flush", cw.writes)
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal("Flush", err)
+	}
+	if cw.writes != 1 || cw.String() != "2:a0,2:a1,2:a2,2:a3,2:a4," {
+		t.Error("Expected one Write, got", cw.writes, cw.String())
+	}
+
+	enc.EncodeString('b', strings.Repeat("x", 100)) // Larger than the buffer
+	if cw.Len() == 25 {
+		t.Error("Full buffer should be written without Flush")
+	}
+
+	cw.Reset()
+	enc.EncodeString('c', "lost")
+	enc.Reset(&cw) // Unflushed output is discarded
+	enc.EncodeString('d', "kept")
+	enc.Flush()
+	if cw.String() != "5:dkept," {
+		t.Error("Reset did not discard", cw.String())
+	}
+
+	if err := netstring.NewEncoder(&cw).Flush(); err != nil { // No-op when unbuffered
+		t.Error("Unbuffered Flush", err)
+	}
+
+	mb := netstring.NewMessageBuilder(netstring.WithWriteBuffering(64))
+	mb.EncodeString('e', "now")
+	if mb.Len() != 7 {
+		t.Error("MessageBuilder should ignore WithWriteBuffering", mb.Len())
+	}
+}
+
+func TestEncoderBoolFormat(t *testing.T) {
+	testCases := []struct {
+		format netstring.BoolFormat
+		expect string
+	}{
+		{netstring.BoolShorthand, "2:bT,2:bf,"},
+		{netstring.BoolWords, "5:btrue,6:bfalse,"},
+		{netstring.BoolDigits, "2:b1,2:b0,"},
+	}
+	for ix, tc := range testCases {
+		var bb bytes.Buffer
+		enc := netstring.NewEncoder(&bb, netstring.WithBoolFormat(tc.format))
+		enc.EncodeBool('b', true)
+		enc.Encode('b', false)
+		if bb.String() != tc.expect {
+			t.Error(ix, "Got", bb.String(), "expected", tc.expect)
+		}
+		dec := netstring.NewDecoder(&bb)
+		for _, expect := range []bool{true, false} {
+			_, v, _ := dec.DecodeKeyed()
+			got, err := strconv.ParseBool(string(v))
+			if err != nil || got != expect {
+				t.Error(ix, "ParseBool", string(v), err)
+			}
+		}
+	}
+
+	var bb bytes.Buffer
+	enc := netstring.NewEncoder(&bb, netstring.WithBoolFormat(netstring.BoolDigits))
+	enc.EncodeBoolFormat('b', true, netstring.BoolWords) // Per call overrides the Option
+	if bb.String() != "5:btrue," {
+		t.Error("Per call format ignored", bb.String())
+	}
+	if err := enc.EncodeBoolFormat('b', true, netstring.BoolFormat(99)); err == nil {
+		t.Error("Expected error for unknown BoolFormat")
+	}
+}
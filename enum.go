@@ -0,0 +1,94 @@
+package netstring
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// integer is the set of types which can be registered with RegisterEnum.
+type integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64
+}
+
+// RegisterEnum registers a codec, as per RegisterCodec, which causes Marshal and Unmarshal
+// to represent the integer-backed enum type T by its symbolic name rather than its
+// number so that wire captures are self-describing. For example:
+//
+//	type Color int
+//	const (
+//	  Red Color = iota
+//	  Green
+//	)
+//	netstring.RegisterEnum(map[Color]string{Red: "red", Green: "green"})
+//
+// causes a Color field containing Green to be encoded as "green". Values missing from
+// "names" are encoded in decimal and Unmarshal accepts either a name or a decimal value,
+// which allows peers to add enum values without breaking older peers outright.
+//
+// An error is returned if any name is empty or is used for more than one value.
+func RegisterEnum[T integer](names map[T]string) error {
+	values := make(map[string]T, len(names))
+	for v, n := range names {
+		if len(n) == 0 {
+			return fmt.Errorf(errorPrefix+"Enum value %d has an empty name", v)
+		}
+		if ov, ok := values[n]; ok {
+			return fmt.Errorf(errorPrefix+"Enum name '%s' used for %d and %d", n, ov, v)
+		}
+		values[n] = v
+	}
+
+	var zero T
+	RegisterCodec(reflect.TypeOf(zero),
+		func(v any) ([]byte, error) {
+			e := v.(T)
+			if n, ok := names[e]; ok {
+				return []byte(n), nil
+			}
+			return formatEnumNumber(e), nil
+		},
+		func(b []byte) (any, error) {
+			if e, ok := values[string(b)]; ok {
+				return e, nil
+			}
+			return parseEnumNumber[T](b)
+		})
+
+	return nil
+}
+
+// formatEnumNumber returns the decimal value of "e". It deliberately avoids fmt as enum
+// types commonly have a String() method.
+func formatEnumNumber[T integer](e T) []byte {
+	rv := reflect.ValueOf(e)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.AppendInt(nil, rv.Int(), 10)
+	}
+
+	return strconv.AppendUint(nil, rv.Uint(), 10)
+}
+
+// parseEnumNumber converts a decimal value to T, checking for overflow.
+func parseEnumNumber[T integer](b []byte) (T, error) {
+	var e T
+	rv := reflect.ValueOf(&e).Elem()
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		vi, err := strconv.ParseInt(string(b), 10, 64)
+		if err != nil || rv.OverflowInt(vi) {
+			return e, fmt.Errorf(errorPrefix+"'%s' is not a known %T name or value", b, e)
+		}
+		rv.SetInt(vi)
+	default:
+		vu, err := strconv.ParseUint(string(b), 10, 64)
+		if err != nil || rv.OverflowUint(vu) {
+			return e, fmt.Errorf(errorPrefix+"'%s' is not a known %T name or value", b, e)
+		}
+		rv.SetUint(vu)
+	}
+
+	return e, nil
+}
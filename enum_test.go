@@ -0,0 +1,102 @@
+package netstring_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+type testColor int8
+
+const (
+	red testColor = iota
+	green
+	blue
+)
+
+func (c testColor) String() string { return "Color!" } // Must not be used by the codec
+
+type testLevel uint16
+
+func TestRegisterEnum(t *testing.T) {
+	err := netstring.RegisterEnum(map[testColor]string{red: "red", green: "green"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer netstring.RegisterCodec(reflect.TypeOf(red), nil, nil)
+
+	type paint struct {
+		Primary   testColor `netstring:"p"`
+		Secondary testColor `netstring:"s"`
+	}
+	var bbuf bytes.Buffer
+	err = netstring.NewEncoder(&bbuf).Marshal('z', &paint{green, blue})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bbuf.String() != "6:pgreen,2:s2,1:z," {
+		t.Error("Marshal wrong", bbuf.String())
+	}
+
+	type testCase struct {
+		input  string
+		expect paint
+		errStr string
+	}
+	testCases := []testCase{
+		{"4:pred,2:s2,1:z,", paint{red, blue}, ""},
+		{"2:p1,1:z,", paint{green, red}, ""},
+		{"7:pyellow,1:z,", paint{}, "not a known"},
+		{"4:p300,1:z,", paint{}, "not a known"}, // Overflows int8
+	}
+	for ix, tc := range testCases {
+		var p paint
+		_, err := netstring.NewDecoder(strings.NewReader(tc.input)).Unmarshal('z', &p)
+		if len(tc.errStr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tc.errStr) {
+				t.Error(ix, "Expected", tc.errStr, "got", err)
+			}
+			continue
+		}
+		if err != nil || p != tc.expect {
+			t.Error(ix, "Unmarshal wrong", p, err)
+		}
+	}
+}
+
+func TestRegisterEnumUnsigned(t *testing.T) {
+	err := netstring.RegisterEnum(map[testLevel]string{1: "low", 1000: "high"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer netstring.RegisterCodec(reflect.TypeOf(testLevel(0)), nil, nil)
+
+	type alarm struct {
+		Level testLevel `netstring:"l"`
+	}
+	var bbuf bytes.Buffer
+	netstring.NewEncoder(&bbuf).Marshal('z', &alarm{7})
+	var a alarm
+	_, err = netstring.NewDecoder(&bbuf).Unmarshal('z', &a)
+	if err != nil || a.Level != 7 {
+		t.Error("Unnamed unsigned value did not round trip", a, err)
+	}
+	_, err = netstring.NewDecoder(strings.NewReader("3:l-1,1:z,")).Unmarshal('z', &a)
+	if err == nil {
+		t.Error("Expected error from negative unsigned enum")
+	}
+}
+
+func TestRegisterEnumErrors(t *testing.T) {
+	err := netstring.RegisterEnum(map[testColor]string{red: ""})
+	if err == nil || !strings.Contains(err.Error(), "empty name") {
+		t.Error("Expected empty name error, not", err)
+	}
+	err = netstring.RegisterEnum(map[testColor]string{red: "x", green: "x"})
+	if err == nil || !strings.Contains(err.Error(), "Enum name 'x' used") {
+		t.Error("Expected duplicate name error, not", err)
+	}
+}
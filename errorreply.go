@@ -0,0 +1,97 @@
+package netstring
+
+import (
+	"strconv"
+)
+
+// Keys used by the standard error-reply message created by Encoder.EncodeError and
+// consumed by Decoder.DecodeError. Applications which use the error-reply convention
+// should avoid these keys in their own reply messages. Client and AccessLog only treat
+// a reply as an error-reply if it contains all three keys.
+const (
+	ErrorRequestIDKey Key = 'R' // Identifier of the offending request, if any
+	ErrorCodeKey      Key = 'C' // Decimal ErrorCode
+	ErrorTextKey      Key = 'E' // Human readable error text
+)
+
+// errorReply is the "basic-struct" of the standard error-reply message.
+type errorReply struct {
+	RequestID string    `netstring:"R"`
+	Code      ErrorCode `netstring:"C"`
+	Text      string    `netstring:"E"`
+}
+
+// RemoteError is the typed error reconstructed by Decoder.DecodeError from a standard
+// error-reply message sent by a peer.
+//
+// If Code identifies a sentinel error known to this package, RemoteError unwraps to that
+// sentinel so errors.Is(remoteErr, netstring.ErrValueToLong), say, works as expected
+// across process boundaries.
+type RemoteError struct {
+	RequestID string    // Identifier of the offending request as supplied by the peer
+	Code      ErrorCode // CodeUnknown if the peer's error was not a NetstringError
+	Text      string    // The peer's err.Error() text
+}
+
+func (re *RemoteError) Error() string {
+	s := errorPrefix + "Remote error"
+	if len(re.RequestID) > 0 {
+		s += " for request " + strconv.Quote(re.RequestID)
+	}
+
+	return s + ": " + re.Text
+}
+
+// Unwrap returns the sentinel error corresponding to Code or nil.
+func (re *RemoteError) Unwrap() error {
+	return ErrorFromCode(re.Code)
+}
+
+// EncodeError encodes a standard error-reply message describing "err" followed by the
+// "eom" end-of-message sentinel. The message contains "keyed" netstrings for the request
+// identifier (ErrorRequestIDKey), the ErrorCode of "err" as returned by CodeOf
+// (ErrorCodeKey) and the text of "err" (ErrorTextKey).
+//
+// "requestID" is an opaque application identifier of the offending request and may be
+// empty. As with all messages, it is good practice to precede the error-reply with a
+// message type so that the recipient knows to call Decoder.DecodeError.
+func (enc *Encoder) EncodeError(eom Key, requestID string, err error) error {
+	er := errorReply{RequestID: requestID, Code: CodeOf(err)}
+	if err != nil {
+		er.Text = err.Error()
+	}
+
+	return enc.Marshal(eom, &er)
+}
+
+// DecodeError decodes a standard error-reply message created by Encoder.EncodeError and
+// returns it as a *RemoteError. The returned error is only non-nil if the message could
+// not be decoded.
+func (dec *Decoder) DecodeError(eom Key) (*RemoteError, error) {
+	var er errorReply
+	_, err := dec.Unmarshal(eom, &er)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RemoteError{RequestID: er.RequestID, Code: er.Code, Text: er.Text}, nil
+}
+
+// remoteErrorOf returns a *RemoteError if "m" is a standard error-reply, otherwise nil.
+// As the error-reply keys may also be used as ordinary data keys, "m" is only considered
+// an error-reply if it contains all of ErrorRequestIDKey, ErrorCodeKey and ErrorTextKey,
+// as EncodeError always emits, and the ErrorCode is a valid decimal number.
+func remoteErrorOf(m *Message) *RemoteError {
+	id, idOk := m.Get(ErrorRequestIDKey)
+	code, codeOk := m.Get(ErrorCodeKey)
+	text, textOk := m.Get(ErrorTextKey)
+	if !idOk || !codeOk || !textOk {
+		return nil
+	}
+	n, err := strconv.ParseUint(string(code), 10, 16)
+	if err != nil {
+		return nil
+	}
+
+	return &RemoteError{RequestID: string(id), Code: ErrorCode(n), Text: string(text)}
+}
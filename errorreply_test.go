@@ -0,0 +1,60 @@
+package netstring_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestErrorReply(t *testing.T) {
+	var bbuf bytes.Buffer
+	enc := netstring.NewEncoder(&bbuf)
+	err := enc.EncodeError('z', "req-42", fmt.Errorf("bad input: %w", netstring.ErrValueToLong))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = enc.EncodeError('z', "", errors.New("something foreign"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := "7:Rreq-42,2:C4,"
+	if !strings.HasPrefix(bbuf.String(), exp) {
+		t.Error("Wire format wrong", bbuf.String())
+	}
+
+	dec := netstring.NewDecoder(&bbuf)
+	re, err := dec.DecodeError('z')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if re.RequestID != "req-42" || re.Code != netstring.CodeValueToLong {
+		t.Error("Wrong RemoteError", re)
+	}
+	if !errors.Is(re, netstring.ErrValueToLong) {
+		t.Error("RemoteError does not unwrap to sentinel", re)
+	}
+	if !strings.Contains(re.Error(), `request "req-42"`) || !strings.Contains(re.Error(), "bad input") {
+		t.Error("Error text wrong", re.Error())
+	}
+
+	re, err = dec.DecodeError('z')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if re.Code != netstring.CodeUnknown || re.Unwrap() != nil {
+		t.Error("Foreign error should have no code", re)
+	}
+	if re.Text != "something foreign" {
+		t.Error("Wrong text", re.Text)
+	}
+
+	_, err = dec.DecodeError('z') // Stream exhausted
+	if err == nil {
+		t.Error("Expected error from exhausted stream")
+	}
+}
@@ -0,0 +1,186 @@
+package netstring
+
+import (
+	"errors"
+)
+
+// ErrorCode is a stable numeric identifier for each sentinel error returned by this
+// package. Codes are suitable for transmission between peers, such as in an error-reply
+// message, and can be turned back into the corresponding sentinel error with
+// [ErrorFromCode].
+//
+// Once assigned, a code value never changes meaning. New codes are only ever appended.
+type ErrorCode uint16
+
+// All ErrorCode values. CodeUnknown is used for errors which are not sentinel errors of
+// this package.
+const (
+	CodeUnknown          ErrorCode = 0
+	CodeLengthNotDigit   ErrorCode = 1
+	CodeLeadingZero      ErrorCode = 2
+	CodeLengthToLong     ErrorCode = 3
+	CodeValueToLong      ErrorCode = 4
+	CodeColonExpected    ErrorCode = 5
+	CodeCommaExpected    ErrorCode = 6
+	CodeNoKey            ErrorCode = 7
+	CodeUnsupportedType  ErrorCode = 8
+	CodeZeroKey          ErrorCode = 9
+	CodeInvalidKey       ErrorCode = 10
+	CodeBadMarshalValue  ErrorCode = 11
+	CodeBadMarshalTag    ErrorCode = 12
+	CodeBadUnmarshalMsg  ErrorCode = 13
+	CodeBadMarshalEOM    ErrorCode = 14
+	CodeKeyNotPermitted  ErrorCode = 15
+	CodeNoMessageType    ErrorCode = 16
+	CodeUnknownMsgType   ErrorCode = 17
+	CodeValueTooLarge    ErrorCode = 18
+	CodeBadScanValue     ErrorCode = 19
+	CodeBadMessageText   ErrorCode = 20
+	CodeSchemaViolation  ErrorCode = 21
+	CodeFieldConstraint  ErrorCode = 22
+	CodeServerClosed     ErrorCode = 23
+	CodeServerBusy       ErrorCode = 24
+	CodeHandlerPanic     ErrorCode = 25
+	CodeBadEscape        ErrorCode = 26
+	CodeNULValue         ErrorCode = 27
+	CodeNoProvenance     ErrorCode = 28
+	CodeBadHMAC          ErrorCode = 29
+	CodeProtocolMismatch ErrorCode = 30
+	CodeMemoryBudget     ErrorCode = 31
+	CodeLengthOverflow   ErrorCode = 32
+)
+
+var codeNames = map[ErrorCode]string{
+	CodeUnknown:          "Unknown",
+	CodeLengthNotDigit:   "LengthNotDigit",
+	CodeLeadingZero:      "LeadingZero",
+	CodeLengthToLong:     "LengthToLong",
+	CodeValueToLong:      "ValueToLong",
+	CodeColonExpected:    "ColonExpected",
+	CodeCommaExpected:    "CommaExpected",
+	CodeNoKey:            "NoKey",
+	CodeUnsupportedType:  "UnsupportedType",
+	CodeZeroKey:          "ZeroKey",
+	CodeInvalidKey:       "InvalidKey",
+	CodeBadMarshalValue:  "BadMarshalValue",
+	CodeBadMarshalTag:    "BadMarshalTag",
+	CodeBadUnmarshalMsg:  "BadUnmarshalMsg",
+	CodeBadMarshalEOM:    "BadMarshalEOM",
+	CodeKeyNotPermitted:  "KeyNotPermitted",
+	CodeNoMessageType:    "NoMessageType",
+	CodeUnknownMsgType:   "UnknownMessageType",
+	CodeValueTooLarge:    "ValueTooLarge",
+	CodeBadScanValue:     "BadScanValue",
+	CodeBadMessageText:   "BadMessageText",
+	CodeSchemaViolation:  "SchemaViolation",
+	CodeFieldConstraint:  "FieldConstraint",
+	CodeServerClosed:     "ServerClosed",
+	CodeServerBusy:       "ServerBusy",
+	CodeHandlerPanic:     "HandlerPanic",
+	CodeBadEscape:        "BadEscape",
+	CodeNULValue:         "NULValue",
+	CodeNoProvenance:     "NoProvenance",
+	CodeBadHMAC:          "BadHMAC",
+	CodeProtocolMismatch: "ProtocolMismatch",
+	CodeMemoryBudget:     "MemoryBudget",
+	CodeLengthOverflow:   "LengthOverflow",
+}
+
+func (c ErrorCode) String() string {
+	if s, ok := codeNames[c]; ok {
+		return s
+	}
+
+	return "Bizarre ErrorCode"
+}
+
+// NetstringError is the concrete type of all sentinel errors returned by this package. The
+// sentinel values are unique so errors.Is() and direct comparison continue to work as
+// expected. Use [NetstringError.Code] or [CodeOf] to obtain the stable numeric identifier of
+// an error.
+type NetstringError struct {
+	code ErrorCode
+	text string
+}
+
+var errorsByCode = make(map[ErrorCode]*NetstringError)
+
+// newError creates a sentinel error and registers it so that it can be reconstructed from
+// its code.
+func newError(code ErrorCode, text string) error {
+	e := &NetstringError{code: code, text: errorPrefix + text}
+	errorsByCode[code] = e
+
+	return e
+}
+
+func (e *NetstringError) Error() string {
+	return e.text
+}
+
+// Code returns the stable numeric identifier of the error.
+func (e *NetstringError) Code() ErrorCode {
+	return e.code
+}
+
+// CodeOf returns the ErrorCode of the first NetstringError found in the chain of "err",
+// or CodeUnknown if there is none.
+func CodeOf(err error) ErrorCode {
+	var ne *NetstringError
+	if errors.As(err, &ne) {
+		return ne.code
+	}
+
+	return CodeUnknown
+}
+
+// ErrorFromCode returns the sentinel error corresponding to "code", typically as
+// received from a peer. It returns nil if "code" is CodeUnknown or is not known to this
+// version of the package.
+func ErrorFromCode(code ErrorCode) error {
+	if e, ok := errorsByCode[code]; ok {
+		return e
+	}
+
+	return nil
+}
+
+var ErrLengthNotDigit = newError(CodeLengthNotDigit, "Length does not start with a digit")
+var ErrLeadingZero = newError(CodeLeadingZero, "Non-zero length cannot have a leading zero")
+var ErrLengthToLong = newError(CodeLengthToLong, "Length contains more bytes than maximum allowed")
+var ErrLengthOverflow = newError(CodeLengthOverflow, "Length is too large to be represented by an int")
+var ErrValueToLong = newError(CodeValueToLong, "Length of value is longer than maximum allowed")
+var ErrColonExpected = newError(CodeColonExpected, "Leading colon delimiter not found after length")
+var ErrCommaExpected = newError(CodeCommaExpected, "Trailing comma delimeter not found after value")
+var ErrValueTooLarge = newError(CodeValueTooLarge, "Length of netstring value exceeds the maximum accepted")
+
+var ErrNoKey = newError(CodeNoKey, "Keyed netstring cannot be NoKey")
+var ErrUnsupportedType = newError(CodeUnsupportedType, "Unsupported go type supplied to Encode()")
+var ErrZeroKey = newError(CodeZeroKey, "Keyed netstring is zero length (thus has no key)")
+var ErrInvalidKey = newError(CodeInvalidKey, "Key is not in range 'a'-'z' or 'A'-'Z'")
+
+var ErrBadMarshalValue = newError(CodeBadMarshalValue, "Marshal only accepts struct{} and *struct{}")
+var ErrBadMarshalTag = newError(CodeBadMarshalTag, "struct tag is not a valid netstring.Key")
+var ErrBadUnmarshalMsg = newError(CodeBadUnmarshalMsg, "Unmarshal only accepts *struct{}")
+var ErrBadMarshalEOM = newError(CodeBadMarshalEOM, "End-of-Message Key is invalid")
+
+var ErrKeyNotPermitted = newError(CodeKeyNotPermitted, "Key is not permitted by KeyPolicy")
+
+var ErrNoMessageType = newError(CodeNoMessageType, "Message does not start with the Registry type key")
+var ErrUnknownMessageType = newError(CodeUnknownMsgType, "Message type is not registered")
+var ErrNoProvenance = newError(CodeNoProvenance, "Stream does not start with a provenance message")
+var ErrProtocolMismatch = newError(CodeProtocolMismatch, "Peer protocol descriptor does not match")
+
+var ErrBadScanValue = newError(CodeBadScanValue, "Scan value is not an encoded Message")
+var ErrBadMessageText = newError(CodeBadMessageText, "Text is not a valid Message representation")
+var ErrBadEscape = newError(CodeBadEscape, "Value contains a '%' not followed by two hex digits")
+var ErrMemoryBudget = newError(CodeMemoryBudget, "Decoder memory budget exceeded")
+var ErrNULValue = newError(CodeNULValue, "Value contains a NUL byte rejected by NULPolicy")
+var ErrBadHMAC = newError(CodeBadHMAC, "Message HMAC trailer is missing or does not verify")
+
+var ErrSchemaViolation = newError(CodeSchemaViolation, "Message does not conform to Schema")
+var ErrFieldConstraint = newError(CodeFieldConstraint, "Field value violates its tag constraint")
+
+var ErrServerClosed = newError(CodeServerClosed, "Server closed")
+var ErrServerBusy = newError(CodeServerBusy, "Server is at its connection limit")
+var ErrHandlerPanic = newError(CodeHandlerPanic, "Server handler failed unexpectedly")
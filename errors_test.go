@@ -0,0 +1,65 @@
+package netstring_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestErrorCodes(t *testing.T) {
+	type testCase struct {
+		err  error
+		code netstring.ErrorCode
+		name string
+	}
+	testCases := []testCase{
+		{netstring.ErrLengthNotDigit, netstring.CodeLengthNotDigit, "LengthNotDigit"},
+		{netstring.ErrLeadingZero, netstring.CodeLeadingZero, "LeadingZero"},
+		{netstring.ErrCommaExpected, netstring.CodeCommaExpected, "CommaExpected"},
+		{netstring.ErrInvalidKey, netstring.CodeInvalidKey, "InvalidKey"},
+		{netstring.ErrBadMarshalEOM, netstring.CodeBadMarshalEOM, "BadMarshalEOM"},
+	}
+
+	for ix, tc := range testCases {
+		var ne *netstring.NetstringError
+		if !errors.As(tc.err, &ne) {
+			t.Error(ix, "Sentinel is not a NetstringError", tc.err)
+			continue
+		}
+		if ne.Code() != tc.code {
+			t.Error(ix, "Wrong code", ne.Code(), "expected", tc.code)
+		}
+		if tc.code.String() != tc.name {
+			t.Error(ix, "Wrong code name", tc.code.String(), "expected", tc.name)
+		}
+
+		// Reconstruct from code as a remote peer would
+		if netstring.ErrorFromCode(tc.code) != tc.err {
+			t.Error(ix, "ErrorFromCode did not return the sentinel for", tc.code)
+		}
+
+		// Wrapped errors retain their code and identity
+		wrapped := fmt.Errorf("context: %w", tc.err)
+		if netstring.CodeOf(wrapped) != tc.code {
+			t.Error(ix, "CodeOf wrapped error returned", netstring.CodeOf(wrapped))
+		}
+		if !errors.Is(wrapped, tc.err) {
+			t.Error(ix, "errors.Is failed on wrapped error")
+		}
+	}
+
+	if netstring.CodeOf(errors.New("foreign")) != netstring.CodeUnknown {
+		t.Error("Foreign error should be CodeUnknown")
+	}
+	if netstring.ErrorFromCode(netstring.CodeUnknown) != nil {
+		t.Error("CodeUnknown should not map to an error")
+	}
+	if netstring.ErrorFromCode(60000) != nil {
+		t.Error("Unassigned code should not map to an error")
+	}
+	if netstring.ErrorCode(60000).String() != "Bizarre ErrorCode" {
+		t.Error("Unassigned code has a name", netstring.ErrorCode(60000).String())
+	}
+}
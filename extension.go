@@ -0,0 +1,74 @@
+package netstring
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+/*
+ExtensionKey is the key reserved for extension records. Extension records allow features
+to be added to a protocol after deployment, typically by middleware such as checksums or
+tracing, without the risk of breaking receivers which predate the feature.
+
+The convention is that an application never uses ExtensionKey for its own fields. An
+extension record is a "keyed" netstring with ExtensionKey whose value is a standard
+netstring containing the name of the extension followed by the extension payload, as
+created by [Encoder.EncodeExtension] and parsed by [ParseExtension]. The name
+distinguishes extensions from each other so any number may share the one key. An
+example of a message carrying a tracing extension:
+
+	4:nBob,20:X5:trace,abc-123-xyz,1:z,
+
+Unmarshal never reports ExtensionKey as an "unknown" key unless the "basic-struct" has
+a field for it, so a receiver which treats unknown keys as an error is unaffected by the
+addition of extensions. Receivers which want the extensions use [Message.Extension].
+*/
+const ExtensionKey Key = 'X'
+
+var errBadExtension = errors.New(errorPrefix + "Extension value does not start with a named netstring")
+
+// EncodeExtension encodes an extension record named "name" with a value of "payload". See
+// ExtensionKey. "name" must not be empty.
+func (enc *Encoder) EncodeExtension(name string, payload []byte) error {
+	if len(name) == 0 {
+		return fmt.Errorf(errorPrefix + "Extension name cannot be empty")
+	}
+	val, err := AppendNetstring(nil, NoKey, []byte(name))
+	if err != nil {
+		return err
+	}
+
+	return enc.EncodeBytes(ExtensionKey, val, payload)
+}
+
+// ParseExtension splits the value of an ExtensionKey netstring into its name and payload.
+// The returned payload refers to "val". An error is returned if "val" does not start with
+// a standard netstring containing a non-empty name.
+func ParseExtension(val []byte) (name string, payload []byte, err error) {
+	colon := bytes.IndexByte(val, ':')
+	if colon < 1 || val[0] < '1' || val[0] > '9' {
+		return "", nil, errBadExtension
+	}
+	l, err := strconv.Atoi(string(val[:colon]))
+	if err != nil || l > len(val)-colon-2 || val[colon+1+l] != ',' {
+		return "", nil, errBadExtension
+	}
+
+	return string(val[colon+1 : colon+1+l]), val[colon+2+l:], nil
+}
+
+// Extension returns the payload of the first extension record in the Message named
+// "name". The returned bool is false if there is no such extension. Malformed extension
+// records are ignored.
+func (m *Message) Extension(name string) ([]byte, bool) {
+	for _, val := range m.GetAll(ExtensionKey) {
+		n, payload, err := ParseExtension(val)
+		if err == nil && n == name {
+			return payload, true
+		}
+	}
+
+	return nil, false
+}
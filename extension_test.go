@@ -0,0 +1,81 @@
+package netstring_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestExtension(t *testing.T) {
+	var bb bytes.Buffer
+	enc := netstring.NewEncoder(&bb)
+	enc.EncodeString('n', "Bob")
+	enc.EncodeExtension("trace", []byte("abc-123-xyz"))
+	enc.EncodeExtension("crc", nil)
+	enc.EncodeBytes('z')
+	exp := "4:nBob,20:X5:trace,abc-123-xyz,7:X3:crc,,1:z,"
+	if bb.String() != exp {
+		t.Fatal("Expected", exp, "got", bb.String())
+	}
+	err := enc.EncodeExtension("", nil)
+	if err == nil {
+		t.Error("Expected error with an empty name")
+	}
+
+	dec := netstring.NewDecoder(bytes.NewReader(bb.Bytes()))
+	m, err := dec.ReadMessage('z')
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	v, ok := m.Extension("trace")
+	if !ok || string(v) != "abc-123-xyz" {
+		t.Error("trace extension wrong", ok, string(v))
+	}
+	v, ok = m.Extension("crc")
+	if !ok || len(v) != 0 {
+		t.Error("crc extension wrong", ok, string(v))
+	}
+	_, ok = m.Extension("other")
+	if ok {
+		t.Error("Did not expect to find 'other' extension")
+	}
+
+	var rec struct { // An older receiver knows nothing of extensions
+		Name string `netstring:"n"`
+	}
+	dec = netstring.NewDecoder(bytes.NewReader(bb.Bytes()))
+	unknown, err := dec.Unmarshal('z', &rec)
+	if err != nil || unknown != netstring.NoKey || rec.Name != "Bob" {
+		t.Error("Extensions should be ignored by Unmarshal", err, unknown, rec.Name)
+	}
+}
+
+func TestParseExtension(t *testing.T) {
+	testCases := []struct {
+		val     string
+		name    string
+		payload string
+		ok      bool
+	}{
+		{"5:trace,abc", "trace", "abc", true},
+		{"1:a,", "a", "", true},
+		{"", "", "", false},
+		{"0:,abc", "", "", false},
+		{"05:trace,", "", "", false},
+		{"5:trace", "", "", false},
+		{"5:trace;", "", "", false},
+		{"9:trace,", "", "", false},
+		{"x:", "", "", false},
+	}
+	for ix, tc := range testCases {
+		name, payload, err := netstring.ParseExtension([]byte(tc.val))
+		if (err == nil) != tc.ok {
+			t.Error(ix, "Unexpected error state", err)
+			continue
+		}
+		if name != tc.name || string(payload) != tc.payload {
+			t.Error(ix, "Expected", tc.name, tc.payload, "got", name, string(payload))
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package netstring
+
+import (
+	"io"
+)
+
+// FrameWriter is an io.Writer adapter which encodes every Write() as a single standard
+// netstring. It allows arbitrary byte-oriented code, such as a gob.Encoder or an image
+// upload, to be framed over netstrings without that code knowing anything about
+// netstrings. A FrameWriter *must* be constructed with NewFrameWriter.
+//
+// A zero-length Write() writes nothing as a zero-length frame conveys no information to
+// the corresponding FrameReader.
+type FrameWriter struct {
+	enc *Encoder
+}
+
+// NewFrameWriter constructs a FrameWriter which writes each frame as a netstring to
+// "output".
+func NewFrameWriter(output io.Writer) *FrameWriter {
+	return &FrameWriter{enc: NewEncoder(output)}
+}
+
+// Write encodes "p" as a single netstring. It returns len(p) if the netstring was written
+// successfully, otherwise it returns zero and the error from the Encoder.
+func (fw *FrameWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	err := fw.enc.EncodeBytes(NoKey, p)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// FrameReader is an io.Reader adapter which returns the value of one netstring per
+// Read(). It is the counterpart of FrameWriter. A FrameReader *must* be constructed with
+// NewFrameReader.
+//
+// If the buffer supplied to Read() is large enough, each Read() returns exactly one
+// netstring value. If the buffer is too small, the remainder of the value is returned by
+// subsequent Read() calls before the next netstring is decoded, thus a Read() never
+// returns bytes from more than one netstring.
+//
+// Zero-length netstrings are skipped.
+type FrameReader struct {
+	dec     *Decoder
+	pending []byte // Unread portion of the current frame
+}
+
+// NewFrameReader constructs a FrameReader which decodes netstrings from "rdr".
+func NewFrameReader(rdr io.Reader) *FrameReader {
+	return &FrameReader{dec: NewDecoder(rdr)}
+}
+
+// Read copies the value of the next netstring, or the unread remainder of the current
+// netstring, into "p". Any error from the underlying Decoder is returned once all
+// previously decoded bytes have been consumed.
+func (fr *FrameReader) Read(p []byte) (int, error) {
+	for len(fr.pending) == 0 {
+		ns, err := fr.dec.Decode()
+		if err != nil {
+			return 0, err
+		}
+		fr.pending = ns
+	}
+
+	n := copy(p, fr.pending)
+	fr.pending = fr.pending[n:]
+
+	return n, nil
+}
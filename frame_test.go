@@ -0,0 +1,77 @@
+package netstring_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestFrameWriter(t *testing.T) {
+	var bbuf bytes.Buffer
+	fw := netstring.NewFrameWriter(&bbuf)
+	for _, s := range []string{"abc", "", "wxyz"} {
+		n, err := fw.Write([]byte(s))
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if n != len(s) {
+			t.Error("Write returned", n, "expected", len(s))
+		}
+	}
+
+	exp := "3:abc,4:wxyz,"
+	if bbuf.String() != exp {
+		t.Error("FrameWriter wrote", bbuf.String(), "expected", exp)
+	}
+
+	var bw badWriter
+	bw.err = "fail"
+	bw.when = 1
+	fw = netstring.NewFrameWriter(&bw)
+	n, err := fw.Write([]byte("abc"))
+	if err == nil || n != 0 {
+		t.Error("Expected error and zero return from bad writer", n, err)
+	}
+}
+
+func TestFrameReader(t *testing.T) {
+	fr := netstring.NewFrameReader(bytes.NewBufferString("3:abc,0:,6:uvwxyz,"))
+
+	buf := make([]byte, 10)
+	n, err := fr.Read(buf)
+	if err != nil || string(buf[:n]) != "abc" {
+		t.Fatal("First Read wrong", n, err, string(buf[:n]))
+	}
+
+	small := make([]byte, 4) // Force a split frame
+	n, err = fr.Read(small)
+	if err != nil || string(small[:n]) != "uvwx" {
+		t.Fatal("Second Read wrong", n, err, string(small[:n]))
+	}
+	n, err = fr.Read(buf)
+	if err != nil || string(buf[:n]) != "yz" {
+		t.Fatal("Third Read wrong", n, err, string(buf[:n]))
+	}
+
+	n, err = fr.Read(buf)
+	if err != io.EOF || n != 0 {
+		t.Error("Expected EOF at end of frames", n, err)
+	}
+}
+
+func TestFrameRoundTrip(t *testing.T) {
+	var bbuf bytes.Buffer
+	fw := netstring.NewFrameWriter(&bbuf)
+	fw.Write([]byte("Hello "))
+	fw.Write([]byte("World"))
+
+	all, err := io.ReadAll(netstring.NewFrameReader(&bbuf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(all) != "Hello World" {
+		t.Error("Round trip returned", string(all))
+	}
+}
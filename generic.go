@@ -0,0 +1,76 @@
+package netstring
+
+import (
+	"strconv"
+	"unsafe"
+)
+
+// Number is the constraint satisfied by every integer and floating point type, including
+// named types derived from them, as accepted by EncodeNumber and ParseNumber.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// numberTraits reports whether T is a floating point type and whether it is signed, along
+// with its size in bits. These are derived arithmetically so that no reflection or
+// interface conversion is needed.
+func numberTraits[T Number]() (float, signed bool, bits int) {
+	var zero T
+	one := T(1)
+	float = one/2 != zero
+	signed = zero-one < zero
+
+	return float, signed, int(unsafe.Sizeof(zero)) * 8
+}
+
+// EncodeNumber encodes "val" with the same format as the corresponding EncodeInt64,
+// EncodeUint64, EncodeFloat32 or EncodeFloat64 function. Unlike Encode, the type of "val"
+// is checked at compile time and "val" is not converted to an interface so no allocation
+// is made.
+func EncodeNumber[T Number](enc *Encoder, key Key, val T) (err error) {
+	defer enc.stick(&err)
+	if enc.mu != nil {
+		enc.mu.Lock()
+		defer enc.mu.Unlock()
+	}
+	if enc.err != nil {
+		return enc.err
+	}
+
+	float, signed, bits := numberTraits[T]()
+	b := enc.numberBuffer[0:0:len(enc.numberBuffer)]
+	switch {
+	case float:
+		f := float64(val)
+		if enc.deterministic && f == 0 {
+			f = 0 // Drop the sign of negative zero
+		}
+		b = strconv.AppendFloat(b, f, 'f', -1, bits)
+	case signed:
+		b = strconv.AppendInt(b, int64(val), 10)
+	default:
+		b = strconv.AppendUint(b, uint64(val), 10)
+	}
+
+	return enc.encodeBytes(key, [][]byte{b})
+}
+
+// ParseNumber is the inverse of EncodeNumber. It converts "val" to a T and returns an
+// error if "val" is not a valid number or is out of range for T.
+func ParseNumber[T Number](val []byte) (T, error) {
+	float, signed, bits := numberTraits[T]()
+	s := string(val)
+	switch {
+	case float:
+		f, err := strconv.ParseFloat(s, bits)
+		return T(f), err
+	case signed:
+		i, err := strconv.ParseInt(s, 10, bits)
+		return T(i), err
+	default:
+		u, err := strconv.ParseUint(s, 10, bits)
+		return T(u), err
+	}
+}
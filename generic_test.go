@@ -0,0 +1,73 @@
+package netstring_test
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+type celsius float32
+
+type port uint16
+
+func TestEncodeNumber(t *testing.T) {
+	var bb bytes.Buffer
+	enc := netstring.NewEncoder(&bb)
+	netstring.EncodeNumber(enc, 'a', int8(-128))
+	netstring.EncodeNumber(enc, 'b', uint64(math.MaxUint64))
+	netstring.EncodeNumber(enc, 'c', celsius(21.5))
+	netstring.EncodeNumber(enc, 'd', 0.1)
+	netstring.EncodeNumber(enc, 'e', port(443))
+	netstring.EncodeNumber(enc, netstring.NoKey, -7)
+	expect := "5:a-128,21:b18446744073709551615,5:c21.5,4:d0.1,4:e443,2:-7,"
+	if bb.String() != expect {
+		t.Error("Got", bb.String(), "expected", expect)
+	}
+
+	bb.Reset() // Same wire format as the type-specific functions
+	enc.EncodeFloat32('c', 21.5)
+	enc.EncodeInt64('x', -128)
+	var cmp bytes.Buffer
+	enc = netstring.NewEncoder(&cmp)
+	netstring.EncodeNumber(enc, 'c', float32(21.5))
+	netstring.EncodeNumber(enc, 'x', int16(-128))
+	if bb.String() != cmp.String() {
+		t.Error("Format differs", bb.String(), cmp.String())
+	}
+
+	if err := netstring.EncodeNumber(enc, '#', 1); err != netstring.ErrInvalidKey {
+		t.Error("Expected ErrInvalidKey, got", err)
+	}
+
+	enc = netstring.NewEncoder(io.Discard)
+	allocs := testing.AllocsPerRun(100, func() {
+		netstring.EncodeNumber(enc, 'n', uint32(123456))
+	})
+	if allocs != 0 {
+		t.Error("EncodeNumber allocated", allocs)
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	if v, err := netstring.ParseNumber[int8]([]byte("-128")); err != nil || v != -128 {
+		t.Error("int8", v, err)
+	}
+	if _, err := netstring.ParseNumber[int8]([]byte("128")); err == nil {
+		t.Error("int8 overflow not detected")
+	}
+	if v, err := netstring.ParseNumber[port]([]byte("443")); err != nil || v != 443 {
+		t.Error("port", v, err)
+	}
+	if _, err := netstring.ParseNumber[uint]([]byte("-1")); err == nil {
+		t.Error("Negative uint not detected")
+	}
+	if v, err := netstring.ParseNumber[celsius]([]byte("21.5")); err != nil || v != 21.5 {
+		t.Error("celsius", v, err)
+	}
+	if _, err := netstring.ParseNumber[float64]([]byte("abc")); err == nil {
+		t.Error("Bad float not detected")
+	}
+}
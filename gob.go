@@ -0,0 +1,24 @@
+package netstring
+
+import (
+	"encoding/gob"
+	"io"
+)
+
+// WrapGob layers a gob.Encoder and gob.Decoder over netstring frames carried by "conn",
+// which is typically a network connection. Every Write() issued by the gob.Encoder is
+// framed as a netstring with a FrameWriter and the gob.Decoder reads those frames back via
+// a FrameReader.
+//
+// The benefit over using gob directly on "conn" is that the byte stream gains message
+// boundaries which makes request/response patterns easier to reason about and lets
+// netstring tools such as Dump inspect the traffic.
+//
+// Both ends of "conn" must use WrapGob (or the equivalent FrameWriter/FrameReader
+// arrangement) to interoperate.
+func WrapGob(conn io.ReadWriter) (enc *gob.Encoder, dec *gob.Decoder) {
+	enc = gob.NewEncoder(NewFrameWriter(conn))
+	dec = gob.NewDecoder(NewFrameReader(conn))
+
+	return
+}
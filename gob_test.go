@@ -0,0 +1,51 @@
+package netstring_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestWrapGob(t *testing.T) {
+	type request struct {
+		Name    string
+		Age     int
+		Friends []string
+	}
+
+	var bbuf bytes.Buffer
+	enc, dec := netstring.WrapGob(&bbuf)
+
+	out := []request{{"Bjorn", 21, []string{"Anna"}}, {"Bob", 73, nil}}
+	for _, r := range out {
+		err := enc.Encode(&r)
+		if err != nil {
+			t.Fatal("Encode failed", err)
+		}
+	}
+
+	// The byte stream should be pure netstrings
+	nsDec := netstring.NewDecoder(bytes.NewReader(bbuf.Bytes()))
+	for {
+		_, err := nsDec.Decode()
+		if err != nil {
+			if err != io.EOF {
+				t.Fatal("gob stream is not pure netstrings", err)
+			}
+			break
+		}
+	}
+
+	for ix, exp := range out {
+		var in request
+		err := dec.Decode(&in)
+		if err != nil {
+			t.Fatal(ix, "Decode failed", err)
+		}
+		if in.Name != exp.Name || in.Age != exp.Age || len(in.Friends) != len(exp.Friends) {
+			t.Error(ix, "Decoded", in, "expected", exp)
+		}
+	}
+}
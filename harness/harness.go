@@ -0,0 +1,222 @@
+/*
+Package harness runs scripted netstring conversations against an external command which
+speaks netstrings on its stdin and stdout. It is intended for CI interoperability tests
+against implementations of the same protocol in other languages.
+
+A Script is a series of steps, normally parsed from a text file with ParseScript. Each
+non-blank line is one step, with '#' introducing a comment line:
+
+	# Ping-pong
+	send   "Mping"
+	send   "z"
+	expect "Mpong"
+	expect "z"
+	close
+	eof
+
+Values are Go double-quoted strings so that any byte sequence, including the key of a
+"keyed" netstring, can be expressed. The steps are:
+
+	send "value"    Encode "value" as a netstring to the command's stdin
+	expect "value"  Decode a netstring from the command's stdout and compare it to "value"
+	close           Close the command's stdin
+	eof             Expect the command to close its stdout
+
+Once all steps complete, the command's stdin is closed, if not already closed, and Run
+waits for the command to exit.
+*/
+package harness
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/markdingo/netstring"
+)
+
+// Action is the type of a Step.
+type Action int
+
+// All Step Actions.
+const (
+	Send Action = iota
+	Expect
+	Close
+	EOF
+)
+
+func (a Action) String() string {
+	switch a {
+	case Send:
+		return "send"
+	case Expect:
+		return "expect"
+	case Close:
+		return "close"
+	case EOF:
+		return "eof"
+	}
+
+	return "Bizarre Action"
+}
+
+// Step is a single action in a Script. Value is only meaningful for Send and Expect.
+type Step struct {
+	Line   int // Line number in the script file, if any
+	Action Action
+	Value  []byte
+}
+
+// Script is the sequence of Steps executed by Run.
+type Script []Step
+
+// ParseScript parses a script in the format described in the package documentation.
+func ParseScript(rdr io.Reader) (Script, error) {
+	var script Script
+	scanner := bufio.NewScanner(rdr)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		arg = strings.TrimSpace(arg)
+		step := Step{Line: lineNo}
+		switch verb {
+		case "send", "expect":
+			step.Action = Send
+			if verb == "expect" {
+				step.Action = Expect
+			}
+			v, err := strconv.Unquote(arg)
+			if err != nil || len(arg) == 0 || arg[0] != '"' {
+				return nil, fmt.Errorf("harness: line %d: %s value must be a double-quoted string",
+					lineNo, verb)
+			}
+			step.Value = []byte(v)
+		case "close", "eof":
+			step.Action = Close
+			if verb == "eof" {
+				step.Action = EOF
+			}
+			if len(arg) > 0 {
+				return nil, fmt.Errorf("harness: line %d: %s takes no value", lineNo, verb)
+			}
+		default:
+			return nil, fmt.Errorf("harness: line %d: unknown step '%s'", lineNo, verb)
+		}
+		script = append(script, step)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return script, nil
+}
+
+// StepError describes the Step at which a Script failed.
+type StepError struct {
+	Step Step
+	Err  error
+}
+
+func (se *StepError) Error() string {
+	return fmt.Sprintf("harness: step %s at line %d: %s", se.Step.Action, se.Step.Line, se.Err)
+}
+
+func (se *StepError) Unwrap() error {
+	return se.Err
+}
+
+// Run starts "cmd" with pipes connected to its stdin and stdout and executes "script"
+// against it. The command's stderr is left as configured by the caller. If "ctx" is done
+// before the script completes, the command is killed.
+//
+// The first failing Step is returned as a *StepError. If all steps succeed, the error
+// from waiting for the command to exit is returned.
+func Run(ctx context.Context, cmd *exec.Cmd, script Script) error {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() { // Kill the command if ctx is done first
+		select {
+		case <-ctx.Done():
+			cmd.Process.Kill()
+		case <-done:
+		}
+	}()
+
+	enc := netstring.NewEncoder(stdin)
+	dec := netstring.NewDecoder(stdout)
+	stdinOpen := true
+	for _, step := range script {
+		err = runStep(step, enc, dec)
+		if step.Action == Close {
+			stdinOpen = false
+			err = stdin.Close()
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+			stdin.Close()
+			cmd.Process.Kill()
+			cmd.Wait()
+			return &StepError{step, err}
+		}
+	}
+
+	if stdinOpen {
+		stdin.Close()
+	}
+	io.Copy(io.Discard, stdout) // Wait requires stdout to be drained
+
+	return cmd.Wait()
+}
+
+func runStep(step Step, enc *netstring.Encoder, dec *netstring.Decoder) error {
+	switch step.Action {
+	case Send:
+		return enc.EncodeBytes(netstring.NoKey, step.Value)
+
+	case Expect:
+		ns, err := dec.Decode()
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(ns, step.Value) {
+			return fmt.Errorf("got %s, expected %s", strconv.Quote(string(ns)),
+				strconv.Quote(string(step.Value)))
+		}
+
+	case EOF:
+		ns, err := dec.Decode()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("got %s, expected EOF", strconv.Quote(string(ns)))
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package harness_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/markdingo/netstring"
+	"github.com/markdingo/netstring/harness"
+)
+
+// TestMain allows the test binary to act as the external command under test.
+func TestMain(m *testing.M) {
+	if os.Getenv("HARNESS_HELPER") == "upper" {
+		upperServer()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// upperServer echoes each netstring back in upper case until EOF.
+func upperServer() {
+	dec := netstring.NewDecoder(os.Stdin)
+	enc := netstring.NewEncoder(os.Stdout)
+	for {
+		ns, err := dec.Decode()
+		if err != nil {
+			return
+		}
+		enc.EncodeBytes(netstring.NoKey, bytes.ToUpper(ns))
+	}
+}
+
+func helperCmd() *exec.Cmd {
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), "HARNESS_HELPER=upper")
+
+	return cmd
+}
+
+func TestParseScript(t *testing.T) {
+	s, err := harness.ParseScript(strings.NewReader(
+		"# Comment\n\nsend \"a\\x00b\"\n  expect   \"A\"\nclose\neof\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s) != 4 || s[0].Line != 3 || string(s[0].Value) != "a\x00b" ||
+		s[1].Action != harness.Expect || s[3].Action != harness.EOF {
+		t.Error("Script wrong", s)
+	}
+
+	for ix, text := range []string{
+		"send hello",
+		"send",
+		"expect 'x'",
+		"close now",
+		"sned \"x\"",
+	} {
+		_, err := harness.ParseScript(strings.NewReader(text))
+		if err == nil {
+			t.Error(ix, "Expected error from", text)
+		}
+	}
+
+	if harness.Close.String() != "close" || harness.Action(99).String() != "Bizarre Action" {
+		t.Error("Action String wrong")
+	}
+}
+
+func TestRun(t *testing.T) {
+	script, _ := harness.ParseScript(strings.NewReader(
+		"send \"Mping\"\nsend \"z\"\nexpect \"MPING\"\nexpect \"Z\"\nclose\neof\n"))
+	err := harness.Run(context.Background(), helperCmd(), script)
+	if err != nil {
+		t.Error("Unexpected failure", err)
+	}
+
+	// Without explicit close
+	script, _ = harness.ParseScript(strings.NewReader("send \"x\"\nexpect \"X\""))
+	err = harness.Run(context.Background(), helperCmd(), script)
+	if err != nil {
+		t.Error("Unexpected failure", err)
+	}
+}
+
+func TestRunFailures(t *testing.T) {
+	script, _ := harness.ParseScript(strings.NewReader("send \"x\"\nexpect \"x\"\n"))
+	err := harness.Run(context.Background(), helperCmd(), script)
+	var se *harness.StepError
+	if !errors.As(err, &se) || se.Step.Line != 2 || !strings.Contains(err.Error(), `got "X"`) {
+		t.Error("Expected mismatch at line 2, not", err)
+	}
+
+	script, _ = harness.ParseScript(strings.NewReader("send \"x\"\neof\n"))
+	err = harness.Run(context.Background(), helperCmd(), script)
+	if err == nil || !strings.Contains(err.Error(), "expected EOF") {
+		t.Error("Expected EOF failure, not", err)
+	}
+
+	script, _ = harness.ParseScript(strings.NewReader("close\nexpect \"x\"\n"))
+	err = harness.Run(context.Background(), helperCmd(), script)
+	if !errors.Is(err, io.EOF) {
+		t.Error("Expected io.EOF, not", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	script, _ = harness.ParseScript(strings.NewReader("expect \"never\"\n"))
+	err = harness.Run(ctx, helperCmd(), script)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("Expected deadline exceeded, not", err)
+	}
+
+	err = harness.Run(context.Background(), exec.Command("/nonexistent/command"), script)
+	if err == nil {
+		t.Error("Expected start error")
+	}
+}
@@ -0,0 +1,193 @@
+package netstring
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"strconv"
+)
+
+// HMACKey is the key reserved for the HMAC trailer emitted by an Encoder constructed with
+// WithHMAC. Applications need only avoid this key if they use WithHMAC.
+const HMACKey Key = 'Y'
+
+/*
+WithHMAC provides lightweight message authentication for deployments which do not run
+TLS. "newHash" is a hash constructor such as sha256.New and "key" is the secret shared by
+both ends.
+
+An Encoder constructed WithHMAC emits an HMACKey trailer immediately before the
+end-of-message sentinel of every message written by Marshal, MarshalRegistered and
+EncodeMessage. Applications which write messages netstring by netstring call
+Encoder.EncodeHMAC themselves prior to the sentinel. The trailer value is the hex encoded
+HMAC of the wire form of every netstring written since the sentinel which followed the
+previous trailer, excluding any provenance message. The sentinel itself is not covered
+so each message is authenticated independently of those before it, which allows messages
+from different Encoders, such as MessageBuilders, to be interleaved on one stream.
+
+A Decoder constructed WithHMAC verifies each trailer as it is decoded by Decode or
+DecodeKeyed and does not return it to the application. A trailer which fails
+verification is a permanent ErrBadHMAC. In addition, Unmarshal and ReadMessage return
+ErrBadHMAC if the end-of-message sentinel is not immediately preceded by a verified
+trailer. Netstrings read with DecodeAtMost or ValueReader are not covered so they cannot
+be verified.
+
+WithHMAC does not prevent replay or the re-ordering of whole messages.
+*/
+func WithHMAC(key []byte, newHash func() hash.Hash) Option {
+	return func(o *options) {
+		o.hmacKey, o.hmacHash = key, newHash
+	}
+}
+
+// macWriter is the io.Writer which tees everything written by an Encoder into its HMAC.
+// The trailing "skip" bytes of the next Write, being WithPrettyOutput padding, are not
+// teed.
+type macWriter struct {
+	out  io.Writer
+	mac  hash.Hash
+	skip int
+}
+
+func (mw *macWriter) Write(p []byte) (int, error) {
+	n, err := mw.out.Write(p)
+	if hashed := len(p) - mw.skip; n > hashed {
+		mw.mac.Write(p[:hashed])
+	} else {
+		mw.mac.Write(p[:n])
+	}
+	mw.skip = 0
+
+	return n, err
+}
+
+// EncodeHMAC writes an HMACKey trailer covering every netstring written since the
+// previous trailer. It is only needed by applications which do not use Marshal,
+// MarshalRegistered or EncodeMessage to write their messages. An error is returned if
+// the Encoder was not constructed WithHMAC.
+func (enc *Encoder) EncodeHMAC() (err error) {
+	defer enc.stick(&err)
+	if enc.mu != nil {
+		enc.mu.Lock()
+		defer enc.mu.Unlock()
+	}
+	if enc.err != nil {
+		return enc.err
+	}
+
+	return enc.encodeHMAC()
+}
+
+// encodeHMAC is the guts of EncodeHMAC. The caller must hold the lock, if any.
+func (enc *Encoder) encodeHMAC() error {
+	if enc.mac == nil {
+		return errors.New(errorPrefix + "EncodeHMAC requires an Encoder constructed WithHMAC")
+	}
+	if enc.pending { // Ensure provenance is not covered by the first trailer
+		err := enc.encodeProvenance()
+		if err != nil {
+			return err
+		}
+	}
+	sum := enc.mac.Sum(nil)
+	err := enc.encodeBytes(HMACKey, [][]byte{[]byte(hex.EncodeToString(sum))})
+	enc.mac.Reset()
+	enc.macTrailed = err == nil
+
+	return err
+}
+
+// macSentinel excludes the netstring following a trailer, normally the end-of-message
+// sentinel, from the HMAC. It is called by written.
+func (enc *Encoder) macSentinel() {
+	if enc.macTrailed {
+		enc.mac.Reset()
+		enc.macTrailed = false
+	}
+}
+
+// decoderMAC is the HMAC state of a Decoder constructed WithHMAC.
+type decoderMAC struct {
+	mac      hash.Hash
+	scratch  []byte
+	verified bool   // The most recent netstring was a verified trailer
+	trailed  bool   // The netstring preceding the most recent was a verified trailer
+	keep     bool   // Retain input so the MAC can be rebuilt by Parser.Rollback
+	input    []byte // Everything written to mac since the last reset, if keep
+}
+
+func (dm *decoderMAC) write(p []byte) {
+	dm.mac.Write(p)
+	if dm.keep {
+		dm.input = append(dm.input, p...)
+	}
+}
+
+func (dm *decoderMAC) reset() {
+	dm.mac.Reset()
+	dm.input = dm.input[:0]
+}
+
+// rebuild returns the MAC to the state it was in when "input" had been written since the
+// last reset.
+func (dm *decoderMAC) rebuild(input []byte) {
+	dm.reset()
+	dm.write(input)
+}
+
+// macAdd accumulates the netstring "ns" into the HMAC. The wire form is re-created, with any
+// escaping re-applied, as the Decoder only retains the value.
+func (dec *Decoder) macAdd(ns []byte) {
+	dm := dec.mac
+	if dm.verified { // The sentinel following a trailer is not covered
+		dm.trailed, dm.verified = true, false
+		return
+	}
+	if dec.escaping {
+		ns = EscapeValue(ns)
+	} else if dec.nulPolicy == NULEscape {
+		ns = escapeNUL(ns)
+	}
+	dm.scratch = strconv.AppendInt(dm.scratch[:0], int64(len(ns)), 10)
+	dm.scratch = append(dm.scratch, leadingColon)
+	dm.write(dm.scratch)
+	dm.write(ns)
+	dm.write(trailingDelimiter)
+	dm.trailed, dm.verified = dm.verified, false
+}
+
+// hmacTrailer returns the MAC of "ns" if it is an HMACKey trailer.
+func (dec *Decoder) hmacTrailer(ns []byte) ([]byte, bool) {
+	if len(ns) == 0 || Key(ns[0]) != HMACKey {
+		return nil, false
+	}
+	if dec.longKeys { // Otherwise a long key which happens to start with HMACKey
+		return bytes.CutPrefix(ns[1:], longKeySeparator)
+	}
+
+	return ns[1:], true
+}
+
+// macVerify checks the value of an HMACKey trailer against the accumulated HMAC.
+func (dec *Decoder) macVerify(val []byte) bool {
+	dm := dec.mac
+	sum, err := hex.DecodeString(string(val))
+	ok := err == nil && hmac.Equal(sum, dm.mac.Sum(nil))
+	dm.reset()
+	dm.verified = ok
+
+	return ok
+}
+
+// macTrailed returns ErrBadHMAC if the Decoder was constructed WithHMAC and the
+// end-of-message sentinel just decoded was not immediately preceded by a verified trailer.
+func (dec *Decoder) macTrailed() error {
+	if dec.mac != nil && !dec.mac.trailed {
+		return ErrBadHMAC
+	}
+
+	return nil
+}
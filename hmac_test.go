@@ -0,0 +1,148 @@
+package netstring_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+type hmacMsg struct {
+	Name string `netstring:"n"`
+	Note string `netstring:"o"`
+}
+
+func TestHMAC(t *testing.T) {
+	secret := []byte("secret")
+	opts := []netstring.Option{netstring.WithHMAC(secret, sha256.New), netstring.WithEscaping(),
+		netstring.WithProvenance(netstring.Provenance{Producer: "test"})}
+
+	var bbuf bytes.Buffer
+	enc := netstring.NewEncoder(&bbuf, opts...)
+	err := enc.Marshal('z', &hmacMsg{"Bob", "100%"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = enc.EncodeMessage('z', netstring.KV{Key: 'n', Value: []byte("Alice")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc.EncodeString('n', "Eve") // By hand
+	enc.EncodeHMAC()
+	enc.EncodeBytes('z')
+	wire := bbuf.Bytes()
+	if bytes.Count(wire, []byte(":Y")) != 3 {
+		t.Fatal("Expected three trailers", string(wire))
+	}
+
+	dec := netstring.NewDecoder(bytes.NewReader(wire), opts...)
+	_, err = dec.ReadProvenance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m hmacMsg
+	unknown, err := dec.Unmarshal('z', &m)
+	if err != nil || unknown != netstring.NoKey || m.Note != "100%" {
+		t.Fatal("Unmarshal", m, unknown, err)
+	}
+	for ix, exp := range []string{"Alice", "Eve"} {
+		msg, err := dec.ReadMessage('z')
+		if err != nil {
+			t.Fatal(ix, "ReadMessage", err)
+		}
+		if len(msg.Fields) != 1 || string(msg.Fields[0].Value) != exp {
+			t.Error(ix, "Trailer not skipped or wrong value", msg.Fields)
+		}
+	}
+
+	// Tamper with the value, use the wrong secret or omit the trailer
+	tampered := bytes.Replace(wire, []byte("nBob"), []byte("nRob"), 1)
+	dec = netstring.NewDecoder(bytes.NewReader(tampered), opts...)
+	dec.ReadProvenance()
+	_, err = dec.Unmarshal('z', &m)
+	if !errors.Is(err, netstring.ErrBadHMAC) {
+		t.Error("Tampered message expected ErrBadHMAC, got", err)
+	}
+	_, err = dec.Decode()
+	if !errors.Is(err, netstring.ErrBadHMAC) {
+		t.Error("ErrBadHMAC should be permanent, got", err)
+	}
+
+	dec = netstring.NewDecoder(bytes.NewReader(wire), netstring.WithEscaping(),
+		netstring.WithHMAC([]byte("wrong"), sha256.New))
+	dec.ReadProvenance()
+	_, err = dec.Unmarshal('z', &m)
+	if !errors.Is(err, netstring.ErrBadHMAC) {
+		t.Error("Wrong secret expected ErrBadHMAC, got", err)
+	}
+
+	dec = netstring.NewDecoder(bytes.NewBufferString("4:nBob,1:z,"), opts...)
+	_, err = dec.ReadMessage('z')
+	if !errors.Is(err, netstring.ErrBadHMAC) {
+		t.Error("Missing trailer expected ErrBadHMAC, got", err)
+	}
+
+	err = netstring.NewEncoder(&bbuf).EncodeHMAC()
+	if err == nil {
+		t.Error("Expected error from EncodeHMAC without WithHMAC")
+	}
+}
+
+func TestHMACMessageBuilders(t *testing.T) {
+	opts := []netstring.Option{netstring.WithHMAC([]byte("secret"), sha256.New)}
+	var wire bytes.Buffer
+	for _, name := range []string{"Alice", "Bob", "Eve"} { // A fresh Encoder per message
+		mb := netstring.NewMessageBuilder(opts...)
+		err := mb.EncodeMessage('z', netstring.KV{Key: 'n', Value: []byte(name)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		mb.WriteTo(&wire)
+	}
+
+	dec := netstring.NewDecoder(&wire, opts...)
+	for ix, exp := range []string{"Alice", "Bob", "Eve"} {
+		m, err := dec.ReadMessage('z')
+		if err != nil {
+			t.Fatal(ix, err)
+		}
+		if v, _ := m.Get('n'); string(v) != exp {
+			t.Error(ix, "Expected", exp, "got", string(v))
+		}
+	}
+}
+
+func TestHMACMessageBuilderAbort(t *testing.T) {
+	opts := []netstring.Option{netstring.WithHMAC([]byte("secret"), sha256.New),
+		netstring.WithProvenance(netstring.Provenance{Producer: "test"})}
+	var wire bytes.Buffer
+	mb := netstring.NewMessageBuilder(opts...)
+	mb.EncodeString('n', "Mallory") // Abandoned mid-message
+	mb.Abort()
+	err := mb.EncodeMessage('z', netstring.KV{Key: 'n', Value: []byte("Alice")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mb.WriteTo(&wire)
+	mb.EncodeString('n', "Mallory")
+	mb.Abort()
+	mb.EncodeMessage('z', netstring.KV{Key: 'n', Value: []byte("Bob")})
+	mb.WriteTo(&wire)
+
+	dec := netstring.NewDecoder(&wire, opts...)
+	_, err = dec.ReadProvenance()
+	if err != nil {
+		t.Fatal("Provenance discarded by Abort", err)
+	}
+	for ix, exp := range []string{"Alice", "Bob"} {
+		m, err := dec.ReadMessage('z')
+		if err != nil {
+			t.Fatal(ix, err)
+		}
+		if v, _ := m.Get('n'); string(v) != exp {
+			t.Error(ix, "Expected", exp, "got", string(v))
+		}
+	}
+}
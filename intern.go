@@ -0,0 +1,107 @@
+package netstring
+
+import "sync"
+
+// Defaults applied by NewInterner to a zero or negative limit.
+const (
+	DefaultInternLength  = 64   // Longest value interned
+	DefaultInternEntries = 4096 // Most distinct values retained
+)
+
+/*
+Interner deduplicates frequently repeated decoded values, such as country codes and
+other enum-like strings, so that millions of similar messages share one copy of each
+value rather than each holding its own. This cuts both allocations and heap size for
+analytic consumers. An Interner *must* be constructed with [NewInterner].
+
+A Decoder constructed WithInterning uses the Interner for the string fields populated by
+Unmarshal. Applications using DecodeKeyed or ReadMessage call [Interner.String]
+directly. Only strings are interned as they are immutable; the []byte values returned by
+the Decoder belong to the caller and are never shared.
+
+The Interner only retains values up to its maximum length and stops adding new values
+once it holds its maximum number of entries, after which unseen values are returned as
+ordinary copies. This bounds memory when the assumption of repetition turns out to be
+wrong, such as with a field of unique identifiers.
+
+An Interner is safe for concurrent use and can be shared by any number of Decoders.
+*/
+type Interner struct {
+	maxLength  int
+	maxEntries int
+
+	mu    sync.Mutex
+	strs  map[string]string
+	stats InternerStats
+}
+
+// InternerStats are the cumulative counts returned by Interner.Stats.
+type InternerStats struct {
+	Hits    int64 // Values returned from the Interner
+	Misses  int64 // Values copied, whether subsequently retained or not
+	Entries int   // Distinct values retained
+}
+
+// NewInterner constructs an empty Interner which retains values of up to "maxLength"
+// bytes and up to "maxEntries" distinct values. A zero or negative limit means
+// DefaultInternLength or DefaultInternEntries respectively.
+func NewInterner(maxLength, maxEntries int) *Interner {
+	if maxLength <= 0 {
+		maxLength = DefaultInternLength
+	}
+	if maxEntries <= 0 {
+		maxEntries = DefaultInternEntries
+	}
+
+	return &Interner{maxLength: maxLength, maxEntries: maxEntries,
+		strs: make(map[string]string)}
+}
+
+// WithInterning causes a Decoder to obtain the string fields populated by Unmarshal from
+// "in". See Interner. Encoder ignores this Option.
+func WithInterning(in *Interner) Option {
+	return func(o *options) {
+		o.interner = in
+	}
+}
+
+// String returns "val" as a string, which is shared with previous callers if "val" has
+// been seen before.
+func (in *Interner) String(val []byte) string {
+	if len(val) > in.maxLength {
+		return string(val)
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if s, ok := in.strs[string(val)]; ok { // Lookup does not allocate
+		in.stats.Hits++
+		return s
+	}
+	in.stats.Misses++
+	s := string(val)
+	if len(in.strs) < in.maxEntries {
+		in.strs[s] = s
+	}
+
+	return s
+}
+
+// Stats returns a copy of the Interner's counts.
+func (in *Interner) Stats() InternerStats {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	st := in.stats
+	st.Entries = len(in.strs)
+
+	return st
+}
+
+// Reset discards all retained values and counts, such as when moving on to a dataset
+// with a different set of repeated values.
+func (in *Interner) Reset() {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.strs = make(map[string]string)
+	in.stats = InternerStats{}
+}
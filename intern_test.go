@@ -0,0 +1,69 @@
+package netstring_test
+
+import (
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestInterner(t *testing.T) {
+	in := netstring.NewInterner(4, 2)
+	a := in.String([]byte("NZ"))
+	b := in.String([]byte("NZ"))
+	if a != "NZ" || unsafe.StringData(a) != unsafe.StringData(b) {
+		t.Error("Repeated value not shared")
+	}
+	in.String([]byte("IS"))
+	c := in.String([]byte("AU")) // Full, so not retained
+	d := in.String([]byte("AU"))
+	if c != d || unsafe.StringData(c) == unsafe.StringData(d) {
+		t.Error("Value retained beyond maxEntries")
+	}
+	e := in.String([]byte("LONGER"))
+	if e != "LONGER" || in.Stats().Entries != 2 {
+		t.Error("Long value retained", in.Stats())
+	}
+	st := in.Stats()
+	if st.Hits != 1 || st.Misses != 4 {
+		t.Error("Unexpected stats", st)
+	}
+	in.Reset()
+	if st := in.Stats(); st != (netstring.InternerStats{}) {
+		t.Error("Reset did not clear", st)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		in.String([]byte("NZ"))
+	})
+	if allocs != 0 {
+		t.Error("Hit allocated", allocs)
+	}
+}
+
+func TestDecoderWithInterning(t *testing.T) {
+	type visit struct {
+		Country string `netstring:"c"`
+		Page    string `netstring:"p"`
+	}
+	in := netstring.NewInterner(0, 0)
+	dec := netstring.NewDecoder(strings.NewReader("3:cNZ,6:p/home,1:z,3:cNZ,6:p/cart,1:z,"),
+		netstring.WithInterning(in))
+	var v1, v2 visit
+	if _, err := dec.Unmarshal('z', &v1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dec.Unmarshal('z', &v2); err != nil {
+		t.Fatal(err)
+	}
+	if v1.Country != "NZ" || unsafe.StringData(v1.Country) != unsafe.StringData(v2.Country) {
+		t.Error("Country not interned", v1, v2)
+	}
+	if v1.Page != "/home" || v2.Page != "/cart" {
+		t.Error("Wrong pages", v1, v2)
+	}
+	if st := in.Stats(); st.Hits != 1 || st.Entries != 3 {
+		t.Error("Unexpected stats", st)
+	}
+}
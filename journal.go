@@ -0,0 +1,157 @@
+package netstring
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// Keys of the "keyed" netstring entries written by Journal.
+const (
+	JournalReceived Key = 'R' // Entry value is a message received by the Server
+	JournalSent     Key = 'S' // Entry value is a reply sent by the Server
+)
+
+/*
+Journal is an append-only, size-rotated file of messages. Each entry is a single "keyed"
+netstring whose key is JournalReceived or JournalSent and whose value is the complete
+encoded message, so a Journal is itself a netstring stream which can be audited or
+replayed with JournalReader.
+
+When appending an entry would grow the file beyond MaxSize, the file is rotated: "path"
+is renamed to "path.1", "path.1" to "path.2" and so on, keeping at most MaxFiles rotated
+files. A Journal *must* be constructed with [OpenJournal] and is safe for concurrent use.
+*/
+type Journal struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+	buf  bytes.Buffer
+	enc  *Encoder
+}
+
+// OpenJournal opens or creates the journal file at "path" for appending. A "maxSize" of
+// zero or less disables rotation.
+func OpenJournal(path string, maxSize int64, maxFiles int) (*Journal, error) {
+	j := &Journal{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	j.enc = NewEncoder(&j.buf)
+	err := j.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return j, nil
+}
+
+func (j *Journal) open() error {
+	f, err := os.OpenFile(j.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	j.f, j.size = f, fi.Size()
+
+	return nil
+}
+
+// Append writes "msg", a complete encoded message, as an entry with "key" using a single
+// Write. The file is rotated first if necessary.
+func (j *Journal) Append(key Key, msg []byte) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.buf.Reset()
+	err := j.enc.EncodeBytes(key, msg)
+	if err != nil {
+		return err
+	}
+	if j.maxSize > 0 && j.size > 0 && j.size+int64(j.buf.Len()) > j.maxSize {
+		err = j.rotate()
+		if err != nil {
+			return err
+		}
+	}
+	n, err := j.f.Write(j.buf.Bytes())
+	j.size += int64(n)
+
+	return err
+}
+
+// rotate shifts the rotated files along by one and starts a new journal file.
+func (j *Journal) rotate() error {
+	err := j.f.Close()
+	if err != nil {
+		return err
+	}
+	if j.maxFiles < 1 {
+		os.Remove(j.path)
+	} else {
+		os.Remove(j.path + "." + strconv.Itoa(j.maxFiles))
+		for n := j.maxFiles - 1; n > 0; n-- {
+			os.Rename(j.path+"."+strconv.Itoa(n), j.path+"."+strconv.Itoa(n+1))
+		}
+		err = os.Rename(j.path, j.path+".1")
+		if err != nil {
+			return err
+		}
+	}
+
+	return j.open()
+}
+
+// Close closes the journal file.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.f.Close()
+}
+
+// JournalHandler is a middleware Handler which appends each message received by the
+// Server and each reply sent to "j" before passing the request to "next". Either
+// direction is omitted if "received" or "sent" is false. Empty replies are not recorded.
+// Journal errors are not reported to the peer.
+//
+//	srv.Handler = netstring.JournalHandler(handler, journal, true, true)
+func JournalHandler(next Handler, j *Journal, received, sent bool) Handler {
+	return HandlerFunc(func(reply *MessageBuilder, req *Request) {
+		if received {
+			var buf bytes.Buffer
+			if req.Message.encode(NewEncoder(&buf)) == nil {
+				j.Append(JournalReceived, buf.Bytes())
+			}
+		}
+		next.ServeNetstring(reply, req)
+		if sent && reply.Len() > 0 {
+			j.Append(JournalSent, reply.Bytes())
+		}
+	})
+}
+
+// JournalReader reads the entries of a journal file written by Journal.
+type JournalReader struct {
+	dec *Decoder
+}
+
+// NewJournalReader constructs a JournalReader which reads from "rdr". All "opts" are
+// applied to the underlying Decoder.
+func NewJournalReader(rdr io.Reader, opts ...Option) *JournalReader {
+	return &JournalReader{dec: NewDecoder(rdr, opts...)}
+}
+
+// Next returns the key and encoded message of the next entry. The message can be decoded
+// with NewDecoder(bytes.NewReader(msg)).ReadMessage(eom). Next returns io.EOF once all
+// entries have been read.
+func (jr *JournalReader) Next() (Key, []byte, error) {
+	return jr.dec.DecodeKeyed()
+}
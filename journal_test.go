@@ -0,0 +1,84 @@
+package netstring_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	j, err := netstring.OpenJournal(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := netstring.JournalHandler(echoHandler, j, true, true)
+	req := &netstring.Request{Message: netstring.Message{EOM: 'z',
+		Fields: []netstring.KV{{Key: 'a', Value: []byte("hello")}}}}
+	reply := netstring.NewMessageBuilder()
+	h.ServeNetstring(reply, req)
+	j.Close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	jr := netstring.NewJournalReader(f)
+	for ix, expect := range []struct {
+		key netstring.Key
+		val string
+	}{
+		{netstring.JournalReceived, "hello"},
+		{netstring.JournalSent, "HELLO"},
+	} {
+		key, msg, err := jr.Next()
+		if err != nil {
+			t.Fatal(ix, err)
+		}
+		if key != expect.key {
+			t.Error(ix, "Key", key, "expected", expect.key)
+		}
+		m, err := netstring.NewDecoder(bytes.NewReader(msg)).ReadMessage('z')
+		if err != nil {
+			t.Fatal(ix, err)
+		}
+		if v, _ := m.Get('a'); string(v) != expect.val {
+			t.Error(ix, "Value", string(v), "expected", expect.val)
+		}
+	}
+	if _, _, err := jr.Next(); err != io.EOF {
+		t.Error("Expected io.EOF, not", err)
+	}
+}
+
+func TestJournalRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	j, err := netstring.OpenJournal(path, 20, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ix := 0; ix < 5; ix++ { // Each entry is 14 bytes so each rotates
+		err = j.Append(netstring.JournalReceived, []byte("6:aentry,"))
+		if err != nil {
+			t.Fatal(ix, err)
+		}
+	}
+	j.Close()
+
+	for ix, suffix := range []string{"", ".1", ".2"} {
+		fi, err := os.Stat(path + suffix)
+		if err != nil {
+			t.Error(ix, err)
+		} else if fi.Size() != 14 {
+			t.Error(ix, "Size", fi.Size())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); err == nil {
+		t.Error("MaxFiles exceeded")
+	}
+}
@@ -0,0 +1,46 @@
+package netstring
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// EncodeJSON encodes "v" with json.Marshal and writes the result as a netstring. This
+// suits protocols which use netstrings purely as a framing layer around JSON bodies. "key"
+// must pass Key.Assess() otherwise an error is returned. Use NoKey for a standard
+// netstring. Errors from json.Marshal are returned unchanged.
+func (enc *Encoder) EncodeJSON(key Key, v any) (err error) {
+	defer enc.stick(&err)
+	if err = enc.Err(); err != nil {
+		return err
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return enc.EncodeBytes(key, b)
+}
+
+// DecodeJSON decodes the next netstring and populates "v" from its value with
+// json.Unmarshal, thus reversing EncodeJSON. If "key" is NoKey the next netstring is
+// decoded with Decode, otherwise it is decoded with DecodeKeyed and an error is returned
+// if its key is not "key". Errors from json.Unmarshal are returned unchanged.
+func (dec *Decoder) DecodeJSON(key Key, v any) error {
+	var val []byte
+	var err error
+	if key == NoKey {
+		val, err = dec.Decode()
+	} else {
+		var k Key
+		k, val, err = dec.DecodeKeyed()
+		if err == nil && k != key {
+			err = fmt.Errorf(errorPrefix+"DecodeJSON expected key %s but got %s", key, k)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(val, v)
+}
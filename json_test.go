@@ -0,0 +1,59 @@
+package netstring_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestJSON(t *testing.T) {
+	type body struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+
+	var bbuf bytes.Buffer
+	enc := netstring.NewEncoder(&bbuf)
+	err := enc.EncodeJSON('j', body{"Bob", 73})
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	err = enc.EncodeJSON(netstring.NoKey, []int{1, 2})
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	err = enc.EncodeJSON('j', make(chan int))
+	if err == nil {
+		t.Error("Expected json.Marshal error for chan")
+	}
+
+	exp := `24:j{"name":"Bob","age":73},5:[1,2],`
+	if bbuf.String() != exp {
+		t.Fatal("Wire mismatch got", bbuf.String(), "expected", exp)
+	}
+
+	dec := netstring.NewDecoder(bytes.NewReader(bbuf.Bytes()))
+	var b body
+	err = dec.DecodeJSON('j', &b)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if b.Name != "Bob" || b.Age != 73 {
+		t.Error("Decoded", b)
+	}
+	var ints []int
+	err = dec.DecodeJSON(netstring.NoKey, &ints)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if len(ints) != 2 || ints[1] != 2 {
+		t.Error("Decoded", ints)
+	}
+
+	dec = netstring.NewDecoder(bytes.NewReader([]byte("3:k{},")))
+	err = dec.DecodeJSON('j', &b)
+	if err == nil {
+		t.Error("Expected error for mismatched key")
+	}
+}
@@ -31,3 +31,22 @@ func (k Key) Assess() (keyed bool, err error) {
 
 	return false, ErrInvalidKey
 }
+
+// Set assigns the single letter "s" to the Key. An empty string assigns NoKey. Together
+// with String, Set makes *Key a flag.Value so that command line tools can accept keys,
+// such as an end-of-message sentinel, with:
+//
+//	eom := netstring.Key('z')
+//	flag.Var(&eom, "eom", "End-of-message key")
+func (k *Key) Set(s string) error {
+	return k.UnmarshalText([]byte(s))
+}
+
+// fold returns the lower-case equivalent of an upper-case key, otherwise "k".
+func (k Key) fold() Key {
+	if k >= 'A' && k <= 'Z' {
+		return k + 'a' - 'A'
+	}
+
+	return k
+}
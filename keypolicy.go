@@ -0,0 +1,42 @@
+package netstring
+
+import (
+	"strings"
+)
+
+// KeyPolicy restricts which keys a peer may send. It is attached to the Decoder of a
+// connection with Decoder.SetKeyPolicy and is a simple but effective hardening measure
+// for exposed services. A KeyPolicy is consulted by DecodeKeyed and thus by all
+// message-level functions which use it, such as Unmarshal and ReadMessage.
+//
+// A key listed in Deny is always rejected. Otherwise a key listed in Allow is always
+// permitted. Keys in neither list are permitted unless DenyByDefault is set. Remember to
+// include the end-of-message sentinel in Allow when DenyByDefault is set!
+//
+// A rejected key causes ErrKeyNotPermitted to be returned. If Terminate is set the error
+// is permanent, in the same way as a parse error, which effectively terminates the
+// connection from the application's perspective.
+type KeyPolicy struct {
+	Allow         string // Keys which are permitted, e.g. "acnz"
+	Deny          string // Keys which are never permitted
+	DenyByDefault bool   // Reject keys which are not in Allow
+	Terminate     bool   // Make violations permanent
+}
+
+// Permits returns true if "key" is acceptable according to the policy.
+func (kp *KeyPolicy) Permits(key Key) bool {
+	if strings.IndexByte(kp.Deny, byte(key)) >= 0 {
+		return false
+	}
+	if strings.IndexByte(kp.Allow, byte(key)) >= 0 {
+		return true
+	}
+
+	return !kp.DenyByDefault
+}
+
+// SetKeyPolicy attaches "kp" to the Decoder such that all subsequent "keyed" netstrings
+// are checked against it. Passing nil removes any previously set policy.
+func (dec *Decoder) SetKeyPolicy(kp *KeyPolicy) {
+	dec.keyPolicy = kp
+}
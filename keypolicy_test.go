@@ -0,0 +1,64 @@
+package netstring_test
+
+import (
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestKeyPolicyPermits(t *testing.T) {
+	type testCase struct {
+		kp     netstring.KeyPolicy
+		key    netstring.Key
+		permit bool
+	}
+	testCases := []testCase{
+		{netstring.KeyPolicy{}, 'a', true},
+		{netstring.KeyPolicy{Deny: "xy"}, 'a', true},
+		{netstring.KeyPolicy{Deny: "xy"}, 'y', false},
+		{netstring.KeyPolicy{Allow: "ab", DenyByDefault: true}, 'a', true},
+		{netstring.KeyPolicy{Allow: "ab", DenyByDefault: true}, 'c', false},
+		{netstring.KeyPolicy{Allow: "ab", Deny: "b", DenyByDefault: true}, 'b', false},
+	}
+
+	for ix, tc := range testCases {
+		if tc.kp.Permits(tc.key) != tc.permit {
+			t.Error(ix, "Permits", string(tc.key), "expected", tc.permit)
+		}
+	}
+}
+
+func TestDecoderKeyPolicy(t *testing.T) {
+	dec := newWith("3:a21,3:x99,4:nBob,1:z,")
+	dec.SetKeyPolicy(&netstring.KeyPolicy{Allow: "anz", DenyByDefault: true})
+
+	k, _, err := dec.DecodeKeyed()
+	if err != nil || k != 'a' {
+		t.Fatal("First DecodeKeyed returned", k, err)
+	}
+	_, _, err = dec.DecodeKeyed()
+	if err != netstring.ErrKeyNotPermitted {
+		t.Fatal("Expected ErrKeyNotPermitted, not", err)
+	}
+	k, _, err = dec.DecodeKeyed() // Not terminated so parsing continues
+	if err != nil || k != 'n' {
+		t.Fatal("DecodeKeyed after violation returned", k, err)
+	}
+
+	dec = newWith("3:x99,4:nBob,1:z,")
+	dec.SetKeyPolicy(&netstring.KeyPolicy{Deny: "x", Terminate: true})
+	_, err = dec.ReadMessage('z')
+	if err != netstring.ErrKeyNotPermitted {
+		t.Fatal("Expected ErrKeyNotPermitted, not", err)
+	}
+	_, _, err = dec.DecodeKeyed()
+	if err != netstring.ErrKeyNotPermitted {
+		t.Error("Expected terminating policy to make error permanent, not", err)
+	}
+
+	dec.SetKeyPolicy(nil) // Removing the policy does not undo termination
+	_, err = dec.Decode()
+	if err != netstring.ErrKeyNotPermitted {
+		t.Error("Expected permanent error, not", err)
+	}
+}
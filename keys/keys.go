@@ -0,0 +1,109 @@
+/*
+Package keys defines conventional netstring keys with common semantics so that
+independent applications using "keyed" netstrings converge on compatible messages by
+default.
+
+The conventions are:
+
+	M  MessageType   Application defined message type, first in the message
+	V  Version       Version of the message type
+	R  RequestID     Opaque identifier correlating requests and replies
+	T  Timestamp     RFC3339Nano time in UTC
+	E  Error         Error text (also used by netstring.Encoder.EncodeError)
+	C  ErrorCode     netstring.ErrorCode (also used by netstring.Encoder.EncodeError)
+	z  EOM           End-of-message sentinel
+
+Applications remain free to use any other key for their own fields. Helpers are provided
+to emit the conventional keys with an Encoder and to extract them from a decoded
+netstring.Message.
+*/
+package keys
+
+import (
+	"time"
+
+	"github.com/markdingo/netstring"
+)
+
+// The conventional keys.
+const (
+	MessageType netstring.Key = 'M'
+	Version     netstring.Key = 'V'
+	RequestID   netstring.Key = netstring.ErrorRequestIDKey
+	Timestamp   netstring.Key = 'T'
+	Error       netstring.Key = netstring.ErrorTextKey
+	ErrorCode   netstring.Key = netstring.ErrorCodeKey
+	EOM         netstring.Key = 'z'
+)
+
+// TimestampLayout is the time.Format layout of Timestamp values.
+const TimestampLayout = time.RFC3339Nano
+
+// Header contains the conventional values found at the start of a message.
+type Header struct {
+	MessageType string
+	Version     string
+	RequestID   string
+	Timestamp   time.Time // Zero if not present
+}
+
+// EncodeHeader encodes the MessageType, Version, RequestID and Timestamp of "h" as
+// "keyed" netstrings. Empty strings and a zero Timestamp are omitted. The message type
+// is always encoded first so that receivers can dispatch on it.
+func EncodeHeader(enc *netstring.Encoder, h Header) error {
+	err := enc.EncodeString(MessageType, h.MessageType)
+	if err != nil {
+		return err
+	}
+	if len(h.Version) > 0 {
+		err = enc.EncodeString(Version, h.Version)
+		if err != nil {
+			return err
+		}
+	}
+	if len(h.RequestID) > 0 {
+		err = enc.EncodeString(RequestID, h.RequestID)
+		if err != nil {
+			return err
+		}
+	}
+	if !h.Timestamp.IsZero() {
+		err = EncodeTimestamp(enc, h.Timestamp)
+	}
+
+	return err
+}
+
+// EncodeTimestamp encodes "t" in UTC with TimestampLayout using the Timestamp key.
+func EncodeTimestamp(enc *netstring.Encoder, t time.Time) error {
+	return enc.EncodeTime(Timestamp, t.UTC(), TimestampLayout)
+}
+
+// EncodeEOM encodes the conventional end-of-message sentinel.
+func EncodeEOM(enc *netstring.Encoder) error {
+	return enc.EncodeBytes(EOM)
+}
+
+// ParseTimestamp converts a Timestamp value back to a time.Time.
+func ParseTimestamp(val []byte) (time.Time, error) {
+	return time.Parse(TimestampLayout, string(val))
+}
+
+// HeaderOf extracts the conventional header values from "m". Absent values are left
+// empty. An error is only returned if a Timestamp is present but invalid.
+func HeaderOf(m *netstring.Message) (h Header, err error) {
+	if v, ok := m.Get(MessageType); ok {
+		h.MessageType = string(v)
+	}
+	if v, ok := m.Get(Version); ok {
+		h.Version = string(v)
+	}
+	if v, ok := m.Get(RequestID); ok {
+		h.RequestID = string(v)
+	}
+	if v, ok := m.Get(Timestamp); ok {
+		h.Timestamp, err = ParseTimestamp(v)
+	}
+
+	return
+}
@@ -0,0 +1,58 @@
+package keys_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/markdingo/netstring"
+	"github.com/markdingo/netstring/keys"
+)
+
+func TestHeaderRoundTrip(t *testing.T) {
+	var bbuf bytes.Buffer
+	enc := netstring.NewEncoder(&bbuf)
+
+	ts := time.Date(2023, 5, 17, 10, 11, 12, 13, time.FixedZone("NZST", 12*3600))
+	out := keys.Header{MessageType: "login", Version: "2", RequestID: "r1", Timestamp: ts}
+	err := keys.EncodeHeader(enc, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc.EncodeString('u', "bob")
+	keys.EncodeEOM(enc)
+
+	exp := "6:Mlogin,2:V2,3:Rr1,31:T2023-05-16T22:11:12.000000013Z,4:ubob,1:z,"
+	if bbuf.String() != exp {
+		t.Fatal("Wire format wrong", bbuf.String())
+	}
+
+	dec := netstring.NewDecoder(&bbuf)
+	m, err := dec.ReadMessage(keys.EOM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in, err := keys.HeaderOf(&m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in.MessageType != out.MessageType || in.Version != out.Version ||
+		in.RequestID != out.RequestID || !in.Timestamp.Equal(ts) {
+		t.Error("Header mismatch", in, out)
+	}
+}
+
+func TestHeaderOmissions(t *testing.T) {
+	var bbuf bytes.Buffer
+	enc := netstring.NewEncoder(&bbuf)
+	keys.EncodeHeader(enc, keys.Header{MessageType: "ping"})
+	if bbuf.String() != "5:Mping," {
+		t.Error("Empty values not omitted", bbuf.String())
+	}
+
+	m := netstring.Message{EOM: keys.EOM, Fields: []netstring.KV{{Key: keys.Timestamp, Value: []byte("yesterday")}}}
+	_, err := keys.HeaderOf(&m)
+	if err == nil {
+		t.Error("Expected error from invalid timestamp")
+	}
+}
@@ -0,0 +1,141 @@
+package netstring
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// errNeedMore is returned by pushFeed to tell a push-driven Decoder, as used by
+// LintWriter and Parser, that it has consumed everything supplied thus far. It never
+// escapes to the application.
+var errNeedMore = errors.New(errorPrefix + "push decoder needs more bytes")
+
+// pushFeed is the io.Reader which supplies pushed bytes to a LintWriter or Parser Decoder.
+type pushFeed struct {
+	buf []byte
+}
+
+func (pf *pushFeed) Read(p []byte) (int, error) {
+	if len(pf.buf) == 0 {
+		return 0, errNeedMore
+	}
+	n := copy(p, pf.buf)
+	pf.buf = pf.buf[n:]
+
+	return n, nil
+}
+
+// needMore resets "dec" after it has returned errNeedMore so that it resumes parsing once
+// more bytes are pushed.
+func needMore(dec *Decoder) {
+	dec.parseError = nil // Not really an error, just wait for more
+	dec.at, dec.end = 0, 0
+}
+
+/*
+LintWriter is a debugging io.Writer which re-parses everything written to it with a
+strict Decoder before forwarding it to the underlying io.Writer. It is designed to sit
+between an Encoder and its destination, particularly when application layers such as
+transforms or middleware manipulate the byte stream, so that the process never emits a
+malformed netstring. A LintWriter *must* be constructed with NewLintWriter.
+
+	lw := netstring.NewLintWriter(conn)
+	enc := netstring.NewEncoder(lw)
+
+Bytes are held back until they form a complete, valid netstring at which point they are
+forwarded. Once a malformed netstring is detected, nothing more is forwarded and every
+subsequent Write returns the same error. Use SetPanic to turn lint errors into panics
+which is often more useful during development.
+*/
+type LintWriter struct {
+	out     io.Writer
+	dec     *Decoder
+	feed    pushFeed
+	pending []byte // Bytes parsed but not yet forwarded
+	sent    int64  // Bytes forwarded thus far
+	err     error
+	panic   bool
+}
+
+// NewLintWriter constructs a LintWriter which forwards valid netstrings to "output". Any
+// Options, such as WithMaximumLength, are applied to the checking Decoder.
+func NewLintWriter(output io.Writer, opts ...Option) *LintWriter {
+	lw := &LintWriter{out: output}
+	opts = append(opts[:len(opts):len(opts)], func(o *options) {
+		o.bufferSize = 0 // consumed must see every byte taken from the feed
+	})
+	lw.dec = NewDecoder(&lw.feed, opts...)
+
+	return lw
+}
+
+// SetPanic causes lint errors to panic rather than be returned by Write and Close.
+func (lw *LintWriter) SetPanic(on bool) {
+	lw.panic = on
+}
+
+// fail records "err" as the permanent error of the LintWriter.
+func (lw *LintWriter) fail(err error) error {
+	lw.err = fmt.Errorf(errorPrefix+"Lint: %w", err)
+	if lw.panic {
+		panic(lw.err)
+	}
+
+	return lw.err
+}
+
+// Write parses "p" and forwards all netstrings which are now complete to the underlying
+// io.Writer with a single Write. It returns len(p) and nil if "p" is consistent with a
+// valid netstring stream, otherwise it returns zero and the lint or io.Writer error.
+func (lw *LintWriter) Write(p []byte) (int, error) {
+	if lw.err != nil {
+		return 0, lw.err
+	}
+	lw.pending = append(lw.pending, p...)
+	lw.feed.buf = p
+
+	// The wire form of a netstring can differ from its decoded value, such as with
+	// WithEscaping, and some netstrings, such as HMAC trailers, are never returned, so
+	// complete netstrings are measured by the bytes the Decoder has consumed.
+	var ready int // Bytes of pending which form complete netstrings
+	for {
+		_, err := lw.dec.Decode()
+		if err == errNeedMore {
+			needMore(lw.dec)
+			if lw.dec.state == parseFirstByte { // Include any trailing padding
+				ready = int(lw.dec.consumed() - lw.sent)
+			}
+			break
+		}
+		if err != nil {
+			return 0, lw.fail(err)
+		}
+		ready = int(lw.dec.consumed() - lw.sent)
+	}
+
+	if ready > 0 {
+		_, err := lw.out.Write(lw.pending[:ready])
+		if err != nil {
+			lw.err = err
+			return 0, err
+		}
+		lw.pending = append(lw.pending[:0], lw.pending[ready:]...)
+		lw.sent += int64(ready)
+	}
+
+	return len(p), nil
+}
+
+// Close checks that no partial netstring remains unforwarded. It does not close the
+// underlying io.Writer.
+func (lw *LintWriter) Close() error {
+	if lw.err != nil {
+		return lw.err
+	}
+	if len(lw.pending) > 0 {
+		return lw.fail(io.ErrUnexpectedEOF)
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package netstring_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestLintWriter(t *testing.T) {
+	var out countingWriter
+	lw := netstring.NewLintWriter(&out)
+	enc := netstring.NewEncoder(lw)
+	enc.EncodeString('a', "hello")
+	enc.EncodeInt(netstring.NoKey, 1234567890)
+	enc.EncodeBytes('z')
+	err := lw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "6:ahello,10:1234567890,1:z," {
+		t.Error("Forwarded bytes wrong", out.String())
+	}
+	if out.writes != 3 { // One per completed netstring as Encoder writes piecewise
+		t.Error("Expected one forwarding Write per netstring, not", out.writes)
+	}
+
+	// Multiple netstrings and a partial in one Write
+	out = countingWriter{}
+	lw = netstring.NewLintWriter(&out)
+	n, err := lw.Write([]byte("1:a,2:bc,3:d"))
+	if n != 12 || err != nil || out.String() != "1:a,2:bc," {
+		t.Error("Partial forwarding wrong", n, err, out.String())
+	}
+	err = lw.Close()
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Error("Expected ErrUnexpectedEOF from Close, not", err)
+	}
+}
+
+// The wire form differs from the decoded values with these Options, so forwarding must
+// follow the bytes consumed rather than the value lengths.
+func TestLintWriterOptions(t *testing.T) {
+	hmac := netstring.WithHMAC([]byte("secret"), sha256.New)
+	testCases := []struct {
+		encOpts  []netstring.Option
+		lintOpts []netstring.Option
+	}{
+		{[]netstring.Option{netstring.WithEscaping()}, []netstring.Option{netstring.WithEscaping()}},
+		{[]netstring.Option{hmac}, []netstring.Option{hmac}},
+		{[]netstring.Option{netstring.WithPrettyOutput('z')},
+			[]netstring.Option{netstring.WithFramePadding("\n")}},
+	}
+
+	for ix, tc := range testCases {
+		var ref, out bytes.Buffer
+		lw := netstring.NewLintWriter(&out, tc.lintOpts...)
+		enc := netstring.NewEncoder(io.MultiWriter(&ref, lw), tc.encOpts...)
+		for _, val := range []string{"x\x01y", "%2"} {
+			err := enc.EncodeMessage('z', netstring.KV{Key: 'a', Value: []byte(val)})
+			if err != nil {
+				t.Fatal(ix, err)
+			}
+			if out.String() != ref.String() {
+				t.Errorf("%d Forwarded %q, expected %q", ix, out.String(), ref.String())
+			}
+		}
+		if err := lw.Close(); err != nil {
+			t.Error(ix, "Close", err)
+		}
+	}
+}
+
+func TestLintWriterErrors(t *testing.T) {
+	var out bytes.Buffer
+	lw := netstring.NewLintWriter(&out)
+	lw.Write([]byte("3:abc,"))
+	n, err := lw.Write([]byte("01:x,"))
+	if n != 0 || !errors.Is(err, netstring.ErrLeadingZero) {
+		t.Error("Expected ErrLeadingZero, not", n, err)
+	}
+	_, err2 := lw.Write([]byte("1:a,"))
+	if err2 != err {
+		t.Error("Lint error should be sticky", err2)
+	}
+	if lw.Close() != err {
+		t.Error("Close should return the lint error")
+	}
+	if out.String() != "3:abc," {
+		t.Error("Nothing after the error should be forwarded", out.String())
+	}
+
+	lw = netstring.NewLintWriter(&out, netstring.WithMaximumLength(2))
+	_, err = lw.Write([]byte("3:abc,"))
+	if !errors.Is(err, netstring.ErrLengthToLong) {
+		t.Error("Options not applied", err)
+	}
+
+	lw = netstring.NewLintWriter(&badWriter{when: 1, err: "bad"})
+	_, err = lw.Write([]byte("1:a,"))
+	if err == nil || err.Error() != "bad" {
+		t.Error("Expected underlying writer error, not", err)
+	}
+
+	lw = netstring.NewLintWriter(&out)
+	lw.SetPanic(true)
+	defer func() {
+		r := recover()
+		if r == nil {
+			t.Error("Expected panic")
+		}
+	}()
+	lw.Write([]byte("x"))
+}
@@ -0,0 +1,278 @@
+//go:build go1.21
+
+package netstring
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Keys of the log record messages emitted by LogShipper. Each record is a message of
+// these "keyed" netstrings terminated by LogEOM.
+const (
+	LogTimeKey    Key = 't' // Record time in time.RFC3339Nano format, absent if zero
+	LogLevelKey   Key = 'l' // As returned by slog.Level.String, e.g. "INFO"
+	LogMessageKey Key = 'm' // The log message
+	LogAttrKey    Key = 'a' // One per attribute as "name=value", with "group." prefixes
+	LogEOM        Key = 'z' // End-of-message sentinel
+)
+
+// Reconnect backoff limits of LogShipper.
+const (
+	logShipMinBackoff = 50 * time.Millisecond
+	logShipMaxBackoff = 5 * time.Second
+)
+
+/*
+LogShipper ships structured log records as netstring messages over a connection so that
+applications can send their logs to a collector using this package end to end. Records
+are supplied by the slog.Handler returned by Handler or by the io.Writer returned by
+Writer. A LogShipper *must* be constructed with [NewLogShipper].
+
+Records are encoded immediately and placed in a bounded in-memory spool from which a
+background goroutine writes them to the connection. If the connection fails, it is
+re-dialled with exponential backoff while records accumulate in the spool. Logging never
+blocks: if the spool is full the record is dropped and counted by Dropped. A record
+which was being written when the connection failed is written again on the new
+connection, so the collector may occasionally see a record twice.
+
+A receiver reads each record with Decoder.ReadMessage(LogEOM).
+*/
+type LogShipper struct {
+	dial             DialFunc
+	network, address string
+	spool            chan []byte
+	dropped          atomic.Int64
+	ctx              context.Context
+	cancel           context.CancelFunc
+	closeOnce        sync.Once
+	done             chan struct{}
+}
+
+// NewLogShipper constructs a LogShipper which connects to "address" on the named network
+// with "dial", which is typically nil for the default of net.Dialer. The spool holds up
+// to "spoolSize" records. The first connection is made in the background so
+// NewLogShipper never blocks.
+func NewLogShipper(dial DialFunc, network, address string, spoolSize int) *LogShipper {
+	if dial == nil {
+		dial = dialNet
+	}
+	ls := &LogShipper{dial: dial, network: network, address: address,
+		spool: make(chan []byte, spoolSize), done: make(chan struct{})}
+	ls.ctx, ls.cancel = context.WithCancel(context.Background())
+	go ls.run()
+
+	return ls
+}
+
+// Dropped returns the number of records dropped because the spool was full or the
+// LogShipper was closed.
+func (ls *LogShipper) Dropped() int64 {
+	return ls.dropped.Load()
+}
+
+// Close stops the LogShipper. Records still in the spool are written if the connection
+// is up, otherwise they are dropped. Records logged after Close are dropped.
+func (ls *LogShipper) Close() error {
+	ls.closeOnce.Do(ls.cancel)
+	<-ls.done
+
+	return nil
+}
+
+// send places an encoded record in the spool without blocking.
+func (ls *LogShipper) send(msg []byte) {
+	if ls.ctx.Err() != nil {
+		ls.dropped.Add(1)
+		return
+	}
+	select {
+	case ls.spool <- msg:
+	default:
+		ls.dropped.Add(1)
+	}
+}
+
+// run writes spooled records to the connection, re-dialling as needed, until Close.
+func (ls *LogShipper) run() {
+	defer close(ls.done)
+	var conn Transport
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	backoff := logShipMinBackoff
+	for {
+		var msg []byte
+		select {
+		case msg = <-ls.spool:
+		case <-ls.ctx.Done():
+			ls.drain(conn)
+			return
+		}
+		for { // Until msg is written or Close
+			if conn == nil {
+				var err error
+				conn, err = ls.dial(ls.ctx, ls.network, ls.address)
+				if err != nil {
+					conn = nil
+					select {
+					case <-time.After(backoff):
+					case <-ls.ctx.Done():
+						ls.dropped.Add(int64(1 + len(ls.spool)))
+						return
+					}
+					if backoff *= 2; backoff > logShipMaxBackoff {
+						backoff = logShipMaxBackoff
+					}
+					continue
+				}
+				backoff = logShipMinBackoff
+			}
+			_, err := conn.Write(msg)
+			if err == nil {
+				break
+			}
+			conn.Close()
+			conn = nil
+		}
+	}
+}
+
+// drain writes whatever remains in the spool to "conn" as part of Close.
+func (ls *LogShipper) drain(conn Transport) {
+	for {
+		select {
+		case msg := <-ls.spool:
+			if conn == nil {
+				ls.dropped.Add(1)
+				continue
+			}
+			_, err := conn.Write(msg)
+			if err != nil {
+				ls.dropped.Add(1)
+				conn = nil
+			}
+		default:
+			return
+		}
+	}
+}
+
+// record encodes a log record as a message.
+func record(t time.Time, level slog.Level, msg string, attrs []byte) []byte {
+	var b []byte
+	if !t.IsZero() {
+		b, _ = AppendNetstring(b, LogTimeKey, t.AppendFormat(nil, time.RFC3339Nano))
+	}
+	b, _ = AppendNetstring(b, LogLevelKey, []byte(level.String()))
+	b, _ = AppendNetstring(b, LogMessageKey, []byte(msg))
+	b = append(b, attrs...)
+	b, _ = AppendNetstring(b, LogEOM)
+
+	return b
+}
+
+// Handler returns an slog.Handler which ships each record. Of "opts", which may be nil,
+// only Level is used.
+func (ls *LogShipper) Handler(opts *slog.HandlerOptions) slog.Handler {
+	h := &logHandler{ls: ls}
+	if opts != nil && opts.Level != nil {
+		h.level = opts.Level
+	}
+
+	return h
+}
+
+// logHandler is the slog.Handler returned by LogShipper.Handler.
+type logHandler struct {
+	ls     *LogShipper
+	level  slog.Leveler // nil means slog.LevelInfo
+	attrs  []byte       // Encoded netstrings from WithAttrs
+	prefix string       // Accumulated WithGroup names, each followed by a '.'
+}
+
+func (h *logHandler) Enabled(_ context.Context, level slog.Level) bool {
+	threshold := slog.LevelInfo
+	if h.level != nil {
+		threshold = h.level.Level()
+	}
+
+	return level >= threshold
+}
+
+func (h *logHandler) Handle(_ context.Context, r slog.Record) error {
+	attrs := h.attrs
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = appendAttr(attrs, h.prefix, a)
+		return true
+	})
+	h.ls.send(record(r.Time, r.Level, r.Message, attrs))
+
+	return nil
+}
+
+func (h *logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	nh := *h
+	nh.attrs = append([]byte(nil), h.attrs...)
+	for _, a := range attrs {
+		nh.attrs = appendAttr(nh.attrs, h.prefix, a)
+	}
+
+	return &nh
+}
+
+func (h *logHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	nh := *h
+	nh.prefix += name + "."
+
+	return &nh
+}
+
+// appendAttr appends "a" as one LogAttrKey netstring per leaf attribute.
+func appendAttr(b []byte, prefix string, a slog.Attr) []byte {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return b
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			b = appendAttr(b, prefix, ga)
+		}
+		return b
+	}
+	b, _ = AppendNetstring(b, LogAttrKey, []byte(prefix+a.Key+"="+a.Value.String()))
+
+	return b
+}
+
+// Writer returns an io.Writer which ships each Write as a record at slog.LevelInfo with
+// the written bytes, less any trailing newline, as the message. This suits the standard
+// log package, e.g. log.SetOutput(ls.Writer()). Write never fails.
+func (ls *LogShipper) Writer() io.Writer {
+	return logWriter{ls}
+}
+
+type logWriter struct {
+	ls *LogShipper
+}
+
+func (lw logWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSuffix(string(p), "\n")
+	lw.ls.send(record(time.Now(), slog.LevelInfo, msg, nil))
+
+	return len(p), nil
+}
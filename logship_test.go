@@ -0,0 +1,101 @@
+//go:build go1.21
+
+package netstring_test
+
+import (
+	"context"
+	"errors"
+	"log"
+	"log/slog"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+// pipeDialer fails "failures" times then returns one end of a net.Pipe whose other end is
+// sent to "accepted".
+func pipeDialer(failures int32, accepted chan<- net.Conn) netstring.DialFunc {
+	var calls atomic.Int32
+	return func(ctx context.Context, network, address string) (netstring.Transport, error) {
+		if calls.Add(1) <= failures {
+			return nil, errors.New("connection refused")
+		}
+		client, server := net.Pipe()
+		accepted <- server
+		return client, nil
+	}
+}
+
+func TestLogShipper(t *testing.T) {
+	accepted := make(chan net.Conn, 1)
+	ls := netstring.NewLogShipper(pipeDialer(2, accepted), "tcp", "collector:514", 10)
+
+	logger := slog.New(ls.Handler(&slog.HandlerOptions{Level: slog.LevelDebug}))
+	logger.Debug("starting", "port", 80)
+	logger.WithGroup("req").With("id", 7).Info("done", slog.Group("user", "name", "bob"))
+	stdLog := log.New(ls.Writer(), "", 0)
+	stdLog.Println("plain")
+
+	conn := <-accepted // Records were spooled while dial failed
+	defer conn.Close()
+	dec := netstring.NewDecoder(conn)
+	exp := []struct {
+		level, msg string
+		attrs      []string
+	}{
+		{"DEBUG", "starting", []string{"port=80"}},
+		{"INFO", "done", []string{"req.id=7", "req.user.name=bob"}},
+		{"INFO", "plain", nil},
+	}
+	for ix, e := range exp {
+		m, err := dec.ReadMessage(netstring.LogEOM)
+		if err != nil {
+			t.Fatal(ix, "ReadMessage", err)
+		}
+		if _, ok := m.Get(netstring.LogTimeKey); !ok {
+			t.Error(ix, "Missing time")
+		}
+		if v, _ := m.Get(netstring.LogLevelKey); string(v) != e.level {
+			t.Error(ix, "Level", string(v), "expected", e.level)
+		}
+		if v, _ := m.Get(netstring.LogMessageKey); string(v) != e.msg {
+			t.Error(ix, "Message", string(v), "expected", e.msg)
+		}
+		attrs := m.GetStrings(netstring.LogAttrKey)
+		if len(attrs) != len(e.attrs) {
+			t.Fatal(ix, "Attrs", attrs, "expected", e.attrs)
+		}
+		for ax := range attrs {
+			if attrs[ax] != e.attrs[ax] {
+				t.Error(ix, "Attr", attrs[ax], "expected", e.attrs[ax])
+			}
+		}
+	}
+
+	if slog.New(ls.Handler(nil)).Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("Default level should be Info")
+	}
+	go func() { // Close drains the spool
+		dec.ReadMessage(netstring.LogEOM)
+	}()
+	stdLog.Println("last")
+	ls.Close()
+	if ls.Dropped() != 0 {
+		t.Error("Unexpected drops", ls.Dropped())
+	}
+}
+
+func TestLogShipperDropped(t *testing.T) {
+	ls := netstring.NewLogShipper(pipeDialer(1000, nil), "tcp", "collector:514", 1)
+	logger := slog.New(ls.Handler(nil))
+	for ix := 0; ix < 3; ix++ {
+		logger.Info("lost")
+	}
+	ls.Close()
+	logger.Info("after close")
+	if ls.Dropped() != 4 {
+		t.Error("Expected 4 drops, got", ls.Dropped())
+	}
+}
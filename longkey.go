@@ -0,0 +1,144 @@
+package netstring
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// MaximumLongKeyLength is the maximum number of letters in a LongKey.
+const MaximumLongKeyLength = 4
+
+// longKeySeparator follows the key of every "keyed" netstring WithLongKeys.
+var longKeySeparator = []byte{'='}
+
+/*
+LongKey is a key of one to MaximumLongKeyLength letters, such as "ct" for content-type,
+for protocols which have outgrown single letter keys. LongKeys are only available to an
+Encoder and Decoder constructed WithLongKeys, in which mode the key of every "keyed"
+netstring is followed by an '=' separator so that keys of different lengths can be told
+apart. The message used in the package documentation, with a long key added, becomes:
+
+	4:a=21,9:C=Iceland,7:n=Bjorn,7:ct=html,2:z=,
+
+Long keys are encoded with [Encoder.EncodeLongKeyed] and decoded with
+[Decoder.DecodeLongKeyed]. All other functions, such as Encode*(), DecodeKeyed, Marshal
+and Unmarshal, continue to work with single letter keys in this mode, but DecodeKeyed
+returns an error wrapping ErrInvalidKey for a netstring with a long key.
+*/
+type LongKey string
+
+// Assess returns ErrInvalidKey unless the LongKey consists of one to MaximumLongKeyLength
+// letters, each of which passes Key.Assess.
+func (lk LongKey) Assess() error {
+	if len(lk) == 0 || len(lk) > MaximumLongKeyLength {
+		return ErrInvalidKey
+	}
+	for ix := 0; ix < len(lk); ix++ {
+		keyed, err := Key(lk[ix]).Assess()
+		if err != nil || !keyed {
+			return ErrInvalidKey
+		}
+	}
+
+	return nil
+}
+
+// WithLongKeys causes an Encoder and Decoder to follow the key of every "keyed" netstring
+// with a separator so that LongKeys can be used. See LongKey. Both peers must use this
+// Option.
+func WithLongKeys() Option {
+	return func(o *options) {
+		o.longKeys = true
+	}
+}
+
+// keyPrefix returns the number of bytes which precede the value of a "keyed" netstring.
+func (enc *Encoder) keyPrefix() int {
+	if enc.longKeys {
+		return 1 + len(longKeySeparator)
+	}
+
+	return 1
+}
+
+// EncodeLongKeyed encodes the variadic arguments as a single "keyed" netstring with a
+// LongKey. The Encoder must have been constructed WithLongKeys. Single letter keys are
+// identical to EncodeBytes whereas netstrings with longer keys are counted by Stats, and
+// reported to SetHook, as standard netstrings.
+func (enc *Encoder) EncodeLongKeyed(key LongKey, val ...[]byte) (err error) {
+	if !enc.longKeys {
+		return fmt.Errorf(errorPrefix + "EncodeLongKeyed requires WithLongKeys")
+	}
+	err = key.Assess()
+	if err != nil {
+		return err
+	}
+	if len(key) == 1 {
+		return enc.EncodeBytes(Key(key[0]), val...)
+	}
+
+	defer enc.stick(&err)
+	if enc.mu != nil {
+		enc.mu.Lock()
+		defer enc.mu.Unlock()
+	}
+	if enc.err != nil {
+		return enc.err
+	}
+	prefixed := append([][]byte{[]byte(key), longKeySeparator}, val...)
+
+	return enc.encodeBytes(NoKey, prefixed)
+}
+
+// DecodeLongKeyed is the LongKey equivalent of DecodeKeyed. It returns the next
+// available netstring along with its LongKey, which may well be a single letter. The
+// Decoder must have been constructed WithLongKeys. A KeyPolicy applies to single letter
+// keys only.
+func (dec *Decoder) DecodeLongKeyed() (LongKey, []byte, error) {
+	if !dec.longKeys {
+		return "", nil, fmt.Errorf(errorPrefix + "DecodeLongKeyed requires WithLongKeys")
+	}
+	ns, err := dec.next()
+	if err != nil {
+		return "", nil, err
+	}
+	if ns == nil {
+		return "", nil, dec.parseError
+	}
+	if len(ns) == 0 {
+		return "", nil, ErrZeroKey
+	}
+	lk, val, err := splitLongKey(ns)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(lk) == 1 && dec.keyPolicy != nil && !dec.keyPolicy.Permits(Key(lk[0])) {
+		if dec.keyPolicy.Terminate {
+			dec.parseError = ErrKeyNotPermitted
+		}
+		return "", nil, ErrKeyNotPermitted
+	}
+
+	return lk, val, nil
+}
+
+// splitLongKey splits the value of a "keyed" netstring WithLongKeys into its LongKey and
+// value. ErrInvalidKey is returned if the value does not start with a valid LongKey and
+// separator.
+func splitLongKey(ns []byte) (LongKey, []byte, error) {
+	head := ns
+	if len(head) > MaximumLongKeyLength+len(longKeySeparator) { // Don't scan the whole value
+		head = head[:MaximumLongKeyLength+len(longKeySeparator)]
+	}
+	ix := bytes.Index(head, longKeySeparator)
+	if ix < 1 || ix > MaximumLongKeyLength {
+		return "", nil, ErrInvalidKey
+	}
+	lk := LongKey(ns[:ix])
+	err := lk.Assess()
+	if err != nil {
+		return "", nil, err
+	}
+
+	return lk, ns[ix+len(longKeySeparator):], nil
+}
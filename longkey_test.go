@@ -0,0 +1,89 @@
+package netstring_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestLongKeys(t *testing.T) {
+	var bb bytes.Buffer
+	enc := netstring.NewEncoder(&bb, netstring.WithLongKeys())
+	enc.EncodeInt('a', 21)
+	enc.EncodeString('C', "Iceland")
+	enc.EncodeLongKeyed("n", []byte("Bjorn"))
+	enc.EncodeLongKeyed("ct", []byte("ht"), []byte("ml"))
+	enc.EncodeBytes('z')
+	expect := "4:a=21,9:C=Iceland,7:n=Bjorn,7:ct=html,2:z=,"
+	if bb.String() != expect {
+		t.Fatal("Got", bb.String(), "expected", expect)
+	}
+	if st := enc.Stats(); st.Keys['C'].Bytes != 7 || st.Keys[netstring.NoKey].Bytes != 7 {
+		t.Error("Stats should exclude the separator", st.Keys)
+	}
+
+	dec := netstring.NewDecoder(strings.NewReader(expect), netstring.WithLongKeys())
+	k, v, err := dec.DecodeKeyed()
+	if err != nil || k != 'a' || string(v) != "21" {
+		t.Error("DecodeKeyed", k, string(v), err)
+	}
+	for ix, exp := range []struct{ key, val string }{{"C", "Iceland"}, {"n", "Bjorn"}, {"ct", "html"}, {"z", ""}} {
+		lk, v, err := dec.DecodeLongKeyed()
+		if err != nil || string(lk) != exp.key || string(v) != exp.val {
+			t.Error(ix, "DecodeLongKeyed", lk, string(v), err)
+		}
+	}
+
+	dec = netstring.NewDecoder(strings.NewReader("7:ct=html,6:b=next,"), netstring.WithLongKeys())
+	if _, _, err = dec.DecodeKeyed(); !errors.Is(err, netstring.ErrInvalidKey) {
+		t.Error("DecodeKeyed of long key expected ErrInvalidKey, got", err)
+	}
+	if k, v, err = dec.DecodeKeyed(); err != nil || k != 'b' || string(v) != "next" {
+		t.Error("Long key error should not be permanent", k, string(v), err)
+	}
+
+	type msg struct {
+		Age  int    `netstring:"a"`
+		Name string `netstring:"n"`
+	}
+	bb.Reset() // Marshal, Unmarshal and HMAC work in this mode
+	secret := []byte("s")
+	enc = netstring.NewEncoder(&bb, netstring.WithLongKeys(), netstring.WithHMAC(secret, sha256.New))
+	if err := enc.Marshal('z', msg{21, "Bjorn"}); err != nil {
+		t.Fatal("Marshal", err)
+	}
+	var got msg
+	dec = netstring.NewDecoder(&bb, netstring.WithLongKeys(), netstring.WithHMAC(secret, sha256.New))
+	if _, err := dec.Unmarshal('z', &got); err != nil || got.Name != "Bjorn" || got.Age != 21 {
+		t.Error("Unmarshal", got, err)
+	}
+}
+
+func TestLongKeyErrors(t *testing.T) {
+	for ix, lk := range []netstring.LongKey{"", "abcde", "a1", "c-t"} {
+		if lk.Assess() != netstring.ErrInvalidKey {
+			t.Error(ix, "Expected ErrInvalidKey for", lk)
+		}
+	}
+	enc := netstring.NewEncoder(&bytes.Buffer{})
+	if err := enc.EncodeLongKeyed("ct"); err == nil {
+		t.Error("EncodeLongKeyed should require WithLongKeys")
+	}
+	enc = netstring.NewEncoder(&bytes.Buffer{}, netstring.WithLongKeys())
+	if err := enc.EncodeLongKeyed("toolong"); err != netstring.ErrInvalidKey {
+		t.Error("Expected ErrInvalidKey, got", err)
+	}
+	if _, _, err := netstring.NewDecoder(strings.NewReader("")).DecodeLongKeyed(); err == nil {
+		t.Error("DecodeLongKeyed should require WithLongKeys")
+	}
+	for ix, input := range []string{"4:ctht,", "8:abcde=x,", "2:=x,", "0:,"} {
+		dec := netstring.NewDecoder(strings.NewReader(input), netstring.WithLongKeys())
+		if _, _, err := dec.DecodeLongKeyed(); err == nil {
+			t.Error(ix, "Expected error for", input)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package netstring
+
+/*
+LookupPath returns the value at "path" within "val", which is a series of "keyed"
+netstrings such as the value of an encapsulating netstring created by Encoder.Nested.
+Each element of "path" selects the first netstring with that key at the current level
+of encapsulation and the value of the last element is returned. For example, with a
+"val" of:
+
+	4:ubob,15:M2:a1,6:bhello,,
+
+LookupPath(val, 'M', 'b') returns "hello". Nothing is copied or decoded other than the
+netstrings which are skipped over, so routers which only need one field deep inside a
+message avoid materializing the intermediate messages. The returned value refers to
+"val".
+
+The returned bool is false if any element of "path" is not found, if "path" is empty
+or if a netstring on the way is malformed.
+*/
+func LookupPath(val []byte, path ...Key) ([]byte, bool) {
+	if len(path) == 0 {
+		return nil, false
+	}
+	for _, key := range path {
+		var found bool
+		for len(val) > 0 && !found {
+			var ns []byte
+			var ok bool
+			ns, val, ok = scanNetstring(val)
+			if !ok {
+				return nil, false
+			}
+			if len(ns) > 0 && Key(ns[0]) == key {
+				val = ns[1:]
+				found = true
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+
+	return val, true
+}
+
+// Lookup returns the value at "path" where the first element of "path" selects a
+// netstring of the Message and each subsequent element descends into that netstring's
+// value as per LookupPath. The returned bool is false if the path cannot be followed.
+func (m *Message) Lookup(path ...Key) ([]byte, bool) {
+	if len(path) == 0 {
+		return nil, false
+	}
+	val, ok := m.Get(path[0])
+	if !ok || len(path) == 1 {
+		return val, ok
+	}
+
+	return LookupPath(val, path[1:]...)
+}
+
+// scanNetstring splits the netstring at the start of "b" from the bytes which follow
+// it. Leading zeros and lengths which exceed MaximumLength are rejected, as they are by
+// Decoder.
+func scanNetstring(b []byte) (val, rest []byte, ok bool) {
+	var l, ix int
+	for ; ix < len(b) && b[ix] >= '0' && b[ix] <= '9'; ix++ {
+		if ix > 0 && l == 0 {
+			return nil, nil, false // Leading zero
+		}
+		l = l*10 + int(b[ix]-'0')
+		if l > MaximumLength {
+			return nil, nil, false
+		}
+	}
+	if ix == 0 || ix == len(b) || b[ix] != leadingColon {
+		return nil, nil, false
+	}
+	b = b[ix+1:]
+	if l >= len(b) || b[l] != trailingComma {
+		return nil, nil, false
+	}
+
+	return b[:l], b[l+1:], true
+}
@@ -0,0 +1,67 @@
+package netstring_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestLookupPath(t *testing.T) {
+	val := []byte("4:ubob,15:M2:a1,6:bhello,,")
+	testCases := []struct {
+		path   []netstring.Key
+		expect string
+		ok     bool
+	}{
+		{[]netstring.Key{'u'}, "bob", true},
+		{[]netstring.Key{'M', 'b'}, "hello", true},
+		{[]netstring.Key{'M', 'a'}, "1", true},
+		{[]netstring.Key{'M'}, "2:a1,6:bhello,", true},
+		{[]netstring.Key{'M', 'c'}, "", false},
+		{[]netstring.Key{'M', 'b', 'h'}, "", false}, // "hello" is not a netstring
+		{[]netstring.Key{'x'}, "", false},
+		{nil, "", false},
+	}
+	for ix, tc := range testCases {
+		got, ok := netstring.LookupPath(val, tc.path...)
+		if ok != tc.ok || string(got) != tc.expect {
+			t.Error(ix, "Got", string(got), ok, "expected", tc.expect, tc.ok)
+		}
+	}
+
+	for ix, bad := range []string{"3:abc", "03:uab,", "3:uab;", ":,", "9999999999:u"} {
+		if _, ok := netstring.LookupPath([]byte(bad), 'u'); ok {
+			t.Error(ix, "Malformed input should fail", bad)
+		}
+	}
+}
+
+func TestMessageLookup(t *testing.T) {
+	var bb bytes.Buffer
+	enc := netstring.NewEncoder(&bb)
+	enc.EncodeString('t', "route")
+	outer := enc.Nested('M')
+	inner := outer.Nested('N')
+	inner.EncodeString('d', "eu-west")
+	inner.Close()
+	outer.Close()
+	enc.EncodeBytes('z')
+
+	m, err := netstring.NewDecoder(&bb).ReadMessage('z')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := m.Lookup('M', 'N', 'd'); !ok || string(v) != "eu-west" {
+		t.Error("Nested lookup", string(v), ok)
+	}
+	if v, ok := m.Lookup('t'); !ok || string(v) != "route" {
+		t.Error("Top-level lookup", string(v), ok)
+	}
+	if _, ok := m.Lookup('M', 'x'); ok {
+		t.Error("Missing key found")
+	}
+	if _, ok := m.Lookup(); ok {
+		t.Error("Empty path found")
+	}
+}
@@ -3,6 +3,7 @@ package netstring
 import (
 	"fmt"
 	"reflect"
+	"sort"
 )
 
 // Marshal takes "message" as a struct or a pointer to a struct and encodes all exported
@@ -25,10 +26,12 @@ import (
 // "netstring" tag) can only be one of the following basic go types: all ints and uints,
 // all floats, strings and byte slices. That's it! Put another way, fields cannot be
 // complex types such as maps, arrays, structs, pointers, etc. Any unsupported field type
-// which has a "netstring" tag returns an error.
+// which has a "netstring" tag returns an error. These constraints can be relaxed for
+// specific types with RegisterCodec, and interface fields are supported WithRegistry.
 //
 // The "netstring" tag value must be a valid netstring.Key and each "netstring" tag value
-// must be unique otherwise an error is returned.
+// must be unique otherwise an error is returned. The key may be followed by validation
+// constraints as described in Validate.
 //
 // Though fields are encoded in the order found in the struct via the "reflect" package,
 // this sequence should not be relied on. Always use the "keyed" values to associate
@@ -68,7 +71,11 @@ import (
 //
 // Particularly note the preceding message type "r0" and the trailing end-of-message
 // sentinel 'Z'.
-func (enc *Encoder) Marshal(eom Key, message any) error {
+func (enc *Encoder) Marshal(eom Key, message any) (err error) {
+	defer enc.stick(&err)
+	if err = enc.Err(); err != nil {
+		return err
+	}
 	k, e := eom.Assess()
 	if e != nil {
 		return e
@@ -91,14 +98,30 @@ func (enc *Encoder) Marshal(eom Key, message any) error {
 	if kind != reflect.Struct { // Only go one-level deep, so no **struct{}
 		return ErrBadMarshalValue
 	}
+	if enc.validate {
+		e = Validate(message)
+		if e != nil {
+			return e
+		}
+	}
+
+	order := make([]int, to.NumField())
+	for ix := range order {
+		order[ix] = ix
+	}
+	if enc.deterministic { // Invalid tags are detected as they are encoded
+		sort.SliceStable(order, func(i, j int) bool {
+			return to.Field(order[i]).Tag.Get("netstring") < to.Field(order[j]).Tag.Get("netstring")
+		})
+	}
 
 	dupes := make(map[Key]string)
-	for ix := 0; ix < to.NumField(); ix++ {
+	for _, ix := range order {
 		sf := to.Field(ix) // Get StructField
 		if !sf.IsExported() {
 			continue
 		}
-		tag := sf.Tag.Get("netstring")
+		tag, spec := splitTag(sf.Tag.Get("netstring"))
 		if len(tag) == 0 {
 			continue
 		}
@@ -120,9 +143,32 @@ func (enc *Encoder) Marshal(eom Key, message any) error {
 				tag, sf.Name, n)
 		}
 		dupes[key] = sf.Name
+		_, err = parseConstraints(sf, spec)
+		if err != nil {
+			return err
+		}
 
 		kind := sf.Type.Kind()
 		vf := vo.Field(ix)
+		if c, ok := lookupCodec(sf.Type); ok && c.encode != nil {
+			b, err := c.encode(vf.Interface())
+			if err != nil {
+				return fmt.Errorf(errorPrefix+"%s codec encode failed: %w", sf.Name, err)
+			}
+			enc.EncodeBytes(key, b)
+			continue
+		}
+		if m, ok := asMarshaler(vf); ok {
+			b, err := m.MarshalNetstring()
+			if err != nil {
+				return fmt.Errorf(errorPrefix+"%s MarshalNetstring failed: %w", sf.Name, err)
+			}
+			enc.EncodeBytes(key, b)
+			continue
+		}
+		if kind == reflect.Pointer && sf.Type.Implements(marshalerType) {
+			continue // A nil Marshaler is omitted
+		}
 		switch kind {
 		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 			enc.EncodeInt64(key, vf.Int())
@@ -132,6 +178,18 @@ func (enc *Encoder) Marshal(eom Key, message any) error {
 			enc.EncodeFloat64(key, vf.Float())
 		case reflect.String:
 			enc.EncodeString(key, vf.String())
+		case reflect.Interface:
+			if enc.registry == nil {
+				return fmt.Errorf(errorPrefix+"%s type unsupported (%s) without WithRegistry",
+					sf.Name, kind)
+			}
+			if vf.IsNil() {
+				continue // A nil interface is omitted
+			}
+			err = enc.encodeRegistered(key, eom, vf.Elem().Interface())
+			if err != nil {
+				return fmt.Errorf(errorPrefix+"%s: %w", sf.Name, err)
+			}
 		case reflect.Slice: // Is it a byte slice?
 			eKind := sf.Type.Elem().Kind()
 			if eKind == reflect.Uint8 {
@@ -146,6 +204,12 @@ func (enc *Encoder) Marshal(eom Key, message any) error {
 		}
 	}
 
+	if enc.mac != nil {
+		err = enc.EncodeHMAC()
+		if err != nil {
+			return err
+		}
+	}
 	enc.EncodeBytes(eom)
 
 	return nil
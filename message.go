@@ -0,0 +1,205 @@
+package netstring
+
+import (
+	"context"
+	"io"
+)
+
+// KV is a single "keyed" netstring expressed as a Key and its value.
+type KV struct {
+	Key   Key
+	Value []byte
+}
+
+// Message is a raw, undecoded message consisting of a series of "keyed" netstrings which
+// was terminated on the wire by the EOM end-of-message sentinel. Unlike Unmarshal, which
+// requires a "basic-struct" known in advance, a Message retains every netstring so it is
+// suited to proxies, batch consumers and diagnostics.
+//
+// Fields are held in wire order and excludes the EOM sentinel. Repeated keys, as used by
+// TLV-style repeated fields, are retained in their arrival order so ranging over Fields
+// iterates the message in wire order. Use Get for single-valued keys and GetAll for
+// repeated keys.
+type Message struct {
+	EOM    Key
+	Fields []KV
+}
+
+// Get returns the value of the first netstring in the Message with a key of "key". The
+// returned bool is false if there is no such netstring.
+func (m *Message) Get(key Key) ([]byte, bool) {
+	for _, kv := range m.Fields {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+
+	return nil, false
+}
+
+// GetAll returns the values of all netstrings in the Message with a key of "key" in wire
+// order. It returns nil if there are no such netstrings.
+func (m *Message) GetAll(key Key) [][]byte {
+	var vals [][]byte
+	for _, kv := range m.Fields {
+		if kv.Key == key {
+			vals = append(vals, kv.Value)
+		}
+	}
+
+	return vals
+}
+
+// GetStrings returns the values of all netstrings in the Message with a key of "key" as
+// strings in wire order, thus reversing Encoder.EncodeStrings. It returns nil if there
+// are no such netstrings.
+func (m *Message) GetStrings(key Key) []string {
+	var vals []string
+	for _, kv := range m.Fields {
+		if kv.Key == key {
+			vals = append(vals, string(kv.Value))
+		}
+	}
+
+	return vals
+}
+
+// KeyStat summarizes all occurrences of a single key in a message.
+type KeyStat struct {
+	Count int // Number of netstrings with this key
+	Bytes int // Total length of their values, excluding the key byte
+}
+
+// KeyStats is a per-message histogram of keys. It is intended for security and
+// monitoring tooling which wants to flag unusual messages, such as those with unexpected
+// keys or oversized fields, without having to understand the semantics of the message.
+type KeyStats map[Key]KeyStat
+
+func (ks KeyStats) add(key Key, length int) {
+	st := ks[key]
+	st.Count++
+	st.Bytes += length
+	ks[key] = st
+}
+
+// KeyStats returns the key histogram of the Message.
+func (m *Message) KeyStats() KeyStats {
+	ks := make(KeyStats)
+	for _, kv := range m.Fields {
+		ks.add(kv.Key, len(kv.Value))
+	}
+
+	return ks
+}
+
+// ReadMessage reads "keyed" netstrings until "eom" is seen and returns them as a
+// Message. The "eom" sentinel can be any valid Key excepting NoKey.
+//
+// If the io.Reader reaches EOF before any netstrings of the message have been read,
+// io.EOF is returned. If EOF occurs part way through a message, including part way
+// through its first netstring, io.ErrUnexpectedEOF is returned.
+func (dec *Decoder) ReadMessage(eom Key) (m Message, err error) {
+	keyed, err := eom.Assess()
+	if err != nil {
+		return
+	}
+	if !keyed {
+		err = ErrBadMarshalEOM
+		return
+	}
+
+	m.EOM = eom
+	for {
+		k, v, e := dec.DecodeKeyed()
+		if e != nil {
+			if e == io.EOF && (len(m.Fields) > 0 || dec.state != parseFirstByte) {
+				e = io.ErrUnexpectedEOF
+			}
+			err = e
+			return
+		}
+		if k == eom {
+			if dec.wantStats() {
+				dec.endOfMessage(m.KeyStats())
+			}
+			err = dec.macTrailed()
+			return
+		}
+		m.Fields = append(m.Fields, KV{k, v})
+	}
+}
+
+// ReadMessages reads up to "n" messages with ReadMessage and returns them as a slice. If
+// "n" is negative, messages are read until io.EOF. This function is designed for batch
+// consumers such as ETL jobs which process netstring streams in bulk.
+//
+// If "n" is negative, reaching io.EOF on a message boundary is not an error. If "n" is
+// positive and io.EOF is reached before "n" messages are read, the messages read thus far
+// are returned along with io.EOF. Any other error is returned along with all messages read
+// prior to the error.
+func (dec *Decoder) ReadMessages(eom Key, n int) ([]Message, error) {
+	return dec.ReadMessagesContext(context.Background(), eom, n)
+}
+
+// ReadMessagesContext is the same as ReadMessages excepting that "ctx" is checked prior
+// to reading each message. If "ctx" is done, the messages read thus far are returned
+// along with ctx.Err().
+//
+// Note that "ctx" cannot interrupt a Read() which is blocked in the underlying
+// io.Reader. Use a deadline on the connection for that.
+func (dec *Decoder) ReadMessagesContext(ctx context.Context, eom Key, n int) ([]Message, error) {
+	var msgs []Message
+	for n < 0 || len(msgs) < n {
+		err := ctx.Err()
+		if err != nil {
+			return msgs, err
+		}
+		m, err := dec.ReadMessage(eom)
+		if err != nil {
+			if err == io.EOF && n < 0 {
+				err = nil
+			}
+			return msgs, err
+		}
+		msgs = append(msgs, m)
+	}
+
+	return msgs, nil
+}
+
+// encode writes all Fields of the Message followed by the EOM sentinel to "enc".
+func (m *Message) encode(enc *Encoder) error {
+	return enc.EncodeMessage(m.EOM, m.Fields...)
+}
+
+// EncodeMessage encodes a complete message consisting of a "keyed" netstring for each of
+// "pairs" followed by the "eom" end-of-message sentinel. This replaces the common series
+// of Encode*() calls plus sentinel with one checked call:
+//
+//	err := enc.EncodeMessage('z',
+//		netstring.KV{Key: 'f', Value: []byte("upper")},
+//		netstring.KV{Key: 'i', Value: []byte("input")})
+//
+// As with EncodeKV, all keys and value lengths are validated before anything is written.
+// "eom" must be a valid Key excepting NoKey otherwise ErrBadMarshalEOM is returned.
+func (enc *Encoder) EncodeMessage(eom Key, pairs ...KV) error {
+	keyed, err := eom.Assess()
+	if err != nil {
+		return err
+	}
+	if !keyed {
+		return ErrBadMarshalEOM
+	}
+	err = enc.EncodeKV(pairs)
+	if err != nil {
+		return err
+	}
+	if enc.mac != nil {
+		err = enc.EncodeHMAC()
+		if err != nil {
+			return err
+		}
+	}
+
+	return enc.EncodeBytes(eom)
+}
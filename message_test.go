@@ -0,0 +1,247 @@
+package netstring_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestReadMessage(t *testing.T) {
+	dec := newWith("3:a21,8:CIceland,1:z,6:nBjorn,1:z,")
+	m, err := dec.ReadMessage('z')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.EOM != 'z' || len(m.Fields) != 2 {
+		t.Fatal("Wrong message", m)
+	}
+	v, ok := m.Get('C')
+	if !ok || string(v) != "Iceland" {
+		t.Error("Get('C') returned", string(v), ok)
+	}
+	_, ok = m.Get('n')
+	if ok {
+		t.Error("Get('n') should not be found in first message")
+	}
+
+	m, err = dec.ReadMessage('z')
+	if err != nil || len(m.Fields) != 1 || m.Fields[0].Key != 'n' {
+		t.Fatal("Second message wrong", m, err)
+	}
+
+	_, err = dec.ReadMessage('z')
+	if err != io.EOF {
+		t.Error("Expected io.EOF, not", err)
+	}
+
+	dec = newWith("3:a21,")
+	_, err = dec.ReadMessage('z')
+	if err != io.ErrUnexpectedEOF {
+		t.Error("Expected io.ErrUnexpectedEOF, not", err)
+	}
+
+	dec = newWith("3:a2") // Truncated first netstring
+	_, err = dec.ReadMessage('z')
+	if err != io.ErrUnexpectedEOF {
+		t.Error("Expected io.ErrUnexpectedEOF for partial netstring, not", err)
+	}
+
+	_, err = dec.ReadMessage(netstring.NoKey)
+	if err != netstring.ErrBadMarshalEOM {
+		t.Error("Expected ErrBadMarshalEOM, not", err)
+	}
+	_, err = dec.ReadMessage('$')
+	if err != netstring.ErrInvalidKey {
+		t.Error("Expected ErrInvalidKey, not", err)
+	}
+}
+
+func TestReadMessages(t *testing.T) {
+	const stream = "3:a21,1:z,3:a22,1:z,3:a23,1:z,"
+
+	dec := newWith(stream)
+	msgs, err := dec.ReadMessages('z', -1)
+	if err != nil || len(msgs) != 3 {
+		t.Fatal("ReadMessages(-1) returned", len(msgs), err)
+	}
+	for ix, m := range msgs {
+		v, _ := m.Get('a')
+		if string(v) != []string{"21", "22", "23"}[ix] {
+			t.Error(ix, "Wrong value", string(v))
+		}
+	}
+
+	dec = newWith(stream)
+	msgs, err = dec.ReadMessages('z', 2)
+	if err != nil || len(msgs) != 2 {
+		t.Fatal("ReadMessages(2) returned", len(msgs), err)
+	}
+	msgs, err = dec.ReadMessages('z', 2)
+	if err != io.EOF || len(msgs) != 1 {
+		t.Fatal("ReadMessages(2) at end returned", len(msgs), err)
+	}
+
+	dec = newWith("3:a21,1:z,03:a22,1:z,")
+	msgs, err = dec.ReadMessages('z', -1)
+	if err != netstring.ErrLeadingZero || len(msgs) != 1 {
+		t.Error("ReadMessages with bad stream returned", len(msgs), err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dec = newWith(stream)
+	msgs, err = dec.ReadMessagesContext(ctx, 'z', -1)
+	if err != context.Canceled || len(msgs) != 0 {
+		t.Error("ReadMessagesContext with cancelled context returned", len(msgs), err)
+	}
+}
+
+func TestKeyStats(t *testing.T) {
+	const stream = "3:a21,4:nBob,6:nAlice,1:z,"
+
+	var got []netstring.KeyStats
+	hook := func(ks netstring.KeyStats) {
+		got = append(got, ks)
+	}
+	exp := netstring.KeyStats{'a': {1, 2}, 'n': {2, 8}}
+
+	dec := newWith(stream + stream)
+	dec.SetStatsHook(hook)
+	m, err := dec.ReadMessage('z')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m.KeyStats(), exp) {
+		t.Error("Message.KeyStats wrong", m.KeyStats())
+	}
+
+	type record struct {
+		Age int `netstring:"a"`
+	}
+	var r record
+	unknown, err := dec.Unmarshal('z', &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unknown != 'n' || r.Age != 21 {
+		t.Error("Unmarshal returned", unknown, r)
+	}
+
+	if len(got) != 2 {
+		t.Fatal("Hook called", len(got), "times, expected 2")
+	}
+	for ix, ks := range got {
+		if !reflect.DeepEqual(ks, exp) {
+			t.Error(ix, "Hook KeyStats wrong", ks)
+		}
+	}
+}
+
+func TestMessageGetAll(t *testing.T) {
+	dec := newWith("4:tone,2:a1,4:ttwo,6:tthree,1:z,")
+	m, err := dec.ReadMessage('z')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		key    netstring.Key
+		expect []string
+	}{
+		{'t', []string{"one", "two", "three"}},
+		{'a', []string{"1"}},
+		{'x', nil},
+	}
+	for ix, tc := range testCases {
+		var got []string
+		for _, v := range m.GetAll(tc.key) {
+			got = append(got, string(v))
+		}
+		if !reflect.DeepEqual(got, tc.expect) {
+			t.Error(ix, "Expected", tc.expect, "got", got)
+		}
+	}
+	if v, _ := m.Get('t'); string(v) != "one" {
+		t.Error("Get should return the first of a repeated key", string(v))
+	}
+
+	var order string
+	for _, kv := range m.Fields {
+		order += kv.Key.String()
+	}
+	if order != "tatt" {
+		t.Error("Fields not in wire order", order)
+	}
+}
+
+func TestEncodeStrings(t *testing.T) {
+	var bbuf bytes.Buffer
+	enc := netstring.NewEncoder(&bbuf)
+	enc.EncodeString('n', "Bob")
+	enc.EncodeStrings('t', []string{"one", "", "three"})
+	enc.EncodeStrings('x', nil)
+	enc.EncodeBytes('z')
+	exp := "4:nBob,4:tone,1:t,6:tthree,1:z,"
+	if bbuf.String() != exp {
+		t.Fatal("Got", bbuf.String(), "expected", exp)
+	}
+
+	m, err := netstring.NewDecoder(&bbuf).ReadMessage('z')
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := m.GetStrings('t')
+	if !reflect.DeepEqual(got, []string{"one", "", "three"}) {
+		t.Error("GetStrings", got)
+	}
+	if m.GetStrings('x') != nil {
+		t.Error("GetStrings of missing key should be nil")
+	}
+
+	err = enc.EncodeStrings('#', []string{"a"})
+	if err == nil {
+		t.Error("Expected error for invalid key")
+	}
+}
+
+func TestEncoderEncodeMessage(t *testing.T) {
+	var bb bytes.Buffer
+	enc := netstring.NewEncoder(&bb)
+	err := enc.EncodeMessage('z', netstring.KV{Key: 'f', Value: []byte("upper")},
+		netstring.KV{Key: 'i', Value: []byte("input")})
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	err = enc.EncodeMessage('z') // An empty message is just the sentinel
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	exp := "6:fupper,6:iinput,1:z,1:z,"
+	if bb.String() != exp {
+		t.Error("Expected", exp, "got", bb.String())
+	}
+
+	bb.Reset()
+	testCases := []struct {
+		eom   netstring.Key
+		pairs []netstring.KV
+		err   error
+	}{
+		{netstring.NoKey, nil, netstring.ErrBadMarshalEOM},
+		{'!', nil, netstring.ErrInvalidKey},
+		{'z', []netstring.KV{{Key: 'a'}, {Key: netstring.NoKey}}, netstring.ErrNoKey},
+	}
+	for ix, tc := range testCases {
+		err := enc.EncodeMessage(tc.eom, tc.pairs...)
+		if err != tc.err {
+			t.Error(ix, "Expected", tc.err, "got", err)
+		}
+	}
+	if bb.Len() != 0 {
+		t.Error("Nothing should be written on error, got", bb.String())
+	}
+}
@@ -0,0 +1,58 @@
+package netstring
+
+import (
+	"bytes"
+	"fmt"
+)
+
+/*
+NestedEncoder is a child Encoder returned by [Encoder.Nested] which assembles netstrings
+in memory so that, on [NestedEncoder.Close], they are emitted as the value of a single
+encapsulating netstring of the parent Encoder. This is the encapsulating netstring
+end-of-message strategy described in the package documentation, without the need to
+manage a second Encoder and buffer by hand:
+
+	child := enc.Nested('m')
+	child.EncodeInt('a', 21)
+	child.EncodeString('C', "Iceland")
+	child.EncodeString('n', "Bjorn")
+	err := child.Close() // Writes "27:m3:a21,8:CIceland,6:nBjorn,,"
+
+NestedEncoder embeds an Encoder so all the Encode*() functions, Marshal and Nested
+itself are available; a NestedEncoder of a NestedEncoder produces a netstring within a
+netstring within a netstring. Nothing reaches the parent until Close and nothing at all
+if the NestedEncoder is abandoned.
+*/
+type NestedEncoder struct {
+	*Encoder
+	parent *Encoder
+	key    Key
+	buf    bytes.Buffer
+	closed bool
+}
+
+// Nested returns a NestedEncoder whose netstrings are emitted as the value of a single
+// netstring with "key" when it is closed. The child has the same maximum length and
+// WithDeterministic setting as the parent. Other Options, such as WithEscaping and
+// WithHMAC, are applied by the parent to the encapsulating netstring as a whole.
+func (enc *Encoder) Nested(key Key) *NestedEncoder {
+	ne := &NestedEncoder{parent: enc, key: key}
+	ne.Encoder = NewEncoder(&ne.buf, WithMaximumLength(enc.maxLength))
+	ne.Encoder.deterministic = enc.deterministic
+	ne.Encoder.registry = enc.registry
+
+	return ne
+}
+
+// Close emits the netstrings assembled thus far as the value of a single netstring of
+// the parent Encoder. Close returns the parent's error, such as ErrValueToLong if the
+// assembled netstrings exceed the parent's maximum length. A NestedEncoder can only be
+// closed once.
+func (ne *NestedEncoder) Close() error {
+	if ne.closed {
+		return fmt.Errorf(errorPrefix+"NestedEncoder for key '%s' already closed", ne.key)
+	}
+	ne.closed = true
+
+	return ne.parent.EncodeBytes(ne.key, ne.buf.Bytes())
+}
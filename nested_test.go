@@ -0,0 +1,57 @@
+package netstring_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestNestedEncoder(t *testing.T) {
+	var bb bytes.Buffer
+	enc := netstring.NewEncoder(&bb)
+	child := enc.Nested(netstring.NoKey)
+	child.EncodeInt('a', 21)
+	child.EncodeString('C', "Iceland")
+	child.EncodeString('n', "Bjorn")
+	if bb.Len() != 0 {
+		t.Error("Nothing should reach the parent before Close", bb.String())
+	}
+	if err := child.Close(); err != nil {
+		t.Fatal("Close", err)
+	}
+	expect := "26:3:a21,8:CIceland,6:nBjorn,,"
+	if bb.String() != expect {
+		t.Error("Got", bb.String(), "expected", expect)
+	}
+	if err := child.Close(); err == nil {
+		t.Error("Second Close should fail")
+	}
+
+	bb.Reset() // Nested within nested, decoded one layer at a time
+	outer := enc.Nested('o')
+	inner := outer.Nested('i')
+	inner.EncodeString('v', "x")
+	inner.Close()
+	outer.EncodeBytes('z')
+	outer.Close()
+	k, v, err := netstring.NewDecoder(&bb).DecodeKeyed()
+	if err != nil || k != 'o' {
+		t.Fatal("Outer", k, err)
+	}
+	dec := netstring.NewDecoder(bytes.NewReader(v))
+	k, v, _ = dec.DecodeKeyed()
+	if k != 'i' || string(v) != "2:vx," {
+		t.Error("Inner", k, string(v))
+	}
+	if k, _, _ = dec.DecodeKeyed(); k != 'z' {
+		t.Error("Expected 'z' after inner, got", k)
+	}
+
+	small := netstring.NewEncoder(&bb, netstring.WithMaximumLength(5))
+	child = small.Nested('m')
+	child.EncodeString('a', "abc") // "4:aabc," fits the child but not the parent
+	if err := child.Close(); err != netstring.ErrValueToLong {
+		t.Error("Expected ErrValueToLong, got", err)
+	}
+}
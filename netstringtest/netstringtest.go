@@ -0,0 +1,186 @@
+/*
+Package netstringtest provides utilities for testing protocols built on the netstring
+package, in the same spirit as net/http/httptest and testing/iotest.
+
+RoundTripStrict is a conformance check for "basic-struct" messages. It is intended to be
+called from the tests of a protocol package with representative values of each message,
+so that protocol authors learn of any field which does not survive the trip across the
+wire before the protocol is deployed:
+
+	func TestMessages(t *testing.T) {
+		netstringtest.RoundTripStrict(t, &Login{User: "bob", Expiry: time.Now()})
+	}
+*/
+package netstringtest
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/markdingo/netstring"
+	"github.com/markdingo/netstring/tiny"
+)
+
+// eomCandidates are tried in order as the end-of-message key of RoundTripStrict.
+const eomCandidates = "zyxwvutsrqponmlkjihgfedcbaZYXWVUTSRQPONMLKJIHGFEDCBA"
+
+/*
+RoundTripStrict asserts that "msg", a "basic-struct" or a pointer to one, survives a
+Marshal and Unmarshal round trip without loss. It:
+
+  - Marshals "msg" with an end-of-message key not used by any of its fields
+  - Re-parses the output with the independent parser of package tiny, checking that it is
+    a series of well-formed "keyed" netstrings with no repeated keys, terminated by
+    exactly one end-of-message sentinel
+  - Unmarshals the output into a new value of the same type, which must not report any
+    unknown keys
+  - Compares each tagged field of the new value with the original
+
+Each field which does not compare equal is reported with t.Errorf along with its wire
+representation, so lossy encodings, such as a codec which truncates precision, are
+identified by name. NaN compares equal to NaN and a nil slice compares equal to an empty
+slice as neither distinction is representable on the wire. Failures which prevent the
+comparison, such as a Marshal error, are reported with t.Fatalf.
+*/
+func RoundTripStrict(t testing.TB, msg any) {
+	t.Helper()
+
+	rv := reflect.ValueOf(msg)
+	if rv.Kind() == reflect.Pointer {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		t.Fatalf("RoundTripStrict: %T is not a struct or a pointer to a struct", msg)
+		return
+	}
+	rt := rv.Type()
+	fields := taggedFields(rt)
+	eom := netstring.NoKey
+	for ix := 0; ix < len(eomCandidates); ix++ {
+		if _, ok := fields[netstring.Key(eomCandidates[ix])]; !ok {
+			eom = netstring.Key(eomCandidates[ix])
+			break
+		}
+	}
+	if eom == netstring.NoKey {
+		t.Fatalf("RoundTripStrict: %s uses every key so has no end-of-message key", rt)
+		return
+	}
+
+	var bbuf bytes.Buffer
+	err := netstring.NewEncoder(&bbuf).Marshal(eom, msg)
+	if err != nil {
+		t.Fatalf("RoundTripStrict: Marshal of %s failed: %v", rt, err)
+		return
+	}
+	wire := bbuf.Bytes()
+	values, err := parseStrict(wire, eom)
+	if err != nil {
+		t.Fatalf("RoundTripStrict: Marshal of %s produced invalid output %q: %v", rt, wire, err)
+		return
+	}
+
+	out := reflect.New(rt)
+	unknown, err := netstring.NewDecoder(bytes.NewReader(wire)).Unmarshal(eom, out.Interface())
+	if err != nil {
+		t.Fatalf("RoundTripStrict: Unmarshal of %s failed: %v", rt, err)
+		return
+	}
+	if unknown != netstring.NoKey {
+		t.Errorf("RoundTripStrict: Unmarshal of %s reported unknown key %s", rt, unknown)
+	}
+
+	for ix := 0; ix < rt.NumField(); ix++ { // Report in field order
+		key, ok := keyOf(rt.Field(ix))
+		if !ok {
+			continue
+		}
+		before := rv.Field(ix)
+		after := out.Elem().Field(ix)
+		if !equal(before, after) {
+			t.Errorf("RoundTripStrict: %s.%s (key %s) is lossy: %v is sent as %q and received as %v",
+				rt, rt.Field(ix).Name, key, before, values[key], after)
+		}
+	}
+}
+
+// taggedFields returns the index of each exported field with a "netstring" tag, by key.
+// Invalid tags are left for Marshal to report.
+func taggedFields(rt reflect.Type) map[netstring.Key]int {
+	fields := make(map[netstring.Key]int)
+	for ix := 0; ix < rt.NumField(); ix++ {
+		if key, ok := keyOf(rt.Field(ix)); ok {
+			fields[key] = ix
+		}
+	}
+
+	return fields
+}
+
+// keyOf returns the key of "sf" if it is exported and has a "netstring" tag.
+func keyOf(sf reflect.StructField) (netstring.Key, bool) {
+	tag, _, _ := strings.Cut(sf.Tag.Get("netstring"), ",")
+	if !sf.IsExported() || len(tag) != 1 {
+		return netstring.NoKey, false
+	}
+
+	return netstring.Key(tag[0]), true
+}
+
+// parseStrict parses "wire" as a single message terminated by "eom" with tiny.Parser,
+// which shares no code with netstring.Decoder. It returns the value of each key.
+func parseStrict(wire []byte, eom netstring.Key) (map[netstring.Key][]byte, error) {
+	values := make(map[netstring.Key][]byte)
+	p := tiny.NewParser(make([]byte, len(wire)))
+	for len(wire) > 0 {
+		used, ready, err := p.Feed(wire)
+		if err != nil {
+			return nil, err
+		}
+		wire = wire[used:]
+		if !ready {
+			return nil, fmt.Errorf("truncated netstring")
+		}
+		k, v, err := p.Keyed()
+		if err != nil {
+			return nil, err
+		}
+		key := netstring.Key(k)
+		if key == eom {
+			if len(wire) > 0 {
+				return nil, fmt.Errorf("%d bytes follow the end-of-message sentinel", len(wire))
+			}
+			if len(v) > 0 {
+				return nil, fmt.Errorf("end-of-message sentinel has a value")
+			}
+			return values, nil
+		}
+		if _, ok := values[key]; ok {
+			return nil, fmt.Errorf("key %s is repeated", key)
+		}
+		values[key] = append([]byte(nil), v...)
+	}
+
+	return nil, fmt.Errorf("no end-of-message sentinel")
+}
+
+// equal compares two field values, treating NaN as equal to NaN and nil slices as equal
+// to empty slices.
+func equal(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if math.IsNaN(a.Float()) && math.IsNaN(b.Float()) {
+			return true
+		}
+	case reflect.Slice:
+		if a.Len() == 0 && b.Len() == 0 {
+			return true
+		}
+	}
+
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
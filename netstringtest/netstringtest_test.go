@@ -0,0 +1,127 @@
+package netstringtest_test
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/markdingo/netstring"
+	"github.com/markdingo/netstring/netstringtest"
+)
+
+// recorder captures the failures reported by RoundTripStrict.
+type recorder struct {
+	testing.TB
+	errors []string
+	fatal  bool
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...any) {
+	r.Errorf(format, args...)
+	r.fatal = true
+	runtime.Goexit()
+}
+
+// run calls RoundTripStrict in its own goroutine so that Fatalf can exit it.
+func run(msg any) *recorder {
+	r := &recorder{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		netstringtest.RoundTripStrict(r, msg)
+	}()
+	<-done
+
+	return r
+}
+
+// cents truncates to two decimal places on the wire.
+type cents float64
+
+func (c cents) MarshalNetstring() ([]byte, error) {
+	return []byte(strconv.FormatFloat(float64(c), 'f', 2, 64)), nil
+}
+
+func (c *cents) UnmarshalNetstring(val []byte) error {
+	f, err := strconv.ParseFloat(string(val), 64)
+	*c = cents(f)
+	return err
+}
+
+type clean struct {
+	Name   string  `netstring:"n"`
+	Age    int8    `netstring:"a"`
+	Height float32 `netstring:"h"`
+	Ratio  float64 `netstring:"r"`
+	Data   []byte  `netstring:"d"`
+	Count  uint64  `netstring:"z"` // Forces a different EOM
+	Price  cents   `netstring:"p"`
+	Notes  string  // Ignored
+}
+
+type lossy struct {
+	Name  string `netstring:"n"`
+	Price cents  `netstring:"p"`
+}
+
+func TestRoundTripStrict(t *testing.T) {
+	r := run(&clean{"Bob", -7, 0.1, math.NaN(), nil, math.MaxUint64, 1.25, "x"})
+	if len(r.errors) > 0 {
+		t.Error("Unexpected failures", r.errors)
+	}
+	r = run(clean{Data: []byte{}})
+	if len(r.errors) > 0 {
+		t.Error("Unexpected failures by value", r.errors)
+	}
+
+	r = run(&lossy{"Bob", 1.255})
+	if r.fatal || len(r.errors) != 1 {
+		t.Fatal("Expected one lossy field", r.errors)
+	}
+	if !strings.Contains(r.errors[0], "lossy.Price (key p) is lossy") {
+		t.Error("Wrong failure", r.errors[0])
+	}
+}
+
+func TestRoundTripStrictFatal(t *testing.T) {
+	type badTag struct {
+		Name string `netstring:"!"`
+	}
+	type unsupported struct {
+		Map map[string]int `netstring:"m"`
+	}
+	var all []reflect.StructField
+	for k := netstring.Key('a'); k <= 'z'; k++ {
+		all = append(all, reflect.StructField{Name: "L" + k.String(), Type: reflect.TypeOf(""),
+			Tag: reflect.StructTag(`netstring:"` + k.String() + `"`)})
+		all = append(all, reflect.StructField{Name: "U" + k.String(), Type: reflect.TypeOf(""),
+			Tag: reflect.StructTag(`netstring:"` + strings.ToUpper(k.String()) + `"`)})
+	}
+	full := reflect.New(reflect.StructOf(all)).Interface()
+
+	testCases := []struct {
+		msg    any
+		expect string
+	}{
+		{42, "not a struct"},
+		{&badTag{}, "Marshal of"},
+		{unsupported{}, "Marshal of"},
+		{full, "no end-of-message key"},
+	}
+	for ix, tc := range testCases {
+		r := run(tc.msg)
+		if !r.fatal || len(r.errors) != 1 || !strings.Contains(r.errors[0], tc.expect) {
+			t.Error(ix, "Expected fatal", tc.expect, "got", r.errors)
+		}
+	}
+}
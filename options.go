@@ -0,0 +1,429 @@
+package netstring
+
+import (
+	"hash"
+	"strings"
+	"time"
+)
+
+// Option is a functional option which modifies the behaviour of an Encoder or Decoder as
+// it is constructed by NewEncoder or NewDecoder. Options are created by the With*()
+// functions. The same Options can be supplied to both constructors; an Option which only
+// applies to one of them is ignored by the other.
+type Option func(*options)
+
+// options accumulates the settings supplied by Options prior to them being applied to an
+// Encoder or Decoder.
+type options struct {
+	maxLength        int
+	discardOversized bool
+	padding          string
+	bufferSize       int
+	noAutoBuffer     bool
+	writeBufferSize  int
+	progress         ProgressFunc
+	statsHook        func(KeyStats)
+	keyPolicy        *KeyPolicy
+	trace            TraceFunc
+	validate         bool
+	announce         AnnounceFunc
+	softLimits       *SoftLimits
+	budget           *MemoryBudget
+	interner         *Interner
+	watchdogAfter    time.Duration
+	watchdogFn       WatchdogFunc
+	hmacKey          []byte
+	hmacHash         func() hash.Hash
+	schema           *Schema
+	atomic           bool
+	deterministic    bool
+	boolFormat       BoolFormat
+	escaping         bool
+	foldKeys         bool
+	longKeys         bool
+	registry         *Registry
+	pretty           bool
+	prettyEOM        Key
+	nulPolicy        NULPolicy
+	sampleEvery      int
+	sticky           bool
+	provenance       *Provenance
+	locking          bool
+}
+
+// TraceFunc is the signature of the callback supplied to WithTrace. "ns" is the value of
+// a complete netstring, including the key byte of "keyed" netstrings.
+//
+// The callback is made synchronously from within the Encoder or Decoder and "ns" must not
+// be modified or retained.
+type TraceFunc func(ns []byte)
+
+// defaultOptions returns the settings used in the absence of any Options.
+func defaultOptions() options {
+	return options{maxLength: MaximumLength}
+}
+
+// applyOptions returns the default settings modified by "opts".
+func applyOptions(opts []Option) options {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// WithMaximumLength sets the maximum length of a netstring value produced by an Encoder
+// or accepted by a Decoder, overriding the package-wide MaximumLength. Lowering the limit
+// is useful for deployments which only ever exchange small messages and want to reject
+// anything else early. Raising the limit beyond MaximumLength is possible for controlled
+// environments on 64-bit platforms.
+//
+// A Decoder returns ErrLengthToLong as soon as a length exceeds the limit. Lengths which
+// cannot be represented by an int return ErrLengthOverflow, which is only possible with a
+// limit of math.MaxInt or with WithDiscardOversized, as otherwise the limit is reached
+// first.
+//
+// A negative value restores the default of MaximumLength.
+func WithMaximumLength(n int) Option {
+	if n < 0 {
+		n = MaximumLength
+	}
+
+	return func(o *options) {
+		o.maxLength = n
+	}
+}
+
+// WithDiscardOversized causes a Decoder to skip netstrings which exceed the maximum
+// length rather than treating them as a permanent error. Decode*() returns
+// ErrValueTooLarge for the oversized netstring alone and subsequent calls carry on with
+// the following netstring. The oversized value is never stored; exactly length+1 bytes
+// (the value and trailing comma) are read and discarded. See also Decoder.DiscardValue.
+//
+// This is useful for tolerant ingestion, such as of logs, where one huge record should
+// not kill the whole stream. Lengths which are too large to be represented by an int are
+// still a permanent ErrLengthOverflow error.
+func WithDiscardOversized() Option {
+	return func(o *options) {
+		o.discardOversized = true
+	}
+}
+
+// DefaultFramePadding is the padding skipped by a Decoder constructed with
+// WithFramePadding("").
+const DefaultFramePadding = "\r\n "
+
+// WithFramePadding causes a Decoder to skip any of the bytes in "padding" which appear
+// between netstrings, as inserted by some legacy producers to make streams more readable,
+// such as a newline after each netstring. Parsing remains strict within each netstring
+// so padding between the length and the colon or between the value and the comma is
+// still an error. An empty "padding" means DefaultFramePadding. Digits cannot be padding
+// and are ignored. Encoder ignores this Option; see WithPrettyOutput to write padding.
+func WithFramePadding(padding string) Option {
+	if len(padding) == 0 {
+		padding = DefaultFramePadding
+	}
+	padding = strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return -1
+		}
+		return r
+	}, padding)
+
+	return func(o *options) {
+		o.padding = padding
+	}
+}
+
+// AnnounceFunc is the signature of the callback supplied to WithLengthAnnounce. "length"
+// is the announced length of the netstring value which is about to be read. Returning a
+// non-nil error vetoes the netstring.
+type AnnounceFunc func(length int) error
+
+// WithBuffering causes a Decoder to wrap its io.Reader in a bufio.Reader of "size" bytes,
+// saving applications the bother of doing so themselves. A size of zero or less means the
+// default, which is no buffering unless the io.Reader is a net.Conn, as described in
+// WithoutAutoBuffering. Encoder ignores this Option.
+func WithBuffering(size int) Option {
+	return func(o *options) {
+		o.bufferSize = size
+	}
+}
+
+// WithWriteBuffering causes an Encoder to buffer its output in a bufio.Writer of "size"
+// bytes so that many small netstrings are written to the io.Writer with a few large
+// Writes. Netstrings only reach the io.Writer when the buffer fills or when
+// Encoder.Flush is called, so the application *must* call Flush once it has finished a
+// message or burst of messages, otherwise the peer waits forever. Conn.Close flushes
+// before closing. A size of zero or less means no buffering, which is the default.
+//
+// MessageBuilder ignores this Option as it is already buffered. Decoder ignores this
+// Option.
+func WithWriteBuffering(size int) Option {
+	return func(o *options) {
+		o.writeBufferSize = size
+	}
+}
+
+// AutoBufferSize is the size of the bufio.Reader which a Decoder wraps around a net.Conn
+// by default.
+const AutoBufferSize = 4096
+
+// WithoutAutoBuffering stops a Decoder from automatically wrapping a net.Conn in a
+// bufio.Reader of AutoBufferSize bytes. Forgetting to buffer a network connection is the
+// most common performance mistake made with this package, so by default NewDecoder does
+// it for the application, unless WithBuffering is also supplied in which case its size
+// applies. An application which needs every Read to go directly to the net.Conn, or
+// which supplies an io.Reader that is already buffered, uses this Option. Decoder.ReadStats
+// shows whether buffering is effective. Encoder ignores this Option.
+func WithoutAutoBuffering() Option {
+	return func(o *options) {
+		o.noAutoBuffer = true
+	}
+}
+
+// WithProgress is the Option equivalent of Encoder.SetProgress and Decoder.SetProgress.
+func WithProgress(fn ProgressFunc) Option {
+	return func(o *options) {
+		o.progress = fn
+	}
+}
+
+// WithStatsHook is the Option equivalent of Decoder.SetStatsHook. Encoder ignores this
+// Option.
+func WithStatsHook(fn func(KeyStats)) Option {
+	return func(o *options) {
+		o.statsHook = fn
+	}
+}
+
+// WithKeyPolicy is the Option equivalent of Decoder.SetKeyPolicy. Encoder ignores this
+// Option.
+func WithKeyPolicy(kp *KeyPolicy) Option {
+	return func(o *options) {
+		o.keyPolicy = kp
+	}
+}
+
+// WithTrace arranges for "fn" to be called with every netstring successfully encoded by
+// an Encoder or decoded by a Decoder. It is intended for debugging and diagnostics; a
+// Decoder does not call "fn" for netstrings skipped due to WithDiscardOversized.
+func WithTrace(fn TraceFunc) Option {
+	return func(o *options) {
+		o.trace = fn
+	}
+}
+
+// WithValidation causes Encoder.Marshal to check the "basic-struct" against the
+// constraints in its "netstring" tags before anything is encoded. See Validate. Decoder
+// ignores this Option as Unmarshal always enforces constraints.
+func WithValidation() Option {
+	return func(o *options) {
+		o.validate = true
+	}
+}
+
+// WithLengthAnnounce arranges for a Decoder to call "fn" as soon as the length of each
+// netstring has been parsed, which is before the value is read or any memory is
+// allocated for it. This gives applications a finer-grained defence than a static maximum
+// length as "fn" can take into account current load, the identity of the peer and so on.
+// Encoder ignores this Option.
+//
+// If "fn" returns an error, the Decoder stops parsing and returns that error in perpetuity
+// in the same way as a parse error, which typically results in the application dropping
+// the connection. Note that the key of a "keyed" netstring is part of the value so it is
+// not yet known when "fn" is called.
+func WithLengthAnnounce(fn AnnounceFunc) Option {
+	return func(o *options) {
+		o.announce = fn
+	}
+}
+
+// WithSchema causes a MessageBuilder to validate each message against "schema" prior to
+// writing it. See MessageBuilder.Validate. Encoder and Decoder ignore this Option.
+func WithSchema(schema *Schema) Option {
+	return func(o *options) {
+		o.schema = schema
+	}
+}
+
+// WithAtomicWrites causes an Encoder to assemble each netstring in an internal buffer and
+// emit it with exactly one Write() call rather than the usual multiple Write() calls.
+// This preserves message-per-packet semantics on packet-oriented transports such as UDP
+// and unixgram sockets, and prevents netstrings from different goroutines interleaving
+// on a shared io.Writer which is itself safe for concurrent use.
+//
+// The cost is a copy of every value. Encoder.EncodeReader reads the whole value into
+// memory before writing it. Decoder ignores this Option.
+func WithAtomicWrites() Option {
+	return func(o *options) {
+		o.atomic = true
+	}
+}
+
+// WithDeterministic causes an Encoder to produce byte-stable output regardless of Go
+// version, platform or struct field order so that golden files and fingerprints do not
+// churn. In this mode:
+//
+//   - Marshal encodes fields in ascending Key order rather than struct order
+//   - Negative zero floats are encoded as "0"
+//
+// All other encodings are already stable: integers and floats are formatted by strconv
+// with the shortest exact representation and are never subject to locale. Decoder ignores
+// this Option.
+func WithDeterministic() Option {
+	return func(o *options) {
+		o.deterministic = true
+	}
+}
+
+// WithBoolFormat causes an Encoder to represent booleans with "format" rather than the
+// default BoolShorthand of "T" and "f", to interoperate with peers whose parsers do not
+// accept the strconv.ParseBool shorthand. Decoder ignores this Option.
+func WithBoolFormat(format BoolFormat) Option {
+	return func(o *options) {
+		o.boolFormat = format
+	}
+}
+
+// WithEscaping causes an Encoder to apply EscapeValue to every value and a Decoder to
+// apply UnescapeValue to every value, so that the raw stream between them only contains
+// printable ASCII. This is intended for pipelines where humans read raw streams. Both
+// peers must use this Option.
+//
+// The length of an escaped netstring is the length of the escaped value, which is the
+// length checked against the maximum length. A Decoder returns ErrBadEscape for a value
+// which is not correctly escaped, after which decoding can continue. The key of a "keyed"
+// netstring is never escaped as it is always printable. Encoder.EncodeReader reads the
+// whole value into memory before escaping it.
+func WithEscaping() Option {
+	return func(o *options) {
+		o.escaping = true
+	}
+}
+
+// WithKeyFolding causes Decoder.Unmarshal and Decoder.UnmarshalRegistered to treat keys
+// case-insensitively so that, e.g., 'A' and 'a' populate the same field. The message-type
+// and end-of-message keys are also matched case-insensitively. This is intended for
+// interoperating with a peer which regrettably mixes cases.
+//
+// A struct with tags which conflict once folded, such as 'c' and 'C', is rejected by
+// Unmarshal. Supplied to NewRegistry, this Option causes Registry.Register to reject
+// such structs at registration time. Encoder ignores this Option.
+func WithKeyFolding() Option {
+	return func(o *options) {
+		o.foldKeys = true
+	}
+}
+
+// NULPolicy determines how an Encoder and Decoder treat NUL (zero) bytes in values. See
+// WithNULPolicy.
+type NULPolicy int
+
+// All NULPolicy values.
+const (
+	NULPass   NULPolicy = iota // NUL bytes are passed through unchanged (the default)
+	NULReject                  // Values containing NUL bytes are rejected with ErrNULValue
+	NULEscape                  // NUL and '%' bytes are escaped as %00 and %25
+)
+
+// WithNULPolicy sets how NUL bytes in values are treated. Many C consumers treat NUL as a
+// string terminator and silently truncate values, so a producer feeding such a consumer
+// may wish to reject or escape them.
+//
+// With NULReject, an Encoder returns ErrNULValue without writing anything and a Decoder
+// returns ErrNULValue for the offending netstring, after which decoding can continue.
+//
+// With NULEscape, an Encoder replaces NUL with %00 and '%' with %25 and a Decoder
+// reverses the escaping with UnescapeValue, so both peers must use NULEscape. The
+// escaping is subsumed by WithEscaping if that is also in effect.
+//
+// Encoder.EncodeReader reads the whole value into memory for any policy other than
+// NULPass.
+func WithNULPolicy(policy NULPolicy) Option {
+	return func(o *options) {
+		o.nulPolicy = policy
+	}
+}
+
+// escapeNUL returns a copy of "val" with NUL and '%' bytes replaced by %XX.
+func escapeNUL(val []byte) []byte {
+	res := make([]byte, 0, len(val))
+	for _, b := range val {
+		if b == 0 || b == '%' {
+			res = append(res, '%', hexDigits[b>>4], hexDigits[b&0xF])
+			continue
+		}
+		res = append(res, b)
+	}
+
+	return res
+}
+
+// WithSampling causes the trace and stats hooks to fire for only one in every "n" events
+// so that instrumentation can be left enabled on very hot connections. The TraceFunc of
+// WithTrace is sampled per netstring and the hook of WithStatsHook is sampled per
+// message; KeyStats are not even gathered for messages which are not sampled. The first
+// event is always sampled. An "n" of one or less samples every event.
+//
+// Soft limits are not subject to sampling.
+func WithSampling(n int) Option {
+	return func(o *options) {
+		o.sampleEvery = n
+	}
+}
+
+// WithStickyErrors causes an Encoder to retain the first error returned by any Encode*()
+// function or Marshal, in the same way as bufio.Writer. Once an error has occurred, all
+// subsequent calls write nothing and return that same error. This allows a sequence of
+// calls to be made without checking each return value, with the outcome inspected once
+// with Encoder.Err. The error is cleared by Encoder.Reset and MessageBuilder.Abort.
+// Decoder ignores this Option.
+//
+//	enc := netstring.NewEncoder(w, netstring.WithStickyErrors())
+//	enc.EncodeInt('a', 1)
+//	enc.EncodeString('b', "two")
+//	enc.EncodeBytes('z')
+//	if err := enc.Err(); err != nil {
+//		...
+//	}
+func WithStickyErrors() Option {
+	return func(o *options) {
+		o.sticky = true
+	}
+}
+
+// WithLocking makes an Encoder safe for concurrent use by multiple goroutines, such as
+// when they share one network connection. Each Encode*() call writes its complete
+// netstring while holding an internal mutex so netstrings from different goroutines never
+// interleave. Decoder ignores this Option.
+//
+// Note that the guarantee is per netstring, not per message. Functions which write
+// multiple netstrings, such as Marshal and EncodeKV, can be interleaved with netstrings
+// from other goroutines. To write complete messages, use a MessageBuilder per goroutine
+// with a destination, such as a net.Conn, whose Write is safe for concurrent use.
+func WithLocking() Option {
+	return func(o *options) {
+		o.locking = true
+	}
+}
+
+// sampler implements the one-in-every "every" decision of WithSampling.
+type sampler struct {
+	every int
+	count int
+}
+
+// next returns true if the next event is sampled.
+func (s *sampler) next() bool {
+	if s.every <= 1 {
+		return true
+	}
+	s.count++
+	if s.count == s.every {
+		s.count = 0
+	}
+
+	return s.count == 1
+}
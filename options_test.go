@@ -0,0 +1,508 @@
+package netstring_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestWithMaximumLength(t *testing.T) {
+	type testCase struct {
+		input  string
+		max    int
+		expect error
+	}
+	testCases := []testCase{
+		{"5:abcde,", 5, nil},
+		{"6:abcdef,", 5, netstring.ErrLengthToLong},
+		{"9:abcdefghi,", 5, netstring.ErrLengthToLong},
+		{"0:,", 0, nil},
+		{"1:a,", 0, netstring.ErrLengthToLong},
+		{"1000000000x", -1, netstring.ErrLengthToLong}, // Default limit
+	}
+	if strconv.IntSize == 64 { // Raising the limit is only meaningful on 64-bit platforms
+		testCases = append(testCases,
+			testCase{"1000000000x", netstring.MaximumLength + 1, netstring.ErrColonExpected})
+	}
+
+	for ix, tc := range testCases {
+		dec := netstring.NewDecoder(bytes.NewBufferString(tc.input), netstring.WithMaximumLength(tc.max))
+		_, err := dec.Decode()
+		if err != tc.expect {
+			t.Error(ix, "Expected", tc.expect, "got", err)
+		}
+	}
+}
+
+func TestEncoderWithMaximumLength(t *testing.T) {
+	var bbuf bytes.Buffer
+	enc := netstring.NewEncoder(&bbuf, netstring.WithMaximumLength(3))
+	err := enc.EncodeString(netstring.NoKey, "abc")
+	if err != nil {
+		t.Error("Value at limit should encode", err)
+	}
+	err = enc.EncodeString('k', "abc") // Key byte counts towards the length
+	if err != netstring.ErrValueToLong {
+		t.Error("Expected ErrValueToLong, not", err)
+	}
+	if bbuf.String() != "3:abc," {
+		t.Error("Unexpected output", bbuf.String())
+	}
+}
+
+func TestPerInstanceMaximumLength(t *testing.T) {
+	small := netstring.NewEncoder(io.Discard, netstring.WithMaximumLength(16))
+	dflt := netstring.NewEncoder(io.Discard)
+	if small.MaximumLength() != 16 || dflt.MaximumLength() != netstring.MaximumLength {
+		t.Error("Encoder limits are not per-instance", small.MaximumLength(), dflt.MaximumLength())
+	}
+	big := strings.Repeat("x", 17)
+	if err := small.EncodeString(netstring.NoKey, big); err != netstring.ErrValueToLong {
+		t.Error("Expected ErrValueToLong, not", err)
+	}
+	if err := dflt.EncodeString(netstring.NoKey, big); err != nil {
+		t.Error("Default limit should accept", err)
+	}
+
+	reset := netstring.NewEncoder(io.Discard, netstring.WithMaximumLength(16), netstring.WithMaximumLength(-1))
+	if reset.MaximumLength() != netstring.MaximumLength {
+		t.Error("Negative should restore default, got", reset.MaximumLength())
+	}
+	dec := netstring.NewDecoder(strings.NewReader(""), netstring.WithMaximumLength(16))
+	if dec.MaximumLength() != 16 {
+		t.Error("Decoder limit wrong", dec.MaximumLength())
+	}
+	if strconv.IntSize == 64 {
+		raised := netstring.NewEncoder(io.Discard, netstring.WithMaximumLength(netstring.MaximumLength*2))
+		if raised.MaximumLength() != netstring.MaximumLength*2 {
+			t.Error("Raised limit not retained", raised.MaximumLength())
+		}
+	}
+}
+
+func TestOptionEquivalents(t *testing.T) {
+	var encTrace, decTrace []string
+	var progressCalls, statsCalls int
+	opts := []netstring.Option{
+		netstring.WithTrace(func(ns []byte) { encTrace = append(encTrace, string(ns)) }),
+		netstring.WithProgress(func(done, total int64) { progressCalls++ }),
+	}
+	var bbuf bytes.Buffer
+	enc := netstring.NewEncoder(&bbuf, opts...)
+	enc.EncodeBytes('a', []byte("12"), []byte("34"))
+	enc.EncodeString('x', "denied")
+	enc.EncodeBytes('z')
+	if progressCalls != 3 { // Once per non-empty sub-value
+		t.Error("Encoder WithProgress not applied", progressCalls)
+	}
+	if len(encTrace) != 3 || encTrace[0] != "a1234" || encTrace[2] != "z" {
+		t.Error("Encoder WithTrace wrong", encTrace)
+	}
+
+	dec := netstring.NewDecoder(&bbuf,
+		netstring.WithBuffering(16),
+		netstring.WithTrace(func(ns []byte) { decTrace = append(decTrace, string(ns)) }),
+		netstring.WithStatsHook(func(netstring.KeyStats) { statsCalls++ }),
+		netstring.WithKeyPolicy(&netstring.KeyPolicy{Deny: "x"}))
+	_, err := dec.ReadMessage('z')
+	if err != netstring.ErrKeyNotPermitted {
+		t.Error("WithKeyPolicy not applied", err)
+	}
+	_, err = dec.ReadMessage('z') // Resumes after the denied key
+	if err != nil || statsCalls != 1 {
+		t.Error("WithStatsHook not applied", err, statsCalls)
+	}
+	_, err = dec.ReadMessage('z')
+	if err != io.EOF {
+		t.Error("Expected io.EOF, not", err)
+	}
+	if len(decTrace) != 3 || decTrace[0] != "a1234" || decTrace[1] != "xdenied" {
+		t.Error("Decoder WithTrace wrong", decTrace)
+	}
+}
+
+func TestConfigOptions(t *testing.T) {
+	cfg := netstring.Config{MaximumLength: 2}
+	dec := netstring.NewDecoder(bytes.NewBufferString("3:abc,"), cfg.Options()...)
+	_, err := dec.Decode()
+	if err != netstring.ErrLengthToLong {
+		t.Error("Expected ErrLengthToLong, not", err)
+	}
+}
+
+func TestWithLengthAnnounce(t *testing.T) {
+	veto := errors.New("too busy")
+	var announced []int
+	fn := func(length int) error {
+		announced = append(announced, length)
+		if length > 3 {
+			return veto
+		}
+		return nil
+	}
+	dec := netstring.NewDecoder(bytes.NewBufferString("0:,3:abc,4:abcd,1:a,"),
+		netstring.WithLengthAnnounce(fn))
+	for ix := 0; ix < 2; ix++ {
+		_, err := dec.Decode()
+		if err != nil {
+			t.Fatal(ix, err)
+		}
+	}
+	_, err := dec.Decode()
+	if err != veto {
+		t.Error("Expected veto error, not", err)
+	}
+	_, err = dec.Decode()
+	if err != veto {
+		t.Error("Veto error should be permanent, not", err)
+	}
+	if len(announced) != 3 || announced[0] != 0 || announced[2] != 4 {
+		t.Error("Announced lengths wrong", announced)
+	}
+}
+
+// writeRecorder records each Write as a separate element.
+type writeRecorder struct {
+	writes []string
+}
+
+func (wr *writeRecorder) Write(b []byte) (int, error) {
+	wr.writes = append(wr.writes, string(b))
+	return len(b), nil
+}
+
+func TestWithAtomicWrites(t *testing.T) {
+	var traced []string
+	var progress int
+	wr := &writeRecorder{}
+	enc := netstring.NewEncoder(wr, netstring.WithAtomicWrites(),
+		netstring.WithTrace(func(ns []byte) { traced = append(traced, string(ns)) }))
+	enc.SetProgress(func(done, total int64) { progress++ })
+
+	enc.EncodeString('a', "hello")
+	enc.EncodeBytes(netstring.NoKey, []byte("ab"), []byte("cd"))
+	enc.EncodeInt('n', 42)
+	enc.EncodeReader('r', strings.NewReader("stream"), 6)
+	enc.EncodeBytes('z')
+
+	expect := []string{"6:ahello,", "4:abcd,", "3:n42,", "7:rstream,", "1:z,"}
+	if strings.Join(wr.writes, "|") != strings.Join(expect, "|") {
+		t.Error("Writes wrong", wr.writes)
+	}
+	expect = []string{"ahello", "abcd", "n42", "rstream", "z"}
+	if strings.Join(traced, "|") != strings.Join(expect, "|") {
+		t.Error("Trace wrong", traced)
+	}
+	if progress != 4 {
+		t.Error("Expected 4 progress calls, got", progress)
+	}
+
+	err := enc.EncodeReader('r', strings.NewReader("short"), 6)
+	if !errors.Is(err, io.ErrUnexpectedEOF) || len(wr.writes) != 5 {
+		t.Error("Short EncodeReader should write nothing", err, len(wr.writes))
+	}
+}
+
+// TestWithDeterministic is a conformance test of deterministic output. The expected
+// bytes must never change.
+func TestWithDeterministic(t *testing.T) {
+	type record struct {
+		Name   string  `netstring:"n"`
+		Age    int     `netstring:"a"`
+		Height float64 `netstring:"h"`
+		Delta  float32 `netstring:"D"`
+		Code   []byte  `netstring:"c"`
+		Count  uint64  `netstring:"C"`
+	}
+
+	var bbuf bytes.Buffer
+	enc := netstring.NewEncoder(&bbuf, netstring.WithDeterministic())
+	enc.EncodeFloat64('f', math.Copysign(0, -1))
+	enc.EncodeFloat32('g', float32(math.Copysign(0, -1)))
+	enc.EncodeFloat64('f', 1e21)
+	enc.EncodeFloat64('f', 0.1)
+	enc.EncodeFloat32('g', 0.1)
+	enc.EncodeFloat64('f', math.Inf(-1))
+	enc.EncodeFloat64('f', math.NaN())
+	enc.EncodeBool('b', true)
+	enc.EncodeInt64('i', math.MinInt64)
+	enc.EncodeUint64('u', math.MaxUint64)
+	err := enc.Marshal('z', &record{"Bob", 42, 1.85, float32(math.Copysign(0, -1)),
+		[]byte("nz"), 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := "2:f0,2:g0,23:f1000000000000000000000,4:f0.1,4:g0.1,5:f-Inf,4:fNaN,2:bT," +
+		"21:i-9223372036854775808,21:u18446744073709551615," +
+		"2:C7,2:D0,3:a42,3:cnz,5:h1.85,4:nBob,1:z,"
+	if bbuf.String() != expect {
+		t.Error("Deterministic output changed\n", bbuf.String(), "\n", expect)
+	}
+}
+
+func TestWithEscaping(t *testing.T) {
+	var bbuf bytes.Buffer
+	enc := netstring.NewEncoder(&bbuf, netstring.WithEscaping())
+	enc.EncodeString('a', "x,y\n")
+	enc.EncodeBytes('s', []byte("1 "), []byte("%"))
+	enc.EncodeReader('r', strings.NewReader("\t"), 1)
+	bbuf.WriteString("4:b%zz,") // Incorrectly escaped
+	enc.EncodeBytes('z')
+
+	expect := "7:ax,y%0A,8:s1%20%25,4:r%09,4:b%zz,1:z,"
+	if bbuf.String() != expect {
+		t.Fatal("Escaped stream wrong", bbuf.String())
+	}
+
+	dec := netstring.NewDecoder(&bbuf, netstring.WithEscaping())
+	testCases := []struct {
+		key netstring.Key
+		val string
+		err error
+	}{
+		{'a', "x,y\n", nil},
+		{'s', "1 %", nil},
+		{'r', "\t", nil},
+		{netstring.NoKey, "", netstring.ErrBadEscape},
+		{'z', "", nil},
+	}
+	for ix, tc := range testCases {
+		k, v, err := dec.DecodeKeyed()
+		if err != tc.err {
+			t.Error(ix, "Expected", tc.err, "got", err)
+			continue
+		}
+		if k != tc.key || string(v) != tc.val {
+			t.Error(ix, "Expected", tc.key, tc.val, "got", k, string(v))
+		}
+	}
+}
+
+func TestWithKeyFolding(t *testing.T) {
+	type record struct {
+		Age  int    `netstring:"a"`
+		Name string `netstring:"N"`
+	}
+
+	testCases := []struct {
+		fold   bool
+		expect record
+	}{
+		{false, record{Age: 0, Name: ""}},
+		{true, record{Age: 22, Name: "Bob"}},
+	}
+	for ix, tc := range testCases {
+		var opts []netstring.Option
+		if tc.fold {
+			opts = append(opts, netstring.WithKeyFolding())
+		}
+		dec := netstring.NewDecoder(bytes.NewBufferString("3:A22,4:nBob,1:z,"), opts...)
+		var r record
+		unknown, err := dec.Unmarshal('z', &r)
+		if err != nil {
+			t.Fatal(ix, err)
+		}
+		if r != tc.expect {
+			t.Error(ix, "Expected", tc.expect, "got", r)
+		}
+		if !tc.fold && unknown == netstring.NoKey {
+			t.Error(ix, "Expected unknown keys without folding")
+		}
+	}
+
+	type clash struct {
+		Country     string `netstring:"c"`
+		CountryCode string `netstring:"C"`
+	}
+	dec := netstring.NewDecoder(bytes.NewBufferString("1:z,"), netstring.WithKeyFolding())
+	_, err := dec.Unmarshal('z', &clash{})
+	if err == nil {
+		t.Error("Expected conflict error for folded tags")
+	}
+}
+
+func TestWithNULPolicy(t *testing.T) {
+	val := []byte("a\x00b%")
+	testCases := []struct {
+		policy netstring.NULPolicy
+		encErr error
+		wire   string
+		decErr error
+	}{
+		{netstring.NULPass, nil, "5:va\x00b%,", nil},
+		{netstring.NULReject, netstring.ErrNULValue, "", netstring.ErrNULValue},
+		{netstring.NULEscape, nil, "9:va%00b%25,", netstring.ErrBadEscape},
+	}
+
+	for ix, tc := range testCases {
+		var bbuf bytes.Buffer
+		enc := netstring.NewEncoder(&bbuf, netstring.WithNULPolicy(tc.policy))
+		err := enc.EncodeBytes('v', val)
+		if err != tc.encErr {
+			t.Error(ix, "Encode expected", tc.encErr, "got", err)
+		}
+		if bbuf.String() != tc.wire {
+			t.Error(ix, "Wire expected", tc.wire, "got", bbuf.String())
+		}
+		err = enc.EncodeReader('v', bytes.NewReader(val), int64(len(val)))
+		if err != tc.encErr {
+			t.Error(ix, "EncodeReader expected", tc.encErr, "got", err)
+		}
+
+		// Decode the pass-through form followed by a good netstring
+		dec := netstring.NewDecoder(bytes.NewBufferString(tc.wire+"5:va\x00b%,2:ok,"),
+			netstring.WithNULPolicy(tc.policy))
+		if len(tc.wire) > 0 {
+			_, v, err := dec.DecodeKeyed()
+			if err != nil || !bytes.Equal(v, val) {
+				t.Error(ix, "Round trip failed", v, err)
+			}
+		}
+		_, v, err := dec.DecodeKeyed()
+		if err != tc.decErr {
+			t.Error(ix, "Decode expected", tc.decErr, "got", err)
+		}
+		if err == nil && tc.policy == netstring.NULPass && !bytes.Equal(v, val) {
+			t.Error(ix, "Pass through altered value", v)
+		}
+		k, v, err := dec.DecodeKeyed()
+		if err != nil || k != 'o' || string(v) != "k" {
+			t.Error(ix, "Decoding did not continue", k, string(v), err)
+		}
+	}
+}
+
+func TestWithSampling(t *testing.T) {
+	var stream string
+	for ix := 0; ix < 10; ix++ {
+		stream += "2:a" + strconv.Itoa(ix) + ",1:z,"
+	}
+
+	testCases := []struct {
+		n      int
+		traces int
+		stats  int
+	}{
+		{0, 20, 10},
+		{1, 20, 10},
+		{3, 7, 4},
+		{100, 1, 1},
+	}
+	for ix, tc := range testCases {
+		var traces, stats int
+		dec := netstring.NewDecoder(bytes.NewBufferString(stream),
+			netstring.WithSampling(tc.n),
+			netstring.WithTrace(func([]byte) { traces++ }),
+			netstring.WithStatsHook(func(netstring.KeyStats) { stats++ }))
+		for {
+			_, err := dec.ReadMessage('z')
+			if err != nil {
+				break
+			}
+		}
+		if traces != tc.traces || stats != tc.stats {
+			t.Error(ix, "Expected", tc.traces, tc.stats, "got", traces, stats)
+		}
+
+		traces = 0
+		enc := netstring.NewEncoder(io.Discard, netstring.WithSampling(tc.n),
+			netstring.WithTrace(func([]byte) { traces++ }))
+		for jx := 0; jx < 20; jx++ {
+			enc.EncodeBytes('z')
+		}
+		if traces != tc.traces {
+			t.Error(ix, "Encoder expected", tc.traces, "got", traces)
+		}
+	}
+}
+
+func TestWithStickyErrors(t *testing.T) {
+	var bb bytes.Buffer
+	enc := netstring.NewEncoder(&bb, netstring.WithStickyErrors())
+	enc.EncodeString('a', "one")
+	enc.EncodeInt('!', 2) // Invalid key becomes sticky
+	enc.EncodeString('b', "three")
+	err := enc.EncodeBytes('z')
+	if err != netstring.ErrInvalidKey {
+		t.Error("Expected ErrInvalidKey from subsequent call, not", err)
+	}
+	if enc.Err() != netstring.ErrInvalidKey {
+		t.Error("Expected Err() to return ErrInvalidKey, not", enc.Err())
+	}
+	if bb.String() != "4:aone," {
+		t.Error("Expected nothing written after error, got", bb.String())
+	}
+
+	enc = netstring.NewEncoder(&bb) // Without the Option errors are not retained
+	enc.EncodeInt('!', 2)
+	if enc.Err() != nil {
+		t.Error("Non-sticky Encoder should not retain errors", enc.Err())
+	}
+	err = enc.EncodeBytes('z')
+	if err != nil {
+		t.Error("Non-sticky Encoder should continue after error", err)
+	}
+}
+
+// lockedBuffer is a bytes.Buffer which is safe for concurrent Writes but does nothing to
+// prevent the Writes of multiple writers interleaving.
+type lockedBuffer struct {
+	mu sync.Mutex
+	bb bytes.Buffer
+}
+
+func (lb *lockedBuffer) Write(b []byte) (int, error) {
+	defer runtime.Gosched() // Encourage interleaving
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.bb.Write(b)
+}
+
+func TestWithLocking(t *testing.T) {
+	const writers = 8
+	const each = 200
+	var lb lockedBuffer
+	enc := netstring.NewEncoder(&lb, netstring.WithLocking(), netstring.WithStickyErrors())
+	var wg sync.WaitGroup
+	for ix := 0; ix < writers; ix++ {
+		wg.Add(1)
+		go func(key netstring.Key) {
+			defer wg.Done()
+			for jx := 0; jx < each; jx++ {
+				enc.EncodeString(key, strings.Repeat(string(key), jx%20))
+			}
+		}(netstring.Key('a' + ix))
+	}
+	wg.Wait()
+	if enc.Err() != nil {
+		t.Fatal("Unexpected error", enc.Err())
+	}
+
+	dec := netstring.NewDecoder(&lb.bb)
+	count := 0
+	for {
+		k, v, err := dec.DecodeKeyed()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal("Interleaved output after", count, err)
+		}
+		if strings.Trim(string(v), k.String()) != "" {
+			t.Fatal("Interleaved value after", count, k, len(v))
+		}
+		count++
+	}
+	if count != writers*each {
+		t.Error("Expected", writers*each, "netstrings, got", count)
+	}
+}
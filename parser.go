@@ -0,0 +1,182 @@
+package netstring
+
+/*
+Parser is a push alternative to Decoder which never blocks. Rather than reading from an
+io.Reader, the application feeds a Parser whatever bytes it is handed and the Parser
+calls back for each netstring as soon as it is complete. This suits event driven
+environments such as WASM front-ends receiving WebSocket message events, where a
+blocking Read is not available. A Parser *must* be constructed with [NewParser] or
+[NewKeyedParser].
+
+Netstrings can span any number of Feed calls and any number of netstrings can arrive in
+one Feed call. All Decoder Options apply, such as WithMaximumLength, WithKeyPolicy and
+WithEscaping. When built for js/wasm, the Parser also has FeedJS and OnMessage methods
+to accept JavaScript byte arrays directly.
+
+As with Decoder, parse errors are permanent and are returned by every subsequent Feed.
+*/
+type Parser struct {
+	dec   *Decoder
+	feed  pushFeed
+	keyed bool
+	fn    func(ns []byte) error
+	kfn   func(key Key, val []byte) error
+	cp    *checkpoint // Set by Checkpoint
+}
+
+// checkpoint is the Parser state saved by Checkpoint. As well as the Decoder itself, the
+// state it refers to by pointer is saved.
+type checkpoint struct {
+	dec      Decoder
+	feed     []byte
+	since    []byte // All bytes fed after the checkpoint
+	reads    ReadStats
+	soft     softState
+	mac      decoderMAC
+	macInput []byte
+	charged  int64
+}
+
+// NewParser constructs a Parser which calls "fn" with each standard netstring. If "fn"
+// returns an error, Feed stops and returns that error. The bytes remaining from that
+// Feed are retained and are parsed by the next Feed, which may be passed nil.
+func NewParser(fn func(ns []byte) error, opts ...Option) *Parser {
+	p := &Parser{fn: fn}
+	p.dec = newPushDecoder(&p.feed, opts)
+
+	return p
+}
+
+// NewKeyedParser constructs a Parser which calls "fn" with the key and value of each
+// "keyed" netstring as per Decoder.DecodeKeyed. Errors which DecodeKeyed treats as
+// non-permanent, such as ErrZeroKey and ErrKeyNotPermitted, are returned by Feed in the
+// same way as an error returned by "fn".
+func NewKeyedParser(fn func(key Key, val []byte) error, opts ...Option) *Parser {
+	p := &Parser{kfn: fn, keyed: true}
+	p.dec = newPushDecoder(&p.feed, opts)
+
+	return p
+}
+
+// newPushDecoder constructs the Decoder of a Parser. With WithHMAC, the MAC input is
+// retained so that Rollback can rebuild the MAC.
+func newPushDecoder(feed *pushFeed, opts []Option) *Decoder {
+	dec := NewDecoder(feed, opts...)
+	if dec.mac != nil {
+		dec.mac.keep = true
+	}
+
+	return dec
+}
+
+// Feed parses "data" and calls back for each netstring which is now complete. "data" is
+// not retained once Feed returns.
+func (p *Parser) Feed(data []byte) error {
+	if p.cp != nil {
+		p.cp.since = append(p.cp.since, data...)
+	}
+	if len(p.feed.buf) == 0 {
+		p.feed.buf = data
+	} else { // Full slice expression forces a copy so the caller's data is not touched
+		p.feed.buf = append(p.feed.buf[:len(p.feed.buf):len(p.feed.buf)], data...)
+	}
+	defer func() {
+		if len(p.feed.buf) > 0 { // Retain a copy for the next Feed
+			p.feed.buf = append([]byte(nil), p.feed.buf...)
+		}
+	}()
+
+	for {
+		var err error
+		if p.keyed {
+			var k Key
+			var v []byte
+			k, v, err = p.dec.DecodeKeyed()
+			if err == nil {
+				err = p.kfn(k, v)
+			}
+		} else {
+			var ns []byte
+			ns, err = p.dec.Decode()
+			if err == nil {
+				err = p.fn(ns)
+			}
+		}
+		if err == errNeedMore {
+			needMore(p.dec)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Buffered returns true if a partial netstring has been fed but not yet completed.
+// Applications use this at the end of a stream to detect truncation.
+func (p *Parser) Buffered() bool {
+	return p.dec.state != parseFirstByte || p.dec.at < p.dec.end || len(p.feed.buf) > 0
+}
+
+// Checkpoint saves the state of the Parser so that a subsequent Rollback can return it to
+// this point. This allows speculative parsing, such as when auto-detecting whether a
+// connection carries netstrings or some other framing: feed a few bytes and, if they do
+// not parse, roll back and hand the bytes to another protocol.
+//
+// Only one checkpoint is held; a second Checkpoint replaces the first. Callbacks made
+// between Checkpoint and Rollback are not undone. The HMAC of WithHMAC, the charge of
+// WithMemoryBudget and the state of WithSoftLimits are rolled back along with the parse
+// state, but a Parser shed by its MemoryBudget remains shed.
+func (p *Parser) Checkpoint() {
+	dec := p.dec
+	cp := &checkpoint{dec: *dec, feed: append([]byte(nil), p.feed.buf...),
+		reads: dec.counter.stats}
+	if dec.soft != nil {
+		cp.soft = *dec.soft
+	}
+	if dec.mac != nil {
+		cp.mac = *dec.mac
+		cp.macInput = append([]byte(nil), dec.mac.input...)
+	}
+	if dec.budget != nil {
+		cp.charged = dec.budget.charge()
+	}
+	p.cp = cp
+}
+
+// Rollback returns the Parser to its state at the most recent Checkpoint, including
+// clearing any parse error which has occurred since, and returns all bytes fed since the
+// Checkpoint. The checkpoint is consumed. Rollback returns nil and does nothing if there
+// is no checkpoint. If the MemoryBudget no longer has room for the charge held at the
+// Checkpoint, the Parser fails with ErrMemoryBudget.
+func (p *Parser) Rollback() []byte {
+	if p.cp == nil {
+		return nil
+	}
+	cp := p.cp
+	p.cp = nil
+	dec := p.dec
+	*dec = cp.dec
+	p.feed.buf = cp.feed
+	dec.counter.stats = cp.reads
+	if dec.soft != nil {
+		*dec.soft = cp.soft
+	}
+	if dec.mac != nil {
+		*dec.mac = cp.mac
+		dec.mac.input = nil // Must not share with the checkpoint
+		dec.mac.rebuild(cp.macInput)
+	}
+	if dec.budget != nil {
+		if err := dec.budget.restore(cp.charged); err != nil {
+			dec.parseError = err
+		}
+	}
+
+	return cp.since
+}
+
+// Commit discards the checkpoint, if any, so that fed bytes are no longer retained.
+func (p *Parser) Commit() {
+	p.cp = nil
+}
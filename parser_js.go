@@ -0,0 +1,41 @@
+//go:build js && wasm
+
+package netstring
+
+import (
+	"syscall/js"
+)
+
+// FeedJS is the same as Feed excepting that "data" is a JavaScript Uint8Array or
+// ArrayBuffer, such as the data of a WebSocket message event when the WebSocket
+// binaryType is "arraybuffer".
+func (p *Parser) FeedJS(data js.Value) error {
+	if data.InstanceOf(js.Global().Get("ArrayBuffer")) {
+		data = js.Global().Get("Uint8Array").New(data)
+	}
+	b := make([]byte, data.Get("length").Int())
+	js.CopyBytesToGo(b, data)
+
+	return p.Feed(b)
+}
+
+// OnMessage returns a js.Func suitable as a WebSocket "message" event listener which
+// feeds the data of each event to the Parser. Errors returned by Feed are passed to
+// "onError", if not nil. The caller must Release the js.Func once it is no longer
+// needed.
+//
+//	ws := js.Global().Get("WebSocket").New(url)
+//	ws.Set("binaryType", "arraybuffer")
+//	ws.Call("addEventListener", "message", parser.OnMessage(onError))
+func (p *Parser) OnMessage(onError func(error)) js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		if len(args) == 0 {
+			return nil
+		}
+		err := p.FeedJS(args[0].Get("data"))
+		if err != nil && onError != nil {
+			onError(err)
+		}
+		return nil
+	})
+}
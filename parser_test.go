@@ -0,0 +1,200 @@
+package netstring_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestParser(t *testing.T) {
+	stream := []byte("3:abc,0:,5:hello,")
+	for _, chunk := range []int{1, 2, 5, len(stream)} {
+		var got []string
+		p := netstring.NewParser(func(ns []byte) error {
+			got = append(got, string(ns))
+			return nil
+		})
+		for off := 0; off < len(stream); off += chunk {
+			end := off + chunk
+			if end > len(stream) {
+				end = len(stream)
+			}
+			err := p.Feed(stream[off:end])
+			if err != nil {
+				t.Fatal(chunk, "Unexpected error", err)
+			}
+		}
+		if len(got) != 3 || got[0] != "abc" || got[1] != "" || got[2] != "hello" {
+			t.Error(chunk, "Parsed wrong", got)
+		}
+		if p.Buffered() {
+			t.Error(chunk, "Nothing should remain buffered")
+		}
+		p.Feed([]byte("3:ab"))
+		if !p.Buffered() {
+			t.Error(chunk, "Partial netstring should be buffered")
+		}
+	}
+}
+
+func TestParserErrors(t *testing.T) {
+	p := netstring.NewParser(func([]byte) error { return nil }, netstring.WithMaximumLength(3))
+	err := p.Feed([]byte("1:a,4:"))
+	if err != netstring.ErrLengthToLong {
+		t.Error("Expected ErrLengthToLong, not", err)
+	}
+	err = p.Feed([]byte("1:a,"))
+	if err != netstring.ErrLengthToLong {
+		t.Error("Parse errors should be permanent, not", err)
+	}
+
+	stop := errors.New("stop")
+	var got []string
+	p = netstring.NewParser(func(ns []byte) error {
+		got = append(got, string(ns))
+		if len(got) == 1 {
+			return stop
+		}
+		return nil
+	})
+	data := []byte("1:a,1:b,1:")
+	err = p.Feed(data)
+	if err != stop {
+		t.Error("Expected callback error, not", err)
+	}
+	copy(data, "XXXXXXXXXX") // Parser must not retain the caller's data
+	err = p.Feed([]byte("c,"))
+	if err != nil {
+		t.Error("Unexpected error", err)
+	}
+	if len(got) != 3 || got[1] != "b" || got[2] != "c" {
+		t.Error("Remaining bytes not parsed", got)
+	}
+}
+
+func TestKeyedParser(t *testing.T) {
+	type kv struct {
+		k netstring.Key
+		v string
+	}
+	var got []kv
+	p := netstring.NewKeyedParser(func(k netstring.Key, v []byte) error {
+		got = append(got, kv{k, string(v)})
+		return nil
+	}, netstring.WithKeyPolicy(&netstring.KeyPolicy{Deny: "x"}))
+	err := p.Feed([]byte("3:abc,1:z,2:xy,2:bc,"))
+	if err != netstring.ErrKeyNotPermitted {
+		t.Error("Expected ErrKeyNotPermitted, not", err)
+	}
+	err = p.Feed(nil) // Continue after a non-permanent error
+	if err != nil {
+		t.Error("Unexpected error", err)
+	}
+	exp := []kv{{'a', "bc"}, {'z', ""}, {'b', "c"}}
+	if len(got) != len(exp) {
+		t.Fatal("Expected", exp, "got", got)
+	}
+	for ix := range exp {
+		if got[ix] != exp[ix] {
+			t.Error(ix, "Expected", exp[ix], "got", got[ix])
+		}
+	}
+}
+
+func TestParserCheckpoint(t *testing.T) {
+	var got []string
+	p := netstring.NewParser(func(ns []byte) error {
+		got = append(got, string(ns))
+		return nil
+	})
+	err := p.Feed([]byte("3:abc,5:he"))
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+
+	p.Checkpoint() // Part way through a netstring
+	p.Feed([]byte("l"))
+	err = p.Feed([]byte("GET / HTTP/1.1"))
+	if err == nil {
+		t.Fatal("Expected a parse error")
+	}
+	since := p.Rollback()
+	if string(since) != "lGET / HTTP/1.1" {
+		t.Error("Rollback returned", string(since))
+	}
+	if p.Rollback() != nil {
+		t.Error("Second Rollback should return nil")
+	}
+
+	err = p.Feed([]byte("llo,")) // Error is gone and partial "he" is intact
+	if err != nil {
+		t.Fatal("Unexpected error after Rollback", err)
+	}
+	if len(got) != 2 || got[1] != "hello" {
+		t.Error("Expected hello after Rollback, got", got)
+	}
+
+	p.Checkpoint()
+	p.Commit()
+	p.Feed([]byte("1:x,"))
+	if p.Rollback() != nil {
+		t.Error("Rollback after Commit should do nothing")
+	}
+	if len(got) != 3 || got[2] != "x" {
+		t.Error("Expected x after Commit, got", got)
+	}
+}
+
+func TestParserCheckpointState(t *testing.T) {
+	opts := []netstring.Option{netstring.WithHMAC([]byte("secret"), sha256.New)}
+	var wire bytes.Buffer
+	enc := netstring.NewEncoder(&wire, opts...)
+	enc.EncodeMessage('z', netstring.KV{Key: 'n', Value: []byte("Alice")})
+	enc.EncodeMessage('z', netstring.KV{Key: 'n', Value: []byte("Bob")})
+
+	var got []string
+	p := netstring.NewKeyedParser(func(key netstring.Key, val []byte) error {
+		if key == 'n' {
+			got = append(got, string(val))
+		}
+		return nil
+	}, opts...)
+	b := wire.Bytes()
+	if err := p.Feed(b[:10]); err != nil { // After "6:nAlice," so the MAC has input
+		t.Fatal(err)
+	}
+	p.Checkpoint()
+	if err := p.Feed(b[10:]); err != nil {
+		t.Fatal(err)
+	}
+	since := p.Rollback()
+	if err := p.Feed(since); err != nil {
+		t.Fatal("HMAC not rolled back", err)
+	}
+	if strings.Join(got, ",") != "Alice,Bob,Bob" {
+		t.Error("Unexpected values", got)
+	}
+
+	mb := netstring.NewMemoryBudget(100, netstring.BudgetReject)
+	p = netstring.NewParser(func(ns []byte) error { return nil }, netstring.WithMemoryBudget(mb))
+	p.Checkpoint()
+	p.Feed([]byte("50:xx"))
+	since = p.Rollback()
+	if mb.InUse() != 0 {
+		t.Error("Budget charge not rolled back", mb.InUse())
+	}
+	p.Feed(since)
+	if mb.InUse() != 50 {
+		t.Error("Expected a single charge after re-Feed, got", mb.InUse())
+	}
+	p.Checkpoint()
+	p.Feed([]byte(strings.Repeat("x", 48) + ","))
+	p.Rollback()
+	if mb.InUse() != 50 {
+		t.Error("Expected the charge restored by Rollback, got", mb.InUse())
+	}
+}
@@ -0,0 +1,62 @@
+package netstring
+
+import (
+	"fmt"
+)
+
+var (
+	prettyFrame   = []byte("\n")
+	prettyMessage = []byte("\n\n")
+)
+
+// WithPrettyOutput causes an Encoder to write a newline after each netstring so that
+// streams written for humans and diff tools have one netstring per line. If "eom" is not
+// NoKey, a second newline follows each end-of-message sentinel, that is each "keyed"
+// netstring with key "eom" and no value, so that messages are separated by a blank line.
+//
+// Newlines are not part of any netstring so they are not counted by Stats, passed to
+// Trace or covered by WithHMAC. With WithAtomicWrites they are included in the single
+// Write of each netstring. A Decoder constructed WithFramePadding reads the output back.
+// Decoder ignores this Option.
+func WithPrettyOutput(eom Key) Option {
+	return func(o *options) {
+		o.pretty = true
+		o.prettyEOM = eom
+	}
+}
+
+// prettyPadding returns the padding which follows a netstring of length "l", if any.
+func (enc *Encoder) prettyPadding(key Key, keyed bool, l int64) []byte {
+	switch {
+	case !enc.pretty:
+		return nil
+	case keyed && key == enc.prettyEOM && l == int64(enc.keyPrefix()):
+		return prettyMessage
+	}
+
+	return prettyFrame
+}
+
+// writePadding writes the padding which follows a netstring, if any, excluding it from
+// the HMAC.
+func (enc *Encoder) writePadding(key Key, keyed bool, l int64) error {
+	pad := enc.prettyPadding(key, keyed, l)
+	if len(pad) == 0 {
+		return nil
+	}
+	enc.skipMAC(len(pad))
+	_, err := enc.out.Write(pad)
+	enc.skipMAC(0) // In case the Write never reached the macWriter
+	if err != nil {
+		return fmt.Errorf(errorPrefix+"Encoder write padding failed: %w", err)
+	}
+
+	return nil
+}
+
+// skipMAC excludes the trailing "n" bytes of the next Write from the HMAC, if any.
+func (enc *Encoder) skipMAC(n int) {
+	if enc.mw != nil {
+		enc.mw.skip = n
+	}
+}
@@ -0,0 +1,90 @@
+package netstring_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestPrettyOutput(t *testing.T) {
+	type msg struct {
+		Name string `netstring:"n"`
+		Note string `netstring:"o"`
+	}
+	testCases := []struct {
+		opts []netstring.Option
+		exp  string
+	}{
+		{[]netstring.Option{netstring.WithPrettyOutput(netstring.NoKey)},
+			"4:nbob,\n1:o,\n1:z,\n3:abc,\n"},
+		{[]netstring.Option{netstring.WithPrettyOutput('z')},
+			"4:nbob,\n1:o,\n1:z,\n\n3:abc,\n"},
+		{[]netstring.Option{netstring.WithPrettyOutput('z'), netstring.WithAtomicWrites()},
+			"4:nbob,\n1:o,\n1:z,\n\n3:abc,\n"},
+		{[]netstring.Option{netstring.WithPrettyOutput('z'), netstring.WithLongKeys()},
+			"5:n=bob,\n2:o=,\n2:z=,\n\n3:abc,\n"},
+	}
+
+	for ix, tc := range testCases {
+		var bb bytes.Buffer
+		enc := netstring.NewEncoder(&bb, tc.opts...)
+		err := enc.Marshal('z', &msg{Name: "bob"})
+		if err == nil {
+			err = enc.EncodeString(netstring.NoKey, "abc")
+		}
+		if err != nil {
+			t.Fatal(ix, err)
+		}
+		if bb.String() != tc.exp {
+			t.Errorf("%d Expected %q, got %q", ix, tc.exp, bb.String())
+		}
+		if s := enc.Stats(); s.Bytes != int64(bb.Len()-bytes.Count(bb.Bytes(), []byte("\n"))) {
+			t.Error(ix, "Padding should not be counted", s.Bytes, bb.Len())
+		}
+
+		dec := netstring.NewDecoder(&bb, append(tc.opts, netstring.WithFramePadding(""))...)
+		var m msg
+		_, err = dec.Unmarshal('z', &m)
+		if err != nil || m.Name != "bob" {
+			t.Error(ix, "Read back failed", m, err)
+		}
+		ns, err := dec.Decode()
+		if err != nil || string(ns) != "abc" {
+			t.Error(ix, "Read back of trailing netstring", string(ns), err)
+		}
+	}
+}
+
+func TestPrettyOutputHMAC(t *testing.T) {
+	for ix, atomic := range []bool{false, true} {
+		opts := []netstring.Option{netstring.WithHMAC([]byte("secret"), sha256.New),
+			netstring.WithPrettyOutput('z')}
+		if atomic {
+			opts = append(opts, netstring.WithAtomicWrites())
+		}
+		var bb bytes.Buffer
+		enc := netstring.NewEncoder(&bb, opts...)
+		for _, name := range []string{"Alice", "Bob"} {
+			err := enc.EncodeMessage('z', netstring.KV{Key: 'n', Value: []byte(name)})
+			if err != nil {
+				t.Fatal(ix, err)
+			}
+		}
+		if bytes.Count(bb.Bytes(), []byte(",\n\n")) != 2 {
+			t.Error(ix, "Expected a blank line after each message", bb.String())
+		}
+
+		dec := netstring.NewDecoder(&bb, append(opts, netstring.WithFramePadding("\n"))...)
+		for _, exp := range []string{"Alice", "Bob"} {
+			m, err := dec.ReadMessage('z')
+			if err != nil {
+				t.Fatal(ix, "Padding covered by the HMAC?", err)
+			}
+			if v, _ := m.Get('n'); string(v) != exp {
+				t.Error(ix, "Expected", exp, "got", string(v))
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package netstring_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestEncoderProgress(t *testing.T) {
+	type call struct {
+		done, total int64
+	}
+	var calls []call
+
+	var bbuf bytes.Buffer
+	enc := netstring.NewEncoder(&bbuf)
+	enc.SetProgress(func(done, total int64) {
+		calls = append(calls, call{done, total})
+	})
+
+	enc.EncodeBytes('k', []byte("abc"), nil, []byte("de"))
+	exp := []call{{4, 6}, {6, 6}}
+	if len(calls) != len(exp) {
+		t.Fatal("Wrong number of progress calls", calls)
+	}
+	for ix, c := range exp {
+		if calls[ix] != c {
+			t.Error(ix, "Got", calls[ix], "expected", c)
+		}
+	}
+
+	calls = nil
+	enc.SetProgress(nil)
+	enc.EncodeString(netstring.NoKey, "abc")
+	if len(calls) != 0 {
+		t.Error("Progress called after being disabled", calls)
+	}
+}
+
+func TestDecoderProgress(t *testing.T) {
+	var last, total int64
+	var count int
+
+	mr := newMyReader()
+	dec := netstring.NewDecoder(mr)
+	dec.SetProgress(func(d, t int64) {
+		last = d
+		total = t
+		count++
+	})
+	mr.set([]byte("10:abc"))
+	mr.set([]byte("defg"))
+	mr.set([]byte("hij,"))
+	mr.close()
+
+	ns, err := dec.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(ns) != "abcdefghij" {
+		t.Error("Wrong value", string(ns))
+	}
+	if count != 3 || last != 10 || total != 10 {
+		t.Error("Wrong progress", count, last, total)
+	}
+}
@@ -0,0 +1,237 @@
+package netstring
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Keys reserved for the protocol message written by Encoder.WriteProtocol and read by
+// Decoder.ReadProtocol. As with the provenance message, the protocol message is the
+// ProtocolKey marker netstring followed by a message of the remaining keys which is
+// terminated by ProtocolEOM. It is normally only exchanged at the start of a connection.
+const (
+	ProtocolKey         Key = 'Q' // Marker netstring which starts the protocol message
+	ProtocolTypeKey     Key = 'T' // Registry type key of the descriptor
+	ProtocolFieldKey    Key = 'F' // One per field of the descriptor
+	ProtocolEOM         Key = 'q' // End-of-message sentinel of the protocol message
+	protocolMarker          = "netstring-protocol-1"
+	protocolFieldFormat     = "%q %c %q %q"
+)
+
+/*
+ProtocolDescriptor describes the wire format of every message type registered with a
+Registry so that the two ends of a connection can confirm they agree on it before
+exchanging messages. Without such a check, a peer which has been upgraded to change the
+type of a field silently misinterprets messages from a peer which has not.
+
+A ProtocolDescriptor is constructed with [NewProtocolDescriptor] and is typically
+exchanged immediately after connecting:
+
+	local := netstring.NewProtocolDescriptor(reg)
+	conn.Encoder.WriteProtocol(local)
+	peer, err := conn.Decoder.ReadProtocol()
+	...
+	err = local.Compare(peer) // Wraps ErrProtocolMismatch with a field-level diff
+
+Each field is identified by its message type and key and is described by its struct
+field name and Go type. Struct field names are included so that renames are visible in
+the diff even though they do not change the wire format.
+*/
+type ProtocolDescriptor struct {
+	TypeKey Key
+	Fields  []ProtocolField // Sorted by Type then Key
+}
+
+// ProtocolField describes a single field of a registered message type.
+type ProtocolField struct {
+	Type   string // Registered message type
+	Key    Key
+	Name   string // Struct field name
+	GoType string // As per reflect.Type.String(), such as "int64" or "time.Time"
+}
+
+// String returns the canonical form of the field as used by Hash and on the wire.
+func (pf ProtocolField) String() string {
+	return fmt.Sprintf(protocolFieldFormat, pf.Type, pf.Key, pf.Name, pf.GoType)
+}
+
+// NewProtocolDescriptor constructs a ProtocolDescriptor of the tagged fields of every
+// type registered with "reg". Types registered afterwards are not included.
+func NewProtocolDescriptor(reg *Registry) *ProtocolDescriptor {
+	pd := &ProtocolDescriptor{TypeKey: reg.typeKey}
+	reg.mu.RLock()
+	for name, rt := range reg.types {
+		for ix := 0; ix < rt.NumField(); ix++ {
+			sf := rt.Field(ix)
+			tag, _ := splitTag(sf.Tag.Get("netstring"))
+			if sf.IsExported() && len(tag) == 1 {
+				pd.Fields = append(pd.Fields, ProtocolField{Type: name, Key: Key(tag[0]),
+					Name: sf.Name, GoType: sf.Type.String()})
+			}
+		}
+	}
+	reg.mu.RUnlock()
+	pd.sort()
+
+	return pd
+}
+
+func (pd *ProtocolDescriptor) sort() {
+	sort.Slice(pd.Fields, func(i, j int) bool {
+		if pd.Fields[i].Type != pd.Fields[j].Type {
+			return pd.Fields[i].Type < pd.Fields[j].Type
+		}
+		return pd.Fields[i].Key < pd.Fields[j].Key
+	})
+}
+
+// Hash returns a hex encoded SHA-256 digest of the canonical form of the descriptor. The
+// digest is independent of registration and struct field order so it is suitable for
+// logging and for cheap equality checks.
+func (pd *ProtocolDescriptor) Hash() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "typekey %s\n", pd.TypeKey)
+	for _, pf := range pd.Fields {
+		fmt.Fprintln(h, pf)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Diff returns a human readable description of each difference between the descriptor
+// and "peer", in the order of the Fields. It returns nil if the descriptors are
+// identical.
+func (pd *ProtocolDescriptor) Diff(peer *ProtocolDescriptor) []string {
+	var diffs []string
+	if pd.TypeKey != peer.TypeKey {
+		diffs = append(diffs, fmt.Sprintf("type key %s != peer %s", pd.TypeKey, peer.TypeKey))
+	}
+
+	type typeKey struct {
+		typ string
+		key Key
+	}
+	theirs := make(map[typeKey]ProtocolField, len(peer.Fields))
+	for _, pf := range peer.Fields {
+		theirs[typeKey{pf.Type, pf.Key}] = pf
+	}
+	for _, ours := range pd.Fields {
+		tk := typeKey{ours.Type, ours.Key}
+		pf, ok := theirs[tk]
+		delete(theirs, tk)
+		switch {
+		case !ok:
+			diffs = append(diffs, fmt.Sprintf("%s.%s: %s %s missing from peer",
+				ours.Type, ours.Key, ours.Name, ours.GoType))
+		case pf.GoType != ours.GoType:
+			diffs = append(diffs, fmt.Sprintf("%s.%s: %s is %s, peer has %s",
+				ours.Type, ours.Key, ours.Name, ours.GoType, pf.GoType))
+		case pf.Name != ours.Name:
+			diffs = append(diffs, fmt.Sprintf("%s.%s: %s renamed %s by peer",
+				ours.Type, ours.Key, ours.Name, pf.Name))
+		}
+	}
+	for _, pf := range peer.Fields { // Whatever remains is only known to the peer
+		if _, ok := theirs[typeKey{pf.Type, pf.Key}]; ok {
+			diffs = append(diffs, fmt.Sprintf("%s.%s: %s %s only known to peer",
+				pf.Type, pf.Key, pf.Name, pf.GoType))
+		}
+	}
+
+	return diffs
+}
+
+// Compare returns nil if "peer" is identical to the descriptor, otherwise it returns an
+// error which wraps ErrProtocolMismatch and lists every difference.
+func (pd *ProtocolDescriptor) Compare(peer *ProtocolDescriptor) error {
+	diffs := pd.Diff(peer)
+	if len(diffs) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", ErrProtocolMismatch, strings.Join(diffs, "; "))
+}
+
+// WriteProtocol writes "pd" as a protocol message for the peer to read with
+// ReadProtocol.
+func (enc *Encoder) WriteProtocol(pd *ProtocolDescriptor) (err error) {
+	defer enc.stick(&err)
+	if err = enc.Err(); err != nil {
+		return err
+	}
+	fields := make([]string, 0, len(pd.Fields))
+	for _, pf := range pd.Fields {
+		fields = append(fields, pf.String())
+	}
+	err = enc.EncodeString(ProtocolKey, protocolMarker)
+	if err == nil {
+		err = enc.EncodeString(ProtocolTypeKey, pd.TypeKey.String())
+	}
+	if err == nil {
+		err = enc.EncodeStrings(ProtocolFieldKey, fields)
+	}
+	if err == nil {
+		err = enc.EncodeBytes(ProtocolEOM)
+	}
+
+	return err
+}
+
+// ReadProtocol reads the protocol message written by Encoder.WriteProtocol. Use
+// ProtocolDescriptor.Compare to check it against the local descriptor.
+//
+// ErrProtocolMismatch is returned if the next netstring is not the protocol marker, in
+// which case that netstring has been consumed, or if the protocol message is malformed.
+func (dec *Decoder) ReadProtocol() (*ProtocolDescriptor, error) {
+	k, v, err := dec.DecodeKeyed()
+	if err != nil {
+		return nil, err
+	}
+	if k != ProtocolKey || string(v) != protocolMarker {
+		return nil, fmt.Errorf("%w: no protocol message from peer", ErrProtocolMismatch)
+	}
+	m, err := dec.ReadMessage(ProtocolEOM)
+	if err != nil {
+		return nil, err
+	}
+
+	pd := &ProtocolDescriptor{}
+	tk, _ := m.Get(ProtocolTypeKey)
+	if len(tk) != 1 {
+		return nil, fmt.Errorf("%w: malformed type key '%s'", ErrProtocolMismatch, string(tk))
+	}
+	pd.TypeKey = Key(tk[0])
+	for _, line := range m.GetStrings(ProtocolFieldKey) {
+		pf, err := parseProtocolField(line)
+		if err != nil {
+			return nil, err
+		}
+		pd.Fields = append(pd.Fields, pf)
+	}
+	pd.sort()
+
+	return pd, nil
+}
+
+// parseProtocolField is the inverse of ProtocolField.String.
+func parseProtocolField(line string) (pf ProtocolField, err error) {
+	var key rune
+	_, err = fmt.Sscanf(line, protocolFieldFormat, &pf.Type, &key, &pf.Name, &pf.GoType)
+	if err == nil && key > 0xff {
+		err = ErrInvalidKey
+	}
+	var keyed bool
+	if err == nil {
+		keyed, err = Key(key).Assess()
+	}
+	if err != nil || !keyed {
+		return pf, fmt.Errorf("%w: malformed field %s", ErrProtocolMismatch, strconv.Quote(line))
+	}
+	pf.Key = Key(key)
+
+	return pf, nil
+}
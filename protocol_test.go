@@ -0,0 +1,98 @@
+package netstring_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+type protoLoginV1 struct {
+	User  string `netstring:"u"`
+	Count int32  `netstring:"c"`
+}
+
+type protoLoginV2 struct {
+	Account string `netstring:"u"`
+	Count   int64  `netstring:"c"`
+	Token   string `netstring:"t"`
+}
+
+type protoLogout struct {
+	Reason string `netstring:"r"`
+}
+
+func TestProtocolDescriptor(t *testing.T) {
+	reg1 := netstring.NewRegistry('M')
+	reg1.Register("login", protoLoginV1{})
+	reg1.Register("log out", protoLogout{}) // Names with spaces survive the wire
+
+	var bb bytes.Buffer
+	local := netstring.NewProtocolDescriptor(reg1)
+	if err := netstring.NewEncoder(&bb).WriteProtocol(local); err != nil {
+		t.Fatal("WriteProtocol", err)
+	}
+	peer, err := netstring.NewDecoder(&bb).ReadProtocol()
+	if err != nil {
+		t.Fatal("ReadProtocol", err)
+	}
+	if err := local.Compare(peer); err != nil {
+		t.Error("Identical descriptors should compare", err)
+	}
+	if local.Hash() != peer.Hash() {
+		t.Error("Hash differs after round trip")
+	}
+	if len(peer.Fields) != 3 || peer.Fields[0].Type != "log out" || peer.Fields[2].GoType != "string" {
+		t.Error("Unexpected fields", peer.Fields)
+	}
+
+	reg2 := netstring.NewRegistry('M')
+	reg2.Register("login", protoLoginV2{})
+	upgraded := netstring.NewProtocolDescriptor(reg2)
+	if local.Hash() == upgraded.Hash() {
+		t.Error("Hash should change with the protocol")
+	}
+	err = local.Compare(upgraded)
+	if !errors.Is(err, netstring.ErrProtocolMismatch) {
+		t.Fatal("Expected ErrProtocolMismatch, got", err)
+	}
+	expect := []string{
+		"log out.r: Reason string missing from peer",
+		"login.c: Count is int32, peer has int64",
+		"login.u: User renamed Account by peer",
+		"login.t: Token string only known to peer",
+	}
+	diffs := local.Diff(upgraded)
+	if len(diffs) != len(expect) {
+		t.Fatal("Diff", diffs)
+	}
+	for ix, d := range diffs {
+		if d != expect[ix] {
+			t.Error(ix, "Got", d, "expected", expect[ix])
+		}
+		if !strings.Contains(err.Error(), d) {
+			t.Error(ix, "Compare error lacks", d)
+		}
+	}
+
+	other := netstring.NewProtocolDescriptor(netstring.NewRegistry('N'))
+	if diffs := other.Diff(netstring.NewProtocolDescriptor(netstring.NewRegistry('M'))); len(diffs) != 1 {
+		t.Error("Type key difference not reported", diffs)
+	}
+}
+
+func TestReadProtocolErrors(t *testing.T) {
+	for ix, input := range []string{
+		"4:ubob,1:z,",
+		"21:Qnetstring-protocol-1,1:q,",
+		"21:Qnetstring-protocol-1,2:TM,5:Fjunk,1:q,",
+		`21:Qnetstring-protocol-1,2:TM,16:F"a" 1 "B" "int",1:q,`,
+	} {
+		_, err := netstring.NewDecoder(strings.NewReader(input)).ReadProtocol()
+		if !errors.Is(err, netstring.ErrProtocolMismatch) {
+			t.Error(ix, "Expected ErrProtocolMismatch, got", err)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package netstring
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// Keys reserved for the provenance message emitted by an Encoder constructed with
+// WithProvenance and consumed by Decoder.ReadProvenance. The provenance message is the
+// ProvenanceKey marker netstring followed by a message of the remaining keys which is
+// terminated by ProvenanceEOM. Applications need only avoid these keys in the first
+// message of a stream.
+const (
+	ProvenanceKey         Key = 'W' // Marker netstring which starts the provenance message
+	ProvenanceProducerKey Key = 'P' // Name of the producing application
+	ProvenanceVersionKey  Key = 'V' // Version of the producing application
+	ProvenanceSchemaKey   Key = 'H' // Schema.Hash of the messages which follow
+	ProvenanceEOM         Key = 'w' // End-of-message sentinel of the provenance message
+)
+
+// provenanceMarker is the value of the ProvenanceKey netstring. It identifies the format
+// of the provenance message should it ever need to change.
+const provenanceMarker = "netstring-provenance-1"
+
+// Provenance identifies the producer of a stream so that archived streams remain
+// self-identifying long after the producer has gone. See WithProvenance.
+type Provenance struct {
+	Producer   string `netstring:"P"`
+	Version    string `netstring:"V"`
+	SchemaHash string `netstring:"H"` // Typically Schema.Hash() or empty
+}
+
+// WithProvenance causes an Encoder to emit a provenance message describing "p"
+// immediately prior to the first netstring it encodes, so that the stream starts with
+// the provenance message. Nothing is written if nothing is ever encoded. The provenance
+// message is emitted again after Encoder.Reset. Decoder ignores this Option.
+//
+// As each MessageBuilder has its own Encoder, this Option is only useful with an Encoder
+// which produces a whole stream.
+func WithProvenance(p Provenance) Option {
+	return func(o *options) {
+		o.provenance = &p
+	}
+}
+
+// encodeProvenance emits the pending provenance message. It is called by encodeBytes
+// prior to the first netstring of each stream so it must also use encodeBytes, as the
+// lock, if any, is already held.
+func (enc *Encoder) encodeProvenance() error {
+	enc.pending = false // Must precede encodeBytes() as it calls here
+	p := enc.provenance
+	kvs := []KV{
+		{ProvenanceKey, []byte(provenanceMarker)},
+		{ProvenanceProducerKey, []byte(p.Producer)},
+		{ProvenanceVersionKey, []byte(p.Version)},
+		{ProvenanceSchemaKey, []byte(p.SchemaHash)},
+		{ProvenanceEOM, nil},
+	}
+	for _, kv := range kvs {
+		err := enc.encodeBytes(kv.Key, [][]byte{kv.Value})
+		if err != nil {
+			return err
+		}
+	}
+	if enc.mac != nil { // Provenance is not covered by WithHMAC
+		enc.mac.Reset()
+	}
+
+	return nil
+}
+
+// startStream emits the pending provenance message, if any, for a stream whose messages
+// are otherwise assembled by MessageBuilders constructed with messageOptions.
+func (enc *Encoder) startStream() error {
+	if enc.mu != nil {
+		enc.mu.Lock()
+		defer enc.mu.Unlock()
+	}
+	if !enc.pending {
+		return nil
+	}
+	err := enc.encodeProvenance()
+	if err == nil && enc.bw != nil {
+		err = enc.bw.Flush()
+	}
+
+	return err
+}
+
+// messageOptions returns "opts" with the stream-scoped Options removed so that they can
+// be applied to a MessageBuilder which assembles one message of a larger stream.
+func messageOptions(opts []Option) []Option {
+	return append(opts[:len(opts):len(opts)], func(o *options) { o.provenance = nil })
+}
+
+// ReadProvenance reads the provenance message written by an Encoder constructed with
+// WithProvenance. It is normally the first call made on a Decoder.
+//
+// ErrNoProvenance is returned if the next netstring is not the provenance marker, in
+// which case that netstring has been consumed. Use Provenance.Verify to check the schema
+// hash against the Schema the application expects.
+func (dec *Decoder) ReadProvenance() (*Provenance, error) {
+	mac := dec.mac
+	dec.mac = nil // Provenance is not covered by WithHMAC
+	defer func() { dec.mac = mac }()
+
+	k, v, err := dec.DecodeKeyed()
+	if err != nil {
+		return nil, err
+	}
+	if k != ProvenanceKey || string(v) != provenanceMarker {
+		return nil, ErrNoProvenance
+	}
+	p := &Provenance{}
+	_, err = dec.Unmarshal(ProvenanceEOM, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Verify checks that the schema hash of the Provenance matches the hash of "schema". The
+// returned error wraps ErrSchemaViolation.
+func (p *Provenance) Verify(schema *Schema) error {
+	h := schema.Hash()
+	if p.SchemaHash != h {
+		return fmt.Errorf("%w: provenance schema hash '%s' is not '%s'",
+			ErrSchemaViolation, p.SchemaHash, h)
+	}
+
+	return nil
+}
+
+// Hash returns a hex encoded SHA-256 digest of the Schema definition. The digest is of a
+// canonical form so it does not change with the order of fields in the schema file,
+// comments or white space; any change to the end-of-message key or to the key, name, type
+// or "required" attribute of any field does change it.
+func (s *Schema) Hash() string {
+	fields := append([]SchemaField(nil), s.Fields...)
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Key < fields[j].Key })
+
+	h := sha256.New()
+	fmt.Fprintf(h, "eom %s\n", s.EOM)
+	for _, sf := range fields {
+		fmt.Fprintf(h, "%s %s %s", sf.Key, sf.Name, sf.Type)
+		if sf.Required {
+			fmt.Fprint(h, " required")
+		}
+		fmt.Fprintln(h)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
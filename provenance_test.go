@@ -0,0 +1,78 @@
+package netstring_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestProvenance(t *testing.T) {
+	schema, err := netstring.ParseSchema(strings.NewReader("eom z\nu User string required\n"))
+	if err != nil {
+		t.Fatal("Setup error", err)
+	}
+	p := netstring.Provenance{Producer: "collector", Version: "1.2.3", SchemaHash: schema.Hash()}
+
+	var bb bytes.Buffer
+	enc := netstring.NewEncoder(&bb, netstring.WithProvenance(p))
+	if bb.Len() != 0 {
+		t.Error("Provenance should not be written until the first netstring", bb.String())
+	}
+	enc.EncodeString('u', "bob")
+	enc.EncodeBytes('z')
+	enc.EncodeString('u', "alice")
+	enc.EncodeBytes('z')
+
+	dec := netstring.NewDecoder(&bb)
+	got, err := dec.ReadProvenance()
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if *got != p {
+		t.Error("Provenance mismatch", got, p)
+	}
+	err = got.Verify(schema)
+	if err != nil {
+		t.Error("Verify should have succeeded", err)
+	}
+	for ix, user := range []string{"bob", "alice"} { // Provenance is only written once
+		m, err := dec.ReadMessage('z')
+		if err != nil {
+			t.Fatal(ix, "Unexpected error", err)
+		}
+		v, _ := m.Get('u')
+		if string(v) != user {
+			t.Error(ix, "Expected", user, "got", string(v))
+		}
+	}
+
+	other, _ := netstring.ParseSchema(strings.NewReader("eom z\nu User string\n"))
+	err = got.Verify(other)
+	if !errors.Is(err, netstring.ErrSchemaViolation) {
+		t.Error("Expected ErrSchemaViolation from Verify, not", err)
+	}
+
+	dec = netstring.NewDecoder(bytes.NewBufferString("4:ubob,1:z,"))
+	_, err = dec.ReadProvenance()
+	if err != netstring.ErrNoProvenance {
+		t.Error("Expected ErrNoProvenance, not", err)
+	}
+}
+
+func TestSchemaHash(t *testing.T) {
+	a, _ := netstring.ParseSchema(strings.NewReader("eom z\na A int\nb B string\n"))
+	b, _ := netstring.ParseSchema(strings.NewReader("# Reordered\neom z\nb  B  string\na A int\n"))
+	c, _ := netstring.ParseSchema(strings.NewReader("eom z\na A int required\nb B string\n"))
+	if a.Hash() != b.Hash() {
+		t.Error("Field order and white space should not change the hash")
+	}
+	if a.Hash() == c.Hash() {
+		t.Error("Attribute change should change the hash")
+	}
+	if len(a.Hash()) != 64 {
+		t.Error("Expected a hex SHA-256 digest, got", a.Hash())
+	}
+}
@@ -0,0 +1,235 @@
+package netstring
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+/*
+Registry maps message-type values to "basic-struct" types so that a stream containing
+different kinds of messages can be decoded without the caller knowing in advance which
+struct to Unmarshal into. A Registry *must* be constructed with [NewRegistry].
+
+This formalizes the practice recommended in Marshal of starting every message with a
+message type netstring. [Encoder.MarshalRegistered] emits a "keyed" netstring with the
+Registry's type-discriminator key followed by the message, and
+[Decoder.UnmarshalRegistered] reads the discriminator, constructs a new value of the
+registered concrete type and Unmarshals into it. An example:
+
+	type login struct {
+	  User string `netstring:"u"`
+	}
+	type logout struct {
+	  Reason string `netstring:"r"`
+	}
+
+	reg := netstring.NewRegistry('M')
+	reg.Register("login", login{})
+	reg.Register("logout", logout{})
+	...
+	msg, _, err := dec.UnmarshalRegistered(reg, 'z')
+	switch m := msg.(type) {
+	case *login:
+	case *logout:
+	}
+
+An Encoder and Decoder constructed WithRegistry also use the Registry for struct fields
+of interface type, which allows polymorphic payloads, such as different kinds of event,
+within one message type:
+
+	type envelope struct {
+	  Source string `netstring:"s"`
+	  Event  any    `netstring:"e"`
+	}
+
+	enc := netstring.NewEncoder(w, netstring.WithRegistry(reg))
+	enc.Marshal('z', envelope{"web", &login{"bob"}}) // 4:sweb,21:e6:Mlogin,4:ubob,1:z,,1:z,
+
+The value of such a field is a registered message, complete with its message-type
+netstring and end-of-message sentinel, nested within the field's netstring as per
+Encoder.Nested. Unmarshal constructs the registered concrete type and sets the field to
+a pointer to it, so the pointer must implement the field's interface. A nil interface is
+omitted. Without WithRegistry, interface fields are rejected as unsupported.
+
+A Registry is safe for concurrent use.
+*/
+type Registry struct {
+	typeKey  Key
+	foldKeys bool // Reject types with tags which conflict when folded
+	mu       sync.RWMutex
+	types    map[string]reflect.Type
+	names    map[reflect.Type]string
+}
+
+// NewRegistry constructs an empty Registry which uses "typeKey" as the key of the
+// message-type netstring. The only relevant Option is WithKeyFolding.
+func NewRegistry(typeKey Key, opts ...Option) *Registry {
+	return &Registry{typeKey: typeKey, foldKeys: applyOptions(opts).foldKeys,
+		types: make(map[string]reflect.Type),
+		names: make(map[reflect.Type]string)}
+}
+
+// Register associates the message-type value "name" with the type of "prototype" which
+// must be a struct or a pointer to a struct. Each name and each type can only be
+// registered once.
+func (reg *Registry) Register(name string, prototype any) error {
+	rt := reflect.TypeOf(prototype)
+	if rt != nil && rt.Kind() == reflect.Pointer {
+		rt = rt.Elem()
+	}
+	if rt == nil || rt.Kind() != reflect.Struct {
+		return ErrBadMarshalValue
+	}
+	if reg.foldKeys {
+		err := checkFoldConflicts(rt)
+		if err != nil {
+			return err
+		}
+	}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if _, ok := reg.types[name]; ok {
+		return fmt.Errorf(errorPrefix+"Message type '%s' is already registered", name)
+	}
+	if n, ok := reg.names[rt]; ok {
+		return fmt.Errorf(errorPrefix+"%s is already registered as '%s'", rt, n)
+	}
+	reg.types[name] = rt
+	reg.names[rt] = name
+
+	return nil
+}
+
+// WithRegistry causes Encoder.Marshal and Decoder.Unmarshal to encode and decode struct
+// fields of interface type as registered messages of "reg". See Registry.
+func WithRegistry(reg *Registry) Option {
+	return func(o *options) {
+		o.registry = reg
+	}
+}
+
+// checkFoldConflicts returns an error if "rt" has tags which differ only by case.
+// Malformed tags are left for Marshal and Unmarshal to report.
+func checkFoldConflicts(rt reflect.Type) error {
+	folded := make(map[Key]string)
+	for ix := 0; ix < rt.NumField(); ix++ {
+		sf := rt.Field(ix)
+		tag, _ := splitTag(sf.Tag.Get("netstring"))
+		if !sf.IsExported() || len(tag) != 1 {
+			continue
+		}
+		key := Key(tag[0]).fold()
+		if n, ok := folded[key]; ok {
+			return fmt.Errorf(errorPrefix+"Tag '%s' of '%s' conflicts with '%s' when keys are folded",
+				tag, sf.Name, n)
+		}
+		folded[key] = sf.Name
+	}
+
+	return nil
+}
+
+// lookupName returns the registered name of "rt".
+func (reg *Registry) lookupName(rt reflect.Type) (string, bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	n, ok := reg.names[rt]
+
+	return n, ok
+}
+
+// lookupType returns the registered type of "name".
+func (reg *Registry) lookupType(name string) (reflect.Type, bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	rt, ok := reg.types[name]
+
+	return rt, ok
+}
+
+// MarshalRegistered encodes a message-type netstring for the registered type of
+// "message" followed by the message itself as per Marshal. The type of "message" must
+// have been registered with "reg" otherwise ErrUnknownMessageType is returned.
+func (enc *Encoder) MarshalRegistered(reg *Registry, eom Key, message any) (err error) {
+	defer enc.stick(&err)
+	if err = enc.Err(); err != nil {
+		return err
+	}
+	rt := reflect.TypeOf(message)
+	if rt != nil && rt.Kind() == reflect.Pointer {
+		rt = rt.Elem()
+	}
+	name, ok := reg.lookupName(rt)
+	if !ok {
+		return fmt.Errorf("%w: %v", ErrUnknownMessageType, rt)
+	}
+	err = enc.EncodeString(reg.typeKey, name)
+	if err != nil {
+		return err
+	}
+
+	return enc.Marshal(eom, message)
+}
+
+// UnmarshalRegistered reads a message-type netstring, constructs a new value of the
+// corresponding registered type and populates it with Unmarshal. The returned "message"
+// is always a pointer to the registered struct type.
+//
+// ErrNoMessageType is returned if the first netstring does not have the Registry's type
+// key and ErrUnknownMessageType is returned if its value has not been registered. In both
+// cases the rest of the message remains unread.
+func (dec *Decoder) UnmarshalRegistered(reg *Registry, eom Key) (message any, unknown Key, err error) {
+	k, v, err := dec.DecodeKeyed()
+	if err != nil {
+		return
+	}
+	if k != reg.typeKey && !(dec.foldKeys && k.fold() == reg.typeKey.fold()) {
+		err = ErrNoMessageType
+		return
+	}
+	rt, ok := reg.lookupType(string(v))
+	if !ok {
+		err = fmt.Errorf("%w: '%s'", ErrUnknownMessageType, string(v))
+		return
+	}
+
+	message = reflect.New(rt).Interface()
+	unknown, err = dec.Unmarshal(eom, message)
+
+	return
+}
+
+// encodeRegistered encodes "message" with MarshalRegistered as the nested value of a
+// "keyed" netstring. The nested message is terminated by the outer "eom".
+func (enc *Encoder) encodeRegistered(key, eom Key, message any) error {
+	child := enc.Nested(key)
+	err := child.MarshalRegistered(enc.registry, eom, message)
+	if err != nil {
+		return err
+	}
+
+	return child.Close()
+}
+
+// decodeRegistered is the inverse of encodeRegistered. It populates the interface value
+// "fv" with the registered message nested in "val".
+func (dec *Decoder) decodeRegistered(eom Key, val []byte, fv reflect.Value) error {
+	sub := NewDecoder(bytes.NewReader(val), WithMaximumLength(dec.maxLength),
+		WithRegistry(dec.registry))
+	sub.foldKeys, sub.interner = dec.foldKeys, dec.interner
+	message, _, err := sub.UnmarshalRegistered(dec.registry, eom)
+	if err != nil {
+		return err
+	}
+
+	mv := reflect.ValueOf(message) // Always a pointer to the registered struct
+	if !mv.Type().AssignableTo(fv.Type()) {
+		return fmt.Errorf("%w: %s does not implement %s", ErrUnknownMessageType, mv.Type(), fv.Type())
+	}
+	fv.Set(mv)
+
+	return nil
+}
@@ -0,0 +1,183 @@
+package netstring_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+type regLogin struct {
+	User string `netstring:"u"`
+}
+
+type regLogout struct {
+	Reason string `netstring:"r"`
+	Code   int    `netstring:"c"`
+}
+
+func TestRegistry(t *testing.T) {
+	reg := netstring.NewRegistry('M')
+	if err := reg.Register("login", regLogin{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.Register("logout", &regLogout{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.Register("login", regLogout{}); err == nil {
+		t.Error("Expected duplicate name error")
+	}
+	if err := reg.Register("other", &regLogin{}); err == nil {
+		t.Error("Expected duplicate type error")
+	}
+	if err := reg.Register("int", 3); err != netstring.ErrBadMarshalValue {
+		t.Error("Expected ErrBadMarshalValue, not", err)
+	}
+
+	var bbuf bytes.Buffer
+	enc := netstring.NewEncoder(&bbuf)
+	enc.MarshalRegistered(reg, 'z', &regLogin{"bob"})
+	enc.MarshalRegistered(reg, 'z', regLogout{"bored", 3})
+	err := enc.MarshalRegistered(reg, 'z', struct{}{})
+	if !errors.Is(err, netstring.ErrUnknownMessageType) {
+		t.Error("Expected ErrUnknownMessageType, not", err)
+	}
+
+	exp := "6:Mlogin,4:ubob,1:z,7:Mlogout,6:rbored,2:c3,1:z,"
+	if bbuf.String() != exp {
+		t.Fatal("Wire format wrong", bbuf.String())
+	}
+
+	dec := netstring.NewDecoder(&bbuf)
+	msg, _, err := dec.UnmarshalRegistered(reg, 'z')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if li, ok := msg.(*regLogin); !ok || li.User != "bob" {
+		t.Error("First message wrong", msg)
+	}
+	msg, _, err = dec.UnmarshalRegistered(reg, 'z')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lo, ok := msg.(*regLogout); !ok || lo.Reason != "bored" || lo.Code != 3 {
+		t.Error("Second message wrong", msg)
+	}
+
+	dec = newWith("6:Mother,1:z,")
+	_, _, err = dec.UnmarshalRegistered(reg, 'z')
+	if !errors.Is(err, netstring.ErrUnknownMessageType) {
+		t.Error("Expected ErrUnknownMessageType, not", err)
+	}
+
+	dec = newWith("4:ubob,1:z,")
+	_, _, err = dec.UnmarshalRegistered(reg, 'z')
+	if err != netstring.ErrNoMessageType {
+		t.Error("Expected ErrNoMessageType, not", err)
+	}
+}
+
+func TestRegistryKeyFolding(t *testing.T) {
+	type clash struct {
+		Country     string `netstring:"c"`
+		CountryCode string `netstring:"C"`
+	}
+
+	if err := netstring.NewRegistry('M').Register("clash", clash{}); err != nil {
+		t.Error("Unfolded Registry should accept", err)
+	}
+	reg := netstring.NewRegistry('M', netstring.WithKeyFolding())
+	if err := reg.Register("clash", clash{}); err == nil {
+		t.Error("Folded Registry should reject conflicting tags")
+	}
+	if err := reg.Register("logout", regLogout{}); err != nil {
+		t.Fatal(err)
+	}
+
+	bbuf := bytes.NewBufferString("7:mlogout,4:Rbye,2:C7,1:Z,")
+	dec := netstring.NewDecoder(bbuf, netstring.WithKeyFolding())
+	msg, unknown, err := dec.UnmarshalRegistered(reg, 'z')
+	if err != nil || unknown != netstring.NoKey {
+		t.Fatal(err, unknown)
+	}
+	lo, ok := msg.(*regLogout)
+	if !ok || lo.Reason != "bye" || lo.Code != 7 {
+		t.Error("Folded message wrong", msg)
+	}
+}
+
+type regEvent interface{ Kind() string }
+
+func (l *regLogin) Kind() string { return "login" }
+func (l regLogout) Kind() string { return "logout" }
+
+type regEnvelope struct {
+	Source string   `netstring:"s"`
+	Event  regEvent `netstring:"e"`
+	Any    any      `netstring:"a"`
+}
+
+func TestRegistryInterfaceFields(t *testing.T) {
+	reg := netstring.NewRegistry('M')
+	reg.Register("login", regLogin{})
+	reg.Register("logout", regLogout{})
+	opts := []netstring.Option{netstring.WithRegistry(reg)}
+
+	var bb bytes.Buffer
+	enc := netstring.NewEncoder(&bb, opts...)
+	err := enc.Marshal('z', regEnvelope{Source: "web", Event: &regLogin{"bob"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := "4:sweb,21:e6:Mlogin,4:ubob,1:z,,1:z,"
+	if bb.String() != exp {
+		t.Fatalf("Expected %q, got %q", exp, bb.String())
+	}
+	err = enc.Marshal('z', regEnvelope{Event: regLogout{"bye", 3}, Any: &regLogin{"eve"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dec := netstring.NewDecoder(&bb, opts...)
+	var env regEnvelope
+	if _, err := dec.Unmarshal('z', &env); err != nil {
+		t.Fatal(err)
+	}
+	if l, ok := env.Event.(*regLogin); !ok || l.User != "bob" || env.Source != "web" || env.Any != nil {
+		t.Error("First envelope wrong", env)
+	}
+	env = regEnvelope{}
+	if _, err := dec.Unmarshal('z', &env); err != nil {
+		t.Fatal(err)
+	}
+	if l, ok := env.Event.(*regLogout); !ok || l.Reason != "bye" || l.Code != 3 { // Pointer preferred
+		t.Error("Second envelope Event wrong", env.Event)
+	}
+	if l, ok := env.Any.(*regLogin); !ok || l.User != "eve" {
+		t.Error("Second envelope Any wrong", env.Any)
+	}
+
+	// Errors: no registry, unregistered type and a type which does not implement
+	if err := netstring.NewEncoder(&bb).Marshal('z', regEnvelope{}); err == nil {
+		t.Error("Expected Marshal error without WithRegistry")
+	}
+	if _, err := netstring.NewDecoder(&bb).Unmarshal('z', &env); err == nil {
+		t.Error("Expected Unmarshal error without WithRegistry")
+	}
+	err = enc.Marshal('z', regEnvelope{Any: 42})
+	if !errors.Is(err, netstring.ErrUnknownMessageType) {
+		t.Error("Expected ErrUnknownMessageType, got", err)
+	}
+	bb.Reset()
+	bb.WriteString("10:e2:Mp,1:z,,1:z,")
+	reg.Register("p", regPlain{})
+	_, err = netstring.NewDecoder(&bb, opts...).Unmarshal('z', &env)
+	if !errors.Is(err, netstring.ErrUnknownMessageType) {
+		t.Error("Expected ErrUnknownMessageType for non-implementing type, got", err)
+	}
+}
+
+type regPlain struct {
+	N string `netstring:"n"`
+}
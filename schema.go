@@ -0,0 +1,232 @@
+package netstring
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+Schema describes the "keyed" netstrings expected in a message so that messages can be
+validated without a "basic-struct" and so that tools can share a single definition of a
+message. A Schema is normally loaded from a text file with [LoadSchema] or
+[ParseSchema]. The file format is line oriented with '#' introducing a comment:
+
+	# Login request
+	eom z
+	t Type   string required
+	u User   string required
+	a Age    int
+	p Photo  bytes
+
+The "eom" line defines the end-of-message sentinel. All other lines define a field with
+the key, a name, a type and the optional "required" attribute. Valid types are:
+
+	bytes   Any value
+	string  Valid UTF-8
+	int     As produced by Encoder.EncodeInt*()
+	uint    As produced by Encoder.EncodeUint*()
+	float   As produced by Encoder.EncodeFloat*()
+	bool    As produced by Encoder.EncodeBool() in any BoolFormat
+
+A pointer to Schema implements [flag.Value] so that command line tools can accept a
+schema file with, e.g.:
+
+	var schema netstring.Schema
+	flag.Var(&schema, "schema", "Message schema file")
+*/
+type Schema struct {
+	EOM    Key
+	Fields []SchemaField
+
+	path string // Set by LoadSchema
+}
+
+// SchemaField defines a single "keyed" netstring of a Schema.
+type SchemaField struct {
+	Key      Key
+	Name     string
+	Type     string
+	Required bool
+}
+
+// schemaTypes maps valid SchemaField.Type values to their value checking function.
+var schemaTypes = map[string]func([]byte) bool{
+	"bytes":  func([]byte) bool { return true },
+	"string": utf8.Valid,
+	"int": func(v []byte) bool {
+		_, err := strconv.ParseInt(string(v), 10, 64)
+		return err == nil
+	},
+	"uint": func(v []byte) bool {
+		_, err := strconv.ParseUint(string(v), 10, 64)
+		return err == nil
+	},
+	"float": func(v []byte) bool {
+		_, err := strconv.ParseFloat(string(v), 64)
+		return err == nil
+	},
+	"bool": func(v []byte) bool {
+		for _, tf := range boolValues {
+			if bytes.Equal(v, tf[0]) || bytes.Equal(v, tf[1]) {
+				return true
+			}
+		}
+		return false
+	},
+}
+
+// LoadSchema reads and parses the schema file "path".
+func LoadSchema(path string) (*Schema, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s, err := ParseSchema(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	s.path = path
+
+	return s, nil
+}
+
+// ParseSchema parses a schema definition in the format described by Schema.
+func ParseSchema(rdr io.Reader) (*Schema, error) {
+	s := &Schema{}
+	seen := make(map[Key]bool)
+	scanner := bufio.NewScanner(rdr)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if ix := strings.IndexByte(line, '#'); ix >= 0 {
+			line = line[:ix]
+		}
+		words := strings.Fields(line)
+		if len(words) == 0 {
+			continue
+		}
+
+		if words[0] == "eom" {
+			if len(words) != 2 || s.EOM != NoKey {
+				return nil, fmt.Errorf(errorPrefix+"Schema line %d: Expect a single 'eom <key>'",
+					lineNo)
+			}
+			var k Key
+			err := k.UnmarshalText([]byte(words[1]))
+			if err != nil || k == NoKey {
+				return nil, fmt.Errorf(errorPrefix+"Schema line %d: Invalid eom key '%s'",
+					lineNo, words[1])
+			}
+			s.EOM = k
+			continue
+		}
+
+		if len(words) < 3 || len(words) > 4 {
+			return nil, fmt.Errorf(errorPrefix+"Schema line %d: Expect 'key name type [required]'",
+				lineNo)
+		}
+		var sf SchemaField
+		err := sf.Key.UnmarshalText([]byte(words[0]))
+		if err != nil || sf.Key == NoKey {
+			return nil, fmt.Errorf(errorPrefix+"Schema line %d: Invalid key '%s'",
+				lineNo, words[0])
+		}
+		if seen[sf.Key] {
+			return nil, fmt.Errorf(errorPrefix+"Schema line %d: Duplicate key '%s'",
+				lineNo, sf.Key)
+		}
+		seen[sf.Key] = true
+		sf.Name = words[1]
+		sf.Type = words[2]
+		if _, ok := schemaTypes[sf.Type]; !ok {
+			return nil, fmt.Errorf(errorPrefix+"Schema line %d: Unknown type '%s'",
+				lineNo, sf.Type)
+		}
+		if len(words) == 4 {
+			if words[3] != "required" {
+				return nil, fmt.Errorf(errorPrefix+"Schema line %d: Unknown attribute '%s'",
+					lineNo, words[3])
+			}
+			sf.Required = true
+		}
+		s.Fields = append(s.Fields, sf)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if s.EOM == NoKey {
+		return nil, fmt.Errorf(errorPrefix + "Schema has no 'eom' line")
+	}
+	if seen[s.EOM] {
+		return nil, fmt.Errorf(errorPrefix+"Schema eom key '%s' is also a field key", s.EOM)
+	}
+
+	return s, nil
+}
+
+// Field returns the SchemaField with a key of "key". The returned bool is false if there
+// is no such field.
+func (s *Schema) Field(key Key) (SchemaField, bool) {
+	for _, sf := range s.Fields {
+		if sf.Key == key {
+			return sf, true
+		}
+	}
+
+	return SchemaField{}, false
+}
+
+// Validate checks that "m" conforms to the Schema. All required fields must be present
+// and all values of fields defined by the Schema must be valid for their type. Keys not
+// defined by the Schema are ignored in the same way that Unmarshal ignores them. The
+// returned error wraps ErrSchemaViolation.
+func (s *Schema) Validate(m *Message) error {
+	if m.EOM != s.EOM {
+		return fmt.Errorf("%w: eom is '%s' not '%s'", ErrSchemaViolation, m.EOM, s.EOM)
+	}
+	for _, sf := range s.Fields {
+		v, ok := m.Get(sf.Key)
+		if !ok {
+			if sf.Required {
+				return fmt.Errorf("%w: required %s ('%s') is missing",
+					ErrSchemaViolation, sf.Name, sf.Key)
+			}
+			continue
+		}
+		if !schemaTypes[sf.Type](v) {
+			return fmt.Errorf("%w: %s ('%s') value '%s' is not a valid %s",
+				ErrSchemaViolation, sf.Name, sf.Key, v, sf.Type)
+		}
+	}
+
+	return nil
+}
+
+// String returns the path of the schema file loaded with LoadSchema or Set. It is part of
+// the flag.Value interface.
+func (s *Schema) String() string {
+	if s == nil {
+		return ""
+	}
+
+	return s.path
+}
+
+// Set loads the schema file "path" into the Schema. It is part of the flag.Value
+// interface.
+func (s *Schema) Set(path string) error {
+	ns, err := LoadSchema(path)
+	if err != nil {
+		return err
+	}
+	*s = *ns
+
+	return nil
+}
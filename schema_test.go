@@ -0,0 +1,138 @@
+package netstring_test
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+const loginSchema = `# Login request
+eom z
+t Type   string required
+u User   string required  # Trailing comment
+a Age    int
+h Height float
+v Valid  bool
+p Photo  bytes
+`
+
+func TestParseSchema(t *testing.T) {
+	s, err := netstring.ParseSchema(strings.NewReader(loginSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.EOM != 'z' || len(s.Fields) != 6 {
+		t.Fatal("Schema wrong", s)
+	}
+	sf, ok := s.Field('u')
+	if !ok || sf.Name != "User" || sf.Type != "string" || !sf.Required {
+		t.Error("Field 'u' wrong", sf, ok)
+	}
+	_, ok = s.Field('x')
+	if ok {
+		t.Error("Field 'x' should not exist")
+	}
+
+	testCases := []struct {
+		text   string
+		expect string
+	}{
+		{"a Age int", "no 'eom'"},
+		{"eom z\neom y", "line 2: Expect a single"},
+		{"eom 1", "line 1: Invalid eom"},
+		{"eom z\na Age", "line 2: Expect 'key"},
+		{"eom z\nab Age int", "line 2: Invalid key"},
+		{"eom z\na Age int\na Again int", "line 3: Duplicate key"},
+		{"eom z\na Age integer", "line 2: Unknown type"},
+		{"eom z\na Age int optional", "line 2: Unknown attribute"},
+		{"eom z\nz Age int", "also a field key"},
+	}
+	for ix, tc := range testCases {
+		_, err := netstring.ParseSchema(strings.NewReader(tc.text))
+		if err == nil || !strings.Contains(err.Error(), tc.expect) {
+			t.Error(ix, "Expected", tc.expect, "got", err)
+		}
+	}
+}
+
+func TestSchemaValidate(t *testing.T) {
+	s, err := netstring.ParseSchema(strings.NewReader(loginSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kv := func(k netstring.Key, v string) netstring.KV {
+		return netstring.KV{Key: k, Value: []byte(v)}
+	}
+
+	testCases := []struct {
+		m      netstring.Message
+		expect string // Empty means valid
+	}{
+		{netstring.Message{EOM: 'z', Fields: []netstring.KV{kv('t', "login"), kv('u', "bob")}}, ""},
+		{netstring.Message{EOM: 'z', Fields: []netstring.KV{kv('t', "l"), kv('u', "b"),
+			kv('a', "-3"), kv('h', "1.5e3"), kv('v', "T"), kv('p', "\xff"), kv('x', "?")}}, ""},
+		{netstring.Message{EOM: 'y', Fields: []netstring.KV{kv('t', "login"), kv('u', "bob")}}, "eom"},
+		{netstring.Message{EOM: 'z', Fields: []netstring.KV{kv('t', "login")}}, "User ('u') is missing"},
+		{netstring.Message{EOM: 'z', Fields: []netstring.KV{kv('t', "l"), kv('u', "\xff")}}, "not a valid string"},
+		{netstring.Message{EOM: 'z', Fields: []netstring.KV{kv('t', "l"), kv('u', "b"), kv('a', "x")}}, "not a valid int"},
+		{netstring.Message{EOM: 'z', Fields: []netstring.KV{kv('t', "l"), kv('u', "b"), kv('h', "x")}}, "not a valid float"},
+		{netstring.Message{EOM: 'z', Fields: []netstring.KV{kv('t', "l"), kv('u', "b"), kv('v', "t")}}, "not a valid bool"},
+	}
+	for ix, tc := range testCases {
+		err := s.Validate(&tc.m)
+		if len(tc.expect) == 0 {
+			if err != nil {
+				t.Error(ix, "Unexpected error", err)
+			}
+			continue
+		}
+		if !errors.Is(err, netstring.ErrSchemaViolation) || !strings.Contains(err.Error(), tc.expect) {
+			t.Error(ix, "Expected", tc.expect, "got", err)
+		}
+	}
+}
+
+func TestSchemaFlags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "login.schema")
+	err := os.WriteFile(path, []byte(loginSchema), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(&strings.Builder{})
+	eom := netstring.Key('a')
+	var schema netstring.Schema
+	fs.Var(&eom, "eom", "End-of-message key")
+	fs.Var(&schema, "schema", "Schema file")
+	err = fs.Parse([]string{"-eom", "z", "-schema", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eom != 'z' {
+		t.Error("eom flag not set", eom)
+	}
+	if schema.EOM != 'z' || len(schema.Fields) != 6 || schema.String() != path {
+		t.Error("schema flag not loaded", schema)
+	}
+
+	for ix, args := range [][]string{
+		{"-eom", "zz"},
+		{"-eom", "1"},
+		{"-schema", path + ".missing"},
+	} {
+		if fs.Parse(args) == nil {
+			t.Error(ix, "Expected error from", args)
+		}
+	}
+
+	var nilSchema *netstring.Schema
+	if nilSchema.String() != "" {
+		t.Error("nil Schema String should be empty")
+	}
+}
@@ -0,0 +1,370 @@
+package netstring
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sync"
+)
+
+// DefaultEOM is the end-of-message sentinel used by Server when Server.EOM is NoKey.
+const DefaultEOM Key = 'z'
+
+// Request is a single message received by a Server.
+type Request struct {
+	Message             // The decoded message, excluding the end-of-message sentinel
+	RemoteAddr net.Addr // Address of the peer. nil if the Transport has no RemoteAddr
+	ctx        context.Context
+}
+
+// Context returns the context of the Request. It is cancelled when the peer disconnects
+// or the Server is closed, in which case the Handler should abandon the request as no
+// reply can be delivered. Context never returns nil.
+func (r *Request) Context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+
+	return r.ctx
+}
+
+// WithContext returns a shallow copy of the Request with its context changed to "ctx".
+// It is intended for middleware which needs to add values or deadlines to the context.
+func (r *Request) WithContext(ctx context.Context) *Request {
+	r2 := *r
+	r2.ctx = ctx
+
+	return &r2
+}
+
+// Handler responds to a Request. The reply, if any, is assembled in "reply" which is
+// written to the peer as a single Write once ServeNetstring returns. Leaving "reply"
+// empty sends nothing. The Request and its values must not be retained after
+// ServeNetstring returns.
+type Handler interface {
+	ServeNetstring(reply *MessageBuilder, req *Request)
+}
+
+// HandlerFunc is an adapter which allows an ordinary function to be used as a Handler.
+type HandlerFunc func(reply *MessageBuilder, req *Request)
+
+// ServeNetstring calls f(reply, req).
+func (f HandlerFunc) ServeNetstring(reply *MessageBuilder, req *Request) {
+	f(reply, req)
+}
+
+/*
+Server accepts connections and calls Handler for each message received. It wraps the
+network boilerplate common to netstring services: the accept loop, a goroutine per
+connection, message framing and recovery from handler panics.
+
+Messages are "keyed" netstrings terminated by EOM, as read by Decoder.ReadMessage. By
+default the messages on each connection are handled one at a time in the order received.
+Setting MaxInFlight allows that many messages per connection to be handled concurrently,
+in which case replies may be returned in a different order to the requests so clients
+should correlate them, typically with a request identifier.
+
+Each reply is assembled by its own MessageBuilder constructed with Options, excepting
+that a WithProvenance message is only written once per connection, ahead of the first
+reply.
+
+If more than MaxConns connections are active, a new connection is sent a standard
+error-reply of ErrServerBusy, as per Encoder.EncodeError, and closed.
+
+The read loop of each connection continues while handlers run so that a peer disconnect
+is detected promptly and the Request context is cancelled. A clean EOF between messages
+cannot be distinguished from a peer which half-closes its side of the connection after
+sending its requests, so it is not treated as a disconnect: pending handlers run to
+completion and their replies are written before the connection is closed. Any other read
+error cancels the Request context. Up to MaxInFlight pipelined messages are read ahead of
+the handlers.
+
+If a handler panics, the panic is logged, any partial reply is discarded and a standard
+error-reply of ErrHandlerPanic is sent in its place. The connection and the Server
+continue. The request identifier of the error-reply is taken from the ErrorRequestIDKey
+netstring of the request, if present.
+
+The zero value of Server is not usable; at least Handler must be set.
+*/
+type Server struct {
+	Handler  Handler
+	EOM      Key         // End-of-message sentinel. NoKey means DefaultEOM
+	Options  []Option    // Applied to the Encoder and Decoder of every connection
+	ErrorLog *log.Logger // nil means the standard logger
+
+	MaxConns    int // Maximum concurrent connections. Zero means unlimited
+	MaxInFlight int // Maximum concurrent messages per connection. Zero means one
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[Transport]struct{}
+	closed    bool
+}
+
+// ListenAndServeNetstring listens on the network address and serves connections with
+// "handler" using a Server with default settings. It always returns a non-nil error.
+func ListenAndServeNetstring(network, addr string, handler Handler) error {
+	srv := &Server{Handler: handler}
+
+	return srv.ListenAndServe(network, addr)
+}
+
+// DialNetstring connects to the network address and returns a Conn with "opts" applied.
+func DialNetstring(network, addr string, opts ...Option) (*Conn, error) {
+	nc, err := net.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewConn(nc, opts...), nil
+}
+
+// ListenAndServe listens on the network address and calls Serve. It always returns a
+// non-nil error.
+func (srv *Server) ListenAndServe(network, addr string) error {
+	ln, err := net.Listen(network, addr)
+	if err != nil {
+		return err
+	}
+
+	return srv.Serve(ln)
+}
+
+// Serve accepts connections on "ln" and serves each in a new goroutine. Serve always
+// closes "ln" and returns a non-nil error. After Close, the returned error is
+// ErrServerClosed.
+func (srv *Server) Serve(ln net.Listener) error {
+	if !srv.track(ln) {
+		ln.Close()
+		return ErrServerClosed
+	}
+	defer srv.untrack(ln, nil)
+	defer ln.Close()
+
+	for {
+		nc, err := ln.Accept()
+		if err != nil {
+			if srv.isClosed() {
+				return ErrServerClosed
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			return err
+		}
+		ok, busy := srv.trackConn(nc)
+		if !ok {
+			nc.Close()
+			return ErrServerClosed
+		}
+		if busy {
+			go srv.rejectConn(nc)
+			continue
+		}
+		go srv.serveConn(nc)
+	}
+}
+
+// ServeTransport serves the single connection "t" in the calling goroutine until "t"
+// fails or is closed. It is intended for connections which do not originate from a
+// net.Listener, such as a serial port. As with connections accepted by Serve, "t" is
+// subject to MaxConns and is always closed. After Close, the returned error is
+// ErrServerClosed; otherwise it is nil unless the connection was rejected with
+// ErrServerBusy.
+func (srv *Server) ServeTransport(t Transport) error {
+	ok, busy := srv.trackConn(t)
+	if !ok {
+		t.Close()
+		return ErrServerClosed
+	}
+	if busy {
+		srv.rejectConn(t)
+		return ErrServerBusy
+	}
+	srv.serveConn(t)
+	if srv.isClosed() {
+		return ErrServerClosed
+	}
+
+	return nil
+}
+
+// Close immediately closes all listeners and connections. Handlers in progress are not
+// interrupted, but their replies cannot be delivered.
+func (srv *Server) Close() error {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.closed = true
+	for ln := range srv.listeners {
+		ln.Close()
+	}
+	for nc := range srv.conns {
+		nc.Close()
+	}
+
+	return nil
+}
+
+func (srv *Server) isClosed() bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	return srv.closed
+}
+
+// track records an active listener. It returns false if the Server is closed.
+func (srv *Server) track(ln net.Listener) bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.closed {
+		return false
+	}
+	srv.init()
+	srv.listeners[ln] = struct{}{}
+
+	return true
+}
+
+// trackConn records an active connection. It returns false if the Server is closed. A
+// connection which would exceed MaxConns is not recorded and is returned as "busy".
+func (srv *Server) trackConn(nc Transport) (ok, busy bool) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.closed {
+		return false, false
+	}
+	srv.init()
+	if srv.MaxConns > 0 && len(srv.conns) >= srv.MaxConns {
+		return true, true
+	}
+	srv.conns[nc] = struct{}{}
+
+	return true, false
+}
+
+// init creates the tracking maps. The caller must hold srv.mu.
+func (srv *Server) init() {
+	if srv.listeners == nil {
+		srv.listeners = make(map[net.Listener]struct{})
+		srv.conns = make(map[Transport]struct{})
+	}
+}
+
+func (srv *Server) untrack(ln net.Listener, nc Transport) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	delete(srv.listeners, ln)
+	delete(srv.conns, nc)
+}
+
+func (srv *Server) logf(format string, args ...any) {
+	if srv.ErrorLog != nil {
+		srv.ErrorLog.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+func (srv *Server) eom() Key {
+	if srv.EOM == NoKey {
+		return DefaultEOM
+	}
+
+	return srv.EOM
+}
+
+// rejectConn sends an ErrServerBusy error-reply and closes the connection.
+func (srv *Server) rejectConn(nc Transport) {
+	defer nc.Close()
+	busy := NewEncoder(nc, srv.Options...)
+	busy.EncodeError(srv.eom(), "", ErrServerBusy)
+	busy.Flush()
+}
+
+// serveConn reads and handles messages until the connection fails or is closed. Reading
+// is decoupled from dispatching via "queue" so that a disconnect is detected, and the
+// context cancelled, while handlers are still running.
+func (srv *Server) serveConn(nc Transport) {
+	defer srv.untrack(nil, nc)
+	defer nc.Close()
+
+	maxInFlight := srv.MaxInFlight
+	if maxInFlight < 1 {
+		maxInFlight = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	queue := make(chan *Request, maxInFlight)
+	dispatched := make(chan struct{})
+	go srv.dispatch(ctx, nc, queue, maxInFlight, dispatched)
+
+	dec := NewDecoder(nc, srv.Options...)
+	eom := srv.eom()
+	for {
+		m, err := dec.ReadMessage(eom)
+		if err != nil {
+			if err != io.EOF { // Pending replies are still written after a half-close
+				cancel()
+			}
+			close(queue)
+			<-dispatched // Wait for all handlers to complete
+			return
+		}
+		queue <- &Request{Message: m, RemoteAddr: remoteAddr(nc), ctx: ctx}
+	}
+}
+
+// dispatch runs a handler goroutine for each queued Request with at most "maxInFlight"
+// running at a time. It closes "done" once "queue" is closed and all handlers have
+// completed.
+func (srv *Server) dispatch(ctx context.Context, nc Transport, queue chan *Request,
+	maxInFlight int, done chan struct{}) {
+	defer close(done)
+	slots := make(chan struct{}, maxInFlight)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	var writeMu sync.Mutex // Serializes replies from concurrent handlers
+	stream := NewEncoder(nc, srv.Options...)
+	msgOpts := messageOptions(srv.Options) // Provenance is emitted once, by stream
+
+	eom := srv.eom()
+	for req := range queue {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(req *Request) { // Slot is held until the reply is written, in completion order
+			defer wg.Done()
+			defer func() { <-slots }()
+			reply := NewMessageBuilder(msgOpts...)
+			srv.handle(reply, req, eom)
+			writeMu.Lock()
+			var err error
+			if reply.Len() > 0 {
+				err = stream.startStream()
+			}
+			if err == nil {
+				_, err = reply.WriteToContext(ctx, nc)
+			}
+			writeMu.Unlock()
+			if err != nil {
+				nc.Close() // Causes ReadMessage to fail
+			}
+		}(req)
+	}
+}
+
+// handle calls the Handler and recovers from any panic by replacing the reply with an
+// ErrHandlerPanic error-reply.
+func (srv *Server) handle(reply *MessageBuilder, req *Request, eom Key) {
+	defer func() {
+		if r := recover(); r != nil {
+			srv.logf(errorPrefix+"Handler panic serving %s: %v", req.RemoteAddr, r)
+			reply.Abort()
+			id, _ := req.Get(ErrorRequestIDKey)
+			reply.EncodeError(eom, string(id), ErrHandlerPanic)
+		}
+	}()
+	srv.Handler.ServeNetstring(reply, req)
+}
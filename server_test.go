@@ -0,0 +1,379 @@
+package netstring_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/markdingo/netstring"
+)
+
+// startServer runs "srv" on a loopback listener and returns its address.
+func startServer(t *testing.T, srv *netstring.Server) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() { done <- srv.Serve(ln) }()
+	t.Cleanup(func() {
+		srv.Close()
+		if err := <-done; err != netstring.ErrServerClosed {
+			t.Error("Serve returned", err)
+		}
+	})
+
+	return ln.Addr().String()
+}
+
+// echoHandler replies with the request fields upper-cased.
+var echoHandler = netstring.HandlerFunc(func(reply *netstring.MessageBuilder, req *netstring.Request) {
+	if v, ok := req.Get('p'); ok && string(v) == "panic" {
+		panic("asked to")
+	}
+	for _, kv := range req.Fields {
+		reply.EncodeBytes(kv.Key, bytes.ToUpper(kv.Value))
+	}
+	reply.EncodeBytes('z')
+})
+
+func TestServer(t *testing.T) {
+	srv := &netstring.Server{Handler: echoHandler}
+	addr := startServer(t, srv)
+
+	conn, err := netstring.DialNetstring("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	for ix := 0; ix < 3; ix++ {
+		conn.EncodeString('a', "hello")
+		conn.EncodeBytes('z')
+		m, err := conn.ReadMessage('z')
+		if err != nil {
+			t.Fatal(ix, err)
+		}
+		if v, _ := m.Get('a'); string(v) != "HELLO" {
+			t.Error(ix, "Reply wrong", string(v))
+		}
+	}
+}
+
+func TestServerPanic(t *testing.T) {
+	var logBuf bytes.Buffer
+	var mu sync.Mutex
+	srv := &netstring.Server{Handler: echoHandler, EOM: 'Z',
+		ErrorLog: log.New(&lockedWriter{&mu, &logBuf}, "", 0)}
+	addr := startServer(t, srv)
+
+	conn, err := netstring.DialNetstring("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.EncodeString('R', "req1")
+	conn.EncodeString('p', "panic")
+	conn.EncodeBytes('Z')
+	re, err := conn.DecodeError('Z')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if re.RequestID != "req1" || !errors.Is(re, netstring.ErrHandlerPanic) {
+		t.Error("Error-reply wrong", re)
+	}
+	conn.EncodeString('a', "still here") // Connection survives
+	conn.EncodeBytes('Z')
+	_, err = conn.ReadMessage('z')
+	if err != nil {
+		t.Error("Connection did not survive panic", err)
+	}
+	mu.Lock()
+	if !strings.Contains(logBuf.String(), "Handler panic") {
+		t.Error("Panic not logged", logBuf.String())
+	}
+	mu.Unlock()
+
+}
+
+func TestServerPanicHMAC(t *testing.T) {
+	opts := []netstring.Option{netstring.WithHMAC([]byte("secret"), sha256.New)}
+	handler := netstring.HandlerFunc(func(reply *netstring.MessageBuilder, req *netstring.Request) {
+		if _, ok := req.Get('p'); ok {
+			reply.EncodeString('a', "partial") // Discarded along with its HMAC
+			panic("asked to")
+		}
+		reply.EncodeMessage('z', netstring.KV{Key: 'a', Value: []byte("ok")})
+	})
+	srv := &netstring.Server{Handler: handler, Options: opts, ErrorLog: log.New(io.Discard, "", 0)}
+	addr := startServer(t, srv)
+
+	conn, err := netstring.DialNetstring("tcp", addr, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.EncodeMessage('z', netstring.KV{Key: 'p', Value: []byte("panic")})
+	re, err := conn.DecodeError('z')
+	if err != nil {
+		t.Fatal("Error-reply rejected", err)
+	}
+	if !errors.Is(re, netstring.ErrHandlerPanic) {
+		t.Error("Error-reply wrong", re)
+	}
+	conn.EncodeMessage('z', netstring.KV{Key: 'a', Value: []byte("again")})
+	m, err := conn.ReadMessage('z')
+	if err != nil {
+		t.Fatal("Reply after panic rejected", err)
+	}
+	if v, _ := m.Get('a'); string(v) != "ok" {
+		t.Error("Reply wrong", m)
+	}
+}
+
+func TestServerMaxConns(t *testing.T) {
+	srv := &netstring.Server{Handler: echoHandler, MaxConns: 1}
+	addr := startServer(t, srv)
+
+	c1, _ := netstring.DialNetstring("tcp", addr)
+	defer c1.Close()
+	c1.EncodeString('a', "x") // Ensure c1 is established before c2
+	c1.EncodeBytes('z')
+	c1.ReadMessage('z')
+
+	c2, _ := netstring.DialNetstring("tcp", addr)
+	defer c2.Close()
+	re, err := c2.DecodeError('z')
+	if err != nil || !errors.Is(re, netstring.ErrServerBusy) {
+		t.Error("Expected ErrServerBusy error-reply, not", re, err)
+	}
+	_, err = c2.Decode()
+	if err == nil {
+		t.Error("Busy connection should be closed")
+	}
+
+	c1.Close()
+	time.Sleep(50 * time.Millisecond) // Let the server notice
+	c3, _ := netstring.DialNetstring("tcp", addr)
+	defer c3.Close()
+	c3.EncodeString('a', "x")
+	c3.EncodeBytes('z')
+	m, err := c3.ReadMessage('z')
+	if v, _ := m.Get('a'); err != nil || string(v) != "X" {
+		t.Error("Connection slot not released", m, err)
+	}
+}
+
+func TestServerMaxInFlight(t *testing.T) {
+	var mu sync.Mutex
+	var active, peak int
+	release := make(chan struct{})
+	handler := netstring.HandlerFunc(func(reply *netstring.MessageBuilder, req *netstring.Request) {
+		mu.Lock()
+		active++
+		if active > peak {
+			peak = active
+		}
+		mu.Unlock()
+		<-release
+		mu.Lock()
+		active--
+		mu.Unlock()
+		v, _ := req.Get('n')
+		reply.EncodeBytes('n', v)
+		reply.EncodeBytes('z')
+	})
+	srv := &netstring.Server{Handler: handler, MaxInFlight: 2}
+	addr := startServer(t, srv)
+
+	conn, _ := netstring.DialNetstring("tcp", addr)
+	defer conn.Close()
+	for ix := 0; ix < 4; ix++ {
+		conn.EncodeInt('n', ix)
+		conn.EncodeBytes('z')
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	for ix := 0; ix < 4; ix++ {
+		_, err := conn.ReadMessage('z')
+		if err != nil {
+			t.Fatal(ix, err)
+		}
+	}
+	mu.Lock()
+	if peak != 2 {
+		t.Error("Expected two concurrent handlers, not", peak)
+	}
+	mu.Unlock()
+}
+
+func TestServerClose(t *testing.T) {
+	srv := &netstring.Server{Handler: echoHandler}
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	done := make(chan error)
+	go func() { done <- srv.Serve(ln) }()
+
+	conn, err := netstring.DialNetstring("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond) // Let the server accept
+	srv.Close()
+	if err := <-done; err != netstring.ErrServerClosed {
+		t.Error("Expected ErrServerClosed, not", err)
+	}
+	_, err = conn.Decode()
+	if err == nil {
+		t.Error("Connection should be closed by Server.Close")
+	}
+
+	ln, _ = net.Listen("tcp", "127.0.0.1:0")
+	if srv.Serve(ln) != netstring.ErrServerClosed {
+		t.Error("Serve after Close should return ErrServerClosed")
+	}
+
+	err = netstring.ListenAndServeNetstring("tcp", "256.0.0.1:0", echoHandler)
+	if err == nil || errors.Is(err, netstring.ErrServerClosed) {
+		t.Error("Expected listen error, not", err)
+	}
+	_, err = netstring.DialNetstring("tcp", "256.0.0.1:0")
+	if err == nil {
+		t.Error("Expected dial error")
+	}
+}
+
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  *bytes.Buffer
+}
+
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.w.Write(p)
+}
+
+func TestServerUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ns.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skip("Unix sockets unavailable", err)
+	}
+	srv := &netstring.Server{Handler: echoHandler}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	conn, err := netstring.DialNetstring("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.EncodeString('a', "unix")
+	conn.EncodeBytes('z')
+	m, err := conn.ReadMessage('z')
+	if v, _ := m.Get('a'); err != nil || string(v) != "UNIX" {
+		t.Error("Unix socket reply wrong", string(v), err)
+	}
+}
+
+func TestServerContext(t *testing.T) {
+	cancelled := make(chan error, 1)
+	handler := netstring.HandlerFunc(func(reply *netstring.MessageBuilder, req *netstring.Request) {
+		select {
+		case <-req.Context().Done():
+			cancelled <- req.Context().Err()
+		case <-time.After(5 * time.Second):
+			cancelled <- nil
+		}
+	})
+	addr := startServer(t, &netstring.Server{Handler: handler})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Write([]byte("1:z,"))
+	time.Sleep(10 * time.Millisecond) // Let the handler start
+	conn.Write([]byte("5:ab"))        // Disconnect part way through a netstring
+	conn.Close()
+	if err := <-cancelled; err != context.Canceled {
+		t.Error("Handler context not cancelled on disconnect", err)
+	}
+
+	var req netstring.Request
+	if req.Context() == nil {
+		t.Error("Zero Request returned a nil Context")
+	}
+	type ctxKey struct{}
+	r2 := req.WithContext(context.WithValue(context.Background(), ctxKey{}, 1))
+	if r2.Context().Value(ctxKey{}) != 1 || req.Context().Value(ctxKey{}) != nil {
+		t.Error("WithContext did not produce a distinct copy")
+	}
+}
+
+func TestServerHalfClose(t *testing.T) {
+	slow := netstring.HandlerFunc(func(reply *netstring.MessageBuilder, req *netstring.Request) {
+		time.Sleep(20 * time.Millisecond) // Still running when EOF is read
+		echoHandler(reply, req)
+	})
+	addr := startServer(t, &netstring.Server{Handler: slow, MaxInFlight: 2})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("4:aone,1:z,4:atwo,1:z,"))
+	conn.(*net.TCPConn).CloseWrite()
+
+	dec := netstring.NewDecoder(conn)
+	got := map[string]bool{}
+	for ix := 0; ix < 2; ix++ {
+		m, err := dec.ReadMessage('z')
+		if err != nil {
+			t.Fatal(ix, "Reply lost after half-close", err)
+		}
+		v, _ := m.Get('a')
+		got[string(v)] = true
+	}
+	if !got["ONE"] || !got["TWO"] {
+		t.Error("Wrong replies", got)
+	}
+}
+
+func TestServerProvenance(t *testing.T) {
+	srv := &netstring.Server{Handler: echoHandler,
+		Options: []netstring.Option{netstring.WithProvenance(netstring.Provenance{Producer: "srv"})}}
+	addr := startServer(t, srv)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	dec := netstring.NewDecoder(conn)
+	for ix := 0; ix < 3; ix++ {
+		conn.Write([]byte("3:ahi,1:z,"))
+		if ix == 0 {
+			p, err := dec.ReadProvenance()
+			if err != nil || p.Producer != "srv" {
+				t.Fatal("Expected provenance ahead of first reply", p, err)
+			}
+		}
+		m, err := dec.ReadMessage('z')
+		if err != nil {
+			t.Fatal(ix, err)
+		}
+		if len(m.Fields) != 1 {
+			t.Error(ix, "Expected one field, provenance repeated?", m.Fields)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package netstring
+
+import (
+	"io"
+)
+
+// StringReader is a migration adapter which presents the API shape common to other Go
+// netstring packages: a reader returning each netstring as a (string, error). It is
+// implemented on top of Decoder so migrating code gains the strict parser without being
+// rewritten. A StringReader *must* be constructed with NewStringReader.
+//
+// New code should use Decoder directly as it avoids the string allocation per netstring.
+type StringReader struct {
+	dec *Decoder
+}
+
+// NewStringReader constructs a StringReader which decodes standard netstrings from
+// "rdr". All Decoder Options apply.
+func NewStringReader(rdr io.Reader, opts ...Option) *StringReader {
+	return &StringReader{dec: NewDecoder(rdr, opts...)}
+}
+
+// ReadString returns the value of the next netstring. Errors are those of Decoder.Decode,
+// including io.EOF at the end of the stream.
+func (sr *StringReader) ReadString() (string, error) {
+	ns, err := sr.dec.Decode()
+	if err != nil {
+		return "", err
+	}
+
+	return string(ns), nil
+}
+
+// StringWriter is the migration counterpart of StringReader which writes each string as a
+// standard netstring. StringWriter satisfies io.StringWriter. A StringWriter *must* be
+// constructed with NewStringWriter.
+type StringWriter struct {
+	enc *Encoder
+}
+
+// NewStringWriter constructs a StringWriter which encodes netstrings to "output". All
+// Encoder Options apply.
+func NewStringWriter(output io.Writer, opts ...Option) *StringWriter {
+	return &StringWriter{enc: NewEncoder(output, opts...)}
+}
+
+// WriteString encodes "s" as a single standard netstring. It returns len(s) if the
+// netstring was written successfully, otherwise it returns zero and the error from the
+// Encoder. Unlike FrameWriter, an empty "s" is written as an empty netstring.
+func (sw *StringWriter) WriteString(s string) (int, error) {
+	err := sw.enc.EncodeString(NoKey, s)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(s), nil
+}
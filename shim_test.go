@@ -0,0 +1,50 @@
+package netstring_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestStringReaderWriter(t *testing.T) {
+	var bbuf bytes.Buffer
+	var sw io.StringWriter = netstring.NewStringWriter(&bbuf)
+	in := []string{"hello", "", "world"}
+	for ix, s := range in {
+		n, err := sw.WriteString(s)
+		if err != nil || n != len(s) {
+			t.Fatal(ix, "WriteString", n, err)
+		}
+	}
+	exp := "5:hello,0:,5:world,"
+	if bbuf.String() != exp {
+		t.Fatal("Wire mismatch got", bbuf.String(), "expected", exp)
+	}
+
+	sr := netstring.NewStringReader(&bbuf)
+	for ix, s := range in {
+		got, err := sr.ReadString()
+		if err != nil || got != s {
+			t.Error(ix, "ReadString", got, err)
+		}
+	}
+	_, err := sr.ReadString()
+	if err != io.EOF {
+		t.Error("Expected io.EOF, got", err)
+	}
+
+	sr = netstring.NewStringReader(bytes.NewBufferString("12:hello world,"),
+		netstring.WithMaximumLength(9))
+	_, err = sr.ReadString()
+	if err == nil {
+		t.Error("Expected error for oversized value")
+	}
+
+	w := netstring.NewStringWriter(&bbuf, netstring.WithMaximumLength(2))
+	n, err := w.WriteString("abc")
+	if err == nil || n != 0 {
+		t.Error("Expected error for oversized write", n, err)
+	}
+}
@@ -0,0 +1,53 @@
+package netstring
+
+import "strconv"
+
+// sniffDigits is the number of digits in MaximumLength, thus the longest length which
+// SniffNetstring accepts.
+var sniffDigits = len(strconv.Itoa(MaximumLength))
+
+/*
+SniffNetstring inspects the first bytes of a connection and reports whether they
+plausibly start a valid netstring. It is intended for multi-protocol listeners which
+share one port and need to decide which protocol handler to pass a connection to.
+
+If "prefix" is too short to decide, "likely" is false and "needMore" is the minimum
+number of additional bytes needed to make progress. Otherwise "needMore" is zero and
+"likely" is true if "prefix" starts with a well-formed length and colon. If "prefix" also
+extends past the end of the first value, the trailing comma is checked too.
+
+SniffNetstring never consumes or modifies "prefix" and has no knowledge of Options such
+as WithMaximumLength, so a "likely" result is not a guarantee that a Decoder will accept
+the stream.
+*/
+func SniffNetstring(prefix []byte) (likely bool, needMore int) {
+	for ix, b := range prefix {
+		switch {
+		case b == leadingColon:
+			if ix == 0 {
+				return false, 0
+			}
+			return sniffComma(prefix, ix), 0
+		case b < '0' || b > '9':
+			return false, 0
+		case ix == 1 && prefix[0] == '0': // Non-zero length with a leading zero
+			return false, 0
+		case ix >= sniffDigits:
+			return false, 0
+		}
+	}
+
+	return false, 1
+}
+
+// sniffComma returns false if "prefix" extends to the trailing delimiter of the netstring
+// whose colon is at "colon" and that delimiter is not a comma.
+func sniffComma(prefix []byte, colon int) bool {
+	l, _ := strconv.Atoi(string(prefix[:colon])) // Digits already validated
+	comma := colon + 1 + l
+	if comma >= len(prefix) {
+		return true
+	}
+
+	return prefix[comma] == trailingComma
+}
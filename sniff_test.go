@@ -0,0 +1,41 @@
+package netstring_test
+
+import (
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestSniffNetstring(t *testing.T) {
+	testCases := []struct {
+		prefix   string
+		likely   bool
+		needMore int
+	}{
+		{"", false, 1},
+		{"1", false, 1},
+		{"12", false, 1},
+		{"0", false, 1},
+		{"0:", true, 0},
+		{"0:,", true, 0},
+		{"0:x", false, 0},
+		{"3:ab", true, 0},
+		{"3:abc,", true, 0},
+		{"3:abcd", false, 0},
+		{"01:", false, 0},
+		{":", false, 0},
+		{"GET / HTTP/1.1", false, 0},
+		{"12a", false, 0},
+		{"999999999:", true, 0},
+		{"999999999", false, 1},
+		{"9999999999", false, 0},
+		{"\x16\x03\x01", false, 0}, // TLS ClientHello
+	}
+
+	for ix, tc := range testCases {
+		likely, needMore := netstring.SniffNetstring([]byte(tc.prefix))
+		if likely != tc.likely || needMore != tc.needMore {
+			t.Error(ix, tc.prefix, "got", likely, needMore, "expected", tc.likely, tc.needMore)
+		}
+	}
+}
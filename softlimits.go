@@ -0,0 +1,112 @@
+package netstring
+
+import (
+	"fmt"
+	"time"
+)
+
+// SoftLimit identifies which threshold of SoftLimits was exceeded.
+type SoftLimit int
+
+const (
+	SoftValueLength   SoftLimit = iota // A single netstring value
+	SoftMessageLength                  // Total value bytes in a message
+	SoftMessageRate                    // Messages per second
+)
+
+func (sl SoftLimit) String() string {
+	switch sl {
+	case SoftValueLength:
+		return "ValueLength"
+	case SoftMessageLength:
+		return "MessageLength"
+	case SoftMessageRate:
+		return "MessageRate"
+	}
+
+	return "Bizarre SoftLimit"
+}
+
+// Warning describes a single breach of a soft limit. It is passed to SoftLimits.Warn.
+type Warning struct {
+	Limit     SoftLimit
+	Actual    int // The observed value, length or rate
+	Threshold int // The configured soft limit
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s %d exceeds soft limit of %d", w.Limit, w.Actual, w.Threshold)
+}
+
+// SoftLimits defines thresholds which, unlike hard limits such as WithMaximumLength, never
+// reject traffic. Instead, Warn is called each time a threshold is exceeded so that
+// operators can observe drift, via logs or metrics, before tightening hard limits. A zero
+// threshold is disabled. SoftLimits are attached to a Decoder with WithSoftLimits.
+//
+// ValueLength is checked as soon as the length of each netstring is parsed.
+// MessageLength and MessagesPerSecond are checked as each message is completed by
+// ReadMessage or Unmarshal. MessagesPerSecond is measured over consecutive one second
+// windows and Warn is called at most once per window.
+//
+// Warn is called synchronously from within the Decoder so it should return promptly.
+type SoftLimits struct {
+	ValueLength       int
+	MessageLength     int
+	MessagesPerSecond int
+	Warn              func(Warning)
+}
+
+// softState tracks the message rate for SoftLimits.
+type softState struct {
+	limits      SoftLimits
+	windowStart time.Time
+	count       int
+	warned      bool // Rate warning already issued for this window
+}
+
+func (ss *softState) warn(limit SoftLimit, actual, threshold int) {
+	if ss.limits.Warn != nil {
+		ss.limits.Warn(Warning{limit, actual, threshold})
+	}
+}
+
+// checkValue is called with the length of each netstring.
+func (ss *softState) checkValue(length int) {
+	if ss.limits.ValueLength > 0 && length > ss.limits.ValueLength {
+		ss.warn(SoftValueLength, length, ss.limits.ValueLength)
+	}
+}
+
+// checkMessage is called at the end of each message.
+func (ss *softState) checkMessage(stats KeyStats) {
+	if ss.limits.MessageLength > 0 {
+		var total int
+		for _, st := range stats {
+			total += st.Bytes
+		}
+		if total > ss.limits.MessageLength {
+			ss.warn(SoftMessageLength, total, ss.limits.MessageLength)
+		}
+	}
+
+	if ss.limits.MessagesPerSecond > 0 {
+		now := time.Now()
+		if now.Sub(ss.windowStart) >= time.Second {
+			ss.windowStart = now
+			ss.count = 0
+			ss.warned = false
+		}
+		ss.count++
+		if ss.count > ss.limits.MessagesPerSecond && !ss.warned {
+			ss.warned = true
+			ss.warn(SoftMessageRate, ss.count, ss.limits.MessagesPerSecond)
+		}
+	}
+}
+
+// WithSoftLimits attaches SoftLimits to a Decoder. Encoder ignores this Option.
+func WithSoftLimits(sl SoftLimits) Option {
+	return func(o *options) {
+		o.softLimits = &sl
+	}
+}
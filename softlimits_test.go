@@ -0,0 +1,57 @@
+package netstring_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestSoftLimits(t *testing.T) {
+	var warnings []netstring.Warning
+	sl := netstring.SoftLimits{ValueLength: 4, MessageLength: 8, MessagesPerSecond: 2,
+		Warn: func(w netstring.Warning) { warnings = append(warnings, w) }}
+
+	input := "3:abc,1:z," + // Under all limits
+		"6:abcdef,6:ghijkl,1:z," + // Two values and the message over the limits
+		"1:z," + // Third message in the window exceeds the rate
+		"1:z," // Rate warning only issued once per window
+	dec := netstring.NewDecoder(strings.NewReader(input), netstring.WithSoftLimits(sl))
+	msgs, err := dec.ReadMessages('z', -1)
+	if err != nil || len(msgs) != 4 {
+		t.Fatal("Soft limits should not reject traffic", len(msgs), err)
+	}
+
+	expect := []string{
+		"ValueLength 6 exceeds soft limit of 4",
+		"ValueLength 6 exceeds soft limit of 4",
+		"MessageLength 10 exceeds soft limit of 8",
+		"MessageRate 3 exceeds soft limit of 2",
+	}
+	if len(warnings) != len(expect) {
+		t.Fatal("Wrong number of warnings", warnings)
+	}
+	for ix, w := range warnings {
+		if w.String() != expect[ix] {
+			t.Error(ix, "Wrong warning", w)
+		}
+	}
+
+	// Unmarshal also checks message limits
+	warnings = nil
+	var m struct {
+		A string `netstring:"a"`
+	}
+	dec = netstring.NewDecoder(strings.NewReader("10:a123456789,1:z,"), netstring.WithSoftLimits(sl))
+	_, err = dec.Unmarshal('z', &m)
+	if err != nil || len(warnings) != 2 || warnings[1].Limit != netstring.SoftMessageLength {
+		t.Error("Unmarshal soft limits wrong", warnings, err)
+	}
+}
+
+func TestSoftLimitString(t *testing.T) {
+	if netstring.SoftMessageRate.String() != "MessageRate" ||
+		netstring.SoftLimit(99).String() != "Bizarre SoftLimit" {
+		t.Error("SoftLimit String wrong")
+	}
+}
@@ -0,0 +1,65 @@
+package netstring
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"io"
+)
+
+// Value implements the database/sql/driver.Valuer interface so that a Message can be
+// stored directly in a BLOB or TEXT column. The stored value is the canonical encoding of
+// the Message, that is, each of the Fields as a "keyed" netstring followed by the EOM
+// sentinel exactly as it would appear on the wire.
+func (m Message) Value() (driver.Value, error) {
+	var bbuf bytes.Buffer
+	err := m.encode(NewEncoder(&bbuf))
+	if err != nil {
+		return nil, err
+	}
+
+	return bbuf.Bytes(), nil
+}
+
+// Scan implements the database/sql.Scanner interface and is the inverse of Value. "src"
+// must be a []byte or string containing a series of "keyed" netstrings, the last of which
+// is an empty end-of-message sentinel. A nil "src" (SQL NULL) results in an empty Message.
+//
+// ErrBadScanValue is returned if "src" is not a canonically encoded Message.
+func (m *Message) Scan(src any) error {
+	var rdr io.Reader
+	switch v := src.(type) {
+	case nil:
+		*m = Message{}
+		return nil
+	case []byte:
+		rdr = bytes.NewReader(v)
+	case string:
+		rdr = bytes.NewBufferString(v)
+	default:
+		return ErrBadScanValue
+	}
+
+	var fields []KV
+	dec := NewDecoder(rdr)
+	for {
+		k, v, err := dec.DecodeKeyed()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		fields = append(fields, KV{k, v})
+	}
+
+	last := len(fields) - 1
+	if last < 0 || len(fields[last].Value) > 0 { // Trailing EOM sentinel is mandatory
+		return ErrBadScanValue
+	}
+	*m = Message{EOM: fields[last].Key}
+	if last > 0 {
+		m.Fields = fields[:last:last]
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package netstring_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+// Compile-time checks that Message can be used directly with database/sql
+var _ driver.Valuer = netstring.Message{}
+var _ sql.Scanner = &netstring.Message{}
+
+func TestMessageValueScan(t *testing.T) {
+	testCases := []netstring.Message{
+		{EOM: 'z'},
+		{EOM: 'z', Fields: []netstring.KV{{Key: 'a', Value: []byte("hello")}}},
+		{EOM: 'Z', Fields: []netstring.KV{{Key: 'a', Value: []byte{}}, {Key: 'b', Value: []byte("1,2:")}}},
+	}
+
+	for ix, out := range testCases {
+		v, err := out.Value()
+		if err != nil {
+			t.Fatal(ix, err)
+		}
+		var in netstring.Message
+		err = in.Scan(v)
+		if err != nil {
+			t.Fatal(ix, err)
+		}
+		if !reflect.DeepEqual(in, out) {
+			t.Error(ix, "Round trip mismatch", in, out)
+		}
+		var ins netstring.Message // Also accept TEXT columns
+		err = ins.Scan(string(v.([]byte)))
+		if err != nil || !reflect.DeepEqual(ins, out) {
+			t.Error(ix, "String round trip mismatch", ins, err)
+		}
+	}
+
+	v, _ := testCases[1].Value()
+	if string(v.([]byte)) != "6:ahello,1:z," {
+		t.Error("Value not canonical encoding", string(v.([]byte)))
+	}
+}
+
+func TestMessageValueScanErrors(t *testing.T) {
+	_, err := netstring.Message{}.Value()
+	if err != netstring.ErrBadMarshalEOM {
+		t.Error("Expected ErrBadMarshalEOM, not", err)
+	}
+	_, err = netstring.Message{EOM: 'z', Fields: []netstring.KV{{Key: '1'}}}.Value()
+	if err != netstring.ErrInvalidKey {
+		t.Error("Expected ErrInvalidKey, not", err)
+	}
+
+	type testCase struct {
+		src    any
+		expect error
+	}
+	testCases := []testCase{
+		{42, netstring.ErrBadScanValue},
+		{"", netstring.ErrBadScanValue},
+		{"6:ahello,", netstring.ErrBadScanValue},    // No EOM
+		{"6:ahello,1:z", netstring.ErrBadScanValue}, // Truncated
+		{"6:ahello,1:zX", netstring.ErrCommaExpected},
+		{"0:,", netstring.ErrZeroKey},
+	}
+	for ix, tc := range testCases {
+		var m netstring.Message
+		err := m.Scan(tc.src)
+		if err != tc.expect {
+			t.Error(ix, "Expected", tc.expect, "got", err)
+		}
+	}
+
+	m := netstring.Message{EOM: 'z'}
+	err = m.Scan(nil)
+	if err != nil || m.EOM != netstring.NoKey {
+		t.Error("NULL should produce empty Message", m, err)
+	}
+}
@@ -20,6 +20,11 @@ func TestStringers(t *testing.T) {
 		t.Error("netstring.parseState.String() comma failed", s)
 	}
 
+	s = parseDiscard.String()
+	if s != "parseDiscard" {
+		t.Error("netstring.parseState.String() discard failed", s)
+	}
+
 	ps := parseState(23)
 	s = ps.String()
 	if s != "Bizarre parseState" {
@@ -0,0 +1,179 @@
+package netstring
+
+import (
+	"bytes"
+)
+
+// MarshalText implements encoding.TextMarshaler. A "keyed" Key is represented by its
+// letter and NoKey is represented by an empty string. An invalid Key returns
+// ErrInvalidKey.
+func (k Key) MarshalText() ([]byte, error) {
+	keyed, err := k.Assess()
+	if err != nil {
+		return nil, err
+	}
+	if !keyed {
+		return []byte{}, nil
+	}
+
+	return []byte{byte(k)}, nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler and is the inverse of MarshalText.
+func (k *Key) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*k = NoKey
+		return nil
+	}
+	if len(text) > 1 {
+		return ErrInvalidKey
+	}
+	nk := Key(text[0])
+	keyed, err := nk.Assess()
+	if err != nil {
+		return err
+	}
+	if !keyed {
+		return ErrInvalidKey
+	}
+	*k = nk
+
+	return nil
+}
+
+/*
+MarshalText implements encoding.TextMarshaler with a single-line, human-readable
+representation of the Message suitable for logs, templates and configuration files. Each
+field is represented as key=value with fields separated by a single space and the
+end-of-message sentinel is represented by its bare key as the last word. For example:
+
+	t=login u=bob p=hunter%202 z
+
+Values are escaped by replacing space, '%' and all non-printable bytes with %XX where XX
+is the upper-case hex value of the byte. All other bytes, including ',' and ':' which are
+significant to netstrings, appear as-is.
+*/
+func (m Message) MarshalText() ([]byte, error) {
+	keyed, err := m.EOM.Assess()
+	if err != nil {
+		return nil, err
+	}
+	if !keyed {
+		return nil, ErrBadMarshalEOM
+	}
+
+	var bbuf bytes.Buffer
+	for _, kv := range m.Fields {
+		keyed, err = kv.Key.Assess()
+		if err != nil {
+			return nil, err
+		}
+		if !keyed {
+			return nil, ErrNoKey
+		}
+		bbuf.WriteByte(byte(kv.Key))
+		bbuf.WriteByte('=')
+		bbuf.Write(EscapeValue(kv.Value))
+		bbuf.WriteByte(' ')
+	}
+	bbuf.WriteByte(byte(m.EOM))
+
+	return bbuf.Bytes(), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler and is the inverse of
+// MarshalText. ErrBadMessageText is returned if "text" is not in the format produced by
+// MarshalText.
+func (m *Message) UnmarshalText(text []byte) error {
+	words := bytes.Split(text, []byte{' '})
+	last := len(words) - 1
+	var nm Message
+	for ix, w := range words {
+		if len(w) == 0 {
+			return ErrBadMessageText
+		}
+		k := Key(w[0])
+		keyed, err := k.Assess()
+		if err != nil {
+			return err
+		}
+		if !keyed {
+			return ErrBadMessageText
+		}
+		if ix == last { // Must be the bare EOM
+			if len(w) != 1 {
+				return ErrBadMessageText
+			}
+			nm.EOM = k
+			break
+		}
+		if len(w) < 2 || w[1] != '=' {
+			return ErrBadMessageText
+		}
+		v, err := UnescapeValue(w[2:])
+		if err != nil {
+			return ErrBadMessageText
+		}
+		nm.Fields = append(nm.Fields, KV{k, v})
+	}
+	*m = nm
+
+	return nil
+}
+
+const hexDigits = "0123456789ABCDEF"
+
+// EscapeValue returns a copy of "val" with space, '%' and all non-printable bytes replaced
+// by %XX where XX is the upper-case hex value of the byte. The result only contains
+// printable ASCII so it can be safely embedded in logs and read by humans without
+// embedded control characters causing confusion. See also WithEscaping.
+func EscapeValue(val []byte) []byte {
+	res := make([]byte, 0, len(val))
+	for _, b := range val {
+		if b <= ' ' || b > '~' || b == '%' {
+			res = append(res, '%', hexDigits[b>>4], hexDigits[b&0xF])
+			continue
+		}
+		res = append(res, b)
+	}
+
+	return res
+}
+
+// UnescapeValue is the inverse of EscapeValue. It returns ErrBadEscape if a '%' is not
+// followed by two hex digits. Hex digits may be upper or lower case.
+func UnescapeValue(val []byte) ([]byte, error) {
+	res := make([]byte, 0, len(val))
+	for ix := 0; ix < len(val); ix++ {
+		b := val[ix]
+		if b != '%' {
+			res = append(res, b)
+			continue
+		}
+		if ix+2 >= len(val) {
+			return nil, ErrBadEscape
+		}
+		hi, ok1 := unhex(val[ix+1])
+		lo, ok2 := unhex(val[ix+2])
+		if !ok1 || !ok2 {
+			return nil, ErrBadEscape
+		}
+		res = append(res, hi<<4|lo)
+		ix += 2
+	}
+
+	return res, nil
+}
+
+func unhex(b byte) (byte, bool) {
+	switch {
+	case b >= '0' && b <= '9':
+		return b - '0', true
+	case b >= 'a' && b <= 'f':
+		return b - 'a' + 10, true
+	case b >= 'A' && b <= 'F':
+		return b - 'A' + 10, true
+	}
+
+	return 0, false
+}
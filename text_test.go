@@ -0,0 +1,163 @@
+package netstring_test
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/markdingo/netstring"
+)
+
+var _ encoding.TextMarshaler = netstring.Key('a')
+var _ encoding.TextUnmarshaler = new(netstring.Key)
+var _ encoding.TextMarshaler = netstring.Message{}
+var _ encoding.TextUnmarshaler = &netstring.Message{}
+
+func TestKeyText(t *testing.T) {
+	type testCase struct {
+		key    netstring.Key
+		text   string
+		expect error
+	}
+	testCases := []testCase{
+		{'a', "a", nil},
+		{'Z', "Z", nil},
+		{netstring.NoKey, "", nil},
+		{'1', "1", netstring.ErrInvalidKey},
+	}
+	for ix, tc := range testCases {
+		b, err := tc.key.MarshalText()
+		if err != tc.expect {
+			t.Error(ix, "Marshal expected", tc.expect, "got", err)
+			continue
+		}
+		var k netstring.Key
+		err = k.UnmarshalText([]byte(tc.text))
+		if err != tc.expect {
+			t.Error(ix, "Unmarshal expected", tc.expect, "got", err)
+			continue
+		}
+		if err == nil && (string(b) != tc.text || k != tc.key) {
+			t.Error(ix, "Mismatch", string(b), k)
+		}
+	}
+
+	var k netstring.Key
+	if k.UnmarshalText([]byte("ab")) != netstring.ErrInvalidKey {
+		t.Error("Multi-byte key should be invalid")
+	}
+
+	// Keys should appear as letters in JSON, including as map keys
+	b, _ := json.Marshal(map[netstring.Key]netstring.Key{'a': 'b'})
+	if string(b) != `{"a":"b"}` {
+		t.Error("JSON representation wrong", string(b))
+	}
+}
+
+func TestMessageText(t *testing.T) {
+	m := netstring.Message{EOM: 'z', Fields: []netstring.KV{
+		{Key: 't', Value: []byte("login")},
+		{Key: 'p', Value: []byte("hunter 2%\n")},
+		{Key: 'e', Value: []byte{}},
+		{Key: 'c', Value: []byte("1,2:=")},
+	}}
+	b, err := m.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := "t=login p=hunter%202%25%0A e= c=1,2:= z"
+	if string(b) != exp {
+		t.Error("Text wrong", string(b))
+	}
+
+	var in netstring.Message
+	err = in.UnmarshalText(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, m) {
+		t.Error("Round trip mismatch", in)
+	}
+
+	// And through JSON
+	b, _ = json.Marshal(struct{ M netstring.Message }{m})
+	if string(b) != `{"M":"`+exp+`"}` {
+		t.Error("JSON wrong", string(b))
+	}
+
+	// Unmarshal without fields
+	err = in.UnmarshalText([]byte("z"))
+	if err != nil || in.EOM != 'z' || in.Fields != nil {
+		t.Error("EOM only message wrong", in, err)
+	}
+}
+
+func TestMessageTextErrors(t *testing.T) {
+	_, err := netstring.Message{}.MarshalText()
+	if err != netstring.ErrBadMarshalEOM {
+		t.Error("Expected ErrBadMarshalEOM, not", err)
+	}
+	_, err = netstring.Message{EOM: 'z', Fields: []netstring.KV{{}}}.MarshalText()
+	if err != netstring.ErrNoKey {
+		t.Error("Expected ErrNoKey, not", err)
+	}
+
+	type testCase struct {
+		text   string
+		expect error
+	}
+	testCases := []testCase{
+		{"", netstring.ErrBadMessageText},
+		{"a=b", netstring.ErrBadMessageText}, // No EOM
+		{"a=b  z", netstring.ErrBadMessageText},
+		{"ab z", netstring.ErrBadMessageText},
+		{"a=%4 z", netstring.ErrBadMessageText},
+		{"a=%G0 z", netstring.ErrBadMessageText},
+		{"a=b zz", netstring.ErrBadMessageText},
+		{"1=b z", netstring.ErrInvalidKey},
+	}
+	for ix, tc := range testCases {
+		var m netstring.Message
+		err := m.UnmarshalText([]byte(tc.text))
+		if err != tc.expect {
+			t.Error(ix, "Expected", tc.expect, "got", err)
+		}
+	}
+}
+
+func TestEscapeValue(t *testing.T) {
+	testCases := []struct {
+		val    string
+		expect string
+	}{
+		{"", ""},
+		{"plain", "plain"},
+		{"a b", "a%20b"},
+		{"100%", "100%25"},
+		{"1,2:3", "1,2:3"},
+		{"\x00\x7f\xff~", "%00%7F%FF~"},
+		{"line\r\n", "line%0D%0A"},
+	}
+
+	for ix, tc := range testCases {
+		got := netstring.EscapeValue([]byte(tc.val))
+		if string(got) != tc.expect {
+			t.Error(ix, "Expected", tc.expect, "got", string(got))
+		}
+		back, err := netstring.UnescapeValue(got)
+		if err != nil || string(back) != tc.val {
+			t.Error(ix, "Round trip failed", string(back), err)
+		}
+	}
+
+	for ix, bad := range []string{"%", "%4", "%4G", "abc%zz"} {
+		_, err := netstring.UnescapeValue([]byte(bad))
+		if err != netstring.ErrBadEscape {
+			t.Error(ix, "Expected ErrBadEscape for", bad, "got", err)
+		}
+	}
+	if v, _ := netstring.UnescapeValue([]byte("%6a%6A")); string(v) != "jj" {
+		t.Error("Lower case hex not accepted", string(v))
+	}
+}
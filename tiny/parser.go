@@ -0,0 +1,136 @@
+package tiny
+
+// Parser states
+const (
+	stateStart  = iota // Expecting the first length digit
+	stateLength        // Expecting more length digits or the colon
+	stateValue         // Expecting value bytes
+	stateComma         // Expecting the trailing comma
+	stateReady         // A complete netstring is available
+)
+
+/*
+Parser is a push parser which is fed bytes as they arrive rather than reading them from an
+io.Reader, so it never blocks. Values are stored in the buffer supplied to [NewParser],
+the length of which is the maximum value length accepted, so a Parser never allocates.
+
+Parse errors are permanent: once Feed returns an error, it returns the same error until
+Reset is called, as re-synchronizing with a netstring stream is generally impossible.
+*/
+type Parser struct {
+	buf    []byte
+	state  int
+	length int // Of the current value
+	n      int // Bytes of the current value received thus far
+	err    error
+}
+
+// NewParser constructs a Parser which stores values in "buf". Netstrings with values
+// longer than len(buf) are rejected with ErrValueToLong.
+func NewParser(buf []byte) *Parser {
+	return &Parser{buf: buf}
+}
+
+// Reset discards any partially parsed netstring and any parse error so that the Parser
+// can be used with a new stream.
+func (p *Parser) Reset() {
+	p.state, p.length, p.n, p.err = stateStart, 0, 0, nil
+}
+
+// Feed parses bytes from "in" until a complete netstring is available or "in" is
+// exhausted. It returns the number of bytes used, which is less than len(in) if a
+// netstring completed part way through "in", in which case "ready" is true and the
+// remaining bytes should be fed again once the netstring has been processed with Value
+// or Keyed.
+func (p *Parser) Feed(in []byte) (used int, ready bool, err error) {
+	if p.err != nil {
+		return 0, false, p.err
+	}
+	if p.state == stateReady {
+		p.Reset()
+	}
+	for used < len(in) {
+		b := in[used]
+		switch p.state {
+		case stateStart:
+			if b < '0' || b > '9' {
+				return used, false, p.fail(ErrLengthNotDigit)
+			}
+			p.length = int(b - '0')
+			p.state = stateLength
+		case stateLength:
+			if b == ':' {
+				if p.length > len(p.buf) {
+					return used, false, p.fail(ErrValueToLong)
+				}
+				p.state = stateValue
+				if p.length == 0 {
+					p.state = stateComma
+				}
+				break
+			}
+			if b < '0' || b > '9' {
+				return used, false, p.fail(ErrColonExpected)
+			}
+			if p.length == 0 {
+				return used, false, p.fail(ErrLeadingZero)
+			}
+			p.length = p.length*10 + int(b-'0')
+			if p.length > len(p.buf) { // Also prevents overflow
+				return used, false, p.fail(ErrValueToLong)
+			}
+		case stateValue:
+			c := copy(p.buf[p.n:p.length], in[used:])
+			p.n += c
+			used += c
+			if p.n == p.length {
+				p.state = stateComma
+			}
+			continue
+		case stateComma:
+			if b != ',' {
+				return used, false, p.fail(ErrCommaExpected)
+			}
+			p.state = stateReady
+			return used + 1, true, nil
+		}
+		used++
+	}
+
+	return used, false, nil
+}
+
+func (p *Parser) fail(err error) error {
+	p.err = err
+
+	return err
+}
+
+// Value returns the value of the complete netstring after Feed returns "ready". The
+// returned slice refers to the Parser's buffer so it is only valid until the next Feed.
+func (p *Parser) Value() []byte {
+	if p.state != stateReady {
+		return nil
+	}
+
+	return p.buf[:p.length]
+}
+
+// Keyed returns the key and value of the complete "keyed" netstring after Feed returns
+// "ready". ErrZeroKey is returned for an empty netstring and ErrInvalidKey is returned if
+// the first byte is not a valid key.
+func (p *Parser) Keyed() (Key, []byte, error) {
+	v := p.Value()
+	if len(v) == 0 {
+		return NoKey, nil, ErrZeroKey
+	}
+	k := Key(v[0])
+	if keyed, err := k.Assess(); !keyed {
+		if err == nil {
+			err = ErrNoKey
+		}
+		return NoKey, nil, err
+	}
+
+	return k, v[1:], nil
+}
@@ -0,0 +1,183 @@
+/*
+Package tiny is a minimal netstring codec profile for constrained targets, such as TinyGo
+programs on microcontrollers which exchange netstrings with a host over a UART. It is wire
+compatible with package netstring but uses no reflection, no maps and no fmt, and it
+never allocates on its own behalf.
+
+The profile consists of the manual Encode*() functions of Encoder and the push Parser.
+There is no Marshal, no Unmarshal, no Options and no io.Reader based decoding; a Parser
+is fed whatever bytes arrive, typically from a UART interrupt buffer, and stores values
+in a buffer supplied by the application.
+
+	var storage [64]byte
+	p := tiny.NewParser(storage[:])
+	for {
+		n := uart.Read(rx)
+		in := rx[:n]
+		for len(in) > 0 {
+			used, ready, err := p.Feed(in)
+			if err != nil {
+				... // Parse errors are permanent until Reset
+			}
+			in = in[used:]
+			if ready {
+				key, val, err := p.Keyed()
+				...
+			}
+		}
+	}
+
+Keys have the same meaning as netstring.Key: NoKey for a standard netstring or 'a'-'z'
+and 'A'-'Z' for a "keyed" netstring.
+*/
+package tiny
+
+import (
+	"errors"
+	"io"
+	"strconv"
+)
+
+// Key is the equivalent of netstring.Key.
+type Key byte
+
+// NoKey indicates a standard netstring.
+const NoKey Key = 0
+
+const errorPrefix = "netstring: "
+
+// The errors returned by this package have the same text as their namesakes in package
+// netstring.
+var (
+	ErrLengthNotDigit = errors.New(errorPrefix + "Length does not start with a digit")
+	ErrLeadingZero    = errors.New(errorPrefix + "Non-zero length cannot have a leading zero")
+	ErrValueToLong    = errors.New(errorPrefix + "Length of value is longer than maximum allowed")
+	ErrColonExpected  = errors.New(errorPrefix + "Leading colon delimiter not found after length")
+	ErrCommaExpected  = errors.New(errorPrefix + "Trailing comma delimeter not found after value")
+	ErrNoKey          = errors.New(errorPrefix + "Keyed netstring cannot be NoKey")
+	ErrZeroKey        = errors.New(errorPrefix + "Keyed netstring is zero length (thus has no key)")
+	ErrInvalidKey     = errors.New(errorPrefix + "Key is not in range 'a'-'z' or 'A'-'Z'")
+)
+
+// Assess is the equivalent of netstring.Key.Assess.
+func (k Key) Assess() (keyed bool, err error) {
+	if k == NoKey {
+		return false, nil
+	}
+	if (k >= 'a' && k <= 'z') || (k >= 'A' && k <= 'Z') {
+		return true, nil
+	}
+
+	return false, ErrInvalidKey
+}
+
+/*
+Encoder writes netstrings to an io.Writer. Unlike netstring.Encoder, the zero value is
+not usable; construct an Encoder with [NewEncoder]. Each netstring is written with
+multiple Write() calls using internal scratch buffers so the Encoder does not allocate.
+*/
+type Encoder struct {
+	out     io.Writer
+	scratch [24]byte // Fits the length, colon and key
+	number  [20]byte // Fits any formatted number
+}
+
+// NewEncoder constructs an Encoder which writes to "output".
+func NewEncoder(output io.Writer) *Encoder {
+	return &Encoder{out: output}
+}
+
+// header writes the length, colon and key, if any, of a netstring with a value of "l"
+// bytes.
+func (enc *Encoder) header(key Key, l int) error {
+	keyed, err := key.Assess()
+	if err != nil {
+		return err
+	}
+	if keyed {
+		l++
+	}
+	hdr := strconv.AppendInt(enc.scratch[:0], int64(l), 10)
+	hdr = append(hdr, ':')
+	if keyed {
+		hdr = append(hdr, byte(key))
+	}
+	_, err = enc.out.Write(hdr)
+
+	return err
+}
+
+// trailer writes the trailing comma.
+func (enc *Encoder) trailer() error {
+	enc.scratch[0] = ','
+	_, err := enc.out.Write(enc.scratch[:1])
+
+	return err
+}
+
+// EncodeBytes encodes "val" as a standard netstring if "key" is NoKey, otherwise as a
+// "keyed" netstring.
+func (enc *Encoder) EncodeBytes(key Key, val []byte) error {
+	err := enc.header(key, len(val))
+	if err == nil && len(val) > 0 {
+		_, err = enc.out.Write(val)
+	}
+	if err != nil {
+		return err
+	}
+
+	return enc.trailer()
+}
+
+// EncodeString encodes "val" as per EncodeBytes. The Encoder only avoids allocating a
+// copy of "val" if the io.Writer implements io.StringWriter.
+func (enc *Encoder) EncodeString(key Key, val string) error {
+	sw, ok := enc.out.(io.StringWriter)
+	if !ok {
+		return enc.EncodeBytes(key, []byte(val))
+	}
+	err := enc.header(key, len(val))
+	if err == nil && len(val) > 0 {
+		_, err = sw.WriteString(val)
+	}
+	if err != nil {
+		return err
+	}
+
+	return enc.trailer()
+}
+
+// EncodeInt encodes "val" in decimal as per netstring.Encoder.EncodeInt64.
+func (enc *Encoder) EncodeInt(key Key, val int64) error {
+	return enc.EncodeBytes(key, strconv.AppendInt(enc.number[:0], val, 10))
+}
+
+// EncodeUint encodes "val" in decimal as per netstring.Encoder.EncodeUint64.
+func (enc *Encoder) EncodeUint(key Key, val uint64) error {
+	return enc.EncodeBytes(key, strconv.AppendUint(enc.number[:0], val, 10))
+}
+
+// EncodeBool encodes "val" as per netstring.Encoder.EncodeBool.
+func (enc *Encoder) EncodeBool(key Key, val bool) error {
+	enc.number[0] = 'f'
+	if val {
+		enc.number[0] = 'T'
+	}
+
+	return enc.EncodeBytes(key, enc.number[:1])
+}
+
+// DecodeInt parses a value encoded by EncodeInt.
+func DecodeInt(val []byte) (int64, error) {
+	return strconv.ParseInt(string(val), 10, 64)
+}
+
+// DecodeUint parses a value encoded by EncodeUint.
+func DecodeUint(val []byte) (uint64, error) {
+	return strconv.ParseUint(string(val), 10, 64)
+}
+
+// DecodeBool parses a value encoded by EncodeBool. Any value other than "T" is false.
+func DecodeBool(val []byte) bool {
+	return len(val) == 1 && val[0] == 'T'
+}
@@ -0,0 +1,154 @@
+package tiny_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/markdingo/netstring"
+	"github.com/markdingo/netstring/tiny"
+)
+
+func TestEncoder(t *testing.T) {
+	var bb bytes.Buffer
+	enc := tiny.NewEncoder(&bb)
+	enc.EncodeBytes(tiny.NoKey, []byte("std"))
+	enc.EncodeString('s', "hello")
+	enc.EncodeInt('i', -123)
+	enc.EncodeUint('u', 18446744073709551615)
+	enc.EncodeBool('b', true)
+	enc.EncodeBool('B', false)
+	enc.EncodeBytes('z', nil)
+	err := enc.EncodeBytes('!', nil)
+	if err != tiny.ErrInvalidKey {
+		t.Error("Expected ErrInvalidKey, not", err)
+	}
+
+	// Must be identical to the full package
+	var full bytes.Buffer
+	fe := netstring.NewEncoder(&full)
+	fe.EncodeBytes(netstring.NoKey, []byte("std"))
+	fe.EncodeString('s', "hello")
+	fe.EncodeInt64('i', -123)
+	fe.EncodeUint64('u', 18446744073709551615)
+	fe.EncodeBool('b', true)
+	fe.EncodeBool('B', false)
+	fe.EncodeBytes('z')
+	if bb.String() != full.String() {
+		t.Error("Encoding mismatch", bb.String(), full.String())
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		bb.Reset()
+		enc.EncodeString('s', "hello")
+		enc.EncodeInt('i', 12345)
+	})
+	if allocs != 0 {
+		t.Error("Encoder allocated", allocs)
+	}
+}
+
+func TestParser(t *testing.T) {
+	stream := []byte("3:std,6:shello,5:i-123,1:z,0:,")
+	exp := []string{"std", "shello", "i-123", "z", ""}
+	var storage [8]byte
+	for _, chunk := range []int{1, 2, 7, len(stream)} { // Vary how bytes arrive
+		p := tiny.NewParser(storage[:])
+		var got []string
+		for off := 0; off < len(stream); off += chunk {
+			end := off + chunk
+			if end > len(stream) {
+				end = len(stream)
+			}
+			in := stream[off:end]
+			for len(in) > 0 {
+				used, ready, err := p.Feed(in)
+				if err != nil {
+					t.Fatal(chunk, "Unexpected error", err)
+				}
+				in = in[used:]
+				if ready {
+					got = append(got, string(p.Value()))
+				}
+			}
+		}
+		if len(got) != len(exp) {
+			t.Fatal(chunk, "Expected", exp, "got", got)
+		}
+		for ix := range exp {
+			if got[ix] != exp[ix] {
+				t.Error(chunk, ix, "Expected", exp[ix], "got", got[ix])
+			}
+		}
+	}
+}
+
+func TestParserErrors(t *testing.T) {
+	testCases := []struct {
+		in  string
+		err error
+	}{
+		{"x", tiny.ErrLengthNotDigit},
+		{"01:a,", tiny.ErrLeadingZero},
+		{"1x", tiny.ErrColonExpected},
+		{"9:", tiny.ErrValueToLong},
+		{"123", tiny.ErrValueToLong},
+		{"1:ab", tiny.ErrCommaExpected},
+	}
+	var storage [4]byte
+	for ix, tc := range testCases {
+		p := tiny.NewParser(storage[:])
+		_, _, err := p.Feed([]byte(tc.in))
+		if err != tc.err {
+			t.Error(ix, "Expected", tc.err, "got", err)
+		}
+		_, _, err = p.Feed([]byte("1:a,"))
+		if err != tc.err {
+			t.Error(ix, "Error should be permanent, got", err)
+		}
+		p.Reset()
+		_, ready, err := p.Feed([]byte("1:a,"))
+		if !ready || err != nil {
+			t.Error(ix, "Reset should clear the error", ready, err)
+		}
+	}
+}
+
+func TestParserKeyed(t *testing.T) {
+	testCases := []struct {
+		in  string
+		key tiny.Key
+		val string
+		err error
+	}{
+		{"4:abcd,", 'a', "bcd", nil},
+		{"1:z,", 'z', "", nil},
+		{"0:,", tiny.NoKey, "", tiny.ErrZeroKey},
+		{"2:1a,", tiny.NoKey, "", tiny.ErrInvalidKey},
+	}
+	var storage [8]byte
+	p := tiny.NewParser(storage[:])
+	for ix, tc := range testCases {
+		_, ready, err := p.Feed([]byte(tc.in))
+		if !ready || err != nil {
+			t.Fatal(ix, "Feed failed", ready, err)
+		}
+		k, v, err := p.Keyed()
+		if k != tc.key || string(v) != tc.val || err != tc.err {
+			t.Error(ix, "Expected", tc.key, tc.val, tc.err, "got", k, string(v), err)
+		}
+	}
+}
+
+func TestDecodeHelpers(t *testing.T) {
+	i, err := tiny.DecodeInt([]byte("-42"))
+	if i != -42 || err != nil {
+		t.Error("DecodeInt", i, err)
+	}
+	u, err := tiny.DecodeUint([]byte("42"))
+	if u != 42 || err != nil {
+		t.Error("DecodeUint", u, err)
+	}
+	if !tiny.DecodeBool([]byte("T")) || tiny.DecodeBool([]byte("f")) {
+		t.Error("DecodeBool wrong")
+	}
+}
@@ -0,0 +1,56 @@
+package netstring
+
+import (
+	"context"
+	"io"
+	"net"
+	"time"
+)
+
+/*
+Transport is the minimal connection required by Conn, Client and Server to carry
+netstring messages. Any byte stream will do, so exotic transports such as serial ports,
+QUIC streams and SSH channels can be used without changes to the higher layers. A
+net.Conn is a Transport.
+
+A Transport may optionally implement:
+
+	SetDeadline(time.Time) error  // Used by Client to honour context deadlines
+	RemoteAddr() net.Addr         // Used by Server to populate Request.RemoteAddr
+
+A Client abandons a call on a Transport without SetDeadline by closing the Transport.
+*/
+type Transport interface {
+	io.ReadWriteCloser
+}
+
+// DialFunc connects to "address" on the named network and returns a Transport. See
+// Client.Dial.
+type DialFunc func(ctx context.Context, network, address string) (Transport, error)
+
+// deadliner is the optional Transport interface for setting I/O deadlines.
+type deadliner interface {
+	SetDeadline(t time.Time) error
+}
+
+// writeDeadliner is the optional io.Writer interface, implemented by net.Conn, used by
+// the Encoder *Context functions.
+type writeDeadliner interface {
+	SetWriteDeadline(t time.Time) error
+}
+
+// dialNet is the default DialFunc which uses net.Dialer.
+func dialNet(ctx context.Context, network, address string) (Transport, error) {
+	var d net.Dialer
+
+	return d.DialContext(ctx, network, address)
+}
+
+// remoteAddr returns the RemoteAddr of "t" if it has one, otherwise nil.
+func remoteAddr(t Transport) net.Addr {
+	if ra, ok := t.(interface{ RemoteAddr() net.Addr }); ok {
+		return ra.RemoteAddr()
+	}
+
+	return nil
+}
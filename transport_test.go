@@ -0,0 +1,85 @@
+package netstring_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/markdingo/netstring"
+)
+
+// plainTransport hides the optional methods of a net.Conn, much like a serial port.
+type plainTransport struct {
+	io.ReadWriteCloser
+}
+
+func TestTransport(t *testing.T) {
+	var sawAddr atomic.Bool
+	handler := netstring.HandlerFunc(func(reply *netstring.MessageBuilder, req *netstring.Request) {
+		if req.RemoteAddr != nil {
+			sawAddr.Store(true)
+		}
+		echoHandler(reply, req)
+	})
+	srv := &netstring.Server{Handler: handler}
+	served := make(chan error, 1)
+	c := &netstring.Client{
+		Dial: func(ctx context.Context, network, address string) (netstring.Transport, error) {
+			client, server := net.Pipe()
+			go func() { served <- srv.ServeTransport(plainTransport{server}) }()
+			return plainTransport{client}, nil
+		},
+	}
+
+	req := &netstring.Message{Fields: []netstring.KV{{Key: 'a', Value: []byte("hello")}}}
+	m, err := c.Call(context.Background(), req)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if v, _ := m.Get('a'); string(v) != "HELLO" {
+		t.Error("Reply wrong", string(v))
+	}
+	if sawAddr.Load() {
+		t.Error("RemoteAddr should be nil for a Transport without RemoteAddr")
+	}
+
+	c.Close()
+	err = <-served
+	if err != nil {
+		t.Error("ServeTransport should return nil when the Transport closes, not", err)
+	}
+	srv.Close()
+	unused, _ := net.Pipe()
+	err = srv.ServeTransport(plainTransport{unused})
+	if err != netstring.ErrServerClosed {
+		t.Error("Expected ErrServerClosed, not", err)
+	}
+}
+
+func TestTransportContext(t *testing.T) {
+	var dials int32
+	c := &netstring.Client{
+		Dial: func(ctx context.Context, network, address string) (netstring.Transport, error) {
+			atomic.AddInt32(&dials, 1)
+			client, server := net.Pipe()
+			go io.Copy(io.Discard, server) // Never replies
+			return plainTransport{client}, nil
+		},
+	}
+	defer c.Close()
+
+	for ix := 0; ix < 2; ix++ { // Abandoned Transports are not re-used
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(20*time.Millisecond, cancel)
+		_, err := c.Call(ctx, &netstring.Message{})
+		if err != context.Canceled {
+			t.Error(ix, "Expected context.Canceled, not", err)
+		}
+	}
+	if atomic.LoadInt32(&dials) != 2 {
+		t.Error("Expected two dials, got", atomic.LoadInt32(&dials))
+	}
+}
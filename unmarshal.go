@@ -25,7 +25,12 @@ import (
 // no corresponding field in "message". Obviously only one "unknown" is visible to the
 // caller even though there may be multiple occurrences. Since an unknown key may be
 // acceptable to the application, it is left to the caller to decide whether this
-// situation results in an error, an alert to upgrade, or silence.
+// situation results in an error, an alert to upgrade, or silence. Extension records, as
+// described by ExtensionKey, are not reported as unknown.
+//
+// Any constraints in the "netstring" tags, as described in Validate, are enforced as each
+// field is populated. A violation stops Unmarshal with an error wrapping
+// ErrFieldConstraint, leaving the rest of the message unread.
 //
 // An example:
 //
@@ -81,11 +86,14 @@ func (dec *Decoder) Unmarshal(eom Key, message any) (unknown Key, err error) {
 	// Evaluate message fields
 
 	type field struct {
-		seen   bool
-		name   string
-		kind   reflect.Kind
-		value  reflect.Value
-		maxint int64
+		seen        bool
+		name        string
+		kind        reflect.Kind
+		value       reflect.Value
+		maxint      int64
+		cons        *constraints
+		decode      DecodeFunc // Set if the field type has a registered codec
+		unmarshaler bool       // Set if the field type implements Unmarshaler
 	}
 	keyToField := make(map[Key]*field)
 
@@ -94,7 +102,7 @@ func (dec *Decoder) Unmarshal(eom Key, message any) (unknown Key, err error) {
 		if !sf.IsExported() {
 			continue
 		}
-		tag := sf.Tag.Get("netstring")
+		tag, spec := splitTag(sf.Tag.Get("netstring"))
 		if len(tag) == 0 {
 			continue
 		}
@@ -114,14 +122,34 @@ func (dec *Decoder) Unmarshal(eom Key, message any) (unknown Key, err error) {
 				sf.Name, tag, tag)
 			return
 		}
+		if dec.foldKeys {
+			key = key.fold()
+		}
 		if f, ok := keyToField[key]; ok {
 			err = fmt.Errorf(errorPrefix+"Duplicate tag '%s' for '%s' and '%s'",
 				tag, sf.Name, f.name)
+			if dec.foldKeys {
+				err = fmt.Errorf(errorPrefix+"Tag '%s' of '%s' conflicts with '%s' when keys are folded",
+					tag, sf.Name, f.name)
+			}
 			return
 		}
 
 		vf := vo.Field(ix)
 		kind := sf.Type.Kind()
+		var cons *constraints
+		cons, err = parseConstraints(sf, spec)
+		if err != nil {
+			return
+		}
+		if c, ok := lookupCodec(sf.Type); ok && c.decode != nil {
+			keyToField[key] = &field{false, sf.Name, kind, vf, 0, cons, c.decode, false}
+			continue
+		}
+		if isUnmarshaler(sf.Type) {
+			keyToField[key] = &field{false, sf.Name, kind, vf, 0, cons, nil, true}
+			continue
+		}
 
 		// Some kinds need further checking
 		switch kind {
@@ -138,18 +166,29 @@ func (dec *Decoder) Unmarshal(eom Key, message any) (unknown Key, err error) {
 				return
 			}
 
+		case reflect.Interface:
+			if dec.registry == nil {
+				err = fmt.Errorf(errorPrefix+"%s type unsupported (%s) without WithRegistry",
+					sf.Name, kind)
+				return
+			}
+
 		default:
 			err = fmt.Errorf(errorPrefix+"%s type unsupported (%s)",
 				sf.Name, kind)
 			return
 		}
 
-		keyToField[key] = &field{false, sf.Name, kind, vf, 0} // field looks good, stash it in the map
+		keyToField[key] = &field{false, sf.Name, kind, vf, 0, cons, nil, false} // field looks good, stash it in the map
 	}
 
 	// Have all the information about message destination fields so start consuming
 	// keyed netstrings and map them into the "basic-struct" destination fields.
 
+	var stats KeyStats
+	if dec.wantStats() {
+		stats = make(KeyStats)
+	}
 	for {
 		k, v, e := dec.DecodeKeyed()
 		if e != nil {
@@ -157,13 +196,25 @@ func (dec *Decoder) Unmarshal(eom Key, message any) (unknown Key, err error) {
 			return
 		}
 
-		if k == eom {
+		if dec.foldKeys {
+			k = k.fold()
+		}
+		if k == eom || (dec.foldKeys && k == eom.fold()) {
+			if stats != nil {
+				dec.endOfMessage(stats)
+			}
+			err = dec.macTrailed()
 			return
 		}
+		if stats != nil {
+			stats.add(k, len(v))
+		}
 
 		field, ok := keyToField[k]
 		if !ok {
-			unknown = k
+			if k != ExtensionKey && !(dec.foldKeys && k == ExtensionKey.fold()) {
+				unknown = k
+			}
 			continue
 		}
 
@@ -174,6 +225,43 @@ func (dec *Decoder) Unmarshal(eom Key, message any) (unknown Key, err error) {
 		}
 		field.seen = true
 
+		if field.decode != nil {
+			dv, e := field.decode(v)
+			if e != nil {
+				err = fmt.Errorf(errorPrefix+"%s codec decode failed: %w", field.name, e)
+				return
+			}
+			rv := reflect.ValueOf(dv)
+			if !rv.IsValid() || rv.Type() != field.value.Type() {
+				err = fmt.Errorf(errorPrefix+"%s codec returned %T, not %s",
+					field.name, dv, field.value.Type())
+				return
+			}
+			field.value.Set(rv)
+			if field.cons != nil {
+				err = field.cons.check(field.name, field.value)
+				if err != nil {
+					return
+				}
+			}
+			continue
+		}
+
+		if field.unmarshaler {
+			e := asUnmarshaler(field.value).UnmarshalNetstring(v)
+			if e != nil {
+				err = fmt.Errorf(errorPrefix+"%s UnmarshalNetstring failed: %w", field.name, e)
+				return
+			}
+			if field.cons != nil {
+				err = field.cons.check(field.name, field.value)
+				if err != nil {
+					return
+				}
+			}
+			continue
+		}
+
 		switch field.kind {
 		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 			vi, e := strconv.ParseInt(string(v), 10, 64)
@@ -203,14 +291,32 @@ func (dec *Decoder) Unmarshal(eom Key, message any) (unknown Key, err error) {
 			field.value.SetFloat(vf)
 
 		case reflect.String:
-			field.value.SetString(string(v))
+			if dec.interner != nil {
+				field.value.SetString(dec.interner.String(v))
+			} else {
+				field.value.SetString(string(v))
+			}
 
 		case reflect.Slice:
 			field.value.SetBytes(v)
 
+		case reflect.Interface:
+			e := dec.decodeRegistered(eom, v, field.value)
+			if e != nil {
+				err = fmt.Errorf(errorPrefix+"%s: %w", field.name, e)
+				return
+			}
+
 		default:
 			err = fmt.Errorf(errorPrefix+"%s Internal Error type (%s) ducked early check",
 				field.name, kind)
 		}
+
+		if field.cons != nil {
+			err = field.cons.check(field.name, field.value)
+			if err != nil {
+				return
+			}
+		}
 	}
 }
@@ -0,0 +1,101 @@
+package netstring
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// WatchdogFunc is the signature of the callback supplied to WithWatchdog. "age" is how
+// long ago the first byte of the stalled netstring arrived.
+type WatchdogFunc func(age time.Duration)
+
+// WithWatchdog causes a Decoder to call "fn" if a netstring is still incomplete "after"
+// its first byte arrived, even if the io.Reader has no deadline. This is an early warning
+// of a hung peer: the Decoder continues to wait for the rest of the netstring and it is
+// up to "fn" to take any action, such as incrementing a metric or closing the
+// connection.
+//
+// "fn" is called at most once per netstring and is called from a separate goroutine
+// while the Decoder is blocked in Read, so it must be safe for concurrent use. Only time
+// spent part way through a netstring counts; an idle connection between netstrings is
+// never flagged. An "after" of zero or less or a nil "fn" disables the watchdog, which is
+// the default. Encoder ignores this Option.
+func WithWatchdog(after time.Duration, fn WatchdogFunc) Option {
+	return func(o *options) {
+		o.watchdogAfter, o.watchdogFn = after, fn
+	}
+}
+
+// newWatchdog returns a watchdog for a Decoder or nil if WithWatchdog is not in effect.
+func newWatchdog(o *options) *watchdog {
+	if o.watchdogAfter <= 0 || o.watchdogFn == nil {
+		return nil
+	}
+
+	return &watchdog{after: o.watchdogAfter, fn: o.watchdogFn}
+}
+
+// watchdog times each netstring read by a Decoder. As the timer runs on its own
+// goroutine, state it shares with the Decoder is atomic.
+type watchdog struct {
+	after time.Duration
+	fn    WatchdogFunc
+	timer *time.Timer
+	start atomic.Int64 // UnixNano of the first byte of the current netstring
+	fired atomic.Bool  // fn has been called for the current netstring
+}
+
+// begin marks the arrival of the first byte of a netstring.
+func (wd *watchdog) begin() {
+	wd.start.Store(time.Now().UnixNano())
+	wd.fired.Store(false)
+}
+
+// age returns how long ago the current netstring started.
+func (wd *watchdog) age() time.Duration {
+	return time.Since(time.Unix(0, wd.start.Load()))
+}
+
+// arm starts the timer prior to a Read part way through a netstring.
+func (wd *watchdog) arm() {
+	if wd.fired.Load() {
+		return
+	}
+	remaining := wd.after - wd.age()
+	if wd.timer == nil {
+		wd.timer = time.AfterFunc(remaining, wd.fire)
+	} else {
+		wd.timer.Reset(remaining)
+	}
+}
+
+// disarm stops the timer once a Read returns.
+func (wd *watchdog) disarm() {
+	if wd.timer != nil {
+		wd.timer.Stop()
+	}
+}
+
+// fire is called by the timer. The age is re-checked as the timer may have fired just as
+// the Decoder moved on to a new netstring.
+func (wd *watchdog) fire() {
+	age := wd.age()
+	if age < wd.after || wd.fired.Swap(true) {
+		return
+	}
+	wd.fn(age)
+}
+
+// fill reads more bytes into the staging buffer, with the watchdog, if any, armed when
+// the read is part way through a netstring. With WithMemoryBudget, it also detects
+// shedding. The caller resets dec.at as appropriate.
+func (dec *Decoder) fill() {
+	if dec.watchdog != nil && dec.state != parseFirstByte {
+		dec.watchdog.arm()
+		defer dec.watchdog.disarm()
+	}
+	dec.end, dec.parseError = dec.rdr.Read(dec.buf[:])
+	if dec.budget != nil {
+		dec.checkBudget()
+	}
+}
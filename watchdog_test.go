@@ -0,0 +1,41 @@
+package netstring_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/markdingo/netstring"
+)
+
+func TestWatchdog(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	ages := make(chan time.Duration, 10)
+	after := 20 * time.Millisecond
+	dec := netstring.NewDecoder(server, netstring.WithoutAutoBuffering(),
+		netstring.WithWatchdog(after, func(age time.Duration) { ages <- age }))
+
+	go func() {
+		client.Write([]byte("5:he"))
+		time.Sleep(after * 3) // Stall part way through
+		client.Write([]byte("llo,"))
+		time.Sleep(after * 3) // Idle between netstrings
+		client.Write([]byte("3:abc,"))
+	}()
+
+	for ix, exp := range []string{"hello", "abc"} {
+		ns, err := dec.Decode()
+		if err != nil || string(ns) != exp {
+			t.Fatal(ix, "Decode", string(ns), err)
+		}
+	}
+	if len(ages) != 1 {
+		t.Fatal("Expected exactly one watchdog call, got", len(ages))
+	}
+	if age := <-ages; age < after {
+		t.Error("Watchdog fired early", age)
+	}
+}